@@ -135,8 +135,32 @@ const (
 	ANNOTATION_SVC_HOST = "svcHost"
 )
 
+// package source provenance annotation keys
+const (
+	ANNOTATION_SOURCE_COMMIT = "fission.io/source-commit"
+	ANNOTATION_SOURCE_REPO   = "fission.io/source-repo"
+	ANNOTATION_SOURCE_REF    = "fission.io/source-ref"
+)
+
+// prebuilt container image annotation keys of packages created with 'fission package create --image'
+const (
+	ANNOTATION_IMAGE        = "fission.io/image"
+	ANNOTATION_IMAGE_DIGEST = "fission.io/image-digest"
+)
+
+// ANNOTATION_CONTENT_HASH is the hash of the archives, environment, build commands and
+// build references of a package, set by 'fission package create --replace-if-changed'.
+const ANNOTATION_CONTENT_HASH = "fission.io/content-hash"
+
+// ANNOTATION_REAP_PRIORITY is the integer priority of the function services of a function.
+// Idle function services of higher priority are reaped and evicted after those of lower priority.
+const ANNOTATION_REAP_PRIORITY = "fission.io/reap-priority"
+
 const (
 	ArchiveLiteralSizeLimit int64 = 256 * 1024
+
+	// ArchiveUploadSizeLimit is the default maximum size of archives the CLI uploads.
+	ArchiveUploadSizeLimit int64 = 256 * 1024 * 1024
 )
 
 const (
@@ -17,6 +17,8 @@ limitations under the License.
 package v1
 
 import (
+	"strconv"
+
 	asv2 "k8s.io/api/autoscaling/v2"
 	apiv1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -283,9 +285,45 @@ type (
 		// +optional
 		BuildCommand string `json:"buildcmd,omitempty"`
 
+		// BuildCommands are custom build commands that builder runs in order,
+		// stopping at the first failing one. It can't be set together with BuildCommand.
+		// +optional
+		BuildCommands []string `json:"buildcmds,omitempty"`
+
+		// BuildEnv is the environment variables set for the build command.
+		// +optional
+		BuildEnv []BuildEnvVar `json:"buildenv,omitempty"`
+
+		// BuildResources are the CPU and memory requests and limits needed by the build
+		// of the source archive. The builder of the environment is shared by the builds
+		// of its packages, so the resources of its builder container are raised to at
+		// least these before the build, and kept until the environment changes.
+		// +optional
+		BuildResources *apiv1.ResourceRequirements `json:"buildresources,omitempty"`
+
+		// Secrets are references to secrets available to the build of the source archive.
+		// +optional
+		// +nullable
+		Secrets []SecretReference `json:"secrets,omitempty"`
+
+		// ConfigMaps are references to configmaps available to the build of the source archive.
+		// +optional
+		// +nullable
+		ConfigMaps []ConfigMapReference `json:"configmaps,omitempty"`
+
 		// In the future, we can have a debug build here too
 	}
 
+	// BuildEnvVar is an environment variable set for the build command of a package.
+	BuildEnvVar struct {
+		// Name of the environment variable.
+		Name string `json:"name"`
+
+		// Value of the environment variable.
+		// +optional
+		Value string `json:"value,omitempty"`
+	}
+
 	// PackageStatus contains the build status of a package also the build log for examination.
 	PackageStatus struct {
 		// TODO: Add another status field to indicate whether a package
@@ -891,3 +929,13 @@ func (fn Function) GetRequestPerPod() int {
 	}
 	return fn.Spec.RequestsPerPod
 }
+
+// GetReapPriority returns the priority of the function services of fn set with the
+// ANNOTATION_REAP_PRIORITY annotation, or 0 if it is unset or not an integer.
+func (fn Function) GetReapPriority() int {
+	priority, err := strconv.Atoi(fn.ObjectMeta.Annotations[ANNOTATION_REAP_PRIORITY])
+	if err != nil {
+		return 0
+	}
+	return priority
+}
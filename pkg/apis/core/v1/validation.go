@@ -22,6 +22,7 @@ import (
 	"net/http"
 	"reflect"
 	"regexp"
+	"strconv"
 	"strings"
 
 	"github.com/hashicorp/go-multierror"
@@ -235,6 +236,34 @@ func (spec PackageSpec) Validate() error {
 		}
 	}
 
+	names := make(map[string]bool, len(spec.BuildEnv))
+	for _, env := range spec.BuildEnv {
+		for _, errMsg := range validation.IsEnvVarName(env.Name) {
+			result = multierror.Append(result, MakeValidationErr(ErrorInvalidValue, "PackageSpec.BuildEnv.Name", env.Name, errMsg))
+		}
+		if names[env.Name] {
+			result = multierror.Append(result, MakeValidationErr(ErrorInvalidValue, "PackageSpec.BuildEnv.Name", env.Name, "duplicate environment variable"))
+		}
+		names[env.Name] = true
+	}
+
+	for _, s := range spec.Secrets {
+		result = multierror.Append(result, s.Validate())
+	}
+	for _, c := range spec.ConfigMaps {
+		result = multierror.Append(result, c.Validate())
+	}
+
+	if len(spec.BuildCommand) > 0 && len(spec.BuildCommands) > 0 {
+		result = multierror.Append(result, MakeValidationErr(ErrorInvalidValue, "PackageSpec.BuildCommands", spec.BuildCommands, "can't be set together with PackageSpec.BuildCommand"))
+	}
+	for _, cmd := range spec.BuildCommands {
+		if len(strings.TrimSpace(cmd)) == 0 {
+			result = multierror.Append(result, MakeValidationErr(ErrorInvalidValue, "PackageSpec.BuildCommands", spec.BuildCommands, "build command can't be empty"))
+			break
+		}
+	}
+
 	return result.ErrorOrNil()
 }
 
@@ -579,6 +608,13 @@ func (f *Function) Validate() error {
 		validateMetadata("Function", f.ObjectMeta),
 		f.Spec.Validate())
 
+	if priority, ok := f.ObjectMeta.Annotations[ANNOTATION_REAP_PRIORITY]; ok {
+		if _, err := strconv.Atoi(priority); err != nil {
+			result = multierror.Append(result, MakeValidationErr(ErrorInvalidValue,
+				fmt.Sprintf("Function.ObjectMeta.Annotations.%v", ANNOTATION_REAP_PRIORITY), priority, "not an integer"))
+		}
+	}
+
 	return result.ErrorOrNil()
 }
 
@@ -87,6 +87,21 @@ func (in *Builder) DeepCopy() *Builder {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BuildEnvVar) DeepCopyInto(out *BuildEnvVar) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BuildEnvVar.
+func (in *BuildEnvVar) DeepCopy() *BuildEnvVar {
+	if in == nil {
+		return nil
+	}
+	out := new(BuildEnvVar)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *CanaryConfig) DeepCopyInto(out *CanaryConfig) {
 	*out = *in
@@ -849,6 +864,31 @@ func (in *PackageSpec) DeepCopyInto(out *PackageSpec) {
 	out.Environment = in.Environment
 	in.Source.DeepCopyInto(&out.Source)
 	in.Deployment.DeepCopyInto(&out.Deployment)
+	if in.BuildCommands != nil {
+		in, out := &in.BuildCommands, &out.BuildCommands
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.BuildEnv != nil {
+		in, out := &in.BuildEnv, &out.BuildEnv
+		*out = make([]BuildEnvVar, len(*in))
+		copy(*out, *in)
+	}
+	if in.BuildResources != nil {
+		in, out := &in.BuildResources, &out.BuildResources
+		*out = new(corev1.ResourceRequirements)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Secrets != nil {
+		in, out := &in.Secrets, &out.Secrets
+		*out = make([]SecretReference, len(*in))
+		copy(*out, *in)
+	}
+	if in.ConfigMaps != nil {
+		in, out := &in.ConfigMaps, &out.ConfigMaps
+		*out = make([]ConfigMapReference, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PackageSpec.
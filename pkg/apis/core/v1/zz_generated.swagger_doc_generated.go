@@ -42,6 +42,16 @@ func (Builder) SwaggerDoc() map[string]string {
 	return map_Builder
 }
 
+var map_BuildEnvVar = map[string]string{
+	"":      "BuildEnvVar is an environment variable set for the build command of a package.",
+	"name":  "Name of the environment variable.",
+	"value": "Value of the environment variable.",
+}
+
+func (BuildEnvVar) SwaggerDoc() map[string]string {
+	return map_BuildEnvVar
+}
+
 var map_CanaryConfig = map[string]string{
 	"": "CanaryConfig is for canary deployment of two functions.",
 }
@@ -359,11 +369,16 @@ func (PackageRef) SwaggerDoc() map[string]string {
 }
 
 var map_PackageSpec = map[string]string{
-	"":            "PackageSpec includes source/deploy archives and the reference of environment to build the package.",
-	"environment": "Environment is a reference to the environment for building source archive.",
-	"source":      "Source is the archive contains source code and dependencies file. If the package status is in PENDING state, builder manager will then notify builder to compile source and save the result as deployable archive.",
-	"deployment":  "Deployment is the deployable archive that environment runtime used to run user function.",
-	"buildcmd":    "BuildCommand is a custom build command that builder used to build the source archive.",
+	"":               "PackageSpec includes source/deploy archives and the reference of environment to build the package.",
+	"environment":    "Environment is a reference to the environment for building source archive.",
+	"source":         "Source is the archive contains source code and dependencies file. If the package status is in PENDING state, builder manager will then notify builder to compile source and save the result as deployable archive.",
+	"deployment":     "Deployment is the deployable archive that environment runtime used to run user function.",
+	"buildcmd":       "BuildCommand is a custom build command that builder used to build the source archive.",
+	"buildcmds":      "BuildCommands are custom build commands that builder runs in order, stopping at the first failing one. It can't be set together with BuildCommand.",
+	"buildenv":       "BuildEnv is the environment variables set for the build command.",
+	"buildresources": "BuildResources are the CPU and memory requests and limits needed by the build of the source archive. The builder of the environment is shared by the builds of its packages, so the resources of its builder container are raised to at least these before the build, and kept until the environment changes.",
+	"secrets":        "Secrets are references to secrets available to the build of the source archive.",
+	"configmaps":     "ConfigMaps are references to configmaps available to the build of the source archive.",
 }
 
 func (PackageSpec) SwaggerDoc() map[string]string {
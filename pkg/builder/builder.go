@@ -53,6 +53,12 @@ type (
 		// 1. SRC_PKG: path to source package directory
 		// 2. DEPLOY_PKG: path to deployment package directory
 		BuildCommand string `json:"command"`
+		// BuildCommands are run in order instead of BuildCommand if set,
+		// the build fails at the first failing command.
+		BuildCommands []string `json:"commands,omitempty"`
+		// BuildEnv is the environment variables in the form KEY=VALUE
+		// set for the build command in addition to the ones above.
+		BuildEnv []string `json:"buildEnv,omitempty"`
 	}
 
 	PackageBuildResponse struct {
@@ -118,37 +124,38 @@ func (builder *Builder) Handler(w http.ResponseWriter, r *http.Request) {
 		builder.reply(r.Context(), w, "", fmt.Sprintf("%s: %s", e, err.Error()), http.StatusBadRequest)
 		return
 	}
-	logger.Info("builder received request", zap.Any("request", req))
+	// build env values may be credentials, so only log their names
+	logReq := req
+	logReq.BuildEnv = envNames(req.BuildEnv)
+	logger.Info("builder received request", zap.Any("request", logReq))
 
 	logger.Debug("starting build")
 	srcPkgPath := filepath.Join(builder.sharedVolumePath, req.SrcPkgFilename)
 	deployPkgFilename := fmt.Sprintf("%s-%s", req.SrcPkgFilename, strings.ToLower(uniuri.NewLen(6)))
 	deployPkgPath := filepath.Join(builder.sharedVolumePath, deployPkgFilename)
 
-	var buildArgs []string
-	buildCmd := req.BuildCommand
-	if len(buildCmd) == 0 {
-		// use default build command
-		buildCmd = "/build"
-	} else {
-		// split executable command and arguments
-		args := strings.Split(buildCmd, " ")
-		buildCmd = args[0] // get the executable command, executable command will always be on Zero index
-
-		// get all the arguments
-		for i := 1; i < len(args); i++ {
-			buildArgs = append(buildArgs, args[i])
-		}
+	buildCmds := req.BuildCommands
+	if len(buildCmds) == 0 {
+		buildCmds = []string{req.BuildCommand}
 	}
-	buildLogs, err := builder.build(r.Context(), buildCmd, buildArgs, srcPkgPath, deployPkgPath)
-	if err != nil {
-		e := "error building source package"
-		logger.Error(e, zap.Error(err))
 
-		// append error at the end of build logs
-		buildLogs += fmt.Sprintf("%s: %s\n", e, err.Error())
-		builder.reply(r.Context(), w, deployPkgFilename, buildLogs, http.StatusInternalServerError)
-		return
+	var buildLogs string
+	for i, buildCmd := range buildCmds {
+		command, args := splitBuildCommand(buildCmd)
+		logs, err := builder.build(r.Context(), command, args, req.BuildEnv, srcPkgPath, deployPkgPath)
+		buildLogs += logs
+		if err != nil {
+			e := "error building source package"
+			if len(buildCmds) > 1 {
+				e = fmt.Sprintf("%s at build command %d of %d", e, i+1, len(buildCmds))
+			}
+			logger.Error(e, zap.Error(err))
+
+			// append error at the end of build logs
+			buildLogs += fmt.Sprintf("%s: %s\n", e, err.Error())
+			builder.reply(r.Context(), w, deployPkgFilename, buildLogs, http.StatusInternalServerError)
+			return
+		}
 	}
 
 	builder.reply(r.Context(), w, deployPkgFilename, buildLogs, http.StatusOK)
@@ -181,7 +188,7 @@ func (builder *Builder) reply(ctx context.Context, w http.ResponseWriter, pkgFil
 	}
 }
 
-func (builder *Builder) build(ctx context.Context, command string, args []string, srcPkgPath string, deployPkgPath string) (string, error) {
+func (builder *Builder) build(ctx context.Context, command string, args []string, env []string, srcPkgPath string, deployPkgPath string) (string, error) {
 	logger := otelUtils.LoggerWithTraceID(ctx, builder.logger)
 
 	cmd := exec.Command(command, args...)
@@ -196,11 +203,13 @@ func (builder *Builder) build(ctx context.Context, command string, args []string
 		cmd.Dir = path.Dir(srcPkgPath)
 	}
 
-	// set env variables for build command
-	cmd.Env = append(os.Environ(),
+	// set env variables for build command, the package paths can't be overridden
+	pkgEnv := []string{
 		fmt.Sprintf("%s=%s", envSrcPkg, srcPkgPath),
 		fmt.Sprintf("%s=%s", envDeployPkg, deployPkgPath),
-	)
+	}
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Env = append(cmd.Env, pkgEnv...)
 
 	stdout, err := cmd.StdoutPipe()
 	if err != nil {
@@ -213,7 +222,7 @@ func (builder *Builder) build(ctx context.Context, command string, args []string
 	}
 
 	// Init logs
-	logger.Info("building source package", zap.String("command", command), zap.Strings("args", args), zap.Strings("env", cmd.Env))
+	logger.Info("building source package", zap.String("command", command), zap.Strings("args", args), zap.Strings("env", append(os.Environ(), pkgEnv...)), zap.Strings("buildEnv", envNames(env)))
 
 	out := io.MultiReader(stdout, stderr)
 	scanner := bufio.NewScanner(out)
@@ -246,3 +255,24 @@ func (builder *Builder) build(ctx context.Context, command string, args []string
 	}
 	return buildLogs, nil
 }
+
+// splitBuildCommand splits a build command into the executable and its arguments.
+// An empty command runs the default build command.
+func splitBuildCommand(buildCmd string) (string, []string) {
+	if len(buildCmd) == 0 {
+		return "/build", nil
+	}
+	// executable command will always be on Zero index
+	args := strings.Split(buildCmd, " ")
+	return args[0], args[1:]
+}
+
+// envNames returns the names of the environment variables in the form KEY=VALUE.
+func envNames(env []string) []string {
+	var names []string
+	for _, e := range env {
+		name, _, _ := strings.Cut(e, "=")
+		names = append(names, name)
+	}
+	return names
+}
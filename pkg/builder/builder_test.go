@@ -168,4 +168,80 @@ func TestBuilder(t *testing.T) {
 			})
 		}
 	})
+
+	t.Run("BuildEnv", func(t *testing.T) {
+		srcFile, err := os.Create(dir + "/test-env")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer srcFile.Close()
+		body, err := json.Marshal(&PackageBuildRequest{
+			SrcPkgFilename: "test-env",
+			BuildCommand:   "env",
+			BuildEnv:       []string{"FISSION_TEST_BUILD_ENV=value", "SRC_PKG=overridden"},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		w := httptest.NewRecorder()
+		builder.Handler(w, httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body)))
+		resp := w.Result()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d", http.StatusOK, resp.StatusCode)
+		}
+		var buildResp PackageBuildResponse
+		err = json.NewDecoder(resp.Body).Decode(&buildResp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(buildResp.BuildLogs, "FISSION_TEST_BUILD_ENV=value\n") {
+			t.Errorf("expected build env in build logs, got %s", buildResp.BuildLogs)
+		}
+		if strings.Contains(buildResp.BuildLogs, "SRC_PKG=overridden") {
+			t.Errorf("expected SRC_PKG not to be overridden by build env, got %s", buildResp.BuildLogs)
+		}
+	})
+
+	t.Run("BuildCommands", func(t *testing.T) {
+		srcFile, err := os.Create(dir + "/test-cmds")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer srcFile.Close()
+
+		build := func(status int, cmds ...string) PackageBuildResponse {
+			body, err := json.Marshal(&PackageBuildRequest{
+				SrcPkgFilename: "test-cmds",
+				BuildCommands:  cmds,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			w := httptest.NewRecorder()
+			builder.Handler(w, httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body)))
+			resp := w.Result()
+			var buildResp PackageBuildResponse
+			err = json.NewDecoder(resp.Body).Decode(&buildResp)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != status {
+				t.Fatalf("expected status code %d, got %d: %s", status, resp.StatusCode, buildResp.BuildLogs)
+			}
+			return buildResp
+		}
+
+		buildResp := build(http.StatusOK, "echo first", "echo second")
+		if !strings.Contains(buildResp.BuildLogs, "first\nsecond\n") {
+			t.Errorf("expected build commands to run in order, got %s", buildResp.BuildLogs)
+		}
+
+		buildResp = build(http.StatusInternalServerError, "echo first", "lsalas -la", "echo third")
+		if !strings.Contains(buildResp.BuildLogs, "error building source package at build command 2 of 3") {
+			t.Errorf("expected build to fail at the second command, got %s", buildResp.BuildLogs)
+		}
+		if strings.Contains(buildResp.BuildLogs, "third") {
+			t.Errorf("expected build to stop at the failing command, got %s", buildResp.BuildLogs)
+		}
+	})
 }
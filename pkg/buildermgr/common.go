@@ -26,7 +26,11 @@ import (
 	"github.com/dchest/uniuri"
 	"github.com/pkg/errors"
 	"go.uber.org/zap"
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
 
 	fv1 "github.com/fission/fission/pkg/apis/core/v1"
 	"github.com/fission/fission/pkg/builder"
@@ -64,6 +68,8 @@ func buildPackage(ctx context.Context, logger *zap.Logger, fissionClient version
 		FetchType:   fv1.FETCH_SOURCE,
 		Package:     pkg.ObjectMeta,
 		Filename:    srcPkgFilename,
+		Secrets:     pkg.Spec.Secrets,
+		ConfigMaps:  pkg.Spec.ConfigMaps,
 		KeepArchive: false,
 	}
 
@@ -77,13 +83,20 @@ func buildPackage(ctx context.Context, logger *zap.Logger, fissionClient version
 	}
 
 	buildCmd := pkg.Spec.BuildCommand
-	if len(buildCmd) == 0 {
+	if len(buildCmd) == 0 && len(pkg.Spec.BuildCommands) == 0 {
 		buildCmd = env.Spec.Builder.Command
 	}
 
+	var buildEnv []string
+	for _, e := range pkg.Spec.BuildEnv {
+		buildEnv = append(buildEnv, fmt.Sprintf("%s=%s", e.Name, e.Value))
+	}
+
 	pkgBuildReq := &builder.PackageBuildRequest{
 		SrcPkgFilename: srcPkgFilename,
 		BuildCommand:   buildCmd,
+		BuildCommands:  pkg.Spec.BuildCommands,
+		BuildEnv:       buildEnv,
 	}
 
 	logger.Info("started building with source package", zap.String("source_package", srcPkgFilename))
@@ -150,3 +163,79 @@ func updatePackage(ctx context.Context, logger *zap.Logger, fissionClient versio
 	// return resource version for function to update function package ref
 	return pkg, nil
 }
+
+// applyBuildResources raises the requests and limits of the builder container of the
+// environment builder deployment in builderNs to at least resources, so that the
+// builder pods rolled out by the deployment can run the build. The builder is shared
+// by the builds of the packages of the environment, so it keeps the raised resources
+// until the environment changes and its builder is recreated.
+func applyBuildResources(ctx context.Context, logger *zap.Logger, kubernetesClient kubernetes.Interface,
+	env *fv1.Environment, builderNs string, resources *apiv1.ResourceRequirements) error {
+
+	name := fmt.Sprintf("%v-%v", env.ObjectMeta.Name, env.ObjectMeta.ResourceVersion)
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		deploy, err := kubernetesClient.AppsV1().Deployments(builderNs).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return errors.Wrapf(err, "error getting builder deployment %s.%s", name, builderNs)
+		}
+		container := builderContainer(deploy.Spec.Template.Spec.Containers)
+		if container == nil {
+			return errors.Errorf("builder deployment %s.%s has no builder container", name, builderNs)
+		}
+		if !raiseResources(&container.Resources, resources) {
+			return nil
+		}
+		logger.Info("raising builder container resources for package build",
+			zap.String("deployment", name), zap.String("namespace", builderNs),
+			zap.Any("requests", container.Resources.Requests), zap.Any("limits", container.Resources.Limits))
+		_, err = kubernetesClient.AppsV1().Deployments(builderNs).Update(ctx, deploy, metav1.UpdateOptions{})
+		return err
+	})
+}
+
+// hasBuildResources checks if the builder container of pod has at least resources.
+func hasBuildResources(pod *apiv1.Pod, resources *apiv1.ResourceRequirements) bool {
+	container := builderContainer(pod.Spec.Containers)
+	if container == nil {
+		return false
+	}
+	return !raiseResources(container.Resources.DeepCopy(), resources)
+}
+
+func builderContainer(containers []apiv1.Container) *apiv1.Container {
+	for i := range containers {
+		if containers[i].Name == "builder" {
+			return &containers[i]
+		}
+	}
+	return nil
+}
+
+// raiseResources raises the requests and limits of dst to at least the ones of src,
+// and the limits of dst to at least its requests. It returns whether dst changed.
+func raiseResources(dst *apiv1.ResourceRequirements, src *apiv1.ResourceRequirements) bool {
+	raise := func(list *apiv1.ResourceList, name apiv1.ResourceName, q resource.Quantity) bool {
+		if current, ok := (*list)[name]; ok && current.Cmp(q) >= 0 {
+			return false
+		}
+		if *list == nil {
+			*list = make(apiv1.ResourceList)
+		}
+		(*list)[name] = q.DeepCopy()
+		return true
+	}
+
+	changed := false
+	for name, q := range src.Requests {
+		changed = raise(&dst.Requests, name, q) || changed
+	}
+	for name, q := range src.Limits {
+		changed = raise(&dst.Limits, name, q) || changed
+	}
+	for name, q := range dst.Requests {
+		if _, ok := dst.Limits[name]; ok {
+			changed = raise(&dst.Limits, name, q) || changed
+		}
+	}
+	return changed
+}
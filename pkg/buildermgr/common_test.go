@@ -0,0 +1,111 @@
+/*
+Copyright 2024 The Fission Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package buildermgr
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	appsv1 "k8s.io/api/apps/v1"
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	fv1 "github.com/fission/fission/pkg/apis/core/v1"
+)
+
+func TestApplyBuildResources(t *testing.T) {
+	ctx := context.Background()
+	env := &fv1.Environment{ObjectMeta: metav1.ObjectMeta{Name: "python", Namespace: "default", ResourceVersion: "42"}}
+	builderNs := "fission-builder"
+	quantities := func(cpu, memory string) apiv1.ResourceList {
+		return apiv1.ResourceList{
+			apiv1.ResourceCPU:    resource.MustParse(cpu),
+			apiv1.ResourceMemory: resource.MustParse(memory),
+		}
+	}
+	fetcher := apiv1.Container{
+		Name: "fetcher",
+		Resources: apiv1.ResourceRequirements{
+			Requests: quantities("10m", "16Mi"),
+		},
+	}
+	deploy := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "python-42", Namespace: builderNs},
+		Spec: appsv1.DeploymentSpec{
+			Template: apiv1.PodTemplateSpec{
+				Spec: apiv1.PodSpec{
+					Containers: []apiv1.Container{
+						{
+							Name: "builder",
+							Resources: apiv1.ResourceRequirements{
+								Requests: quantities("100m", "128Mi"),
+								Limits:   quantities("200m", "256Mi"),
+							},
+						},
+						fetcher,
+					},
+				},
+			},
+		},
+	}
+	kubernetesClient := fake.NewSimpleClientset(deploy)
+	resources := &apiv1.ResourceRequirements{
+		Requests: quantities("500m", "64Mi"),
+		Limits:   apiv1.ResourceList{apiv1.ResourceCPU: resource.MustParse("1")},
+	}
+
+	oldPod := &apiv1.Pod{Spec: *deploy.Spec.Template.Spec.DeepCopy()}
+	require.False(t, hasBuildResources(oldPod, resources))
+
+	require.NoError(t, applyBuildResources(ctx, zap.NewNop(), kubernetesClient, env, builderNs, resources))
+	updated, err := kubernetesClient.AppsV1().Deployments(builderNs).Get(ctx, "python-42", metav1.GetOptions{})
+	require.NoError(t, err)
+	builder := updated.Spec.Template.Spec.Containers[0]
+	// raised to the build resources, the larger memory request of the builder is kept
+	require.Equal(t, "500m", builder.Resources.Requests.Cpu().String())
+	require.Equal(t, "128Mi", builder.Resources.Requests.Memory().String())
+	require.Equal(t, "1", builder.Resources.Limits.Cpu().String())
+	require.Equal(t, "256Mi", builder.Resources.Limits.Memory().String())
+	require.Equal(t, fetcher, updated.Spec.Template.Spec.Containers[1])
+
+	newPod := &apiv1.Pod{Spec: *updated.Spec.Template.Spec.DeepCopy()}
+	require.True(t, hasBuildResources(newPod, resources))
+	require.False(t, hasBuildResources(oldPod, resources))
+
+	// the deployment is not updated if the builder has the build resources already
+	kubernetesClient.ClearActions()
+	require.NoError(t, applyBuildResources(ctx, zap.NewNop(), kubernetesClient, env, builderNs, resources))
+	for _, action := range kubernetesClient.Actions() {
+		require.NotEqual(t, "update", action.GetVerb())
+	}
+
+	t.Run("limit below request", func(t *testing.T) {
+		dst := apiv1.ResourceRequirements{Limits: quantities("200m", "256Mi")}
+		require.True(t, raiseResources(&dst, &apiv1.ResourceRequirements{Requests: quantities("1", "1Gi")}))
+		require.Equal(t, "1", dst.Limits.Cpu().String())
+		require.Equal(t, "1Gi", dst.Limits.Memory().String())
+	})
+
+	t.Run("no builder deployment", func(t *testing.T) {
+		other := &fv1.Environment{ObjectMeta: metav1.ObjectMeta{Name: "go", Namespace: "default", ResourceVersion: "1"}}
+		require.Error(t, applyBuildResources(ctx, zap.NewNop(), kubernetesClient, other, builderNs, resources))
+	})
+}
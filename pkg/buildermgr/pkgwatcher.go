@@ -85,10 +85,11 @@ func (pkgw *packageWatcher) buildWithCache(ctx context.Context, srcpkg *fv1.Pack
 // Following is the steps build function takes to complete the whole process.
 // 1. Check package status
 // 2. Update package status to running state
-// 3. Check environment builder pod status
-// 4. Call buildPackage to build package
-// 5. Update package resource in package ref of functions that share the same package
-// 6. Update package status to succeed state
+// 3. Raise the environment builder resources to the build resources of package, if any
+// 4. Check environment builder pod status
+// 5. Call buildPackage to build package
+// 6. Update package resource in package ref of functions that share the same package
+// 7. Update package status to succeed state
 // *. Update package status to failed state,if any one of steps above failed/time out
 func (pkgw *packageWatcher) build(ctx context.Context, srcpkg *fv1.Package) {
 	key := pkgw.buildCacheKey(srcpkg.ObjectMeta)
@@ -130,6 +131,20 @@ func (pkgw *packageWatcher) build(ctx context.Context, srcpkg *fv1.Package) {
 
 	logger = logger.With(zap.String("environment", env.Name), zap.String("builder_namespace", builderNs), zap.String("environment_namespace", env.Namespace))
 
+	if pkg.Spec.BuildResources != nil {
+		err = applyBuildResources(ctx, logger, pkgw.k8sClient, env, builderNs, pkg.Spec.BuildResources)
+		if err != nil {
+			e := "error applying build resources to environment builder"
+			logger.Error(e, zap.Error(err))
+			_, er := updatePackage(ctx, logger, pkgw.fissionClient, pkg,
+				fv1.BuildStatusFailed, fmt.Sprintf("%s: %v", e, err), nil)
+			if er != nil {
+				logger.Error("error updating package", zap.Error(er))
+			}
+			return
+		}
+	}
+
 	// if err != nil {
 	//	pkgw.logger.Error("Unable to create BackOff for Health Check", zap.Error(err))
 	//}
@@ -159,6 +174,14 @@ func (pkgw *packageWatcher) build(ctx context.Context, srcpkg *fv1.Package) {
 				continue
 			}
 
+			// Builder pods of the deployment rollout started by applyBuildResources
+			// may not have the build resources yet
+			if pkg.Spec.BuildResources != nil && !hasBuildResources(pod, pkg.Spec.BuildResources) {
+				logger.Info("builder pod does not have the build resources of package yet, will retry again later",
+					zap.String("pod", pod.Name))
+				continue
+			}
+
 			// Pod may become "Running" state but still failed at health check, so use
 			// pod.Status.ContainerStatuses instead of pod.Status.Phase to check pod readiness states.
 			podIsReady := true
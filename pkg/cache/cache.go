@@ -31,6 +31,7 @@ const (
 	DELETE
 	EXPIRE
 	COPY
+	UPDATE
 )
 
 type (
@@ -122,6 +123,16 @@ func (c *Cache[K, V]) service() {
 				}
 			}
 			req.responseChannel <- resp
+		case UPDATE:
+			if val, ok := c.cache[req.key]; ok {
+				resp.existingValue = val.value
+				val.value = req.value
+				val.atime = time.Now()
+			} else {
+				resp.error = ferror.MakeError(ferror.ErrorNotFound,
+					fmt.Sprintf("key '%v' not found", req.key))
+			}
+			req.responseChannel <- resp
 		case DELETE:
 			delete(c.cache, req.key)
 			req.responseChannel <- resp
@@ -171,6 +182,21 @@ func (c *Cache[K, V]) Set(key K, value V) (V, error) {
 	return resp.existingValue, resp.error
 }
 
+// Update replaces the value of an existing key and returns the old value.
+// Unlike Set, the key must exist in the cache, otherwise a not found
+// error is returned.
+func (c *Cache[K, V]) Update(key K, value V) (V, error) {
+	respChannel := make(chan *response[K, V])
+	c.requestChannel <- &request[K, V]{
+		requestType:     UPDATE,
+		key:             key,
+		value:           value,
+		responseChannel: respChannel,
+	}
+	resp := <-respChannel
+	return resp.existingValue, resp.error
+}
+
 func (c *Cache[K, V]) Delete(key K) error {
 	respChannel := make(chan *response[K, V])
 	c.requestChannel <- &request[K, V]{
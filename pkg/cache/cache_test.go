@@ -63,3 +63,27 @@ func TestCache(t *testing.T) {
 		log.Panicf("found expired element")
 	}
 }
+
+func TestCacheUpdate(t *testing.T) {
+	c := MakeCache[string, string](0, 0)
+
+	_, err := c.Update("a", "b")
+	if err == nil {
+		log.Panicf("updated missing element")
+	}
+
+	_, err = c.Set("a", "b")
+	checkErr(err)
+
+	old, err := c.Update("a", "c")
+	checkErr(err)
+	if old != "b" {
+		log.Panicf("old value %v", old)
+	}
+
+	val, err := c.Get("a")
+	checkErr(err)
+	if val != "c" {
+		log.Panicf("value %v", val)
+	}
+}
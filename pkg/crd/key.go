@@ -18,6 +18,8 @@ package crd
 
 import (
 	"fmt"
+	"net/url"
+	"strings"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
@@ -81,3 +83,40 @@ func CacheKeyURGFromMeta(metadata *metav1.ObjectMeta) CacheKeyURG {
 		Generation:      metadata.Generation,
 	}
 }
+
+// CacheKey returns a human readable key of the form namespace/name for the object.
+// The namespace is omitted for cluster scoped objects. Both parts are path escaped,
+// so names containing the delimiter can be parsed back with ParseCacheKey.
+func CacheKey(metadata *metav1.ObjectMeta) string {
+	name := url.PathEscape(metadata.Name)
+	if len(metadata.Namespace) == 0 {
+		return name
+	}
+	return url.PathEscape(metadata.Namespace) + "/" + name
+}
+
+// ParseCacheKey is the inverse of CacheKey, it splits a key into namespace and name.
+func ParseCacheKey(key string) (namespace, name string, err error) {
+	parts := strings.Split(key, "/")
+	switch len(parts) {
+	case 1:
+		name, err = url.PathUnescape(parts[0])
+	case 2:
+		namespace, err = url.PathUnescape(parts[0])
+		if err == nil {
+			name, err = url.PathUnescape(parts[1])
+		}
+	default:
+		return "", "", fmt.Errorf("invalid cache key %q: unexpected number of delimiters", key)
+	}
+	if err != nil {
+		return "", "", fmt.Errorf("invalid cache key %q: %w", key, err)
+	}
+	if len(name) == 0 || (len(parts) == 2 && len(namespace) == 0) {
+		return "", "", fmt.Errorf("invalid cache key %q: empty namespace or name", key)
+	}
+	if CacheKey(&metav1.ObjectMeta{Namespace: namespace, Name: name}) != key {
+		return "", "", fmt.Errorf("invalid cache key %q: not in canonical form", key)
+	}
+	return namespace, name, nil
+}
@@ -0,0 +1,48 @@
+package crd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestParseCacheKey(t *testing.T) {
+	for _, test := range []struct {
+		name      string
+		namespace string
+		objName   string
+	}{
+		{name: "namespaced object", namespace: "default", objName: "hello"},
+		{name: "cluster scoped object", objName: "hello"},
+		{name: "name containing delimiter", namespace: "default", objName: "a/b"},
+		{name: "namespace containing delimiter", namespace: "a/b", objName: "c"},
+		{name: "name containing escape character", namespace: "default", objName: "100%/x"},
+		{name: "name containing spaces", namespace: "my ns", objName: "my fn"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			key := CacheKey(&metav1.ObjectMeta{Namespace: test.namespace, Name: test.objName})
+
+			namespace, name, err := ParseCacheKey(key)
+			require.NoError(t, err)
+			require.Equal(t, test.namespace, namespace)
+			require.Equal(t, test.objName, name)
+			require.Equal(t, key, CacheKey(&metav1.ObjectMeta{Namespace: namespace, Name: name}))
+		})
+	}
+}
+
+func TestParseCacheKeyInvalid(t *testing.T) {
+	for _, key := range []string{
+		"",
+		"a/b/c",
+		"/name",
+		"ns/",
+		"ns/%zz",
+		"ns/a%2Fb%",
+		"ns/%61",
+	} {
+		_, _, err := ParseCacheKey(key)
+		require.Error(t, err, "key %q", key)
+	}
+}
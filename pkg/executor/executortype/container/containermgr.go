@@ -200,7 +200,9 @@ func (caaf *Container) GetFuncSvcFromCache(ctx context.Context, fn *fv1.Function
 
 // DeleteFuncSvcFromCache deletes a function service from cache.
 func (caaf *Container) DeleteFuncSvcFromCache(ctx context.Context, fsvc *fscache.FuncSvc) {
-	caaf.fsCache.DeleteEntry(fsvc)
+	if err := caaf.fsCache.DeleteEntry(fsvc); err != nil {
+		caaf.logger.Error("error deleting function service from cache", zap.String("function", fsvc.Function.Name), zap.Error(err))
+	}
 }
 
 // TapService makes a TouchByAddress request to the cache.
@@ -462,6 +464,7 @@ func (caaf *Container) fnCreate(ctx context.Context, fn *fv1.Function) (*fscache
 		Address:           svcAddress,
 		KubernetesObjects: kubeObjRefs,
 		Executor:          fv1.ExecutorTypeContainer,
+		Priority:          fn.GetReapPriority(),
 	}
 
 	_, err = caaf.fsCache.Add(*fsvc)
@@ -716,7 +719,7 @@ func (caaf *Container) idleObjectReaper(ctx context.Context) {
 }
 
 func (caaf *Container) doIdleObjectReaper(ctx context.Context) {
-	funcSvcs, err := caaf.fsCache.ListOld(time.Second * 5)
+	funcSvcs, err := caaf.fsCache.ListOldByExecutor(time.Second*5, fv1.ExecutorTypeContainer)
 	if err != nil {
 		caaf.logger.Error("error reaping idle pods", zap.Error(err))
 		return
@@ -725,10 +728,6 @@ func (caaf *Container) doIdleObjectReaper(ctx context.Context) {
 	for i := range funcSvcs {
 		fsvc := funcSvcs[i]
 
-		if fsvc.Executor != fv1.ExecutorTypeContainer {
-			continue
-		}
-
 		fn, err := caaf.fissionClient.CoreV1().Functions(fsvc.Function.Namespace).Get(ctx, fsvc.Function.Name, metav1.GetOptions{})
 		if err != nil {
 			// CaaF manager handles the function delete event and clean cache/kubeobjs itself,
@@ -199,7 +199,9 @@ func (deploy *NewDeploy) GetFuncSvcFromCache(ctx context.Context, fn *fv1.Functi
 // DeleteFuncSvcFromCache deletes a function service from cache.
 func (deploy *NewDeploy) DeleteFuncSvcFromCache(ctx context.Context, fsvc *fscache.FuncSvc) {
 	otelUtils.SpanTrackEvent(ctx, "DeleteFuncSvcFromCache")
-	deploy.fsCache.DeleteEntry(fsvc)
+	if err := deploy.fsCache.DeleteEntry(fsvc); err != nil {
+		deploy.logger.Error("error deleting function service from cache", zap.String("function", fsvc.Function.Name), zap.Error(err))
+	}
 }
 
 // UnTapService has not been implemented for NewDeployment.
@@ -508,6 +510,7 @@ func (deploy *NewDeploy) fnCreate(ctx context.Context, fn *fv1.Function) (*fscac
 		Address:           svcAddress,
 		KubernetesObjects: kubeObjRefs,
 		Executor:          fv1.ExecutorTypeNewdeploy,
+		Priority:          fn.GetReapPriority(),
 	}
 
 	_, err = deploy.fsCache.Add(*fsvc)
@@ -792,7 +795,7 @@ func (deploy *NewDeploy) doIdleObjectReaper(ctx context.Context) {
 		}
 	}
 
-	funcSvcs, err := deploy.fsCache.ListOld(time.Second * 5)
+	funcSvcs, err := deploy.fsCache.ListOldByExecutor(time.Second*5, fv1.ExecutorTypeNewdeploy)
 	if err != nil {
 		deploy.logger.Error("error reaping idle pods", zap.Error(err))
 		return
@@ -800,9 +803,6 @@ func (deploy *NewDeploy) doIdleObjectReaper(ctx context.Context) {
 
 	for i := range funcSvcs {
 		fsvc := funcSvcs[i]
-		if fsvc.Executor != fv1.ExecutorTypeNewdeploy {
-			continue
-		}
 
 		// For function with the environment that no longer exists, executor
 		// scales down the deployment as usual and prints log to notify user.
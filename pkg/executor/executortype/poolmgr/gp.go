@@ -196,6 +196,18 @@ func (gp *GenericPool) updateCPUUtilizationSvc(ctx context.Context) {
 			return
 		}
 		gp.logger.Debug("pods found", zap.Any("length", len(podMetricsList.Items)))
+		readings := make([]fscache.CPUReading, 0, len(podMetricsList.Items))
+		defer func() {
+			// apply the readings of the whole scrape at once
+			errs := gp.fsCache.SetCPUUtilizations(readings)
+			for i, reading := range readings {
+				if errs[i] != nil {
+					gp.logger.Debug("failed to update function cpu usage", zap.Any("function", reading.Function), zap.String("address", reading.Address), zap.Error(errs[i]))
+					continue
+				}
+				gp.logger.Info("updated function cpu usage", zap.Any("function", reading.Function), zap.String("address", reading.Address), zap.Any("cpuUsage", reading.CPUUsage))
+			}
+		}()
 		for _, val := range podMetricsList.Items {
 			p, _ := resource.ParseQuantity("0m")
 			for _, container := range val.Containers {
@@ -213,8 +225,7 @@ func (gp *GenericPool) updateCPUUtilizationSvc(ctx context.Context) {
 						gp.logger.Error("failed to convert address to string", zap.Any("address", address))
 						return
 					}
-					gp.fsCache.SetCPUUtilizaton(function, address, p)
-					gp.logger.Info("updated function cpu usage", zap.Any("function", function), zap.String("address", address), zap.Any("cpuUsage", p))
+					readings = append(readings, fscache.CPUReading{Function: function, Address: address, CPUUsage: p})
 				}
 			}
 		}
@@ -579,7 +590,7 @@ func (gp *GenericPool) getFuncSvc(ctx context.Context, fn *fv1.Function) (*fscac
 		svc := utils.GetFunctionIstioServiceName(fn.ObjectMeta.Name, fn.ObjectMeta.Namespace)
 		svcHost = fmt.Sprintf("%v.%v:8888", svc, gp.fnNamespace)
 	} else {
-		svcHost = fmt.Sprintf("%v:8888", pod.Status.PodIP)
+		svcHost = net.JoinHostPort(pod.Status.PodIP, "8888")
 	}
 
 	otelUtils.SpanTrackEvent(ctx, "addFunctionLabel", otelUtils.GetAttributesForPod(pod)...)
@@ -628,13 +639,15 @@ func (gp *GenericPool) getFuncSvc(ctx context.Context, fn *fv1.Function) (*fscac
 		KubernetesObjects: kubeObjRefs,
 		Executor:          fv1.ExecutorTypePoolmgr,
 		CPULimit:          cpuLimit,
+		Priority:          fn.GetReapPriority(),
 		Ctime:             time.Now(),
 		Atime:             time.Now(),
 	}
 
-	gp.fsCache.PodToFsvc.Store(pod.GetObjectMeta().GetName(), fsvc)
-	gp.podFSVCMap.Store(pod.ObjectMeta.Name, []interface{}{crd.CacheKeyURGFromMeta(fsvc.Function), fsvc.Address})
-	gp.fsCache.AddFunc(ctx, *fsvc, fn.GetRequestPerPod(), fn.GetRetainPods())
+	err = gp.addFuncSvc(ctx, fsvc, fn.GetRequestPerPod(), fn.GetRetainPods())
+	if err != nil {
+		return nil, err
+	}
 
 	logger.Info("added function service",
 		zap.String("pod", pod.ObjectMeta.Name),
@@ -646,6 +659,34 @@ func (gp *GenericPool) getFuncSvc(ctx context.Context, fn *fv1.Function) (*fscac
 	return fsvc, nil
 }
 
+// addFuncSvc adds the function service of a specialized pod to the pool cache. The
+// pod is deleted if the cache refuses it at the address cap of the function, as are
+// the pods of the function services evicted from the cache to make room for it.
+func (gp *GenericPool) addFuncSvc(ctx context.Context, fsvc *fscache.FuncSvc, requestsPerPod, svcsRetain int) error {
+	gp.fsCache.PodToFsvc.Store(fsvc.Name, fsvc)
+	gp.podFSVCMap.Store(fsvc.Name, []interface{}{crd.CacheKeyURGFromMeta(fsvc.Function), fsvc.Address})
+	evicted, err := gp.fsCache.AddFunc(ctx, *fsvc, requestsPerPod, svcsRetain)
+	if err != nil {
+		gp.forgetPod(fsvc.Name)
+		return err
+	}
+	if evicted != nil {
+		gp.logger.Info("deleting pod of function service evicted from the pool cache",
+			zap.String("function", evicted.Function.Name),
+			zap.String("address", evicted.Address),
+			zap.String("pod", evicted.Name))
+		gp.forgetPod(evicted.Name)
+	}
+	return nil
+}
+
+// forgetPod removes the pod from the pod maps and schedules its deletion.
+func (gp *GenericPool) forgetPod(name string) {
+	gp.fsCache.PodToFsvc.Delete(name)
+	gp.podFSVCMap.Delete(name)
+	go gp.scheduleDeletePod(context.Background(), name)
+}
+
 // getPercent returns  x percent of the quantity i.e multiple it x/100
 func (gp *GenericPool) getPercent(cpuUsage resource.Quantity, percentage float64) (resource.Quantity, error) {
 	val := int64(math.Ceil(float64(cpuUsage.MilliValue()) * percentage))
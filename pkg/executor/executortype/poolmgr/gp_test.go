@@ -0,0 +1,161 @@
+/*
+Copyright 2024 The Fission Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package poolmgr
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	apiv1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+
+	fv1 "github.com/fission/fission/pkg/apis/core/v1"
+	"github.com/fission/fission/pkg/crd"
+	"github.com/fission/fission/pkg/executor/fscache"
+	fClient "github.com/fission/fission/pkg/generated/clientset/versioned/fake"
+)
+
+func TestAddFuncSvcAddressCap(t *testing.T) {
+	ctx := context.Background()
+	fn := &metav1.ObjectMeta{Name: "foo", Namespace: "default", UID: "uid-foo", ResourceVersion: "1"}
+	pods := []string{"pod-1", "pod-2", "pod-3"}
+
+	newPool := func(policy fscache.AddressCapPolicy) *GenericPool {
+		objects := make([]runtime.Object, 0, len(pods))
+		for _, name := range pods {
+			objects = append(objects, &apiv1.Pod{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "fission-function"}})
+		}
+		return &GenericPool{
+			logger:           zap.NewNop(),
+			fnNamespace:      "fission-function",
+			kubernetesClient: fake.NewSimpleClientset(objects...),
+			fsCache:          fscache.MakeFunctionServiceCache(zap.NewNop(), fscache.WithPoolMaxAddresses(1, policy)),
+		}
+	}
+	funcSvc := func(pod string) *fscache.FuncSvc {
+		return &fscache.FuncSvc{
+			Name:     pod,
+			Function: fn,
+			Address:  pod + ":8888",
+			Executor: fv1.ExecutorTypePoolmgr,
+			CPULimit: resource.MustParse("5m"),
+		}
+	}
+	requirePodDeleted := func(t *testing.T, gp *GenericPool, name string) {
+		t.Helper()
+		require.Eventually(t, func() bool {
+			_, err := gp.kubernetesClient.CoreV1().Pods(gp.fnNamespace).Get(ctx, name, metav1.GetOptions{})
+			return k8serrors.IsNotFound(err)
+		}, 5*time.Second, 10*time.Millisecond, "pod %v is not deleted", name)
+		_, ok := gp.fsCache.PodToFsvc.Load(name)
+		require.False(t, ok)
+		_, ok = gp.podFSVCMap.Load(name)
+		require.False(t, ok)
+	}
+	requirePodKept := func(t *testing.T, gp *GenericPool, name string) {
+		t.Helper()
+		_, err := gp.kubernetesClient.CoreV1().Pods(gp.fnNamespace).Get(ctx, name, metav1.GetOptions{})
+		require.NoError(t, err)
+		_, ok := gp.fsCache.PodToFsvc.Load(name)
+		require.True(t, ok)
+		_, ok = gp.podFSVCMap.Load(name)
+		require.True(t, ok)
+	}
+
+	t.Run("reject", func(t *testing.T) {
+		gp := newPool(fscache.AddressCapReject)
+		require.NoError(t, gp.addFuncSvc(ctx, funcSvc("pod-1"), 10, 0))
+		gp.fsCache.MarkAvailable(crd.CacheKeyURGFromMeta(fn), "pod-1:8888")
+
+		require.Error(t, gp.addFuncSvc(ctx, funcSvc("pod-2"), 10, 0))
+		requirePodDeleted(t, gp, "pod-2")
+		requirePodKept(t, gp, "pod-1")
+	})
+
+	t.Run("evict oldest", func(t *testing.T) {
+		gp := newPool(fscache.AddressCapEvictOldest)
+		require.NoError(t, gp.addFuncSvc(ctx, funcSvc("pod-1"), 10, 0))
+		gp.fsCache.MarkAvailable(crd.CacheKeyURGFromMeta(fn), "pod-1:8888")
+
+		require.NoError(t, gp.addFuncSvc(ctx, funcSvc("pod-2"), 10, 0))
+		requirePodDeleted(t, gp, "pod-1")
+		requirePodKept(t, gp, "pod-2")
+
+		// the remaining pod is busy, so the new one is refused
+		require.Error(t, gp.addFuncSvc(ctx, funcSvc("pod-3"), 10, 0))
+		requirePodDeleted(t, gp, "pod-3")
+		requirePodKept(t, gp, "pod-2")
+	})
+}
+
+func TestIdleObjectReaperPinned(t *testing.T) {
+	ctx := context.Background()
+	env := &fv1.Environment{ObjectMeta: metav1.ObjectMeta{Name: "env", Namespace: metav1.NamespaceDefault, UID: "uid-env"}}
+	fns := []*fv1.Function{
+		{ObjectMeta: metav1.ObjectMeta{Name: "pinned", Namespace: metav1.NamespaceDefault, UID: "uid-pinned", ResourceVersion: "1"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "idle", Namespace: metav1.NamespaceDefault, UID: "uid-idle", ResourceVersion: "1"}},
+	}
+	gpm := &GenericPoolManager{
+		logger:                 zap.NewNop(),
+		kubernetesClient:       fake.NewSimpleClientset(),
+		fissionClient:          fClient.NewSimpleClientset(env, fns[0], fns[1]),
+		fsCache:                fscache.MakeFunctionServiceCache(zap.NewNop()),
+		defaultIdlePodReapTime: time.Minute,
+	}
+
+	fsvcs := make([]fscache.FuncSvc, 0, len(fns))
+	for _, fn := range fns {
+		pod := &apiv1.Pod{ObjectMeta: metav1.ObjectMeta{Name: fn.Name, Namespace: "fission-function"}}
+		_, err := gpm.kubernetesClient.CoreV1().Pods(pod.Namespace).Create(ctx, pod, metav1.CreateOptions{})
+		require.NoError(t, err)
+		fsvcs = append(fsvcs, fscache.FuncSvc{
+			Name:        fn.Name,
+			Function:    &fns[len(fsvcs)].ObjectMeta,
+			Environment: env,
+			Address:     fn.Name + ":8888",
+			KubernetesObjects: []apiv1.ObjectReference{
+				{Kind: "pod", Name: pod.Name, Namespace: pod.Namespace},
+			},
+			Executor: fv1.ExecutorTypePoolmgr,
+			Atime:    time.Now().Add(-10 * time.Minute),
+		})
+	}
+	_, errs := gpm.fsCache.AddFuncs(ctx, fsvcs, 10, 0)
+	for i, err := range errs {
+		require.NoError(t, err)
+		gpm.fsCache.MarkAvailable(crd.CacheKeyURGFromMeta(fsvcs[i].Function), fsvcs[i].Address)
+	}
+	require.NoError(t, gpm.fsCache.Pin(&fns[0].ObjectMeta, true))
+
+	gpm.doIdleObjectReaper(ctx)
+
+	require.Eventually(t, func() bool {
+		_, err := gpm.kubernetesClient.CoreV1().Pods("fission-function").Get(ctx, "idle", metav1.GetOptions{})
+		return k8serrors.IsNotFound(err)
+	}, 5*time.Second, 10*time.Millisecond, "idle pod is not reaped")
+	_, err := gpm.kubernetesClient.CoreV1().Pods("fission-function").Get(ctx, "pinned", metav1.GetOptions{})
+	require.NoError(t, err)
+	_, err = gpm.fsCache.GetFuncSvc(ctx, &fns[0].ObjectMeta, 10, 0)
+	require.NoError(t, err)
+}
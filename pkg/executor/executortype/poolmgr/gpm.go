@@ -323,11 +323,13 @@ func (gpm *GenericPoolManager) RefreshFuncPods(ctx context.Context, logger *zap.
 		gpm.logger.Info("created pool for the environment", zap.String("env", env.ObjectMeta.Name), zap.String("namespace", gpm.nsResolver.ResolveNamespace(gpm.nsResolver.FunctionNamespace)))
 	}
 
-	funcSvc, err := gp.fsCache.GetByFunction(&f.ObjectMeta)
+	funcSvc, err := gp.fsCache.GetByFunctionWithContext(ctx, &f.ObjectMeta)
 
 	// delete function service address from cache only when function service address found in cache
 	if err == nil {
-		gp.fsCache.DeleteEntry(funcSvc)
+		if err := gp.fsCache.DeleteEntry(funcSvc); err != nil {
+			logger.Error("error deleting function service from cache", zap.String("function", f.ObjectMeta.Name), zap.Error(err))
+		}
 	}
 
 	funcLabels := gp.labelsForFunction(&f.ObjectMeta)
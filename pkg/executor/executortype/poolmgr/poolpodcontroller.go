@@ -477,7 +477,9 @@ func (p *PoolPodController) spCleanupPodQueueProcessFunc(ctx context.Context) bo
 		fsvc, ok := fsvc.(*fscache.FuncSvc)
 		if ok {
 			p.gpm.fsCache.DeleteFunctionSvc(ctx, fsvc)
-			p.gpm.fsCache.DeleteEntry(fsvc)
+			if err := p.gpm.fsCache.DeleteEntry(fsvc); err != nil {
+				p.logger.Error("error deleting function service from cache", zap.String("key", key), zap.Error(err))
+			}
 		} else {
 			p.logger.Error("could not convert item from PodToFsvc", zap.String("key", key))
 		}
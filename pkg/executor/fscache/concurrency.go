@@ -0,0 +1,149 @@
+/*
+Copyright 2024 The Fission Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fscache
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/fission/fission/pkg/crd"
+	ferror "github.com/fission/fission/pkg/error"
+)
+
+type (
+	// concurrencyLimiter limits the number of callers holding a function service
+	// of a function, checked out with GetFuncSvc and returned with MarkAvailable.
+	concurrencyLimiter struct {
+		mu       sync.Mutex
+		failFast bool
+		funcs    map[crd.CacheKeyURG]*funcCheckouts
+	}
+
+	funcCheckouts struct {
+		count int            // slots taken, including callers still looking up a service
+		held  map[string]int // checked out function services by address
+		// released is closed and replaced whenever a slot is freed to wake up waiters
+		released chan struct{}
+	}
+)
+
+func newConcurrencyLimiter(failFast bool) *concurrencyLimiter {
+	return &concurrencyLimiter{
+		failFast: failFast,
+		funcs:    make(map[crd.CacheKeyURG]*funcCheckouts),
+	}
+}
+
+func (l *concurrencyLimiter) checkouts(key crd.CacheKeyURG) *funcCheckouts {
+	fc, ok := l.funcs[key]
+	if !ok {
+		fc = &funcCheckouts{
+			held:     make(map[string]int),
+			released: make(chan struct{}),
+		}
+		l.funcs[key] = fc
+	}
+	return fc
+}
+
+// acquire takes a slot of the function, waiting for one to be freed if all
+// limit slots are taken. With failFast it returns an ErrorTooManyRequests
+// error instead of waiting. A limit <= 0 disables the limit.
+func (l *concurrencyLimiter) acquire(ctx context.Context, key crd.CacheKeyURG, limit int) error {
+	for {
+		l.mu.Lock()
+		fc := l.checkouts(key)
+		if limit <= 0 || fc.count < limit {
+			fc.count++
+			l.mu.Unlock()
+			return nil
+		}
+		released := fc.released
+		l.mu.Unlock()
+
+		if l.failFast {
+			return ferror.MakeError(ferror.ErrorTooManyRequests,
+				fmt.Sprintf("function '%s' concurrency '%d' limit reached", key, limit))
+		}
+		select {
+		case <-released:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// assign records the function service at address as held by the slot taken with acquire.
+func (l *concurrencyLimiter) assign(key crd.CacheKeyURG, address string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.checkouts(key).held[address]++
+}
+
+// cancel frees a slot taken with acquire for which no function service was checked out.
+func (l *concurrencyLimiter) cancel(key crd.CacheKeyURG) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.free(key, l.checkouts(key), 1)
+}
+
+// release frees the slot of a function service at address checked out with GetFuncSvc.
+// Function services not checked out with GetFuncSvc, e.g. the ones returned by
+// specialization, hold no slot.
+func (l *concurrencyLimiter) release(key crd.CacheKeyURG, address string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	fc, ok := l.funcs[key]
+	if !ok || fc.held[address] == 0 {
+		return
+	}
+	fc.held[address]--
+	if fc.held[address] == 0 {
+		delete(fc.held, address)
+	}
+	l.free(key, fc, 1)
+}
+
+// releaseAddress frees all slots of the function service at address, e.g. once it is deleted.
+func (l *concurrencyLimiter) releaseAddress(key crd.CacheKeyURG, address string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	fc, ok := l.funcs[key]
+	if !ok {
+		return
+	}
+	n := fc.held[address]
+	delete(fc.held, address)
+	l.free(key, fc, n)
+}
+
+// free must be called with l.mu held.
+func (l *concurrencyLimiter) free(key crd.CacheKeyURG, fc *funcCheckouts, n int) {
+	if n <= 0 {
+		return
+	}
+	fc.count -= n
+	if fc.count < 0 {
+		fc.count = 0
+	}
+	close(fc.released)
+	fc.released = make(chan struct{})
+	if fc.count == 0 && len(fc.held) == 0 {
+		delete(l.funcs, key)
+	}
+}
@@ -0,0 +1,236 @@
+/*
+Copyright 2024 The Fission Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fscache
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+	"k8s.io/apimachinery/pkg/labels"
+
+	fv1 "github.com/fission/fission/pkg/apis/core/v1"
+	"github.com/fission/fission/pkg/crd"
+	ferror "github.com/fission/fission/pkg/error"
+)
+
+type (
+	// cacheStatsJSON is the JSON form of CacheStats served by DebugHandler.
+	cacheStatsJSON struct {
+		Count        int                `json:"count"`
+		OldestAtime  string             `json:"oldestAtime,omitempty"`
+		NewestAtime  string             `json:"newestAtime,omitempty"`
+		OldestCtime  string             `json:"oldestCtime,omitempty"`
+		NewestCtime  string             `json:"newestCtime,omitempty"`
+		MeanIdleTime string             `json:"meanIdleTime"`
+		Pool         poolCacheStatsJSON `json:"pool"`
+	}
+
+	poolCacheStatsJSON struct {
+		Groups          int `json:"groups"`
+		Services        int `json:"services"`
+		WaitingRequests int `json:"waitingRequests"`
+	}
+
+	// cacheSnapshot is the cache contents served by DebugHandler on /snapshot.
+	cacheSnapshot struct {
+		Time             string          `json:"time"`
+		Owner            string          `json:"owner,omitempty"`
+		Stats            cacheStatsJSON  `json:"stats"`
+		FunctionServices []*FuncSvc      `json:"functionServices"`
+		PoolServices     []poolSvcRecord `json:"poolServices,omitempty"`
+	}
+)
+
+// DebugHandler returns an http.Handler serving the cache contents as JSON, to be
+// mounted by components under a debug path prefix with http.StripPrefix:
+//
+//	GET /list      function services, filtered by the executor, label selector and
+//	               function query parameters if given, see ListByExecutor and
+//	               ListBySelector; function is a crd.CacheKey of the form namespace/name
+//	GET /stats     aggregated cache statistics, see Stats
+//	GET /dump      pool cache contents, in the format query parameter (json or text)
+//	GET /snapshot  statistics, function services and pool cache contents
+//	GET /verify    inconsistencies between the cache indexes, see Verify
+//
+// All routes go through the cache service loop, so the served contents are consistent
+// per index, but a snapshot is no atomic view across the function service and pool caches.
+func (fsc *FunctionServiceCache) DebugHandler() http.Handler {
+	r := mux.NewRouter()
+	r.HandleFunc("/list", fsc.debugList).Methods("GET")
+	r.HandleFunc("/stats", fsc.debugStats).Methods("GET")
+	r.HandleFunc("/dump", fsc.debugDump).Methods("GET")
+	r.HandleFunc("/snapshot", fsc.debugSnapshot).Methods("GET")
+	r.HandleFunc("/verify", fsc.debugVerify).Methods("GET")
+	return r
+}
+
+func (fsc *FunctionServiceCache) debugList(w http.ResponseWriter, r *http.Request) {
+	fsvcs, err := fsc.listFuncSvcs(r)
+	if err != nil {
+		fsc.debugError(w, err)
+		return
+	}
+	fsc.writeJSON(w, fsvcs)
+}
+
+func (fsc *FunctionServiceCache) debugStats(w http.ResponseWriter, r *http.Request) {
+	fsc.writeJSON(w, newCacheStatsJSON(fsc.Stats()))
+}
+
+func (fsc *FunctionServiceCache) debugDump(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if len(format) == 0 {
+		format = DumpFormatJSON
+	}
+	if format != DumpFormatJSON && format != DumpFormatText {
+		fsc.debugError(w, ferror.MakeError(ferror.ErrorInvalidArgument, fmt.Sprintf("invalid dump format: %v", format)))
+		return
+	}
+	if _, err := fsc.poolCache(); err != nil {
+		fsc.debugError(w, err)
+		return
+	}
+
+	if format == DumpFormatJSON {
+		w.Header().Set("Content-Type", "application/json")
+	} else {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	}
+	// the status is sent with the first write, errors past it can only be logged
+	err := fsc.WriteFnSvcCache(r.Context(), w, format)
+	if err != nil {
+		fsc.logger.Error("error writing function service cache dump", zap.Error(err))
+	}
+}
+
+func (fsc *FunctionServiceCache) debugSnapshot(w http.ResponseWriter, r *http.Request) {
+	fsvcs, err := fsc.ListBySelector(nil)
+	if err != nil {
+		fsc.debugError(w, err)
+		return
+	}
+	sortFuncSvcs(fsvcs)
+	snapshot := cacheSnapshot{
+		Time:             formatJSONTime(time.Now()),
+		Owner:            fsc.owner,
+		Stats:            newCacheStatsJSON(fsc.Stats()),
+		FunctionServices: fsvcs,
+	}
+	if pool, err := fsc.poolCache(); err == nil {
+		snapshot.PoolServices, err = poolSvcRecords(r.Context(), pool)
+		if err != nil {
+			fsc.debugError(w, err)
+			return
+		}
+	}
+	fsc.writeJSON(w, snapshot)
+}
+
+func (fsc *FunctionServiceCache) debugVerify(w http.ResponseWriter, r *http.Request) {
+	fsc.writeJSON(w, fsc.Verify())
+}
+
+// listFuncSvcs returns the function services matching the query parameters of r.
+func (fsc *FunctionServiceCache) listFuncSvcs(r *http.Request) ([]*FuncSvc, error) {
+	query := r.URL.Query()
+	selector := labels.Everything()
+	if s := query.Get("selector"); len(s) > 0 {
+		var err error
+		selector, err = labels.Parse(s)
+		if err != nil {
+			return nil, ferror.MakeError(ferror.ErrorInvalidArgument, fmt.Sprintf("invalid label selector '%v': %v", s, err))
+		}
+	}
+	var namespace, name string
+	if key := query.Get("function"); len(key) > 0 {
+		var err error
+		namespace, name, err = crd.ParseCacheKey(key)
+		if err != nil {
+			return nil, ferror.MakeError(ferror.ErrorInvalidArgument, err.Error())
+		}
+	}
+	fsvcs, err := fsc.ListBySelector(selector)
+	if err != nil {
+		return nil, err
+	}
+	sortFuncSvcs(fsvcs)
+
+	executor := fv1.ExecutorType(query.Get("executor"))
+	if len(executor) == 0 && len(name) == 0 {
+		return fsvcs, nil
+	}
+	filtered := make([]*FuncSvc, 0, len(fsvcs))
+	for _, fsvc := range fsvcs {
+		if len(executor) > 0 && fsvc.Executor != executor {
+			continue
+		}
+		if len(name) > 0 && (fsvc.Function.Namespace != namespace || fsvc.Function.Name != name) {
+			continue
+		}
+		filtered = append(filtered, fsvc)
+	}
+	return filtered, nil
+}
+
+func (fsc *FunctionServiceCache) writeJSON(w http.ResponseWriter, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		fsc.debugError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, err = w.Write(data)
+	if err != nil {
+		fsc.logger.Error("error writing debug response", zap.Error(err))
+	}
+}
+
+func (fsc *FunctionServiceCache) debugError(w http.ResponseWriter, err error) {
+	code, msg := ferror.GetHTTPError(err)
+	http.Error(w, msg, code)
+}
+
+// sortFuncSvcs sorts function services by function namespace and name, for stable output.
+func sortFuncSvcs(fsvcs []*FuncSvc) {
+	sort.Slice(fsvcs, func(i, j int) bool {
+		if fsvcs[i].Function.Namespace != fsvcs[j].Function.Namespace {
+			return fsvcs[i].Function.Namespace < fsvcs[j].Function.Namespace
+		}
+		return fsvcs[i].Function.Name < fsvcs[j].Function.Name
+	})
+}
+
+func newCacheStatsJSON(stats CacheStats) cacheStatsJSON {
+	return cacheStatsJSON{
+		Count:        stats.Count,
+		OldestAtime:  formatJSONTime(stats.OldestAtime),
+		NewestAtime:  formatJSONTime(stats.NewestAtime),
+		OldestCtime:  formatJSONTime(stats.OldestCtime),
+		NewestCtime:  formatJSONTime(stats.NewestCtime),
+		MeanIdleTime: stats.MeanIdleTime.String(),
+		Pool: poolCacheStatsJSON{
+			Groups:          stats.Pool.Groups,
+			Services:        stats.Pool.Services,
+			WaitingRequests: stats.Pool.WaitingRequests,
+		},
+	}
+}
@@ -0,0 +1,173 @@
+/*
+Copyright 2024 The Fission Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fscache
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	fv1 "github.com/fission/fission/pkg/apis/core/v1"
+)
+
+func TestDebugHandler(t *testing.T) {
+	fsc := MakeFunctionServiceCache(zap.NewNop(), WithOwner("executor-1"))
+	_, err := fsc.Add(FuncSvc{
+		Function: &metav1.ObjectMeta{Name: "shop", Namespace: "default", UID: "uid-shop", Labels: map[string]string{"app": "shop"}},
+		Address:  "10.0.0.1:8888",
+		Executor: fv1.ExecutorTypeNewdeploy,
+	})
+	require.NoError(t, err)
+	_, err = fsc.Add(FuncSvc{
+		Function: &metav1.ObjectMeta{Name: "blog", Namespace: "default", UID: "uid-blog", Labels: map[string]string{"app": "blog"}},
+		Address:  "10.0.0.2:8888",
+		Executor: fv1.ExecutorTypeContainer,
+	})
+	require.NoError(t, err)
+	fsc.AddFunc(context.Background(), FuncSvc{
+		Function: &metav1.ObjectMeta{Name: "pooled", Namespace: "default", UID: "uid-pooled"},
+		Address:  "10.0.0.3:8888",
+		Executor: fv1.ExecutorTypePoolmgr,
+		CPULimit: resource.MustParse("5m"),
+	}, 10, 0)
+
+	server := httptest.NewServer(http.StripPrefix("/debug/fscache", fsc.DebugHandler()))
+	defer server.Close()
+
+	get := func(t *testing.T, path string, expectedStatus int) []byte {
+		t.Helper()
+		resp, err := http.Get(server.URL + "/debug/fscache" + path)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		require.Equal(t, expectedStatus, resp.StatusCode, string(body))
+		if expectedStatus == http.StatusOK && !strings.Contains(path, "format=text") {
+			require.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+		}
+		return body
+	}
+	listNames := func(t *testing.T, path string) []string {
+		t.Helper()
+		var fsvcs []map[string]interface{}
+		require.NoError(t, json.Unmarshal(get(t, path, http.StatusOK), &fsvcs))
+		names := make([]string, 0, len(fsvcs))
+		for _, fsvc := range fsvcs {
+			names = append(names, fsvc["function"].(map[string]interface{})["name"].(string))
+		}
+		return names
+	}
+
+	t.Run("list", func(t *testing.T) {
+		var fsvcs []map[string]interface{}
+		require.NoError(t, json.Unmarshal(get(t, "/list", http.StatusOK), &fsvcs))
+		// pool cache entries are only listed by /dump and /snapshot
+		require.Len(t, fsvcs, 2)
+		// sorted by function namespace and name, in the FuncSvc JSON schema
+		require.Equal(t, map[string]interface{}{"namespace": "default", "name": "blog", "uid": "uid-blog"}, fsvcs[0]["function"])
+		require.Equal(t, "10.0.0.2:8888", fsvcs[0]["address"])
+		require.Equal(t, "container", fsvcs[0]["executor"])
+		require.Equal(t, "executor-1", fsvcs[0]["owner"])
+		require.Contains(t, fsvcs[0], "ctime")
+		require.Contains(t, fsvcs[0], "atime")
+
+		require.Equal(t, []string{"shop"}, listNames(t, "/list?executor=newdeploy"))
+		require.Equal(t, []string{"blog"}, listNames(t, "/list?selector=app%3Dblog"))
+		require.Equal(t, []string{}, listNames(t, "/list?selector=app%3Dblog&executor=newdeploy"))
+		get(t, "/list?selector=app%3D%3D%3D", http.StatusBadRequest)
+
+		require.Equal(t, []string{"shop"}, listNames(t, "/list?function=default%2Fshop"))
+		require.Equal(t, []string{}, listNames(t, "/list?function=default%2Fshop&executor=container"))
+		require.Equal(t, []string{}, listNames(t, "/list?function=other%2Fshop"))
+		get(t, "/list?function=default%2Fshop%2Fx", http.StatusBadRequest)
+	})
+
+	t.Run("stats", func(t *testing.T) {
+		var stats map[string]interface{}
+		require.NoError(t, json.Unmarshal(get(t, "/stats", http.StatusOK), &stats))
+		require.Equal(t, float64(2), stats["count"])
+		for _, key := range []string{"oldestAtime", "newestAtime", "oldestCtime", "newestCtime", "meanIdleTime"} {
+			require.IsType(t, "", stats[key], key)
+		}
+		require.Equal(t, map[string]interface{}{"groups": float64(1), "services": float64(1), "waitingRequests": float64(0)}, stats["pool"])
+	})
+
+	t.Run("dump", func(t *testing.T) {
+		var records []map[string]interface{}
+		require.NoError(t, json.Unmarshal(get(t, "/dump", http.StatusOK), &records))
+		require.Len(t, records, 1)
+		require.Equal(t, "pooled", records[0]["functionName"])
+		require.Equal(t, "10.0.0.3:8888", records[0]["address"])
+		require.Equal(t, "5m", records[0]["cpuLimit"])
+
+		text := get(t, "/dump?format=text", http.StatusOK)
+		require.Contains(t, string(text), "function_name:pooled")
+		get(t, "/dump?format=yaml", http.StatusBadRequest)
+	})
+
+	t.Run("snapshot", func(t *testing.T) {
+		var snapshot struct {
+			Time             string                   `json:"time"`
+			Owner            string                   `json:"owner"`
+			Stats            map[string]interface{}   `json:"stats"`
+			FunctionServices []map[string]interface{} `json:"functionServices"`
+			PoolServices     []map[string]interface{} `json:"poolServices"`
+		}
+		require.NoError(t, json.Unmarshal(get(t, "/snapshot", http.StatusOK), &snapshot))
+		require.NotEmpty(t, snapshot.Time)
+		require.Equal(t, "executor-1", snapshot.Owner)
+		require.Equal(t, float64(2), snapshot.Stats["count"])
+		require.Len(t, snapshot.FunctionServices, 2)
+		require.Len(t, snapshot.PoolServices, 1)
+	})
+
+	t.Run("verify", func(t *testing.T) {
+		var inconsistencies []Inconsistency
+		require.NoError(t, json.Unmarshal(get(t, "/verify", http.StatusOK), &inconsistencies))
+		require.Empty(t, inconsistencies)
+	})
+
+	t.Run("routes", func(t *testing.T) {
+		get(t, "/unknown", http.StatusNotFound)
+		resp, err := http.Post(server.URL+"/debug/fscache/list", "application/json", nil)
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+	})
+
+	t.Run("no pool cache", func(t *testing.T) {
+		fsc := MakeFunctionServiceCache(zap.NewNop())
+		fsc.connFunctionCache = nil
+		rec := httptest.NewRecorder()
+		fsc.DebugHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/dump", nil))
+		require.Equal(t, http.StatusInternalServerError, rec.Code)
+
+		rec = httptest.NewRecorder()
+		fsc.DebugHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/snapshot", nil))
+		require.Equal(t, http.StatusOK, rec.Code)
+		require.NotContains(t, rec.Body.String(), "poolServices")
+	})
+}
@@ -0,0 +1,83 @@
+/*
+Copyright 2024 The Fission Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fscache
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// ExportJSONL writes the cached function services to w as JSON Lines, one FuncSvc
+// per line in the schema of MarshalJSON, e.g. to ship the cache state to a log or
+// analytics pipeline. The function services are written sorted by function namespace
+// and name, followed by the function services of the pool cache, if any.
+//
+// Each line is written to w as soon as it is encoded, and flushed if w is an
+// http.Flusher, so the export is never buffered in full. The export stops with the
+// error of ctx once it is done, leaving the lines written so far.
+func (fsc *FunctionServiceCache) ExportJSONL(ctx context.Context, w io.Writer) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	fsvcs, err := fsc.ListBySelector(nil)
+	if err != nil {
+		return err
+	}
+	sortFuncSvcs(fsvcs)
+
+	enc := json.NewEncoder(w)
+	flusher, _ := w.(http.Flusher)
+	writeLines := func(fsvcs []*FuncSvc) error {
+		for _, fsvc := range fsvcs {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			// Encode terminates each value with a newline
+			if err := enc.Encode(fsvc); err != nil {
+				return err
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+		return nil
+	}
+	if err := writeLines(fsvcs); err != nil {
+		return err
+	}
+
+	pool, err := fsc.poolCache()
+	if err != nil {
+		return nil
+	}
+	// the visitor runs inside the pool cache service loop, so it must not block on w
+	poolFsvcs := make([]*FuncSvc, 0)
+	err = pool.ForEachSvc(ctx, func(key string, addr string, fsvc *FuncSvc, cpuUsage, cpuLimit resource.Quantity) {
+		if fsvc != nil {
+			fsvcCopy := *fsvc
+			poolFsvcs = append(poolFsvcs, &fsvcCopy)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	return writeLines(poolFsvcs)
+}
@@ -0,0 +1,117 @@
+/*
+Copyright 2024 The Fission Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fscache
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	fv1 "github.com/fission/fission/pkg/apis/core/v1"
+)
+
+// cancelWriter cancels a context once it has written the given number of lines.
+type cancelWriter struct {
+	bytes.Buffer
+	lines  int
+	cancel context.CancelFunc
+}
+
+func (cw *cancelWriter) Write(p []byte) (int, error) {
+	n, err := cw.Buffer.Write(p)
+	if strings.Count(cw.String(), "\n") >= cw.lines {
+		cw.cancel()
+	}
+	return n, err
+}
+
+func TestExportJSONL(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	fsc := MakeFunctionServiceCache(zap.NewNop(), WithOwner("executor-1"))
+	for _, name := range []string{"foo", "bar"} {
+		_, err := fsc.Add(FuncSvc{
+			Function: &metav1.ObjectMeta{Name: name, Namespace: "default", UID: types.UID("uid-" + name)},
+			Address:  name + ".default",
+			Executor: fv1.ExecutorTypeNewdeploy,
+			Priority: len(name),
+		})
+		require.NoError(t, err)
+	}
+	fsc.AddFunc(ctx, FuncSvc{
+		Function: &metav1.ObjectMeta{Name: "pooled", Namespace: "default", UID: "uid-pooled"},
+		Address:  "pooled.default",
+		Executor: fv1.ExecutorTypePoolmgr,
+		CPULimit: resource.MustParse("5m"),
+	}, 10, 0)
+
+	// an http.Flusher gets each line flushed
+	rec := httptest.NewRecorder()
+	require.NoError(t, fsc.ExportJSONL(ctx, rec))
+	require.True(t, rec.Flushed)
+
+	lines := strings.Split(strings.TrimSuffix(rec.Body.String(), "\n"), "\n")
+	require.Len(t, lines, 3)
+	names := make([]string, 0, len(lines))
+	for _, line := range lines {
+		var fsvc FuncSvc
+		require.NoError(t, json.Unmarshal([]byte(line), &fsvc), line)
+		require.Equal(t, "executor-1", fsvc.Owner)
+		require.False(t, fsvc.Ctime.IsZero())
+		names = append(names, fsvc.Function.Name)
+	}
+	// sorted function services first, then the pool cache
+	require.Equal(t, []string{"bar", "foo", "pooled"}, names)
+
+	var first FuncSvc
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &first))
+	require.Equal(t, "bar.default", first.Address)
+	require.Equal(t, fv1.ExecutorTypeNewdeploy, first.Executor)
+	require.Equal(t, 3, first.Priority)
+
+	t.Run("cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		w := &cancelWriter{lines: 1, cancel: cancel}
+		err := fsc.ExportJSONL(ctx, w)
+		require.ErrorIs(t, err, context.Canceled)
+		// the lines written before cancellation are complete
+		require.Equal(t, 1, strings.Count(w.String(), "\n"))
+		var fsvc FuncSvc
+		require.NoError(t, json.Unmarshal(w.Bytes(), &fsvc))
+		require.Equal(t, "bar", fsvc.Function.Name)
+
+		err = fsc.ExportJSONL(ctx, &bytes.Buffer{})
+		require.ErrorIs(t, err, context.Canceled)
+	})
+
+	t.Run("no pool cache", func(t *testing.T) {
+		fsc.connFunctionCache = nil
+		var buf bytes.Buffer
+		require.NoError(t, fsc.ExportJSONL(context.Background(), &buf))
+		require.Equal(t, 2, strings.Count(buf.String(), "\n"))
+	})
+}
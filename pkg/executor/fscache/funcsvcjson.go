@@ -0,0 +1,174 @@
+/*
+Copyright 2024 The Fission Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fscache
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/pkg/errors"
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	fv1 "github.com/fission/fission/pkg/apis/core/v1"
+)
+
+type (
+	// funcSvcJSON is the JSON schema of a FuncSvc. It only keeps the identity of
+	// the function and environment, so that dumps and snapshots stay compact and
+	// don't change with unrelated object metadata.
+	funcSvcJSON struct {
+		Name              string                  `json:"name,omitempty"`
+		Function          *funcSvcJSONObject      `json:"function,omitempty"`
+		Environment       *funcSvcJSONObject      `json:"environment,omitempty"`
+		Address           string                  `json:"address,omitempty"`
+		KubernetesObjects []apiv1.ObjectReference `json:"kubernetesObjects,omitempty"`
+		Executor          fv1.ExecutorType        `json:"executor,omitempty"`
+		CPULimit          string                  `json:"cpuLimit,omitempty"`
+		MemoryLimit       string                  `json:"memoryLimit,omitempty"` // of the environment runtime
+		Owner             string                  `json:"owner,omitempty"`
+		Pinned            bool                    `json:"pinned,omitempty"`
+		Priority          int                     `json:"priority,omitempty"`
+		Ctime             string                  `json:"ctime,omitempty"`
+		Atime             string                  `json:"atime,omitempty"`
+	}
+
+	funcSvcJSONObject struct {
+		Namespace       string    `json:"namespace,omitempty"`
+		Name            string    `json:"name"`
+		UID             types.UID `json:"uid,omitempty"`
+		ResourceVersion string    `json:"resourceVersion,omitempty"`
+	}
+)
+
+// MarshalJSON encodes the function service with a compact, stable schema.
+// Quantities are encoded in their canonical string form and times in RFC 3339.
+func (fsvc FuncSvc) MarshalJSON() ([]byte, error) {
+	out := funcSvcJSON{
+		Name:              fsvc.Name,
+		Address:           fsvc.Address,
+		KubernetesObjects: fsvc.KubernetesObjects,
+		Executor:          fsvc.Executor,
+		Owner:             fsvc.Owner,
+		Pinned:            fsvc.Pinned,
+		Priority:          fsvc.Priority,
+		Ctime:             formatJSONTime(fsvc.Ctime),
+		Atime:             formatJSONTime(fsvc.Atime),
+	}
+	if fsvc.Function != nil {
+		out.Function = &funcSvcJSONObject{
+			Namespace:       fsvc.Function.Namespace,
+			Name:            fsvc.Function.Name,
+			UID:             fsvc.Function.UID,
+			ResourceVersion: fsvc.Function.ResourceVersion,
+		}
+	}
+	if fsvc.Environment != nil {
+		out.Environment = &funcSvcJSONObject{
+			Namespace:       fsvc.Environment.Namespace,
+			Name:            fsvc.Environment.Name,
+			UID:             fsvc.Environment.UID,
+			ResourceVersion: fsvc.Environment.ResourceVersion,
+		}
+		if memory, ok := fsvc.Environment.Spec.Resources.Limits[apiv1.ResourceMemory]; ok {
+			out.MemoryLimit = memory.String()
+		}
+	}
+	if !fsvc.CPULimit.IsZero() {
+		out.CPULimit = fsvc.CPULimit.String()
+	}
+	return json.Marshal(out)
+}
+
+// UnmarshalJSON decodes a function service encoded with MarshalJSON. The function
+// and environment only hold the fields of the schema.
+func (fsvc *FuncSvc) UnmarshalJSON(data []byte) error {
+	var in funcSvcJSON
+	err := json.Unmarshal(data, &in)
+	if err != nil {
+		return err
+	}
+
+	out := FuncSvc{
+		Name:              in.Name,
+		Address:           in.Address,
+		KubernetesObjects: in.KubernetesObjects,
+		Executor:          in.Executor,
+		Owner:             in.Owner,
+		Pinned:            in.Pinned,
+		Priority:          in.Priority,
+	}
+	if in.Function != nil {
+		out.Function = in.Function.objectMeta()
+	}
+	if in.Environment != nil {
+		out.Environment = &fv1.Environment{ObjectMeta: *in.Environment.objectMeta()}
+	}
+	if len(in.MemoryLimit) > 0 {
+		memory, err := resource.ParseQuantity(in.MemoryLimit)
+		if err != nil {
+			return errors.Wrapf(err, "error parsing function service memory limit %q", in.MemoryLimit)
+		}
+		if out.Environment == nil {
+			out.Environment = &fv1.Environment{}
+		}
+		out.Environment.Spec.Resources.Limits = apiv1.ResourceList{apiv1.ResourceMemory: memory}
+	}
+	if len(in.CPULimit) > 0 {
+		out.CPULimit, err = resource.ParseQuantity(in.CPULimit)
+		if err != nil {
+			return errors.Wrapf(err, "error parsing function service cpu limit %q", in.CPULimit)
+		}
+	}
+	out.Ctime, err = parseJSONTime(in.Ctime)
+	if err != nil {
+		return errors.Wrapf(err, "error parsing function service ctime %q", in.Ctime)
+	}
+	out.Atime, err = parseJSONTime(in.Atime)
+	if err != nil {
+		return errors.Wrapf(err, "error parsing function service atime %q", in.Atime)
+	}
+
+	*fsvc = out
+	return nil
+}
+
+func (o *funcSvcJSONObject) objectMeta() *metav1.ObjectMeta {
+	return &metav1.ObjectMeta{
+		Namespace:       o.Namespace,
+		Name:            o.Name,
+		UID:             o.UID,
+		ResourceVersion: o.ResourceVersion,
+	}
+}
+
+// formatJSONTime returns t in RFC 3339 with nanoseconds in UTC, or an empty string for the zero time.
+func formatJSONTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+func parseJSONTime(s string) (time.Time, error) {
+	if len(s) == 0 {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339Nano, s)
+}
@@ -18,16 +18,28 @@ package fscache
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"io"
+	"net"
+	"os"
+	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/hashicorp/go-multierror"
 	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	"go.uber.org/zap"
+	"golang.org/x/time/rate"
 	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/types"
 
 	fv1 "github.com/fission/fission/pkg/apis/core/v1"
@@ -36,10 +48,30 @@ import (
 	ferror "github.com/fission/fission/pkg/error"
 	"github.com/fission/fission/pkg/executor/metrics"
 	"github.com/fission/fission/pkg/executor/util"
+	otelUtils "github.com/fission/fission/pkg/utils/otel"
 )
 
 type fscRequestType int
 
+// DefaultCPULimit is the CPU limit AddFunc uses for function services without one,
+// unless configured otherwise with WithDefaultCPULimit.
+var DefaultCPULimit = resource.MustParse("1")
+
+// DefaultGetFuncSvcMaxWait is how long GetFuncSvc waits for a function service if the
+// context has no deadline, unless configured otherwise with WithGetFuncSvcMaxWait.
+// Waiting callers depend on another caller specializing a function pod, so it
+// defaults to the default specialization timeout.
+var DefaultGetFuncSvcMaxWait = time.Duration(fv1.DefaultSpecializationTimeOut) * time.Second
+
+// errNoPoolCache is returned by pool cache operations of a FunctionServiceCache without pool cache.
+var errNoPoolCache = ferror.MakeError(ferror.ErrorInternal, "function service cache has no pool cache")
+
+// Formats supported by WriteFnSvcCache
+const (
+	DumpFormatText = "text"
+	DumpFormatJSON = "json"
+)
+
 // type executorType int
 
 // FunctionServiceCache Request Types
@@ -48,8 +80,52 @@ const (
 	LISTOLD
 	LOG
 	LISTOLDPOOL
+	REPLACE
+	LISTBYSELECTOR
+	STATS
+	LISTOLDPAGE
+	PIN
+	EVENT
+	TOUCHBYFUNCTION
+	GETBYFUNCTION
+	GETBYFUNCTIONUID
+	LISTBYEXECUTOR
+	LISTBYENVIRONMENT
+	GETKUBERNETESOBJECTS
+	VERIFY
+	ISPINNED
+)
+
+// DefaultEventBufferSize is the buffer size of the channel returned by Events,
+// unless configured otherwise with WithEventBufferSize.
+const DefaultEventBufferSize = 100
+
+// CacheEventType is the kind of mutation a CacheEvent reports.
+type CacheEventType int
+
+// Cache event types
+const (
+	CacheEventAdded   CacheEventType = iota // a function service was added
+	CacheEventTouched                       // a function service was accessed
+	CacheEventDeleted                       // a function service was deleted
+	CacheEventEvicted                       // an idle function service was reaped
 )
 
+func (t CacheEventType) String() string {
+	switch t {
+	case CacheEventAdded:
+		return "Added"
+	case CacheEventTouched:
+		return "Touched"
+	case CacheEventDeleted:
+		return "Deleted"
+	case CacheEventEvicted:
+		return "Evicted"
+	default:
+		return fmt.Sprintf("CacheEventType(%d)", int(t))
+	}
+}
+
 type (
 	// FuncSvc represents a function service
 	FuncSvc struct {
@@ -60,36 +136,270 @@ type (
 		KubernetesObjects []apiv1.ObjectReference // Kubernetes Objects (within the function namespace)
 		Executor          fv1.ExecutorType
 		CPULimit          resource.Quantity
+		Owner             string // identity of the executor replica which created the function service
+		Pinned            bool   // pinned function services are never reaped for being idle
+		Priority          int    // function services of higher priority are reaped and evicted after those of lower priority, see fv1.Function.GetReapPriority
 
 		Ctime time.Time
 		Atime time.Time
 	}
 
+	// indexCache is a cache indexing function services, implemented by cache.Cache.
+	indexCache[K comparable, V any] interface {
+		Get(key K) (V, error)
+		Set(key K, value V) (V, error)
+		Update(key K, value V) (V, error)
+		Delete(key K) error
+		Copy() map[K]V
+	}
+
 	// FunctionServiceCache represents the function service cache
 	FunctionServiceCache struct {
 		logger            *zap.Logger
-		byFunction        *cache.Cache[crd.CacheKeyUR, *FuncSvc]
-		byAddress         *cache.Cache[string, metav1.ObjectMeta]
-		byFunctionUID     *cache.Cache[types.UID, metav1.ObjectMeta]
+		byFunction        indexCache[crd.CacheKeyUR, *FuncSvc]
+		byAddress         indexCache[string, metav1.ObjectMeta]
+		byFunctionUID     indexCache[types.UID, metav1.ObjectMeta]
 		connFunctionCache *PoolCache // function-key -> funcSvc : map[string]*funcSvc
 		PodToFsvc         sync.Map   // pod-name -> funcSvc: map[string]*FuncSvc
 		WebsocketFsvc     sync.Map   // funcSvc-name -> bool: map[string]bool
 		requestChannel    chan *fscRequest
+		events            chan CacheEvent
+		normalizeAddress  bool
+		defaultCPULimit   resource.Quantity
+		owner             string
+		dumpFileOptions   util.DumpFileOptions
+		limiter           *concurrencyLimiter // limits GetFuncSvc checkouts per function, if set
+		getFuncSvcMaxWait time.Duration       // applied to GetFuncSvc contexts without deadline
+		updateAddress     bool                // Add replaces entries of a function at a changed address
+		atimeInterval     time.Duration       // minimum interval between access time updates, < 0 disables them
+		lastTouch         sync.Map            // touch key -> *atomic.Int64: unix nanoseconds of the last sampled touch
+		touchLimiter      *rate.Limiter       // limits TOUCH requests to the service loop, if set
+		loadMu            sync.Mutex
+		loading           map[crd.CacheKeyUR]*loadCall // function-key -> in-flight GetOrLoad call
+		dumpMu            sync.Mutex
+		dumping           *dumpCall                         // in-flight Dump call
+		tombstones        indexCache[tombstoneKey, FuncSvc] // recently deleted function services, if set
+	}
+
+	// CacheEvent is a mutation of the function service cache, see Events.
+	CacheEvent struct {
+		Type     CacheEventType
+		Function metav1.ObjectMeta
+		Address  string
+		Time     time.Time
+	}
+
+	// tombstoneKey identifies a deleted function service by its function and address.
+	tombstoneKey struct {
+		function crd.CacheKeyUR
+		address  string
+	}
+
+	// FuncSvcLoader creates the function service of a function missing in the cache.
+	FuncSvcLoader func(ctx context.Context) (*FuncSvc, error)
+
+	// loadCall is a FuncSvcLoader call shared by concurrent GetOrLoad callers.
+	loadCall struct {
+		done chan struct{}
+		fsvc *FuncSvc
+		err  error
+	}
+
+	// dumpCall is a Dump call shared by concurrent callers.
+	dumpCall struct {
+		done chan struct{}
+		path string
+		err  error
+	}
+
+	// FunctionServiceCacheOption configures optional behavior of a FunctionServiceCache.
+	FunctionServiceCacheOption func(fsc *FunctionServiceCache)
+
+	// AddFuncOption configures a single AddFunc call.
+	AddFuncOption func(opts *addFuncOptions)
+
+	addFuncOptions struct {
+		cpuLimit *resource.Quantity
 	}
 
 	fscRequest struct {
 		requestType     fscRequestType
 		address         string
 		age             time.Duration
+		namespace       string
+		executor        fv1.ExecutorType
+		oldValue        *FuncSvc
+		newValue        *FuncSvc
+		selector        labels.Selector
+		candidates      []metav1.ObjectMeta
+		function        *metav1.ObjectMeta
+		environment     *metav1.ObjectMeta
+		uid             types.UID
+		pinned          bool
+		event           CacheEvent
 		responseChannel chan *fscResponse
 	}
 
 	fscResponse struct {
-		objects []*FuncSvc
+		objects         []*FuncSvc
+		stats           CacheStats
+		inconsistencies []Inconsistency
+		pinned          bool
 		error
 	}
+
+	// CacheStats holds aggregated information about the cached function services.
+	CacheStats struct {
+		Count        int           // number of cached function services
+		OldestAtime  time.Time     // least recent access time
+		NewestAtime  time.Time     // most recent access time
+		OldestCtime  time.Time     // earliest creation time
+		NewestCtime  time.Time     // latest creation time
+		MeanIdleTime time.Duration // mean time since the last access
+		Pool         PoolCacheStats
+	}
+
+	// poolSvcRecord is a pool cache entry as written by WriteFnSvcCache in json format.
+	poolSvcRecord struct {
+		Key               string `json:"key"`
+		FunctionName      string `json:"functionName"`
+		FunctionNamespace string `json:"functionNamespace"`
+		Address           string `json:"address"`
+		CPUUsage          string `json:"cpuUsage"`
+		CPULimit          string `json:"cpuLimit"`
+		Owner             string `json:"owner,omitempty"`
+		Pinned            bool   `json:"pinned,omitempty"`
+		Priority          int    `json:"priority,omitempty"`
+	}
 )
 
+// Equal reports whether fsvc and other describe the same function service, comparing
+// Function, Environment, Address, KubernetesObjects, Executor and CPULimit. Bookkeeping
+// fields like Name, Owner, Pinned, Priority, Ctime and Atime are ignored.
+func (fsvc *FuncSvc) Equal(other *FuncSvc) bool {
+	if fsvc == nil || other == nil {
+		return fsvc == other
+	}
+	return fsvc.Address == other.Address &&
+		fsvc.Executor == other.Executor &&
+		fsvc.CPULimit.Cmp(other.CPULimit) == 0 &&
+		equality.Semantic.DeepEqual(fsvc.Function, other.Function) &&
+		equality.Semantic.DeepEqual(fsvc.Environment, other.Environment) &&
+		equality.Semantic.DeepEqual(fsvc.KubernetesObjects, other.KubernetesObjects)
+}
+
+// NewFuncSvc returns the function service of function fn in environment env, reachable
+// at address. It returns an ErrorInvalidArgument error if any of them is missing.
+func NewFuncSvc(fn *metav1.ObjectMeta, env *fv1.Environment, address string, executor fv1.ExecutorType) (*FuncSvc, error) {
+	fsvc := &FuncSvc{
+		Function:    fn,
+		Environment: env,
+		Address:     address,
+		Executor:    executor,
+	}
+	err := fsvc.validate()
+	if err != nil {
+		return nil, err
+	}
+	if env == nil {
+		return nil, ferror.MakeError(ferror.ErrorInvalidArgument,
+			fmt.Sprintf("function service of function '%v' has no environment", fn.Name))
+	}
+	return fsvc, nil
+}
+
+// validate checks the fields the cache needs to index fsvc. The environment is
+// not required, since function services of container functions have none.
+func (fsvc *FuncSvc) validate() error {
+	if fsvc.Function == nil {
+		return ferror.MakeError(ferror.ErrorInvalidArgument,
+			fmt.Sprintf("function service '%v' has no function", fsvc.Name))
+	}
+	if len(fsvc.Address) == 0 {
+		return ferror.MakeError(ferror.ErrorInvalidArgument,
+			fmt.Sprintf("function service of function '%v' has no address", fsvc.Function.Name))
+	}
+	return nil
+}
+
+// copyEnvironment replaces the environment of fsvc with a deep copy, so that the
+// cache entry does not alias an environment object mutated by the caller, e.g. one
+// of an informer cache.
+func (fsvc *FuncSvc) copyEnvironment() {
+	if fsvc.Environment != nil {
+		fsvc.Environment = fsvc.Environment.DeepCopy()
+	}
+}
+
+// touch updates the access time of fsvc and returns a copy of it, so that callers
+// do not share the cache entry. It must only be called from the service loop owning fsvc.
+func (fsvc *FuncSvc) touch() *FuncSvc {
+	return fsvc.touchAfter(0)
+}
+
+// touchAfter is like touch, but only updates the access time if it was updated at least
+// interval ago. A negative interval never updates the access time.
+func (fsvc *FuncSvc) touchAfter(interval time.Duration) *FuncSvc {
+	if now := time.Now(); interval >= 0 && now.Sub(fsvc.Atime) >= interval {
+		fsvc.Atime = now
+	}
+	fsvcCopy := *fsvc
+	return &fsvcCopy
+}
+
+// skipTouch reports whether the touch of the entry key can be skipped without a
+// request to the service loop, because access time tracking is disabled or another
+// touch of key was sampled less than the atime interval ago, see WithAtimeSampling.
+func (fsc *FunctionServiceCache) skipTouch(key any) bool {
+	if fsc.atimeInterval == 0 {
+		return false
+	}
+	if fsc.atimeInterval < 0 {
+		return true
+	}
+	v, ok := fsc.lastTouch.Load(key)
+	if !ok {
+		v, _ = fsc.lastTouch.LoadOrStore(key, new(atomic.Int64))
+	}
+	last := v.(*atomic.Int64)
+	now := time.Now().UnixNano()
+	prev := last.Load()
+	if now-prev < int64(fsc.atimeInterval) {
+		return true
+	}
+	// only one of concurrent touches is sampled
+	return !last.CompareAndSwap(prev, now)
+}
+
+// poolCache returns the pool cache, or an ErrorInternal error if the
+// cache was constructed without one.
+func (fsc *FunctionServiceCache) poolCache() (*PoolCache, error) {
+	if fsc.connFunctionCache == nil {
+		return nil, errNoPoolCache
+	}
+	return fsc.connFunctionCache, nil
+}
+
+// loggerKey is the context key of the logger set with ContextWithLogger.
+type loggerKey struct{}
+
+// ContextWithLogger returns a copy of ctx carrying logger, which the cache logs the
+// operations done with ctx to, e.g. a logger with the fields of the originating request.
+func ContextWithLogger(ctx context.Context, logger *zap.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// WithLogger returns the logger of an operation done with ctx: the logger set with
+// ContextWithLogger or the cache logger, with the trace id of ctx if it has one, so
+// that the log lines of the operation can be correlated with the originating request.
+func (fsc *FunctionServiceCache) WithLogger(ctx context.Context) *zap.Logger {
+	logger, ok := ctx.Value(loggerKey{}).(*zap.Logger)
+	if !ok || logger == nil {
+		logger = fsc.logger
+	}
+	return otelUtils.LoggerWithTraceID(ctx, logger)
+}
+
 // IsNotFoundError checks if err is ErrorNotFound.
 func IsNotFoundError(err error) bool {
 	if fe, ok := err.(ferror.Error); ok {
@@ -106,8 +416,157 @@ func IsNameExistError(err error) bool {
 	return false
 }
 
+// WithRequestBufferSize sets the buffer size of the channel serializing cache requests.
+// A buffered channel lets callers enqueue requests without waiting for the service loop,
+// see TryTouchByAddress.
+func WithRequestBufferSize(size int) FunctionServiceCacheOption {
+	return func(fsc *FunctionServiceCache) {
+		fsc.requestChannel = make(chan *fscRequest, size)
+	}
+}
+
+// WithEventBufferSize sets the buffer size of the channel returned by Events.
+func WithEventBufferSize(size int) FunctionServiceCacheOption {
+	return func(fsc *FunctionServiceCache) {
+		fsc.events = make(chan CacheEvent, size)
+	}
+}
+
+// WithAddressNormalization makes the cache canonicalize function service addresses
+// before storing and looking them up, so that equivalent forms of an address such
+// as "HOST:80" and "http://host" refer to the same entry.
+func WithAddressNormalization() FunctionServiceCacheOption {
+	return func(fsc *FunctionServiceCache) {
+		fsc.normalizeAddress = true
+	}
+}
+
+// WithDefaultCPULimit sets the CPU limit AddFunc uses for function services
+// without a CPU limit, see DefaultCPULimit.
+func WithDefaultCPULimit(cpuLimit resource.Quantity) FunctionServiceCacheOption {
+	return func(fsc *FunctionServiceCache) {
+		fsc.defaultCPULimit = cpuLimit
+	}
+}
+
+// WithOwner sets the owner recorded on function services added without one.
+// It defaults to the hostname, i.e. the pod name of the executor replica.
+func WithOwner(owner string) FunctionServiceCacheOption {
+	return func(fsc *FunctionServiceCache) {
+		fsc.owner = owner
+	}
+}
+
+// WithAddressUpdate makes Add replace the cached function service of a function with
+// the added one if its address changed, e.g. after the function moved to another pod,
+// see ReplaceFuncSvc. By default, Add keeps the cached function service.
+func WithAddressUpdate() FunctionServiceCacheOption {
+	return func(fsc *FunctionServiceCache) {
+		fsc.updateAddress = true
+	}
+}
+
+// WithPoolMaxAddresses caps the number of addresses held per function in the pool
+// cache, see WithMaxAddresses and AddFunc.
+func WithPoolMaxAddresses(maxAddresses int, policy AddressCapPolicy) FunctionServiceCacheOption {
+	return func(fsc *FunctionServiceCache) {
+		if fsc.connFunctionCache != nil {
+			WithMaxAddresses(maxAddresses, policy)(fsc.connFunctionCache)
+		}
+	}
+}
+
+// WithDumpFileOptions sets the permissions of the files written by DumpDebugInfo,
+// whether they are compressed and how many of them are kept.
+func WithDumpFileOptions(opts util.DumpFileOptions) FunctionServiceCacheOption {
+	return func(fsc *FunctionServiceCache) {
+		fsc.dumpFileOptions = opts
+	}
+}
+
+// WithConcurrencyLimit makes GetFuncSvc limit the callers holding a function service
+// of a function to its concurrency, until they return the service with MarkAvailable.
+// Callers over the limit wait for a service to be returned, or fail right away with
+// an ErrorTooManyRequests error if failFast is set.
+func WithConcurrencyLimit(failFast bool) FunctionServiceCacheOption {
+	return func(fsc *FunctionServiceCache) {
+		fsc.limiter = newConcurrencyLimiter(failFast)
+	}
+}
+
+// WithGetFuncSvcMaxWait sets how long GetFuncSvc waits for a function service if the
+// context has no deadline, see DefaultGetFuncSvcMaxWait. A maxWait <= 0 waits forever.
+func WithGetFuncSvcMaxWait(maxWait time.Duration) FunctionServiceCacheOption {
+	return func(fsc *FunctionServiceCache) {
+		fsc.getFuncSvcMaxWait = maxWait
+	}
+}
+
+// WithAtimeSampling makes the cache update the access time of a function service at
+// most once per interval, to reduce the contention of read-heavy workloads on the
+// service loop. Touches within interval of the last sampled one return right away,
+// without reporting lookup errors. The access time lags behind by up to interval,
+// so ListOld and the other idle checks may consider a function service idle for
+// up to interval longer than it is, which must be accounted for in the idle ages.
+func WithAtimeSampling(interval time.Duration) FunctionServiceCacheOption {
+	return func(fsc *FunctionServiceCache) {
+		fsc.atimeInterval = interval
+	}
+}
+
+// WithAtimeTrackingDisabled makes the cache never update the access time of function
+// services on lookups and touches, for workloads whose function services are never
+// reaped, e.g. pinned or long-lived ones. The access time stays at the time the
+// function service was added or replaced, so ListOld and the reapers consider it idle
+// since then regardless of its traffic. Function services that must not be reaped
+// should be pinned, see Pin.
+func WithAtimeTrackingDisabled() FunctionServiceCacheOption {
+	return func(fsc *FunctionServiceCache) {
+		fsc.atimeInterval = -1
+	}
+}
+
+// WithTouchRateLimit limits the TOUCH requests of TouchByAddress and TryTouchByAddress
+// to touchesPerSecond, with bursts of up to burst touches, so that a flood of touches
+// e.g. of a misbehaving router cannot starve the other requests of the service loop.
+// Touches over the limit are dropped without reporting lookup errors and counted by
+// the fission_fscache_touches_dropped_total metric. The access time of function services
+// whose touches are dropped lags behind, which may delay their reaping slightly;
+// they are not reaped early.
+func WithTouchRateLimit(touchesPerSecond float64, burst int) FunctionServiceCacheOption {
+	return func(fsc *FunctionServiceCache) {
+		fsc.touchLimiter = rate.NewLimiter(rate.Limit(touchesPerSecond), burst)
+	}
+}
+
+// allowTouch reports whether a TOUCH request is within the touch rate limit,
+// counting the dropped ones, see WithTouchRateLimit.
+func (fsc *FunctionServiceCache) allowTouch() bool {
+	if fsc.touchLimiter == nil || fsc.touchLimiter.Allow() {
+		return true
+	}
+	metrics.FscacheTouchesDropped.Inc()
+	return false
+}
+
+// WithTombstones makes the cache keep the function services it deletes as tombstones
+// for the grace period, so that a function service deleted and added again right
+// away, e.g. of a pod deleted and recreated quickly, is recognized with Tombstoned
+// instead of being specialized again. A function service added again within the grace
+// period resumes its tombstone, keeping its creation time. Tombstones expire after
+// the grace period; a grace period <= 0 disables them.
+func WithTombstones(grace time.Duration) FunctionServiceCacheOption {
+	return func(fsc *FunctionServiceCache) {
+		if grace <= 0 {
+			fsc.tombstones = nil
+			return
+		}
+		fsc.tombstones = cache.MakeCache[tombstoneKey, FuncSvc](grace, 0)
+	}
+}
+
 // MakeFunctionServiceCache starts and returns an instance of FunctionServiceCache.
-func MakeFunctionServiceCache(logger *zap.Logger) *FunctionServiceCache {
+func MakeFunctionServiceCache(logger *zap.Logger, opts ...FunctionServiceCacheOption) *FunctionServiceCache {
 	fsc := &FunctionServiceCache{
 		logger:            logger.Named("function_service_cache"),
 		byFunction:        cache.MakeCache[crd.CacheKeyUR, *FuncSvc](0, 0),
@@ -115,11 +574,29 @@ func MakeFunctionServiceCache(logger *zap.Logger) *FunctionServiceCache {
 		byFunctionUID:     cache.MakeCache[types.UID, metav1.ObjectMeta](0, 0),
 		connFunctionCache: NewPoolCache(logger.Named("conn_function_cache")),
 		requestChannel:    make(chan *fscRequest),
+		events:            make(chan CacheEvent, DefaultEventBufferSize),
+		loading:           make(map[crd.CacheKeyUR]*loadCall),
+		defaultCPULimit:   DefaultCPULimit.DeepCopy(),
+		owner:             defaultOwner(logger),
+		getFuncSvcMaxWait: DefaultGetFuncSvcMaxWait,
+	}
+	for _, opt := range opts {
+		opt(fsc)
 	}
 	go fsc.service()
 	return fsc
 }
 
+// defaultOwner returns the hostname, which is the pod name when running in Kubernetes.
+func defaultOwner(logger *zap.Logger) string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		logger.Warn("error getting hostname for function service owner", zap.Error(err))
+		return ""
+	}
+	return hostname
+}
+
 func (fsc *FunctionServiceCache) service() {
 	for {
 		req := <-fsc.requestChannel
@@ -128,6 +605,27 @@ func (fsc *FunctionServiceCache) service() {
 		case TOUCH:
 			// update atime for this function svc
 			resp.error = fsc._touchByAddress(req.address)
+		case TOUCHBYFUNCTION:
+			resp.error = fsc._touchByFunction(req.function)
+		case GETBYFUNCTION:
+			fsvc, err := fsc.byFunction.Get(crd.CacheKeyURFromMeta(req.function))
+			if err != nil {
+				resp.error = err
+				break
+			}
+			resp.objects = []*FuncSvc{fsvc.touchAfter(fsc.atimeInterval)}
+		case GETBYFUNCTIONUID:
+			m, err := fsc.byFunctionUID.Get(req.uid)
+			if err != nil {
+				resp.error = err
+				break
+			}
+			fsvc, err := fsc.byFunction.Get(crd.CacheKeyURFromMeta(&m))
+			if err != nil {
+				resp.error = err
+				break
+			}
+			resp.objects = []*FuncSvc{fsvc.touchAfter(fsc.atimeInterval)}
 		case LISTOLD:
 			// get svcs idle for > req.age
 			fscs := fsc.byFunctionUID.Copy()
@@ -135,13 +633,24 @@ func (fsc *FunctionServiceCache) service() {
 			for _, m := range fscs {
 				fsvc, err := fsc.byFunction.Get(crd.CacheKeyURFromMeta(&m))
 				if err != nil {
-					fsc.logger.Error("error while getting service", zap.String("error", err.Error()))
-					return
+					// the caches are inconsistent, skip the entry instead of stopping the service loop
+					fsc.logger.Error("function service indexed by function UID is missing in the cache",
+						zap.String("function", m.Name), zap.String("namespace", m.Namespace),
+						zap.String("uid", string(m.UID)), zap.Error(err))
+					continue
 				}
-				if time.Since(fsvc.Atime) > req.age {
-					funcObjects = append(funcObjects, fsvc)
+				if len(req.namespace) > 0 && fsvc.Function.Namespace != req.namespace {
+					continue
+				}
+				if len(req.executor) > 0 && fsvc.Executor != req.executor {
+					continue
+				}
+				if !fsvc.Pinned && time.Since(fsvc.Atime) > req.age {
+					fsvcCopy := *fsvc
+					funcObjects = append(funcObjects, &fsvcCopy)
 				}
 			}
+			sortReapOrder(funcObjects)
 			resp.objects = funcObjects
 		case LOG:
 			fsc.logger.Info("dumping function service cache")
@@ -154,15 +663,85 @@ func (fsc *FunctionServiceCache) service() {
 			}
 			fsc.logger.Info("function service cache", zap.Int("item_count", len(funcCopy)), zap.Strings("cache", info))
 		case LISTOLDPOOL:
+			if fsc.connFunctionCache == nil {
+				resp.error = errNoPoolCache
+				break
+			}
 			fscs := fsc.connFunctionCache.ListAvailableValue()
 			funcObjects := make([]*FuncSvc, 0)
 			for _, fsvc := range fscs {
-				if time.Since(fsvc.Atime) > req.age {
+				if !fsvc.Pinned && time.Since(fsvc.Atime) > req.age {
 					funcObjects = append(funcObjects, fsvc)
 				}
 			}
+			sortReapOrder(funcObjects)
 			resp.objects = funcObjects
-
+		case REPLACE:
+			resp.error = fsc._replaceFuncSvc(req.oldValue, req.newValue)
+		case EVENT:
+			fsc.emit(req.event)
+		case PIN:
+			resp.error = fsc._pin(req.function, req.pinned)
+		case ISPINNED:
+			resp.pinned = fsc._isPinned(req.function)
+		case LISTOLDPAGE:
+			// get svcs idle for > req.age among the candidates
+			funcObjects := make([]*FuncSvc, 0)
+			for i := range req.candidates {
+				fsvc, err := fsc.byFunction.Get(crd.CacheKeyURFromMeta(&req.candidates[i]))
+				if err != nil {
+					// deleted since the candidates were listed
+					continue
+				}
+				if !fsvc.Pinned && time.Since(fsvc.Atime) > req.age {
+					fsvcCopy := *fsvc
+					funcObjects = append(funcObjects, &fsvcCopy)
+				}
+			}
+			resp.objects = funcObjects
+		case STATS:
+			resp.stats = fsc._stats()
+		case LISTBYSELECTOR:
+			funcObjects := make([]*FuncSvc, 0)
+			for _, fsvc := range fsc.byFunction.Copy() {
+				if req.selector.Matches(labels.Set(fsvc.Function.Labels)) {
+					fsvcCopy := *fsvc
+					funcObjects = append(funcObjects, &fsvcCopy)
+				}
+			}
+			resp.objects = funcObjects
+		case LISTBYEXECUTOR:
+			funcObjects := make([]*FuncSvc, 0)
+			for _, fsvc := range fsc.byFunction.Copy() {
+				if fsvc.Executor == req.executor {
+					fsvcCopy := *fsvc
+					funcObjects = append(funcObjects, &fsvcCopy)
+				}
+			}
+			resp.objects = funcObjects
+		case LISTBYENVIRONMENT:
+			funcObjects := make([]*FuncSvc, 0)
+			for _, fsvc := range fsc.byFunction.Copy() {
+				if fsvc.Environment != nil && fsvc.Environment.Name == req.environment.Name &&
+					fsvc.Environment.Namespace == req.environment.Namespace {
+					fsvcCopy := *fsvc
+					funcObjects = append(funcObjects, &fsvcCopy)
+				}
+			}
+			resp.objects = funcObjects
+		case VERIFY:
+			resp.inconsistencies = fsc._verify()
+		case GETKUBERNETESOBJECTS:
+			// unlike GETBYFUNCTION, the access time is kept, inspecting a function
+			// service must not keep it from being reaped
+			fsvc, err := fsc.byFunction.Get(crd.CacheKeyURFromMeta(req.function))
+			if err != nil {
+				resp.error = err
+				break
+			}
+			fsvcCopy := *fsvc
+			fsvcCopy.KubernetesObjects = append([]apiv1.ObjectReference(nil), fsvc.KubernetesObjects...)
+			resp.objects = []*FuncSvc{&fsvcCopy}
 		}
 		req.responseChannel <- resp
 	}
@@ -170,124 +749,633 @@ func (fsc *FunctionServiceCache) service() {
 
 // DumpDebugInfo => dump function service cache data to temporary directory of executor pod.
 func (fsc *FunctionServiceCache) DumpDebugInfo(ctx context.Context) error {
+	_, err := fsc.Dump(ctx)
+	return err
+}
+
+// Dump writes the function service cache to a file in the temporary directory of the
+// executor pod and returns its path. Concurrent callers share a single dump, i.e. one
+// walk of the cache and one file, and all get its path. The shared dump is cancelled
+// with the context of the caller which started it.
+func (fsc *FunctionServiceCache) Dump(ctx context.Context) (string, error) {
+	fsc.dumpMu.Lock()
+	call := fsc.dumping
+	leader := call == nil
+	if leader {
+		call = &dumpCall{done: make(chan struct{})}
+		fsc.dumping = call
+	}
+	fsc.dumpMu.Unlock()
+
+	if leader {
+		call.path, call.err = fsc.dump(ctx)
+
+		fsc.dumpMu.Lock()
+		fsc.dumping = nil
+		fsc.dumpMu.Unlock()
+		close(call.done)
+	} else {
+		select {
+		case <-call.done:
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}
+	return call.path, call.err
+}
+
+func (fsc *FunctionServiceCache) dump(ctx context.Context) (string, error) {
 	fsc.logger.Info("dumping function service")
 
-	file, err := util.CreateDumpFile(fsc.logger)
+	_, err := fsc.poolCache()
 	if err != nil {
-		fsc.logger.Error("error while creating file/dir", zap.String("error", err.Error()))
-		return err
+		return "", err
 	}
-	defer file.Close()
 
-	err = fsc.connFunctionCache.LogFnSvcGroup(ctx, file)
-	if err != nil {
-		fsc.logger.Error("error while logging function service group", zap.String("error", err.Error()))
+	// the dump is written atomically, a failed or cancelled dump leaves no file behind
+	var written int64
+	path, err := util.WriteDumpFile(fsc.logger, fsc.dumpFileOptions, func(file io.Writer) error {
+		w := &countingWriter{w: file}
+		err := fsc.WriteFnSvcCache(ctx, w, DumpFormatText)
+		written = w.n
 		return err
+	})
+	metrics.FscacheDumps.Inc()
+	metrics.FscacheDumpBytes.Observe(float64(written))
+	if err != nil {
+		fsc.logger.Error("error while dumping function service cache", zap.String("error", err.Error()))
+		return "", err
 	}
 
-	fsc.logger.Info("dumped function service")
-	return nil
-}
-
-// GetByFunction gets a function service from cache using function key.
-func (fsc *FunctionServiceCache) GetByFunction(m *metav1.ObjectMeta) (*FuncSvc, error) {
-	key := crd.CacheKeyURFromMeta(m)
+	fsc.logger.Info("dumped function service", zap.String("file", path))
 
-	fsvc, err := fsc.byFunction.Get(key)
+	err = util.RotateDumpFiles(fsc.logger, fsc.dumpFileOptions)
 	if err != nil {
-		return nil, err
+		fsc.logger.Error("error while removing old dump files", zap.Error(err))
 	}
+	return path, nil
+}
 
-	// update atime
-	fsvc.Atime = time.Now()
-
-	fsvcCopy := *fsvc
-	return &fsvcCopy, nil
+// countingWriter counts the bytes written to w.
+type countingWriter struct {
+	w io.Writer
+	n int64
 }
 
-// GetFuncSvc gets a function service from pool cache using function key and returns number of active instances of function pod
-func (fsc *FunctionServiceCache) GetFuncSvc(ctx context.Context, m *metav1.ObjectMeta, requestsPerPod int, concurrency int) (*FuncSvc, error) {
-	key := crd.CacheKeyURGFromMeta(m)
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
 
-	fsvc, err := fsc.connFunctionCache.GetSvcValue(ctx, key, requestsPerPod, concurrency)
+// WriteFnSvcCache writes the pool cache contents to w in the given format,
+// DumpFormatText (the DumpDebugInfo format) or DumpFormatJSON.
+func (fsc *FunctionServiceCache) WriteFnSvcCache(ctx context.Context, w io.Writer, format string) error {
+	pool, err := fsc.poolCache()
 	if err != nil {
-		fsc.logger.Info("Not found in Cache")
-		return nil, err
+		return err
+	}
+	switch format {
+	case DumpFormatText, "":
+		return pool.LogFnSvcGroup(ctx, w)
+	case DumpFormatJSON:
+		records, err := poolSvcRecords(ctx, pool)
+		if err != nil {
+			return err
+		}
+		return json.NewEncoder(w).Encode(records)
+	default:
+		return ferror.MakeError(ferror.ErrorInvalidArgument, fmt.Sprintf("invalid dump format: %v", format))
 	}
-
-	// update atime
-	fsvc.Atime = time.Now()
-
-	fsvcCopy := *fsvc
-	return &fsvcCopy, nil
 }
 
-// GetByFunctionUID gets a function service from cache using function UUID.
-func (fsc *FunctionServiceCache) GetByFunctionUID(uid types.UID) (*FuncSvc, error) {
-	m, err := fsc.byFunctionUID.Get(uid)
+// poolSvcRecords returns a record of each function service address of pool.
+func poolSvcRecords(ctx context.Context, pool *PoolCache) ([]poolSvcRecord, error) {
+	records := make([]poolSvcRecord, 0)
+	err := pool.ForEachSvc(ctx, func(key string, addr string, fsvc *FuncSvc, cpuUsage, cpuLimit resource.Quantity) {
+		record := poolSvcRecord{
+			Key:      key,
+			Address:  addr,
+			CPUUsage: cpuUsage.String(),
+			CPULimit: cpuLimit.String(),
+		}
+		if fsvc != nil && fsvc.Function != nil {
+			record.FunctionName = fsvc.Function.Name
+			record.FunctionNamespace = fsvc.Function.Namespace
+		}
+		if fsvc != nil {
+			record.Owner = fsvc.Owner
+			record.Pinned = fsvc.Pinned
+			record.Priority = fsvc.Priority
+		}
+		records = append(records, record)
+	})
 	if err != nil {
 		return nil, err
 	}
+	return records, nil
+}
 
-	fsvc, err := fsc.byFunction.Get(crd.CacheKeyURFromMeta(&m))
+// ForEachPoolService walks the pool cache and calls visitor for each function service address,
+// allowing other components to inspect the pool without dumping it to disk.
+// The visitor must not call back into the cache.
+func (fsc *FunctionServiceCache) ForEachPoolService(ctx context.Context, visitor PoolServiceVisitor) error {
+	pool, err := fsc.poolCache()
 	if err != nil {
+		return err
+	}
+	return pool.ForEachSvc(ctx, visitor)
+}
+
+// GetByFunction gets a copy of a function service from cache using function key
+// and updates its access time.
+func (fsc *FunctionServiceCache) GetByFunction(m *metav1.ObjectMeta) (*FuncSvc, error) {
+	responseChannel := make(chan *fscResponse)
+	fsc.requestChannel <- &fscRequest{
+		requestType:     GETBYFUNCTION,
+		function:        m,
+		responseChannel: responseChannel,
+	}
+	resp := <-responseChannel
+	if resp.error != nil {
+		return nil, resp.error
+	}
+	return resp.objects[0], nil
+}
+
+// GetByFunctionWithContext is the context aware variant of GetByFunction.
+// It stops waiting for the service loop with the error of ctx once ctx is done.
+// The lookup is recorded as a span in the request trace and the function
+// service attributes are attached to it on a cache hit.
+func (fsc *FunctionServiceCache) GetByFunctionWithContext(ctx context.Context, m *metav1.ObjectMeta) (*FuncSvc, error) {
+	if err := ctx.Err(); err != nil {
 		return nil, err
 	}
 
-	// update atime
-	fsvc.Atime = time.Now()
+	_, span := otel.Tracer("fscache").Start(ctx, "FunctionServiceCache/GetByFunction")
+	defer span.End()
 
-	fsvcCopy := *fsvc
-	return &fsvcCopy, nil
+	// buffered, so that the service loop does not block on an abandoned request
+	responseChannel := make(chan *fscResponse, 1)
+	req := &fscRequest{
+		requestType:     GETBYFUNCTION,
+		function:        m,
+		responseChannel: responseChannel,
+	}
+	var resp *fscResponse
+	select {
+	case fsc.requestChannel <- req:
+	case <-ctx.Done():
+		span.RecordError(ctx.Err())
+		return nil, ctx.Err()
+	}
+	select {
+	case resp = <-responseChannel:
+	case <-ctx.Done():
+		span.RecordError(ctx.Err())
+		return nil, ctx.Err()
+	}
+	if resp.error != nil {
+		span.RecordError(resp.error)
+		return nil, resp.error
+	}
+	fsvc := resp.objects[0]
+	span.SetAttributes(GetAttributesForFuncSvc(fsvc)...)
+	return fsvc, nil
 }
 
-// AddFunc adds a function service to pool cache.
-func (fsc *FunctionServiceCache) AddFunc(ctx context.Context, fsvc FuncSvc, requestsPerPod, svcsRetain int) {
-	fsc.connFunctionCache.SetSvcValue(ctx, crd.CacheKeyURGFromMeta(fsvc.Function), fsvc.Address, &fsvc, fsvc.CPULimit, requestsPerPod, svcsRetain)
-	now := time.Now()
-	fsvc.Ctime = now
-	fsvc.Atime = now
-}
+// GetOrLoad gets a function service from cache using function key. On a cache miss
+// loader is called to create the function service, which is added to the cache.
+// Concurrent callers missing the same function share a single loader call and
+// all receive a copy of its result.
+func (fsc *FunctionServiceCache) GetOrLoad(ctx context.Context, m *metav1.ObjectMeta, loader FuncSvcLoader) (*FuncSvc, error) {
+	fsvc, err := fsc.GetByFunction(m)
+	if err == nil || !IsNotFoundError(err) {
+		return fsvc, err
+	}
 
-func (fsc *FunctionServiceCache) MarkFuncDeleted(key crd.CacheKeyURG) {
-	fsc.connFunctionCache.MarkFuncDeleted(key)
-}
+	key := crd.CacheKeyURFromMeta(m)
+	fsc.loadMu.Lock()
+	call, ok := fsc.loading[key]
+	if !ok {
+		// the entry may have been added by a call finished in the meantime
+		fsvc, err = fsc.GetByFunction(m)
+		if err == nil || !IsNotFoundError(err) {
+			fsc.loadMu.Unlock()
+			return fsvc, err
+		}
+		call = &loadCall{done: make(chan struct{})}
+		fsc.loading[key] = call
+	}
+	fsc.loadMu.Unlock()
 
-// SetCPUUtilizaton updates/sets CPUutilization in the pool cache
-func (fsc *FunctionServiceCache) SetCPUUtilizaton(key crd.CacheKeyURG, svcHost string, cpuUsage resource.Quantity) {
-	fsc.connFunctionCache.SetCPUUtilization(key, svcHost, cpuUsage)
-}
+	if !ok {
+		call.fsvc, call.err = fsc.load(ctx, m, loader)
 
-// MarkAvailable marks the value at key [function][address] as available.
-func (fsc *FunctionServiceCache) MarkAvailable(key crd.CacheKeyURG, svcHost string) {
-	fsc.connFunctionCache.MarkAvailable(key, svcHost)
-}
+		fsc.loadMu.Lock()
+		delete(fsc.loading, key)
+		fsc.loadMu.Unlock()
+		close(call.done)
+	} else {
+		select {
+		case <-call.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
 
-func (fsc *FunctionServiceCache) MarkSpecializationFailure(key crd.CacheKeyURG) {
-	fsc.connFunctionCache.MarkSpecializationFailure(key)
+	if call.err != nil {
+		return nil, call.err
+	}
+	fsvcCopy := *call.fsvc
+	return &fsvcCopy, nil
 }
 
-// Add adds a function service to cache if it does not exist already.
-func (fsc *FunctionServiceCache) Add(fsvc FuncSvc) (*FuncSvc, error) {
-	existing, err := fsc.byFunction.Set(crd.CacheKeyURFromMeta(fsvc.Function), &fsvc)
+func (fsc *FunctionServiceCache) load(ctx context.Context, m *metav1.ObjectMeta, loader FuncSvcLoader) (*FuncSvc, error) {
+	fsvc, err := loader(ctx)
 	if err != nil {
-		if IsNameExistError(err) {
-			err2 := fsc.TouchByAddress(existing.Address)
-			if err2 != nil {
-				return nil, err2
-			}
-			fCopy := *existing
-			return &fCopy, nil
-		}
 		return nil, err
 	}
+	if fsvc.Function == nil {
+		fsvc.Function = m
+	}
+
+	existing, err := fsc.Add(*fsvc)
+	if err != nil {
+		return nil, errors.Wrap(err, "error caching loaded function service")
+	}
+	if existing != nil {
+		return existing, nil
+	}
+
 	now := time.Now()
 	fsvc.Ctime = now
 	fsvc.Atime = now
+	return fsvc, nil
+}
 
-	// Add to byAddress cache. Ignore NameExists errors
-	// because of multiple-specialization. See issue #331.
-	_, err = fsc.byAddress.Set(fsvc.Address, *fsvc.Function)
+// GetFuncSvc gets a function service from pool cache using function key and returns number of active instances of function pod
+// With WithConcurrencyLimit, at most concurrency callers hold a function service of the
+// function until they return it with MarkAvailable.
+// If ctx has no deadline, GetFuncSvc waits at most the max wait of the cache for a function
+// service and then returns an ErrorRequestTimeout error, see WithGetFuncSvcMaxWait.
+func (fsc *FunctionServiceCache) GetFuncSvc(ctx context.Context, m *metav1.ObjectMeta, requestsPerPod int, concurrency int) (*FuncSvc, error) {
+	pool, err := fsc.poolCache()
+	if err != nil {
+		return nil, err
+	}
+	key := crd.CacheKeyURGFromMeta(m)
+
+	// never wait forever for a function service of a saturated pool
+	maxWaitCtx := ctx
+	if _, ok := ctx.Deadline(); !ok && fsc.getFuncSvcMaxWait > 0 {
+		var cancel context.CancelFunc
+		maxWaitCtx, cancel = context.WithTimeout(ctx, fsc.getFuncSvcMaxWait)
+		defer cancel()
+	}
+	timedOut := func(err error) error {
+		if ctx.Err() == nil && errors.Is(maxWaitCtx.Err(), context.DeadlineExceeded) {
+			return ferror.MakeError(ferror.ErrorRequestTimeout,
+				fmt.Sprintf("no function service of function '%s' available within %v", key, fsc.getFuncSvcMaxWait))
+		}
+		return err
+	}
+
+	if fsc.limiter != nil {
+		err = fsc.limiter.acquire(maxWaitCtx, key, concurrency)
+		if err != nil {
+			return nil, timedOut(err)
+		}
+	}
+
+	fsvc, err := pool.GetSvcValue(maxWaitCtx, key, requestsPerPod, concurrency)
+	if err != nil {
+		if fsc.limiter != nil {
+			fsc.limiter.cancel(key)
+		}
+		fsc.WithLogger(ctx).Info("function service not found in cache",
+			zap.String("function", key.String()), zap.Error(err))
+		return nil, timedOut(err)
+	}
+	if fsc.limiter != nil {
+		fsc.limiter.assign(key, fsvc.Address)
+	}
+
+	// the pool cache has updated the atime and returned a copy
+	return fsvc, nil
+}
+
+// GetByFunctionUID gets a copy of a function service from cache using function UUID
+// and updates its access time.
+func (fsc *FunctionServiceCache) GetByFunctionUID(uid types.UID) (*FuncSvc, error) {
+	responseChannel := make(chan *fscResponse)
+	fsc.requestChannel <- &fscRequest{
+		requestType:     GETBYFUNCTIONUID,
+		uid:             uid,
+		responseChannel: responseChannel,
+	}
+	resp := <-responseChannel
+	if resp.error != nil {
+		return nil, resp.error
+	}
+	return resp.objects[0], nil
+}
+
+// GetKubernetesObjects returns a copy of the Kubernetes objects, such as the deployment,
+// service and HPA, of the cached function service of a function. Unlike GetByFunction,
+// it does not update the access time of the function service.
+func (fsc *FunctionServiceCache) GetKubernetesObjects(m *metav1.ObjectMeta) ([]apiv1.ObjectReference, error) {
+	responseChannel := make(chan *fscResponse)
+	fsc.requestChannel <- &fscRequest{
+		requestType:     GETKUBERNETESOBJECTS,
+		function:        m,
+		responseChannel: responseChannel,
+	}
+	resp := <-responseChannel
+	if resp.error != nil {
+		return nil, resp.error
+	}
+	return resp.objects[0].KubernetesObjects, nil
+}
+
+// WithCPULimitOverride makes AddFunc track the function service in the pool cache
+// with cpuLimit instead of the CPU limit of the function service, e.g. a burst limit
+// of a single request. The CPU limit of the function service is kept as is.
+func WithCPULimitOverride(cpuLimit resource.Quantity) AddFuncOption {
+	return func(opts *addFuncOptions) {
+		opts.cpuLimit = &cpuLimit
+	}
+}
+
+// AddFunc adds a function service to pool cache. A function service without CPU
+// limit gets the default CPU limit of the cache. If the function holds the maximum
+// number of addresses set with WithPoolMaxAddresses, the function service is refused
+// with an ErrorTooManyRequests error, or an idle function service of the function is
+// evicted and returned. The caller must clean up the Kubernetes objects of the evicted
+// function service, which is also published as a CacheEventEvicted event.
+func (fsc *FunctionServiceCache) AddFunc(ctx context.Context, fsvc FuncSvc, requestsPerPod, svcsRetain int, opts ...AddFuncOption) (*FuncSvc, error) {
+	var options addFuncOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+	logger := fsc.WithLogger(ctx)
+	pool, err := fsc.poolCache()
+	if err == nil {
+		err = fsc.preparePoolFuncSvc(logger, &fsvc)
+	}
+	if err != nil {
+		logger.Error("error adding function service", zap.String("address", fsvc.Address), zap.Error(err))
+		return nil, err
+	}
+	now := time.Now()
+	fsvc.Ctime = now
+	fsvc.Atime = now
+	cpuLimit := fsvc.CPULimit
+	if options.cpuLimit != nil {
+		cpuLimit = options.cpuLimit.DeepCopy()
+	}
+	evicted, err := pool.SetSvcValue(ctx, crd.CacheKeyURGFromMeta(fsvc.Function), fsvc.Address, &fsvc, cpuLimit, requestsPerPod, svcsRetain)
+	if err != nil {
+		logger.Error("error adding function service", zap.String("address", fsvc.Address), zap.Error(err))
+		return nil, err
+	}
+	fsc.poolEvicted(evicted)
+	return evicted, nil
+}
+
+// poolEvicted releases and publishes function services evicted from the pool cache.
+func (fsc *FunctionServiceCache) poolEvicted(evicted ...*FuncSvc) {
+	for _, fsvc := range evicted {
+		if fsvc == nil || fsvc.Function == nil {
+			continue
+		}
+		if fsc.limiter != nil {
+			fsc.limiter.releaseAddress(crd.CacheKeyURGFromMeta(fsvc.Function), fsvc.Address)
+		}
+		fsc.publish(CacheEventEvicted, fsvc)
+	}
+}
+
+// AddFuncs adds a batch of function services to pool cache in a single request, e.g.
+// when scaling up a warm pool. Unlike AddFunc, the Ctime and Atime of each entry are
+// kept, and only set to the current time if zero. The returned errors are indexed like
+// fsvcs; invalid entries and entries refused at the address cap are skipped. The
+// function services evicted at the address cap are returned like with AddFunc.
+func (fsc *FunctionServiceCache) AddFuncs(ctx context.Context, fsvcs []FuncSvc, requestsPerPod, svcsRetain int) ([]*FuncSvc, []error) {
+	errs := make([]error, len(fsvcs))
+	pool, err := fsc.poolCache()
+	if err != nil {
+		for i := range errs {
+			errs[i] = err
+		}
+		return nil, errs
+	}
+
+	logger := fsc.WithLogger(ctx)
+	now := time.Now()
+	values := make([]PoolSvcValue, 0, len(fsvcs))
+	indexes := make([]int, 0, len(fsvcs)) // of values in fsvcs
+	for i := range fsvcs {
+		fsvc := fsvcs[i]
+		errs[i] = fsc.preparePoolFuncSvc(logger, &fsvc)
+		if errs[i] != nil {
+			continue
+		}
+		if fsvc.Ctime.IsZero() {
+			fsvc.Ctime = now
+		}
+		if fsvc.Atime.IsZero() {
+			fsvc.Atime = now
+		}
+		values = append(values, PoolSvcValue{
+			Function: crd.CacheKeyURGFromMeta(fsvc.Function),
+			Address:  fsvc.Address,
+			Value:    &fsvc,
+			CPULimit: fsvc.CPULimit,
+		})
+		indexes = append(indexes, i)
+	}
+	var evicted []*FuncSvc
+	if len(values) > 0 {
+		var setErrs []error
+		evicted, setErrs = pool.SetSvcValues(ctx, values, requestsPerPod, svcsRetain)
+		for i, err := range setErrs {
+			errs[indexes[i]] = err
+		}
+		fsc.poolEvicted(evicted...)
+	}
+	return evicted, errs
+}
+
+// preparePoolFuncSvc validates fsvc, copies its environment and sets the defaults of
+// the cache for a missing CPU limit and owner, logging to logger.
+func (fsc *FunctionServiceCache) preparePoolFuncSvc(logger *zap.Logger, fsvc *FuncSvc) error {
+	err := fsvc.validate()
+	if err != nil {
+		return err
+	}
+	fsvc.copyEnvironment()
+	if fsvc.CPULimit.IsZero() {
+		logger.Info("function service has no CPU limit, using default",
+			zap.String("function", fsvc.Function.Name),
+			zap.String("address", fsvc.Address),
+			zap.String("cpu_limit", fsc.defaultCPULimit.String()))
+		fsvc.CPULimit = fsc.defaultCPULimit.DeepCopy()
+	}
+	if len(fsvc.Owner) == 0 {
+		fsvc.Owner = fsc.owner
+	}
+	return nil
+}
+
+func (fsc *FunctionServiceCache) MarkFuncDeleted(key crd.CacheKeyURG) {
+	pool, err := fsc.poolCache()
+	if err != nil {
+		fsc.logger.Error("error marking function deleted", zap.String("function", key.String()), zap.Error(err))
+		return
+	}
+	pool.MarkFuncDeleted(key)
+}
+
+// SetCPUUtilizaton updates/sets CPUutilization in the pool cache
+func (fsc *FunctionServiceCache) SetCPUUtilizaton(key crd.CacheKeyURG, svcHost string, cpuUsage resource.Quantity) {
+	fsc.SetCPUUtilization(key, svcHost, cpuUsage)
+}
+
+// WaitingRequests returns the number of requests queued in the pool cache waiting for
+// a function service of the function. It can be used as a backlog based scaling signal.
+func (fsc *FunctionServiceCache) WaitingRequests(key crd.CacheKeyURG) int {
+	pool, err := fsc.poolCache()
+	if err != nil {
+		return 0
+	}
+	return pool.WaitingRequests(key)
+}
+
+// ActiveRequests returns the number of requests served by the pool cache
+// function services of the function.
+func (fsc *FunctionServiceCache) ActiveRequests(key crd.CacheKeyURG) int {
+	pool, err := fsc.poolCache()
+	if err != nil {
+		return 0
+	}
+	return pool.ActiveRequests(key)
+}
+
+// TotalActiveRequests returns the number of requests served by all pool cache
+// function services, e.g. for a global concurrency limit.
+func (fsc *FunctionServiceCache) TotalActiveRequests() int {
+	pool, err := fsc.poolCache()
+	if err != nil {
+		return 0
+	}
+	return pool.TotalActiveRequests()
+}
+
+// ListOverCPULimit returns the pool cache function services whose current CPU usage
+// exceeds their CPU limit, as hotspots to scale out.
+func (fsc *FunctionServiceCache) ListOverCPULimit() []*FuncSvc {
+	pool, err := fsc.poolCache()
+	if err != nil {
+		return nil
+	}
+	return pool.ListOverCPULimit()
+}
+
+// ListStarved returns the keys of the pool cache functions with requests waiting for
+// a function service but no idle one, see PoolCache.ListStarved.
+func (fsc *FunctionServiceCache) ListStarved() []string {
+	pool, err := fsc.poolCache()
+	if err != nil {
+		return nil
+	}
+	return pool.ListStarved()
+}
+
+// TouchPool updates the access time of the pool cache function service at key
+// [function][address]. TouchByAddress only touches the function services of the
+// other executors, so pool function services in use must be touched with TouchPool
+// not to be listed by ListOldForPool. It returns an ErrorNotFound error if the pool
+// cache has no such function service.
+func (fsc *FunctionServiceCache) TouchPool(key crd.CacheKeyURG, svcHost string) error {
+	pool, err := fsc.poolCache()
+	if err != nil {
+		return err
+	}
+	return pool.TouchValue(key, svcHost)
+}
+
+// MarkAvailable marks the value at key [function][address] as available.
+func (fsc *FunctionServiceCache) MarkAvailable(key crd.CacheKeyURG, svcHost string) {
+	pool, err := fsc.poolCache()
+	if err != nil {
+		fsc.logger.Error("error marking function service available", zap.String("address", svcHost), zap.Error(err))
+		return
+	}
+	pool.MarkAvailable(key, svcHost)
+	if fsc.limiter != nil {
+		fsc.limiter.release(key, svcHost)
+	}
+}
+
+func (fsc *FunctionServiceCache) MarkSpecializationFailure(key crd.CacheKeyURG) {
+	pool, err := fsc.poolCache()
+	if err != nil {
+		fsc.logger.Error("error marking specialization failure", zap.String("function", key.String()), zap.Error(err))
+		return
+	}
+	pool.MarkSpecializationFailure(key)
+}
+
+// Add adds a function service to cache if it does not exist already. The cached entry
+// holds a copy of the environment of fsvc. If the function has a cached function service,
+// it is touched and a copy of it is returned, unless the cache was made WithAddressUpdate
+// and fsvc has another address: the cached function service is then replaced by fsvc,
+// keeping its creation time and pin, and a copy of the updated entry is returned.
+// Function services of a function with the same name but another UID, which was
+// deleted and recreated, are deleted.
+// It returns an ErrorInvalidArgument error if fsvc has no function or address.
+func (fsc *FunctionServiceCache) Add(fsvc FuncSvc) (*FuncSvc, error) {
+	err := fsvc.validate()
+	if err != nil {
+		return nil, err
+	}
+	fsvc.copyEnvironment()
+	if len(fsvc.Owner) == 0 {
+		fsvc.Owner = fsc.owner
+	}
+	existing, err := fsc.byFunction.Set(crd.CacheKeyURFromMeta(fsvc.Function), &fsvc)
+	if err != nil {
+		if IsNameExistError(err) {
+			if fsc.updateAddress && fsc.addressKey(existing.Address) != fsc.addressKey(fsvc.Address) {
+				fsvc.Pinned = fsvc.Pinned || existing.Pinned
+				err2 := fsc.ReplaceFuncSvc(existing, &fsvc)
+				if err2 != nil {
+					return nil, err2
+				}
+				return fsc.GetByFunction(fsvc.Function)
+			}
+			err2 := fsc.TouchByAddress(existing.Address)
+			if err2 != nil {
+				return nil, err2
+			}
+			fCopy := *existing
+			return &fCopy, nil
+		}
+		return nil, err
+	}
+	now := time.Now()
+	fsvc.Ctime = now
+	fsvc.Atime = now
+	if tombstone, ok := fsc.takeTombstone(fsvc.Function, fsvc.Address); ok {
+		fsvc.Ctime = tombstone.Ctime
+	}
+	// before indexing the address, which a recreated function may share with its stale entry
+	fsc.deleteRecreated(fsvc.Function)
+
+	// Add to byAddress cache. Ignore NameExists errors
+	// because of multiple-specialization. See issue #331.
+	_, err = fsc.byAddress.Set(fsc.addressKey(fsvc.Address), *fsvc.Function)
 	if err != nil {
 		if IsNameExistError(err) {
 			err = nil
@@ -309,11 +1397,51 @@ func (fsc *FunctionServiceCache) Add(fsvc FuncSvc) (*FuncSvc, error) {
 		return nil, err
 	}
 
+	fsc.publish(CacheEventAdded, &fsvc)
 	return nil, nil
 }
 
-// TouchByAddress makes a TOUCH request to given address.
+// deleteRecreated deletes the function services of the function of m cached with another
+// UID, i.e. before the function was deleted and recreated with the same name. Their UID
+// would otherwise keep resolving to a dead function service.
+func (fsc *FunctionServiceCache) deleteRecreated(m *metav1.ObjectMeta) {
+	for uid, stale := range fsc.byFunctionUID.Copy() {
+		if uid == m.UID || stale.Namespace != m.Namespace || stale.Name != m.Name {
+			continue
+		}
+		fsvc, err := fsc.byFunction.Get(crd.CacheKeyURFromMeta(&stale))
+		if err != nil {
+			// only the mapping of the stale UID is left
+			_ = fsc.byFunctionUID.Delete(uid)
+			continue
+		}
+		fsc.logger.Info("deleting function service of recreated function",
+			zap.String("function", m.Name), zap.String("namespace", m.Namespace),
+			zap.String("stale_uid", string(uid)), zap.String("uid", string(m.UID)))
+		// errors are logged by deleteEntry
+		_ = fsc.deleteEntry(fsvc, CacheEventDeleted)
+	}
+}
+
+// Preload adds entries to the cache, e.g. to warm up the cache from services
+// discovered at startup. Entries of functions already in the cache are skipped.
+// The returned slice holds the error of each entry at the same index, nil if
+// the entry was added or skipped.
+func (fsc *FunctionServiceCache) Preload(entries []FuncSvc) []error {
+	errs := make([]error, len(entries))
+	for i, fsvc := range entries {
+		_, errs[i] = fsc.Add(fsvc)
+	}
+	return errs
+}
+
+// TouchByAddress makes a TOUCH request to given address. With atime sampling,
+// the request is skipped if the address was touched recently, see WithAtimeSampling.
+// Touches over the touch rate limit are dropped, see WithTouchRateLimit.
 func (fsc *FunctionServiceCache) TouchByAddress(address string) error {
+	if fsc.skipTouch(fsc.addressKey(address)) || !fsc.allowTouch() {
+		return nil
+	}
 	responseChannel := make(chan *fscResponse)
 	fsc.requestChannel <- &fscRequest{
 		requestType:     TOUCH,
@@ -324,8 +1452,34 @@ func (fsc *FunctionServiceCache) TouchByAddress(address string) error {
 	return resp.error
 }
 
+// TryTouchByAddress makes a TOUCH request to given address without blocking.
+// The touch is dropped if the request channel is full or the touch is over the touch
+// rate limit (see WithTouchRateLimit), in which case false is returned.
+// Unlike TouchByAddress it does not wait for the touch to complete and does not report
+// lookup errors, so the atime of the function service may be stale under heavy load.
+// This is only useful with a buffered request channel, see WithRequestBufferSize.
+func (fsc *FunctionServiceCache) TryTouchByAddress(address string) bool {
+	if fsc.skipTouch(fsc.addressKey(address)) {
+		return true
+	}
+	if !fsc.allowTouch() {
+		return false
+	}
+	req := &fscRequest{
+		requestType:     TOUCH,
+		address:         address,
+		responseChannel: make(chan *fscResponse, 1),
+	}
+	select {
+	case fsc.requestChannel <- req:
+		return true
+	default:
+		return false
+	}
+}
+
 func (fsc *FunctionServiceCache) _touchByAddress(address string) error {
-	m, err := fsc.byAddress.Get(address)
+	m, err := fsc.byAddress.Get(fsc.addressKey(address))
 	if err != nil {
 		return err
 	}
@@ -334,28 +1488,192 @@ func (fsc *FunctionServiceCache) _touchByAddress(address string) error {
 		return err
 	}
 	fsvc.Atime = time.Now()
+	fsc.emit(CacheEvent{Type: CacheEventTouched, Function: *fsvc.Function, Address: fsvc.Address, Time: fsvc.Atime})
+	return nil
+}
+
+// TouchByFunction updates the access time of the function service of the function
+// m, regardless of the address that served it. It returns an ErrorNotFound error if
+// no function service of m is cached.
+func (fsc *FunctionServiceCache) TouchByFunction(m *metav1.ObjectMeta) error {
+	if fsc.skipTouch(crd.CacheKeyURFromMeta(m)) {
+		return nil
+	}
+	responseChannel := make(chan *fscResponse)
+	fsc.requestChannel <- &fscRequest{
+		requestType:     TOUCHBYFUNCTION,
+		function:        m,
+		responseChannel: responseChannel,
+	}
+	resp := <-responseChannel
+	return resp.error
+}
+
+func (fsc *FunctionServiceCache) _touchByFunction(m *metav1.ObjectMeta) error {
+	fsvc, err := fsc.byFunction.Get(crd.CacheKeyURFromMeta(m))
+	if err != nil {
+		return err
+	}
+	fsvc.Atime = time.Now()
+	fsc.emit(CacheEvent{Type: CacheEventTouched, Function: *fsvc.Function, Address: fsvc.Address, Time: fsvc.Atime})
 	return nil
 }
 
-// DeleteEntry deletes a function service from cache.
-func (fsc *FunctionServiceCache) DeleteEntry(fsvc *FuncSvc) {
+// Events returns a channel of the mutations of the cache: added, touched,
+// deleted and evicted function services, in the order they are applied.
+// Delivery is best-effort: if the channel buffer is full, events are dropped
+// rather than blocking the cache, and counted by the fission_fscache_events_dropped_total
+// metric. All callers share the same channel, so there should be a single consumer.
+func (fsc *FunctionServiceCache) Events() <-chan CacheEvent {
+	return fsc.events
+}
+
+// publish emits event from the service loop, so that it is ordered
+// with the events emitted by serialized requests.
+func (fsc *FunctionServiceCache) publish(eventType CacheEventType, fsvc *FuncSvc) {
+	responseChannel := make(chan *fscResponse)
+	fsc.requestChannel <- &fscRequest{
+		requestType: EVENT,
+		event: CacheEvent{
+			Type:     eventType,
+			Function: *fsvc.Function,
+			Address:  fsvc.Address,
+			Time:     time.Now(),
+		},
+		responseChannel: responseChannel,
+	}
+	<-responseChannel
+}
+
+// emit sends event without blocking; it must only be called from the service loop.
+func (fsc *FunctionServiceCache) emit(event CacheEvent) {
+	select {
+	case fsc.events <- event:
+	default:
+		metrics.FscacheEventsDropped.Inc()
+	}
+}
+
+// ReplaceFuncSvc atomically points the function of old at the new function service,
+// e.g. to switch to a new address during a rolling update. GetByFunction keeps
+// returning a value during the swap; lookups by the old address fail afterwards.
+// The creation time of old is kept while the access time is reset.
+func (fsc *FunctionServiceCache) ReplaceFuncSvc(old, new *FuncSvc) error {
+	responseChannel := make(chan *fscResponse)
+	fsc.requestChannel <- &fscRequest{
+		requestType:     REPLACE,
+		oldValue:        old,
+		newValue:        new,
+		responseChannel: responseChannel,
+	}
+	resp := <-responseChannel
+	return resp.error
+}
+
+func (fsc *FunctionServiceCache) _replaceFuncSvc(old, new *FuncSvc) error {
+	key := crd.CacheKeyURFromMeta(old.Function)
+	if key != crd.CacheKeyURFromMeta(new.Function) {
+		return ferror.MakeError(ferror.ErrorInvalidArgument,
+			fmt.Sprintf("function service of function '%v' cannot be replaced by one of function '%v'", old.Function.Name, new.Function.Name))
+	}
+
+	existing, err := fsc.byFunction.Get(key)
+	if err != nil {
+		return err
+	}
+
+	// register the new address before switching, so that it resolves
+	// as soon as GetByFunction returns the new service
+	_, err = fsc.byAddress.Set(fsc.addressKey(new.Address), *new.Function)
+	if err != nil && !IsNameExistError(err) {
+		return errors.Wrap(err, "error caching fsvc")
+	}
+
+	fsvc := *new
+	fsvc.Ctime = existing.Ctime
+	fsvc.Atime = time.Now()
+	_, err = fsc.byFunction.Update(key, &fsvc)
+	if err != nil {
+		return err
+	}
+
+	if fsc.addressKey(old.Address) != fsc.addressKey(new.Address) {
+		err = fsc.byAddress.Delete(fsc.addressKey(old.Address))
+		if err != nil {
+			return errors.Wrap(err, "error removing old address of fsvc")
+		}
+	}
+	return nil
+}
+
+// addressKey returns the key of address in the byAddress cache.
+func (fsc *FunctionServiceCache) addressKey(address string) string {
+	if !fsc.normalizeAddress {
+		return address
+	}
+	return normalizeAddress(address)
+}
+
+// normalizeAddress canonicalizes address to lowercase host[:port]. The http(s)
+// scheme is replaced by its port and the default http port is stripped, e.g.
+// "http://Host:80/" becomes "host" and "https://host" becomes "host:443".
+func normalizeAddress(address string) string {
+	addr := strings.ToLower(strings.TrimSpace(address))
+	schemePort := "80"
+	if strings.HasPrefix(addr, "https://") {
+		schemePort = "443"
+	}
+	addr = strings.TrimPrefix(strings.TrimPrefix(addr, "http://"), "https://")
+	addr = strings.TrimSuffix(addr, "/")
+
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		// no port given
+		host, port = strings.Trim(addr, "[]"), schemePort
+	}
+	host = strings.TrimSuffix(host, ".")
+	if ip := net.ParseIP(host); ip != nil {
+		// canonical form of IPv6 addresses, e.g. 2001:db8::1 for 2001:0db8:0:0::1
+		host = ip.String()
+	}
+	if port == "80" {
+		if strings.Contains(host, ":") {
+			return "[" + host + "]"
+		}
+		return host
+	}
+	return net.JoinHostPort(host, port)
+}
+
+// DeleteEntry deletes a function service from cache. All indexes of the function
+// service are deleted even if some fail, the returned error aggregates the failures.
+func (fsc *FunctionServiceCache) DeleteEntry(fsvc *FuncSvc) error {
+	return fsc.deleteEntry(fsvc, CacheEventDeleted)
+}
+
+func (fsc *FunctionServiceCache) deleteEntry(fsvc *FuncSvc, eventType CacheEventType) error {
 	msg := "error deleting function service"
+	result := &multierror.Error{}
+
 	err := fsc.byFunction.Delete(crd.CacheKeyURFromMeta(fsvc.Function))
+	deleted := err == nil
 	if err != nil {
 		fsc.logger.Error(
 			msg,
 			zap.String("function", fsvc.Function.Name),
 			zap.Error(err),
 		)
+		result = multierror.Append(result, errors.Wrap(err, "error deleting function service by function"))
 	}
 
-	err = fsc.byAddress.Delete(fsvc.Address)
+	err = fsc.byAddress.Delete(fsc.addressKey(fsvc.Address))
 	if err != nil {
 		fsc.logger.Error(
 			msg,
 			zap.String("function", fsvc.Function.Name),
 			zap.Error(err),
 		)
+		result = multierror.Append(result, errors.Wrap(err, "error deleting function service by address"))
 	}
 
 	err = fsc.byFunctionUID.Delete(fsvc.Function.UID)
@@ -365,16 +1683,113 @@ func (fsc *FunctionServiceCache) DeleteEntry(fsvc *FuncSvc) {
 			zap.String("function", fsvc.Function.Name),
 			zap.Error(err),
 		)
+		result = multierror.Append(result, errors.Wrap(err, "error deleting function service by function UID"))
+	}
+
+	fsc.lastTouch.Delete(crd.CacheKeyURFromMeta(fsvc.Function))
+	fsc.lastTouch.Delete(fsc.addressKey(fsvc.Address))
+	if deleted && fsc.tombstones != nil {
+		key := fsc.tombstoneKey(fsvc.Function, fsvc.Address)
+		// a tombstone left by an earlier deletion is replaced
+		_ = fsc.tombstones.Delete(key)
+		_, _ = fsc.tombstones.Set(key, *fsvc)
 	}
 
 	metrics.FuncRunningSummary.WithLabelValues(fsvc.Function.Name, fsvc.Function.Namespace).Observe(fsvc.Atime.Sub(fsvc.Ctime).Seconds())
+	if deleted {
+		fsc.publish(eventType, fsvc)
+	}
+	return result.ErrorOrNil()
+}
+
+// Tombstoned returns a copy of the function service of function m at address if it
+// was deleted less than the grace period ago, see WithTombstones. It returns false
+// if the function service was not deleted recently or tombstones are disabled.
+func (fsc *FunctionServiceCache) Tombstoned(m *metav1.ObjectMeta, address string) (*FuncSvc, bool) {
+	if fsc.tombstones == nil {
+		return nil, false
+	}
+	fsvc, err := fsc.tombstones.Get(fsc.tombstoneKey(m, address))
+	if err != nil {
+		return nil, false
+	}
+	return &fsvc, true
+}
+
+// takeTombstone removes and returns the tombstone of the function service of
+// function m at address, if there is an unexpired one.
+func (fsc *FunctionServiceCache) takeTombstone(m *metav1.ObjectMeta, address string) (*FuncSvc, bool) {
+	fsvc, ok := fsc.Tombstoned(m, address)
+	if ok {
+		_ = fsc.tombstones.Delete(fsc.tombstoneKey(m, address))
+	}
+	return fsvc, ok
+}
+
+func (fsc *FunctionServiceCache) tombstoneKey(m *metav1.ObjectMeta, address string) tombstoneKey {
+	return tombstoneKey{function: crd.CacheKeyURFromMeta(m), address: fsc.addressKey(address)}
+}
+
+// DeleteByAddress deletes the function service reachable at address from the cache,
+// including its pool cache entry, or the pool cache function services at address,
+// e.g. of poolmgr functions. It returns a not found error if no function service
+// is cached at address.
+func (fsc *FunctionServiceCache) DeleteByAddress(address string) error {
+	pool, err := fsc.poolCache()
+	if err != nil {
+		return err
+	}
+	m, err := fsc.byAddress.Get(fsc.addressKey(address))
+	if err != nil {
+		// addresses of poolmgr function services are only held by the pool cache
+		deleted, poolErr := pool.DeleteByAddress(context.Background(), address)
+		if poolErr != nil {
+			return err
+		}
+		if fsc.limiter != nil {
+			for _, fsvc := range deleted {
+				if fsvc.Function == nil {
+					continue
+				}
+				fsc.limiter.releaseAddress(crd.CacheKeyURGFromMeta(fsvc.Function), fsvc.Address)
+			}
+		}
+		return nil
+	}
+	fsvc, err := fsc.byFunction.Get(crd.CacheKeyURFromMeta(&m))
+	if err != nil {
+		return err
+	}
+
+	// the pool cache entry and the address are deleted even if the entry is
+	// not deleted completely, which only leaves dangling indexes behind
+	result := &multierror.Error{}
+	if err := fsc.DeleteEntry(fsvc); err != nil {
+		result = multierror.Append(result, err)
+	}
+	// with multiple specializations, address may differ from the address of the cached service
+	err = fsc.byAddress.Delete(fsc.addressKey(address))
+	if err != nil {
+		result = multierror.Append(result, errors.Wrap(err, "error deleting function service address"))
+	}
+	fsc.lastTouch.Delete(fsc.addressKey(address))
+	if err := pool.DeleteValue(context.Background(), crd.CacheKeyURGFromMeta(&m), address); err != nil {
+		result = multierror.Append(result, err)
+	}
+	return result.ErrorOrNil()
 }
 
 // DeleteFunctionSvc deletes a function service at key composed of [function][address].
 func (fsc *FunctionServiceCache) DeleteFunctionSvc(ctx context.Context, fsvc *FuncSvc) {
-	err := fsc.connFunctionCache.DeleteValue(ctx, crd.CacheKeyURGFromMeta(fsvc.Function), fsvc.Address)
+	if fsc.limiter != nil {
+		fsc.limiter.releaseAddress(crd.CacheKeyURGFromMeta(fsvc.Function), fsvc.Address)
+	}
+	pool, err := fsc.poolCache()
+	if err == nil {
+		err = pool.DeleteValue(ctx, crd.CacheKeyURGFromMeta(fsvc.Function), fsvc.Address)
+	}
 	if err != nil {
-		fsc.logger.Error(
+		fsc.WithLogger(ctx).Error(
 			"error deleting function service",
 			zap.String("function", fsvc.Function.Name),
 			zap.String("address", fsvc.Address),
@@ -384,23 +1799,46 @@ func (fsc *FunctionServiceCache) DeleteFunctionSvc(ctx context.Context, fsvc *Fu
 }
 
 func (fsc *FunctionServiceCache) SetCPUUtilization(key crd.CacheKeyURG, svcHost string, cpuUsage resource.Quantity) {
-	fsc.connFunctionCache.SetCPUUtilization(key, svcHost, cpuUsage)
+	pool, err := fsc.poolCache()
+	if err != nil {
+		fsc.logger.Error("error setting CPU utilization", zap.String("address", svcHost), zap.Error(err))
+		return
+	}
+	pool.SetCPUUtilization(key, svcHost, cpuUsage)
+}
+
+// SetCPUUtilizations updates the CPU usage of many function services in the pool
+// cache at once, see PoolCache.SetCPUUtilizations.
+func (fsc *FunctionServiceCache) SetCPUUtilizations(readings []CPUReading) []error {
+	pool, err := fsc.poolCache()
+	if err != nil {
+		errs := make([]error, len(readings))
+		for i := range errs {
+			errs[i] = err
+		}
+		return errs
+	}
+	return pool.SetCPUUtilizations(readings)
 }
 
 // DeleteOld deletes aged function service entries from cache.
 func (fsc *FunctionServiceCache) DeleteOld(fsvc *FuncSvc, minAge time.Duration) (bool, error) {
-	if time.Since(fsvc.Atime) < minAge {
+	if time.Since(fsvc.Atime) < minAge || (minAge > 0 && fsc.isPinned(fsvc)) {
 		return false, nil
 	}
 
-	fsc.DeleteEntry(fsvc)
+	eventType := CacheEventDeleted
+	if minAge > 0 {
+		eventType = CacheEventEvicted
+	}
+	err := fsc.deleteEntry(fsvc, eventType)
 
-	return true, nil
+	return true, err
 }
 
 // DeleteOldPoolCache deletes aged function service entries from pool cache.
 func (fsc *FunctionServiceCache) DeleteOldPoolCache(ctx context.Context, fsvc *FuncSvc, minAge time.Duration) (bool, error) {
-	if time.Since(fsvc.Atime) < minAge {
+	if time.Since(fsvc.Atime) < minAge || (minAge > 0 && fsc.isPinned(fsvc)) {
 		return false, nil
 	}
 
@@ -409,7 +1847,70 @@ func (fsc *FunctionServiceCache) DeleteOldPoolCache(ctx context.Context, fsvc *F
 	return true, nil
 }
 
-// ListOld returns a list of aged function services in cache.
+// Pin sets whether the cached function service of fn is pinned, and with a pool
+// cache, the pool cache function services of fn, see PoolCache.Pin. Pinned function
+// services are skipped by ListOld, ListOldPaged, DeleteOld and the pool cache
+// equivalents, so they are never reaped for being idle, nor evicted at the address
+// cap of the pool cache. DeleteOld with a zero minAge still deletes pinned function
+// services, e.g. of deleted functions.
+func (fsc *FunctionServiceCache) Pin(fn *metav1.ObjectMeta, pinned bool) error {
+	responseChannel := make(chan *fscResponse)
+	fsc.requestChannel <- &fscRequest{
+		requestType:     PIN,
+		function:        fn,
+		pinned:          pinned,
+		responseChannel: responseChannel,
+	}
+	resp := <-responseChannel
+	return resp.error
+}
+
+// isPinned checks if fsvc, or the cached function services of its function, are pinned.
+// fsvc may be a copy listed before the function service was pinned.
+func (fsc *FunctionServiceCache) isPinned(fsvc *FuncSvc) bool {
+	if fsvc.Pinned {
+		return true
+	}
+	responseChannel := make(chan *fscResponse)
+	fsc.requestChannel <- &fscRequest{
+		requestType:     ISPINNED,
+		function:        fsvc.Function,
+		responseChannel: responseChannel,
+	}
+	resp := <-responseChannel
+	return resp.pinned
+}
+
+// _pin pins or unpins the cached function service of fn and the pool cache function
+// services of fn, and returns an error if there are neither.
+// It must only be called from the service loop.
+func (fsc *FunctionServiceCache) _pin(fn *metav1.ObjectMeta, pinned bool) error {
+	fsvc, err := fsc.byFunction.Get(crd.CacheKeyURFromMeta(fn))
+	if err == nil {
+		fsvc.Pinned = pinned
+	}
+	if fsc.connFunctionCache == nil {
+		return err
+	}
+	if poolErr := fsc.connFunctionCache.Pin(fn.UID, pinned); poolErr == nil {
+		return nil
+	}
+	return err
+}
+
+// _isPinned checks if the function services of fn are pinned.
+// It must only be called from the service loop.
+func (fsc *FunctionServiceCache) _isPinned(fn *metav1.ObjectMeta) bool {
+	if fsvc, err := fsc.byFunction.Get(crd.CacheKeyURFromMeta(fn)); err == nil && fsvc.Pinned {
+		return true
+	}
+	return fsc.connFunctionCache != nil && fsc.connFunctionCache.IsPinned(fn.UID)
+}
+
+// ListOld returns a list of aged function services in cache, in reaping order:
+// by ascending Priority, and by ascending Atime for the same priority. Reapers
+// stopping early, e.g. once enough resources are freed, keep the function services
+// of higher priority.
 func (fsc *FunctionServiceCache) ListOld(age time.Duration) ([]*FuncSvc, error) {
 	responseChannel := make(chan *fscResponse)
 	fsc.requestChannel <- &fscRequest{
@@ -421,7 +1922,232 @@ func (fsc *FunctionServiceCache) ListOld(age time.Duration) ([]*FuncSvc, error)
 	return resp.objects, resp.error
 }
 
-// ListOldForPool returns a list of aged function services in cache for pooling.
+// ListOldPaged passes the function services idle for longer than age to cb in
+// batches of at most pageSize entries. The cache is scanned page by page, so other
+// cache requests are served between pages and cb may process each batch before
+// the next is listed. Listing stops early if cb returns false.
+func (fsc *FunctionServiceCache) ListOldPaged(age time.Duration, pageSize int, cb func([]*FuncSvc) bool) error {
+	if pageSize <= 0 {
+		return ferror.MakeError(ferror.ErrorInvalidArgument, fmt.Sprintf("invalid page size %d", pageSize))
+	}
+
+	metas := fsc.byFunctionUID.Copy()
+	uids := make([]types.UID, 0, len(metas))
+	for uid := range metas {
+		uids = append(uids, uid)
+	}
+	sort.Slice(uids, func(i, j int) bool { return uids[i] < uids[j] })
+
+	batch := make([]*FuncSvc, 0, pageSize)
+	for start := 0; start < len(uids); start += pageSize {
+		end := start + pageSize
+		if end > len(uids) {
+			end = len(uids)
+		}
+		candidates := make([]metav1.ObjectMeta, 0, end-start)
+		for _, uid := range uids[start:end] {
+			candidates = append(candidates, metas[uid])
+		}
+
+		responseChannel := make(chan *fscResponse)
+		fsc.requestChannel <- &fscRequest{
+			requestType:     LISTOLDPAGE,
+			age:             age,
+			candidates:      candidates,
+			responseChannel: responseChannel,
+		}
+		resp := <-responseChannel
+		if resp.error != nil {
+			return resp.error
+		}
+
+		batch = append(batch, resp.objects...)
+		for len(batch) >= pageSize {
+			if !cb(batch[:pageSize:pageSize]) {
+				return nil
+			}
+			batch = batch[pageSize:]
+		}
+	}
+
+	if len(batch) > 0 {
+		cb(batch)
+	}
+	return nil
+}
+
+// ListOldInNamespace returns a list of aged function services in cache
+// whose function belongs to the given namespace, in the order of ListOld.
+func (fsc *FunctionServiceCache) ListOldInNamespace(age time.Duration, namespace string) ([]*FuncSvc, error) {
+	responseChannel := make(chan *fscResponse)
+	fsc.requestChannel <- &fscRequest{
+		requestType:     LISTOLD,
+		age:             age,
+		namespace:       namespace,
+		responseChannel: responseChannel,
+	}
+	resp := <-responseChannel
+	return resp.objects, resp.error
+}
+
+// ListOldByExecutor returns a list of aged function services in cache
+// created by the executor type execType, so that each executor can reap
+// its function services on its own schedule. They are listed in the order of ListOld.
+func (fsc *FunctionServiceCache) ListOldByExecutor(age time.Duration, execType fv1.ExecutorType) ([]*FuncSvc, error) {
+	responseChannel := make(chan *fscResponse)
+	fsc.requestChannel <- &fscRequest{
+		requestType:     LISTOLD,
+		age:             age,
+		executor:        execType,
+		responseChannel: responseChannel,
+	}
+	resp := <-responseChannel
+	return resp.objects, resp.error
+}
+
+// sortReapOrder sorts function services in the order they are reaped: by ascending
+// priority, then by ascending atime.
+func sortReapOrder(fsvcs []*FuncSvc) {
+	sort.SliceStable(fsvcs, func(i, j int) bool {
+		if fsvcs[i].Priority != fsvcs[j].Priority {
+			return fsvcs[i].Priority < fsvcs[j].Priority
+		}
+		return fsvcs[i].Atime.Before(fsvcs[j].Atime)
+	})
+}
+
+// ListByExecutor returns copies of the cached function services created by
+// the executor type execType. Function services of the pool cache are not
+// included, see ListOldForPool.
+func (fsc *FunctionServiceCache) ListByExecutor(execType fv1.ExecutorType) []*FuncSvc {
+	responseChannel := make(chan *fscResponse)
+	fsc.requestChannel <- &fscRequest{
+		requestType:     LISTBYEXECUTOR,
+		executor:        execType,
+		responseChannel: responseChannel,
+	}
+	resp := <-responseChannel
+	return resp.objects
+}
+
+// ListByEnvironment returns copies of the cached function services of functions
+// running in the environment env, matched by name and namespace, e.g. to evict them
+// when env is updated or deleted. Function services of container functions have
+// no environment and are never returned.
+func (fsc *FunctionServiceCache) ListByEnvironment(env *metav1.ObjectMeta) []*FuncSvc {
+	responseChannel := make(chan *fscResponse)
+	fsc.requestChannel <- &fscRequest{
+		requestType:     LISTBYENVIRONMENT,
+		environment:     env,
+		responseChannel: responseChannel,
+	}
+	resp := <-responseChannel
+	return resp.objects
+}
+
+// DeleteByEnvironment deletes the cached function services of functions running in
+// the environment env, see ListByEnvironment, and returns the deleted ones so that
+// their Kubernetes objects can be cleaned up. Function services are deleted even if
+// the deletion of others fails, the returned error aggregates the failures.
+func (fsc *FunctionServiceCache) DeleteByEnvironment(env *metav1.ObjectMeta) ([]*FuncSvc, error) {
+	result := &multierror.Error{}
+	deleted := make([]*FuncSvc, 0)
+	for _, fsvc := range fsc.ListByEnvironment(env) {
+		if err := fsc.DeleteEntry(fsvc); err != nil {
+			result = multierror.Append(result, err)
+			continue
+		}
+		deleted = append(deleted, fsvc)
+	}
+	return deleted, result.ErrorOrNil()
+}
+
+// DeleteCreatedBefore deletes the cached function services created before t, e.g. to
+// flush the entries created before a configuration change at a known time, and returns
+// the deleted ones so that their Kubernetes objects can be cleaned up. Unlike DeleteOld,
+// it compares the creation time, not the access time, and deletes pinned function
+// services too. Pool cache entries are not deleted. Function services are deleted even
+// if the deletion of others fails, the returned error aggregates the failures.
+func (fsc *FunctionServiceCache) DeleteCreatedBefore(t time.Time) ([]*FuncSvc, error) {
+	fsvcs, err := fsc.ListBySelector(nil)
+	if err != nil {
+		return nil, err
+	}
+	result := &multierror.Error{}
+	deleted := make([]*FuncSvc, 0)
+	for _, fsvc := range fsvcs {
+		if !fsvc.Ctime.Before(t) {
+			continue
+		}
+		if err := fsc.DeleteEntry(fsvc); err != nil {
+			result = multierror.Append(result, err)
+			continue
+		}
+		deleted = append(deleted, fsvc)
+	}
+	return deleted, result.ErrorOrNil()
+}
+
+// ListBySelector returns copies of the cached function services whose function
+// labels match selector. A nil selector matches all function services.
+func (fsc *FunctionServiceCache) ListBySelector(selector labels.Selector) ([]*FuncSvc, error) {
+	if selector == nil {
+		selector = labels.Everything()
+	}
+	responseChannel := make(chan *fscResponse)
+	fsc.requestChannel <- &fscRequest{
+		requestType:     LISTBYSELECTOR,
+		selector:        selector,
+		responseChannel: responseChannel,
+	}
+	resp := <-responseChannel
+	return resp.objects, resp.error
+}
+
+// Stats returns aggregated information about the cached function services and
+// the pool cache, e.g. for capacity planning.
+func (fsc *FunctionServiceCache) Stats() CacheStats {
+	responseChannel := make(chan *fscResponse)
+	fsc.requestChannel <- &fscRequest{
+		requestType:     STATS,
+		responseChannel: responseChannel,
+	}
+	resp := <-responseChannel
+	stats := resp.stats
+	if pool, err := fsc.poolCache(); err == nil {
+		stats.Pool = pool.Stats()
+	}
+	return stats
+}
+
+func (fsc *FunctionServiceCache) _stats() CacheStats {
+	var stats CacheStats
+	var idle time.Duration
+	now := time.Now()
+	for _, fsvc := range fsc.byFunction.Copy() {
+		if stats.Count == 0 || fsvc.Atime.Before(stats.OldestAtime) {
+			stats.OldestAtime = fsvc.Atime
+		}
+		if stats.Count == 0 || fsvc.Atime.After(stats.NewestAtime) {
+			stats.NewestAtime = fsvc.Atime
+		}
+		if stats.Count == 0 || fsvc.Ctime.Before(stats.OldestCtime) {
+			stats.OldestCtime = fsvc.Ctime
+		}
+		if stats.Count == 0 || fsvc.Ctime.After(stats.NewestCtime) {
+			stats.NewestCtime = fsvc.Ctime
+		}
+		idle += now.Sub(fsvc.Atime)
+		stats.Count++
+	}
+	if stats.Count > 0 {
+		stats.MeanIdleTime = idle / time.Duration(stats.Count)
+	}
+	return stats
+}
+
+// ListOldForPool returns a list of aged function services in cache for pooling,
+// in the order of ListOld.
 func (fsc *FunctionServiceCache) ListOldForPool(age time.Duration) ([]*FuncSvc, error) {
 	responseChannel := make(chan *fscResponse)
 	fsc.requestChannel <- &fscRequest{
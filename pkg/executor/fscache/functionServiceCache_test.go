@@ -1,20 +1,45 @@
 package fscache
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
 	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
 	apiv1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
 
 	fv1 "github.com/fission/fission/pkg/apis/core/v1"
 	"github.com/fission/fission/pkg/crd"
+	ferror "github.com/fission/fission/pkg/error"
+	"github.com/fission/fission/pkg/executor/metrics"
+	"github.com/fission/fission/pkg/executor/util"
 )
 
 func panicIf(err error) {
@@ -191,3 +216,2711 @@ func TestFunctionServiceNewCache(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, 0, len(vals))
 }
+
+func TestTouchPool(t *testing.T) {
+	logger, err := zap.NewDevelopment()
+	panicIf(err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	fsc := MakeFunctionServiceCache(logger)
+	fn := &metav1.ObjectMeta{Name: "foo", UID: "1212"}
+	key := crd.CacheKeyURGFromMeta(fn)
+	for _, addr := range []string{"used", "idle"} {
+		fsc.AddFunc(ctx, FuncSvc{Function: fn, Address: addr, CPULimit: resource.MustParse("5m")}, 10, 0)
+		fsc.MarkAvailable(key, addr)
+	}
+	// the visitor runs in the pool cache service loop
+	require.NoError(t, fsc.ForEachPoolService(ctx, func(key string, addr string, fsvc *FuncSvc, cpuUsage, cpuLimit resource.Quantity) {
+		fsvc.Atime = time.Now().Add(-time.Hour)
+	}))
+
+	addrs := func() []string {
+		vals, err := fsc.ListOldForPool(time.Minute)
+		require.NoError(t, err)
+		addrs := make([]string, 0, len(vals))
+		for _, fsvc := range vals {
+			addrs = append(addrs, fsvc.Address)
+		}
+		return addrs
+	}
+	require.ElementsMatch(t, []string{"used", "idle"}, addrs())
+
+	// touching by address does not touch pool function services
+	require.True(t, IsNotFoundError(fsc.TouchByAddress("used")))
+	require.ElementsMatch(t, []string{"used", "idle"}, addrs())
+
+	require.NoError(t, fsc.TouchPool(key, "used"))
+	require.ElementsMatch(t, []string{"idle"}, addrs())
+
+	require.True(t, IsNotFoundError(fsc.TouchPool(key, "missing")))
+	require.True(t, IsNotFoundError(fsc.TouchPool(crd.CacheKeyURG{UID: "missing"}, "used")))
+
+	fsc.connFunctionCache = nil
+	require.Error(t, fsc.TouchPool(key, "used"))
+}
+
+func TestOperationLogger(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	fsc := MakeFunctionServiceCache(zap.New(core))
+
+	ctx, span := sdktrace.NewTracerProvider().Tracer("test").Start(context.Background(), "request")
+	defer span.End()
+	traceID := span.SpanContext().TraceID().String()
+
+	// takeEntries takes the entries logged so far with message
+	takeEntries := func(message string) []observer.LoggedEntry {
+		entries := make([]observer.LoggedEntry, 0)
+		for _, entry := range logs.TakeAll() {
+			if entry.Message == message {
+				entries = append(entries, entry)
+			}
+		}
+		return entries
+	}
+	requireTraceID := func(t *testing.T, message string) {
+		t.Helper()
+		entries := takeEntries(message)
+		require.Len(t, entries, 1)
+		require.Equal(t, traceID, entries[0].ContextMap()["trace_id"])
+	}
+
+	fn := &metav1.ObjectMeta{Name: "foo", UID: "1212"}
+	_, err := fsc.GetFuncSvc(ctx, fn, 1, 1)
+	require.True(t, IsNotFoundError(err))
+	requireTraceID(t, "function service not found in cache")
+
+	fsc.AddFunc(ctx, FuncSvc{Function: fn, Address: "xxx"}, 1, 0)
+	requireTraceID(t, "function service has no CPU limit, using default")
+	fsc.AddFunc(ctx, FuncSvc{Function: fn}, 1, 0)
+	requireTraceID(t, "error adding function service")
+
+	// the logger of the context is used instead of the cache logger
+	fsc.connFunctionCache = nil
+	fsc.DeleteFunctionSvc(ContextWithLogger(ctx, zap.New(core).With(zap.String("request_id", "r-1"))), &FuncSvc{Function: fn, Address: "xxx"})
+	entries := takeEntries("error deleting function service")
+	require.Len(t, entries, 1)
+	require.Equal(t, "r-1", entries[0].ContextMap()["request_id"])
+	require.Equal(t, traceID, entries[0].ContextMap()["trace_id"])
+
+	// no trace id without span
+	fsc.DeleteFunctionSvc(context.Background(), &FuncSvc{Function: fn, Address: "xxx"})
+	entries = takeEntries("error deleting function service")
+	require.Len(t, entries, 1)
+	require.NotContains(t, entries[0].ContextMap(), "trace_id")
+}
+
+func TestGetByFunctionWithContext(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	prevTP := otel.GetTracerProvider()
+	otel.SetTracerProvider(tp)
+	defer otel.SetTracerProvider(prevTP)
+
+	logger, err := zap.NewDevelopment()
+	panicIf(err)
+
+	fsc := MakeFunctionServiceCache(logger)
+	require.NotNil(t, fsc)
+
+	fsvc := FuncSvc{
+		Function: &metav1.ObjectMeta{
+			Name:      "foo",
+			Namespace: "bar",
+			UID:       "1212",
+		},
+		Environment: &fv1.Environment{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "foo-env",
+				Namespace: "bar",
+				UID:       "2323",
+			},
+		},
+		Address: "xxx",
+	}
+	_, err = fsc.Add(fsvc)
+	require.NoError(t, err)
+
+	f, err := fsc.GetByFunctionWithContext(context.Background(), fsvc.Function)
+	require.NoError(t, err)
+	require.Equal(t, fsvc.Address, f.Address)
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 1)
+	require.Equal(t, "FunctionServiceCache/GetByFunction", spans[0].Name)
+
+	attrs := make(map[attribute.Key]string)
+	for _, kv := range spans[0].Attributes {
+		attrs[kv.Key] = kv.Value.AsString()
+	}
+	require.Equal(t, "foo", attrs["function-name"])
+	require.Equal(t, "bar", attrs["function-namespace"])
+	require.Equal(t, "foo-env", attrs["environment-name"])
+	require.Equal(t, "xxx", attrs["address"])
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = fsc.GetByFunctionWithContext(ctx, fsvc.Function)
+	require.ErrorIs(t, err, context.Canceled)
+
+	t.Run("busy service loop", func(t *testing.T) {
+		// keep the service loop busy with a request whose response is not read yet
+		blocked := make(chan *fscResponse)
+		fsc.requestChannel <- &fscRequest{
+			requestType:     TOUCH,
+			address:         fsvc.Address,
+			responseChannel: blocked,
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		_, err := fsc.GetByFunctionWithContext(ctx, fsvc.Function)
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+
+		// the service loop carries on once it is unblocked
+		<-blocked
+		f, err := fsc.GetByFunctionWithContext(context.Background(), fsvc.Function)
+		require.NoError(t, err)
+		require.Equal(t, fsvc.Address, f.Address)
+	})
+}
+
+func TestListOldInNamespace(t *testing.T) {
+	logger, err := zap.NewDevelopment()
+	panicIf(err)
+
+	fsc := MakeFunctionServiceCache(logger)
+	require.NotNil(t, fsc)
+
+	for i, fn := range []metav1.ObjectMeta{
+		{Name: "foo", Namespace: "tenant-a", UID: "1"},
+		{Name: "bar", Namespace: "tenant-a", UID: "2"},
+		{Name: "baz", Namespace: "tenant-b", UID: "3"},
+	} {
+		fn := fn
+		_, err = fsc.Add(FuncSvc{
+			Function: &fn,
+			Address:  fmt.Sprintf("addr-%d", i),
+		})
+		require.NoError(t, err)
+	}
+
+	vals, err := fsc.ListOldInNamespace(0, "tenant-a")
+	require.NoError(t, err)
+	require.Len(t, vals, 2)
+	for _, fsvc := range vals {
+		require.Equal(t, "tenant-a", fsvc.Function.Namespace)
+	}
+
+	vals, err = fsc.ListOldInNamespace(0, "tenant-b")
+	require.NoError(t, err)
+	require.Len(t, vals, 1)
+	require.Equal(t, "baz", vals[0].Function.Name)
+
+	vals, err = fsc.ListOldInNamespace(0, "tenant-c")
+	require.NoError(t, err)
+	require.NotNil(t, vals)
+	require.Empty(t, vals)
+
+	vals, err = fsc.ListOldInNamespace(time.Hour, "tenant-a")
+	require.NoError(t, err)
+	require.Empty(t, vals)
+
+	vals, err = fsc.ListOld(0)
+	require.NoError(t, err)
+	require.Len(t, vals, 3)
+}
+
+func TestTryTouchByAddress(t *testing.T) {
+	logger, err := zap.NewDevelopment()
+	panicIf(err)
+
+	fsc := MakeFunctionServiceCache(logger, WithRequestBufferSize(16))
+	fsvc := FuncSvc{
+		Function: &metav1.ObjectMeta{Name: "foo", UID: "1212"},
+		Address:  "xxx",
+	}
+	_, err = fsc.Add(fsvc)
+	require.NoError(t, err)
+
+	before, err := fsc.GetByFunction(fsvc.Function)
+	require.NoError(t, err)
+
+	require.True(t, fsc.TryTouchByAddress(fsvc.Address))
+	// synchronous request is served after the queued touch
+	require.NoError(t, fsc.TouchByAddress(fsvc.Address))
+
+	after, err := fsc.GetByFunction(fsvc.Function)
+	require.NoError(t, err)
+	require.False(t, after.Atime.Before(before.Atime))
+}
+
+func TestReplaceFuncSvc(t *testing.T) {
+	logger, err := zap.NewDevelopment()
+	panicIf(err)
+
+	fsc := MakeFunctionServiceCache(logger)
+	old := FuncSvc{
+		Function: &metav1.ObjectMeta{Name: "foo", UID: "1212"},
+		Address:  "old",
+	}
+	_, err = fsc.Add(old)
+	require.NoError(t, err)
+	cached, err := fsc.GetByFunction(old.Function)
+	require.NoError(t, err)
+
+	// GetByFunction must not miss while the service is swapped
+	done := make(chan struct{})
+	lookupErr := make(chan error, 1)
+	go func() {
+		defer close(lookupErr)
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			if _, err := fsc.GetByFunction(old.Function); err != nil {
+				lookupErr <- err
+				return
+			}
+		}
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	replacement := FuncSvc{
+		Function: old.Function,
+		Address:  "new",
+	}
+	require.NoError(t, fsc.ReplaceFuncSvc(&old, &replacement))
+	close(done)
+	require.NoError(t, <-lookupErr)
+
+	require.NoError(t, fsc.TouchByAddress("new"))
+	require.Error(t, fsc.TouchByAddress("old"))
+
+	fsvc, err := fsc.GetByFunction(old.Function)
+	require.NoError(t, err)
+	require.Equal(t, "new", fsvc.Address)
+	require.Equal(t, cached.Ctime, fsvc.Ctime)
+	require.True(t, fsvc.Atime.After(cached.Atime))
+
+	other := FuncSvc{
+		Function: &metav1.ObjectMeta{Name: "bar", UID: "3434"},
+		Address:  "other",
+	}
+	err = fsc.ReplaceFuncSvc(fsvc, &other)
+	require.Error(t, err)
+
+	err = fsc.ReplaceFuncSvc(&other, &other)
+	require.True(t, IsNotFoundError(err))
+}
+
+func TestAddExistingAddress(t *testing.T) {
+	logger, err := zap.NewDevelopment()
+	panicIf(err)
+
+	fn := &metav1.ObjectMeta{Name: "foo", UID: "1212"}
+	for _, test := range []struct {
+		name            string
+		opts            []FunctionServiceCacheOption
+		address         string
+		expectedAddress string
+	}{
+		{name: "same address", address: "old", expectedAddress: "old"},
+		{name: "same address with update", opts: []FunctionServiceCacheOption{WithAddressUpdate()}, address: "old", expectedAddress: "old"},
+		{name: "changed address", address: "new", expectedAddress: "old"},
+		{name: "changed address with update", opts: []FunctionServiceCacheOption{WithAddressUpdate()}, address: "new", expectedAddress: "new"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			fsc := MakeFunctionServiceCache(logger, test.opts...)
+			_, err := fsc.Add(FuncSvc{Function: fn, Address: "old"})
+			require.NoError(t, err)
+			require.NoError(t, fsc.Pin(fn, true))
+			cached, err := fsc.GetByFunction(fn)
+			require.NoError(t, err)
+
+			existing, err := fsc.Add(FuncSvc{Function: fn, Address: test.address})
+			require.NoError(t, err)
+			require.NotNil(t, existing)
+			require.Equal(t, test.expectedAddress, existing.Address)
+
+			fsvc, err := fsc.GetByFunction(fn)
+			require.NoError(t, err)
+			require.Equal(t, test.expectedAddress, fsvc.Address)
+			require.Equal(t, cached.Ctime, fsvc.Ctime)
+			require.True(t, fsvc.Pinned)
+			require.NoError(t, fsc.TouchByAddress(test.expectedAddress))
+			if test.expectedAddress != "old" {
+				require.True(t, IsNotFoundError(fsc.TouchByAddress("old")))
+			}
+		})
+	}
+}
+
+func TestAddRecreatedFunction(t *testing.T) {
+	logger, err := zap.NewDevelopment()
+	panicIf(err)
+
+	fsc := MakeFunctionServiceCache(logger)
+	old := &metav1.ObjectMeta{Name: "foo", Namespace: "default", UID: "uid-old"}
+	_, err = fsc.Add(FuncSvc{Function: old, Address: "foo.default"})
+	require.NoError(t, err)
+	other := &metav1.ObjectMeta{Name: "bar", Namespace: "default", UID: "uid-bar"}
+	_, err = fsc.Add(FuncSvc{Function: other, Address: "bar.default"})
+	require.NoError(t, err)
+
+	// the function is deleted and recreated with the same name, its service with the same address
+	recreated := &metav1.ObjectMeta{Name: "foo", Namespace: "default", UID: "uid-new"}
+	_, err = fsc.Add(FuncSvc{Function: recreated, Address: "foo.default"})
+	require.NoError(t, err)
+
+	fsvc, err := fsc.GetByFunctionUID("uid-new")
+	require.NoError(t, err)
+	require.Equal(t, types.UID("uid-new"), fsvc.Function.UID)
+	_, err = fsc.GetByFunctionUID("uid-old")
+	require.True(t, IsNotFoundError(err))
+	_, err = fsc.GetByFunction(old)
+	require.True(t, IsNotFoundError(err))
+	require.NoError(t, fsc.TouchByAddress("foo.default"))
+
+	// functions with other names are kept
+	_, err = fsc.GetByFunctionUID("uid-bar")
+	require.NoError(t, err)
+	fsvcs, err := fsc.ListBySelector(nil)
+	require.NoError(t, err)
+	require.Len(t, fsvcs, 2)
+}
+
+func TestListBySelector(t *testing.T) {
+	logger, err := zap.NewDevelopment()
+	panicIf(err)
+
+	fsc := MakeFunctionServiceCache(logger)
+	for i, fnLabels := range []map[string]string{
+		{"app": "shop", "tier": "frontend"},
+		{"app": "shop", "tier": "backend"},
+		{"app": "blog"},
+		nil,
+	} {
+		_, err := fsc.Add(FuncSvc{
+			Function: &metav1.ObjectMeta{
+				Name:   fmt.Sprintf("fn-%d", i),
+				UID:    types.UID(fmt.Sprintf("uid-%d", i)),
+				Labels: fnLabels,
+			},
+			Address: fmt.Sprintf("addr-%d", i),
+		})
+		require.NoError(t, err)
+	}
+
+	for _, test := range []struct {
+		selector string
+		expected []string
+	}{
+		{selector: "", expected: []string{"fn-0", "fn-1", "fn-2", "fn-3"}},
+		{selector: "app=shop", expected: []string{"fn-0", "fn-1"}},
+		{selector: "app!=shop", expected: []string{"fn-2", "fn-3"}},
+		{selector: "app=shop,tier=backend", expected: []string{"fn-1"}},
+		{selector: "tier in (frontend,backend)", expected: []string{"fn-0", "fn-1"}},
+		{selector: "app notin (shop)", expected: []string{"fn-2", "fn-3"}},
+		{selector: "!app", expected: []string{"fn-3"}},
+		{selector: "app=unknown", expected: []string{}},
+	} {
+		t.Run(test.selector, func(t *testing.T) {
+			selector, err := labels.Parse(test.selector)
+			require.NoError(t, err)
+
+			fsvcs, err := fsc.ListBySelector(selector)
+			require.NoError(t, err)
+			names := make([]string, 0, len(fsvcs))
+			for _, fsvc := range fsvcs {
+				names = append(names, fsvc.Function.Name)
+			}
+			require.ElementsMatch(t, test.expected, names)
+		})
+	}
+
+	// returned values are copies
+	fsvcs, err := fsc.ListBySelector(nil)
+	require.NoError(t, err)
+	require.Len(t, fsvcs, 4)
+	fsvcs[0].Address = "changed"
+	cached, err := fsc.GetByFunction(fsvcs[0].Function)
+	require.NoError(t, err)
+	require.NotEqual(t, "changed", cached.Address)
+}
+
+func TestListByExecutor(t *testing.T) {
+	logger, err := zap.NewDevelopment()
+	panicIf(err)
+
+	fsc := MakeFunctionServiceCache(logger)
+	for i, fixture := range []struct {
+		executor fv1.ExecutorType
+		old      bool
+	}{
+		{executor: fv1.ExecutorTypeNewdeploy, old: true},
+		{executor: fv1.ExecutorTypeNewdeploy},
+		{executor: fv1.ExecutorTypeContainer, old: true},
+		{executor: fv1.ExecutorTypePoolmgr, old: true},
+		{executor: fv1.ExecutorTypeNewdeploy, old: true},
+	} {
+		fn := &metav1.ObjectMeta{Name: fmt.Sprintf("fn-%d", i), UID: types.UID(fmt.Sprintf("uid-%d", i))}
+		_, err := fsc.Add(FuncSvc{Function: fn, Address: fmt.Sprintf("addr-%d", i), Executor: fixture.executor})
+		require.NoError(t, err)
+		if fixture.old {
+			fsvc, err := fsc.byFunction.Get(crd.CacheKeyURFromMeta(fn))
+			require.NoError(t, err)
+			fsvc.Atime = time.Now().Add(-time.Hour)
+		}
+	}
+
+	names := func(fsvcs []*FuncSvc) []string {
+		names := make([]string, 0, len(fsvcs))
+		for _, fsvc := range fsvcs {
+			names = append(names, fsvc.Function.Name)
+		}
+		return names
+	}
+
+	for _, test := range []struct {
+		executor fv1.ExecutorType
+		all      []string
+		old      []string
+	}{
+		{executor: fv1.ExecutorTypeNewdeploy, all: []string{"fn-0", "fn-1", "fn-4"}, old: []string{"fn-0", "fn-4"}},
+		{executor: fv1.ExecutorTypeContainer, all: []string{"fn-2"}, old: []string{"fn-2"}},
+		{executor: fv1.ExecutorTypePoolmgr, all: []string{"fn-3"}, old: []string{"fn-3"}},
+		{executor: "unknown", all: []string{}, old: []string{}},
+	} {
+		t.Run(string(test.executor), func(t *testing.T) {
+			fsvcs := fsc.ListByExecutor(test.executor)
+			require.ElementsMatch(t, test.all, names(fsvcs))
+			for _, fsvc := range fsvcs {
+				require.Equal(t, test.executor, fsvc.Executor)
+			}
+
+			fsvcs, err := fsc.ListOldByExecutor(time.Minute, test.executor)
+			require.NoError(t, err)
+			require.ElementsMatch(t, test.old, names(fsvcs))
+		})
+	}
+
+	// returned values are copies
+	fsvcs := fsc.ListByExecutor(fv1.ExecutorTypeContainer)
+	require.Len(t, fsvcs, 1)
+	fsvcs[0].Address = "changed"
+	cached, err := fsc.GetByFunction(fsvcs[0].Function)
+	require.NoError(t, err)
+	require.Equal(t, "addr-2", cached.Address)
+}
+
+func TestListByEnvironment(t *testing.T) {
+	logger, err := zap.NewDevelopment()
+	panicIf(err)
+
+	fsc := MakeFunctionServiceCache(logger)
+	for i, env := range []*metav1.ObjectMeta{
+		{Name: "nodejs", Namespace: "default"},
+		{Name: "python", Namespace: "default"},
+		{Name: "nodejs", Namespace: "other"},
+		{Name: "nodejs", Namespace: "default"},
+		nil, // container functions have no environment
+	} {
+		fsvc := FuncSvc{
+			Function: &metav1.ObjectMeta{Name: fmt.Sprintf("fn-%d", i), UID: types.UID(fmt.Sprintf("uid-%d", i))},
+			Address:  fmt.Sprintf("addr-%d", i),
+		}
+		if env != nil {
+			fsvc.Environment = &fv1.Environment{ObjectMeta: *env}
+		}
+		_, err := fsc.Add(fsvc)
+		require.NoError(t, err)
+	}
+
+	names := func(fsvcs []*FuncSvc) []string {
+		names := make([]string, 0, len(fsvcs))
+		for _, fsvc := range fsvcs {
+			names = append(names, fsvc.Function.Name)
+		}
+		return names
+	}
+
+	nodejs := &metav1.ObjectMeta{Name: "nodejs", Namespace: "default"}
+	require.ElementsMatch(t, []string{"fn-0", "fn-3"}, names(fsc.ListByEnvironment(nodejs)))
+	require.ElementsMatch(t, []string{"fn-1"}, names(fsc.ListByEnvironment(&metav1.ObjectMeta{Name: "python", Namespace: "default"})))
+	require.ElementsMatch(t, []string{"fn-2"}, names(fsc.ListByEnvironment(&metav1.ObjectMeta{Name: "nodejs", Namespace: "other"})))
+	require.Empty(t, fsc.ListByEnvironment(&metav1.ObjectMeta{Name: "go", Namespace: "default"}))
+
+	deleted, err := fsc.DeleteByEnvironment(nodejs)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"fn-0", "fn-3"}, names(deleted))
+	require.Empty(t, fsc.ListByEnvironment(nodejs))
+	for _, fsvc := range deleted {
+		_, err := fsc.GetByFunction(fsvc.Function)
+		require.True(t, IsNotFoundError(err))
+	}
+	// function services of other environments are kept
+	fsvcs, err := fsc.ListBySelector(nil)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"fn-1", "fn-2", "fn-4"}, names(fsvcs))
+
+	deleted, err = fsc.DeleteByEnvironment(nodejs)
+	require.NoError(t, err)
+	require.Empty(t, deleted)
+}
+
+func TestDeleteCreatedBefore(t *testing.T) {
+	logger, err := zap.NewDevelopment()
+	panicIf(err)
+
+	fsc := MakeFunctionServiceCache(logger)
+	flush := time.Now().Add(-time.Hour).Truncate(time.Second)
+	for i, ctime := range []time.Time{
+		flush.Add(-time.Minute),
+		flush.Add(-time.Second),
+		flush, // created at the flush time, kept
+		flush.Add(time.Second),
+	} {
+		fn := &metav1.ObjectMeta{Name: fmt.Sprintf("fn-%d", i), UID: types.UID(fmt.Sprintf("uid-%d", i))}
+		_, err := fsc.Add(FuncSvc{Function: fn, Address: fmt.Sprintf("addr-%d", i)})
+		require.NoError(t, err)
+
+		fsvc, err := fsc.byFunction.Get(crd.CacheKeyURFromMeta(fn))
+		require.NoError(t, err)
+		fsvc.Ctime = ctime
+		// recently accessed entries are deleted too
+		fsvc.Atime = time.Now()
+	}
+	require.NoError(t, fsc.Pin(&metav1.ObjectMeta{Name: "fn-1", UID: "uid-1"}, true))
+
+	names := func(fsvcs []*FuncSvc) []string {
+		names := make([]string, 0, len(fsvcs))
+		for _, fsvc := range fsvcs {
+			names = append(names, fsvc.Function.Name)
+		}
+		return names
+	}
+
+	deleted, err := fsc.DeleteCreatedBefore(flush)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"fn-0", "fn-1"}, names(deleted))
+	fsvcs, err := fsc.ListBySelector(nil)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"fn-2", "fn-3"}, names(fsvcs))
+	for _, uid := range []types.UID{"uid-0", "uid-1"} {
+		_, err := fsc.GetByFunctionUID(uid)
+		require.True(t, IsNotFoundError(err))
+	}
+
+	deleted, err = fsc.DeleteCreatedBefore(flush)
+	require.NoError(t, err)
+	require.Empty(t, deleted)
+}
+
+func TestEnvironmentCopied(t *testing.T) {
+	logger, err := zap.NewDevelopment()
+	panicIf(err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	fsc := MakeFunctionServiceCache(logger)
+	env := &fv1.Environment{
+		ObjectMeta: metav1.ObjectMeta{Name: "nodejs", Namespace: "default", Labels: map[string]string{"version": "1"}},
+		Spec:       fv1.EnvironmentSpec{AllowedFunctionsPerContainer: fv1.AllowedFunctionsPerContainerSingle},
+	}
+	fn := &metav1.ObjectMeta{Name: "foo", UID: "1212"}
+	_, err = fsc.Add(FuncSvc{Function: fn, Environment: env, Address: "xxx"})
+	require.NoError(t, err)
+	pooled := &metav1.ObjectMeta{Name: "bar", UID: "3434"}
+	fsc.AddFunc(ctx, FuncSvc{Function: pooled, Environment: env, Address: "yyy", CPULimit: resource.MustParse("5m")}, 10, 0)
+
+	// the caller mutating its environment, e.g. an informer cache object
+	expected := env.DeepCopy()
+	env.Labels["version"] = "2"
+	env.Spec.AllowedFunctionsPerContainer = fv1.AllowedFunctionsPerContainerInfinite
+
+	fsvc, err := fsc.GetByFunction(fn)
+	require.NoError(t, err)
+	require.Equal(t, expected, fsvc.Environment)
+
+	var pooledEnv *fv1.Environment
+	require.NoError(t, fsc.ForEachPoolService(ctx, func(key string, addr string, fsvc *FuncSvc, cpuUsage, cpuLimit resource.Quantity) {
+		pooledEnv = fsvc.Environment
+	}))
+	require.Equal(t, expected, pooledEnv)
+}
+
+func TestGetKubernetesObjects(t *testing.T) {
+	logger, err := zap.NewDevelopment()
+	panicIf(err)
+
+	fsc := MakeFunctionServiceCache(logger)
+	objects := []apiv1.ObjectReference{
+		{Kind: "deployment", Name: "foo", Namespace: "default"},
+		{Kind: "service", Name: "foo", Namespace: "default"},
+		{Kind: "horizontalpodautoscaler", Name: "foo", Namespace: "default"},
+	}
+	fn := &metav1.ObjectMeta{Name: "foo", Namespace: "default", UID: "1212"}
+	_, err = fsc.Add(FuncSvc{Function: fn, Address: "xxx", KubernetesObjects: objects})
+	require.NoError(t, err)
+	added, err := fsc.ListBySelector(nil)
+	require.NoError(t, err)
+	require.Len(t, added, 1)
+
+	t.Run("present", func(t *testing.T) {
+		got, err := fsc.GetKubernetesObjects(fn)
+		require.NoError(t, err)
+		require.Equal(t, objects, got)
+
+		// a copy, which doesn't touch the function service
+		got[0].Name = "bar"
+		got, err = fsc.GetKubernetesObjects(fn)
+		require.NoError(t, err)
+		require.Equal(t, "foo", got[0].Name)
+		fsvcs, err := fsc.ListBySelector(nil)
+		require.NoError(t, err)
+		require.Len(t, fsvcs, 1)
+		require.Equal(t, added[0].Atime, fsvcs[0].Atime)
+	})
+
+	t.Run("absent", func(t *testing.T) {
+		_, err := fsc.GetKubernetesObjects(&metav1.ObjectMeta{Name: "bar", Namespace: "default", UID: "3434"})
+		require.True(t, IsNotFoundError(err))
+	})
+}
+
+func TestGetOrLoad(t *testing.T) {
+	logger, err := zap.NewDevelopment()
+	panicIf(err)
+
+	fsc := MakeFunctionServiceCache(logger)
+	fn := &metav1.ObjectMeta{Name: "foo", UID: "1212"}
+
+	const callers = 50
+	var loads, started int32
+	loader := func(ctx context.Context) (*FuncSvc, error) {
+		atomic.AddInt32(&loads, 1)
+		// keep the load in flight until all callers are waiting for it
+		for atomic.LoadInt32(&started) < callers {
+			time.Sleep(time.Millisecond)
+		}
+		time.Sleep(50 * time.Millisecond)
+		return &FuncSvc{Function: fn, Address: "xxx"}, nil
+	}
+
+	var wg sync.WaitGroup
+	results := make(chan *FuncSvc, callers)
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			atomic.AddInt32(&started, 1)
+			fsvc, err := fsc.GetOrLoad(context.Background(), fn, loader)
+			require.NoError(t, err)
+			results <- fsvc
+		}()
+	}
+	wg.Wait()
+	close(results)
+
+	require.EqualValues(t, 1, atomic.LoadInt32(&loads))
+	seen := make(map[*FuncSvc]bool)
+	for fsvc := range results {
+		require.Equal(t, "xxx", fsvc.Address)
+		require.False(t, seen[fsvc], "callers must receive their own copy")
+		seen[fsvc] = true
+	}
+
+	// cached now, the loader is not called again
+	fsvc, err := fsc.GetOrLoad(context.Background(), fn, loader)
+	require.NoError(t, err)
+	require.Equal(t, "xxx", fsvc.Address)
+	require.EqualValues(t, 1, atomic.LoadInt32(&loads))
+
+	// loader errors are returned and not cached
+	other := &metav1.ObjectMeta{Name: "bar", UID: "3434"}
+	_, err = fsc.GetOrLoad(context.Background(), other, func(ctx context.Context) (*FuncSvc, error) {
+		return nil, errors.New("specialization failed")
+	})
+	require.EqualError(t, err, "specialization failed")
+	_, err = fsc.GetByFunction(other)
+	require.True(t, IsNotFoundError(err))
+}
+
+func TestPreload(t *testing.T) {
+	logger, err := zap.NewDevelopment()
+	panicIf(err)
+
+	src := MakeFunctionServiceCache(logger)
+	for i := 0; i < 3; i++ {
+		_, err := src.Add(FuncSvc{
+			Name:     fmt.Sprintf("svc-%d", i),
+			Function: &metav1.ObjectMeta{Name: fmt.Sprintf("fn-%d", i), UID: types.UID(fmt.Sprintf("uid-%d", i))},
+			Address:  fmt.Sprintf("addr-%d", i),
+		})
+		require.NoError(t, err)
+	}
+	snapshot, err := src.ListBySelector(labels.Everything())
+	require.NoError(t, err)
+
+	entries := make([]FuncSvc, 0, len(snapshot)+1)
+	for _, fsvc := range snapshot {
+		entries = append(entries, *fsvc)
+	}
+	entries = append(entries, FuncSvc{Name: "invalid"})
+
+	dst := MakeFunctionServiceCache(logger)
+	// existing entries are kept
+	_, err = dst.Add(FuncSvc{
+		Name:     "existing",
+		Function: snapshot[0].Function,
+		Address:  "existing-addr",
+	})
+	require.NoError(t, err)
+
+	errs := dst.Preload(entries)
+	require.Len(t, errs, len(entries))
+	for _, err := range errs[:len(snapshot)] {
+		require.NoError(t, err)
+	}
+	require.Error(t, errs[len(snapshot)])
+
+	for _, fsvc := range snapshot {
+		cached, err := dst.GetByFunction(fsvc.Function)
+		require.NoError(t, err)
+		if fsvc.Function.UID == snapshot[0].Function.UID {
+			require.Equal(t, "existing-addr", cached.Address)
+			continue
+		}
+		require.Equal(t, fsvc.Name, cached.Name)
+		require.Equal(t, fsvc.Address, cached.Address)
+		require.NoError(t, dst.TouchByAddress(fsvc.Address))
+	}
+}
+
+func TestNormalizeAddress(t *testing.T) {
+	for address, expected := range map[string]string{
+		"10.0.0.1:8888":              "10.0.0.1:8888",
+		"10.0.0.1:80":                "10.0.0.1",
+		"http://10.0.0.1":            "10.0.0.1",
+		"Svc.Fission-Function.:8888": "svc.fission-function:8888",
+		"http://SVC.ns:80/":          "svc.ns",
+		"https://svc.ns":             "svc.ns:443",
+		"svc.ns:443":                 "svc.ns:443",
+		"[fd00::1]:80":               "[fd00::1]",
+		"[FD00::1]:8888":             "[fd00::1]:8888",
+		"[2001:0db8:0:0::1]:8080":    "[2001:db8::1]:8080",
+		"http://[2001:db8::1]":       "[2001:db8::1]",
+		"2001:db8::1":                "[2001:db8::1]",
+	} {
+		require.Equal(t, expected, normalizeAddress(address), address)
+	}
+}
+
+func TestAddressNormalization(t *testing.T) {
+	logger, err := zap.NewDevelopment()
+	panicIf(err)
+
+	fsvc := FuncSvc{
+		Function: &metav1.ObjectMeta{Name: "foo", UID: "1212"},
+		Address:  "Svc.NS:80",
+	}
+
+	// raw addresses are kept by default
+	fsc := MakeFunctionServiceCache(logger)
+	_, err = fsc.Add(fsvc)
+	require.NoError(t, err)
+	require.NoError(t, fsc.TouchByAddress("Svc.NS:80"))
+	require.True(t, IsNotFoundError(fsc.TouchByAddress("svc.ns")))
+
+	fsc = MakeFunctionServiceCache(logger, WithAddressNormalization())
+	_, err = fsc.Add(fsvc)
+	require.NoError(t, err)
+	for _, address := range []string{"Svc.NS:80", "svc.ns", "http://svc.ns", "SVC.NS.:80"} {
+		require.NoError(t, fsc.TouchByAddress(address), address)
+	}
+	require.True(t, IsNotFoundError(fsc.TouchByAddress("svc.ns:8888")))
+
+	require.NoError(t, fsc.DeleteEntry(&FuncSvc{Function: fsvc.Function, Address: "http://svc.ns/"}))
+	require.True(t, IsNotFoundError(fsc.TouchByAddress("svc.ns")))
+}
+
+func TestAddFuncCPULimit(t *testing.T) {
+	logger, err := zap.NewDevelopment()
+	panicIf(err)
+
+	for _, test := range []struct {
+		name     string
+		opts     []FunctionServiceCacheOption
+		cpuLimit resource.Quantity
+		expected string
+	}{
+		{name: "zero limit", expected: DefaultCPULimit.String()},
+		{name: "explicit limit", cpuLimit: resource.MustParse("250m"), expected: "250m"},
+		{name: "default override", opts: []FunctionServiceCacheOption{WithDefaultCPULimit(resource.MustParse("500m"))}, expected: "500m"},
+		{name: "explicit limit with default override", opts: []FunctionServiceCacheOption{WithDefaultCPULimit(resource.MustParse("500m"))},
+			cpuLimit: resource.MustParse("2"), expected: "2"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			fsc := MakeFunctionServiceCache(logger, test.opts...)
+			fsc.AddFunc(context.Background(), FuncSvc{
+				Function: &metav1.ObjectMeta{Name: "foo", UID: "1212"},
+				Address:  "xxx",
+				CPULimit: test.cpuLimit,
+			}, 1, 0)
+
+			var limits []string
+			err := fsc.ForEachPoolService(context.Background(), func(key string, addr string, fsvc *FuncSvc, cpuUsage, cpuLimit resource.Quantity) {
+				limits = append(limits, cpuLimit.String())
+				require.Equal(t, test.expected, fsvc.CPULimit.String())
+			})
+			require.NoError(t, err)
+			require.Equal(t, []string{test.expected}, limits)
+		})
+	}
+}
+
+func TestAddFuncCPULimitOverride(t *testing.T) {
+	logger, err := zap.NewDevelopment()
+	panicIf(err)
+
+	fsc := MakeFunctionServiceCache(logger)
+	fsvc := FuncSvc{
+		Function: &metav1.ObjectMeta{Name: "foo", UID: "1212"},
+		Address:  "xxx",
+		CPULimit: resource.MustParse("250m"),
+	}
+	fsc.AddFunc(context.Background(), fsvc, 1, 0, WithCPULimitOverride(resource.MustParse("1")))
+	require.Equal(t, "250m", fsvc.CPULimit.String())
+
+	var limits, fsvcLimits []string
+	require.NoError(t, fsc.ForEachPoolService(context.Background(), func(key string, addr string, fsvc *FuncSvc, cpuUsage, cpuLimit resource.Quantity) {
+		limits = append(limits, cpuLimit.String())
+		fsvcLimits = append(fsvcLimits, fsvc.CPULimit.String())
+	}))
+	// the pool cache tracks the override, the function service keeps its limit
+	require.Equal(t, []string{"1"}, limits)
+	require.Equal(t, []string{"250m"}, fsvcLimits)
+
+	// usage over the limit of the function service, but not over the override
+	fsc.SetCPUUtilization(crd.CacheKeyURGFromMeta(fsvc.Function), "xxx", resource.MustParse("500m"))
+	require.Empty(t, fsc.ListOverCPULimit())
+	fsc.SetCPUUtilization(crd.CacheKeyURGFromMeta(fsvc.Function), "xxx", resource.MustParse("2"))
+	require.Len(t, fsc.ListOverCPULimit(), 1)
+}
+
+func TestStats(t *testing.T) {
+	logger, err := zap.NewDevelopment()
+	panicIf(err)
+
+	fsc := MakeFunctionServiceCache(logger)
+	require.Equal(t, CacheStats{}, fsc.Stats())
+
+	base := time.Now().Add(-time.Hour).Truncate(time.Second)
+	fixtures := []struct {
+		ctime time.Time
+		atime time.Time
+	}{
+		{ctime: base, atime: base.Add(30 * time.Minute)},
+		{ctime: base.Add(10 * time.Minute), atime: base.Add(20 * time.Minute)},
+		{ctime: base.Add(20 * time.Minute), atime: base.Add(40 * time.Minute)},
+	}
+	for i, fixture := range fixtures {
+		fn := &metav1.ObjectMeta{Name: fmt.Sprintf("fn-%d", i), UID: types.UID(fmt.Sprintf("uid-%d", i))}
+		_, err := fsc.Add(FuncSvc{Function: fn, Address: fmt.Sprintf("addr-%d", i)})
+		require.NoError(t, err)
+
+		fsvc, err := fsc.byFunction.Get(crd.CacheKeyURFromMeta(fn))
+		require.NoError(t, err)
+		fsvc.Ctime = fixture.ctime
+		fsvc.Atime = fixture.atime
+	}
+
+	ctx := context.Background()
+	fsc.AddFunc(ctx, FuncSvc{Function: &metav1.ObjectMeta{Name: "fn-0", UID: "uid-0"}, Address: "pool-0"}, 1, 0)
+	fsc.AddFunc(ctx, FuncSvc{Function: &metav1.ObjectMeta{Name: "fn-0", UID: "uid-0"}, Address: "pool-1"}, 1, 0)
+	fsc.AddFunc(ctx, FuncSvc{Function: &metav1.ObjectMeta{Name: "fn-1", UID: "uid-1"}, Address: "pool-2"}, 1, 0)
+
+	stats := fsc.Stats()
+	require.Equal(t, 3, stats.Count)
+	require.Equal(t, base.Add(20*time.Minute), stats.OldestAtime)
+	require.Equal(t, base.Add(40*time.Minute), stats.NewestAtime)
+	require.Equal(t, base, stats.OldestCtime)
+	require.Equal(t, base.Add(20*time.Minute), stats.NewestCtime)
+
+	// mean of the idle times of 30, 40 and 20 minutes since the atime
+	expectedIdle := time.Since(base.Add(30 * time.Minute))
+	require.InDelta(t, float64(expectedIdle), float64(stats.MeanIdleTime), float64(time.Second))
+
+	require.Equal(t, PoolCacheStats{Groups: 2, Services: 3, WaitingRequests: 0}, stats.Pool)
+}
+
+func TestDeleteByAddress(t *testing.T) {
+	logger, err := zap.NewDevelopment()
+	panicIf(err)
+
+	fsc := MakeFunctionServiceCache(logger)
+	fsvc := FuncSvc{
+		Function: &metav1.ObjectMeta{Name: "foo", UID: "1212"},
+		Address:  "xxx",
+	}
+	_, err = fsc.Add(fsvc)
+	require.NoError(t, err)
+	fsc.AddFunc(context.Background(), fsvc, 1, 0)
+
+	require.NoError(t, fsc.DeleteByAddress("xxx"))
+
+	_, err = fsc.GetByFunction(fsvc.Function)
+	require.True(t, IsNotFoundError(err))
+	_, err = fsc.GetByFunctionUID(fsvc.Function.UID)
+	require.True(t, IsNotFoundError(err))
+	require.True(t, IsNotFoundError(fsc.TouchByAddress("xxx")))
+	require.Equal(t, 0, fsc.Stats().Pool.Services)
+
+	err = fsc.DeleteByAddress("xxx")
+	require.True(t, IsNotFoundError(err))
+
+	// poolmgr function services are only held by the pool cache
+	poolFsvc := FuncSvc{
+		Function: &metav1.ObjectMeta{Name: "bar", UID: "3434"},
+		Address:  "yyy",
+		CPULimit: resource.MustParse("5m"),
+	}
+	_, err = fsc.AddFunc(context.Background(), poolFsvc, 1, 0)
+	require.NoError(t, err)
+	require.Equal(t, 1, fsc.Stats().Pool.Services)
+
+	require.NoError(t, fsc.DeleteByAddress("yyy"))
+	require.Equal(t, 0, fsc.Stats().Pool.Services)
+	err = fsc.DeleteByAddress("yyy")
+	require.True(t, IsNotFoundError(err))
+}
+
+// failingDeleteCache fails to delete keys from the wrapped cache.
+type failingDeleteCache[K comparable, V any] struct {
+	indexCache[K, V]
+	err error
+}
+
+func (c failingDeleteCache[K, V]) Delete(key K) error {
+	return c.err
+}
+
+func TestDeleteEntryErrors(t *testing.T) {
+	logger, err := zap.NewDevelopment()
+	panicIf(err)
+
+	fsc := MakeFunctionServiceCache(logger)
+	fsvc := &FuncSvc{
+		Function: &metav1.ObjectMeta{Name: "foo", Namespace: "default", UID: "1212"},
+		Address:  "xxx",
+	}
+	_, err = fsc.Add(*fsvc)
+	require.NoError(t, err)
+
+	deleteErr := errors.New("delete failed")
+	fsc.byAddress = failingDeleteCache[string, metav1.ObjectMeta]{indexCache: fsc.byAddress, err: deleteErr}
+	events := fsc.Events()
+
+	err = fsc.DeleteEntry(fsvc)
+	require.ErrorIs(t, err, deleteErr)
+	require.ErrorContains(t, err, "error deleting function service by address")
+	require.NotContains(t, err.Error(), "by function UID")
+
+	// the other indexes are deleted regardless
+	_, err = fsc.GetByFunction(fsvc.Function)
+	require.True(t, IsNotFoundError(err))
+	_, err = fsc.GetByFunctionUID(fsvc.Function.UID)
+	require.True(t, IsNotFoundError(err))
+	// the address is left dangling
+	_, err = fsc.byAddress.Get("xxx")
+	require.NoError(t, err)
+	require.Equal(t, CacheEventAdded, (<-events).Type)
+	require.Equal(t, CacheEventDeleted, (<-events).Type)
+
+	_, err = fsc.Add(*fsvc)
+	require.NoError(t, err)
+	deleted, err := fsc.DeleteOld(fsvc, 0)
+	require.True(t, deleted)
+	require.ErrorIs(t, err, deleteErr)
+}
+
+func TestListOldPaged(t *testing.T) {
+	logger, err := zap.NewDevelopment()
+	panicIf(err)
+
+	fsc := MakeFunctionServiceCache(logger)
+	old := make(map[string]bool)
+	for i := 0; i < 7; i++ {
+		fn := &metav1.ObjectMeta{Name: fmt.Sprintf("fn-%d", i), UID: types.UID(fmt.Sprintf("uid-%d", i))}
+		_, err := fsc.Add(FuncSvc{Function: fn, Address: fmt.Sprintf("addr-%d", i)})
+		require.NoError(t, err)
+		if i%3 == 0 {
+			// recently used
+			continue
+		}
+		fsvc, err := fsc.byFunction.Get(crd.CacheKeyURFromMeta(fn))
+		require.NoError(t, err)
+		fsvc.Atime = time.Now().Add(-time.Hour)
+		old[fn.Name] = true
+	}
+	require.Len(t, old, 4)
+
+	var batchSizes []int
+	listed := make(map[string]bool)
+	err = fsc.ListOldPaged(time.Minute, 3, func(fsvcs []*FuncSvc) bool {
+		batchSizes = append(batchSizes, len(fsvcs))
+		for _, fsvc := range fsvcs {
+			listed[fsvc.Function.Name] = true
+		}
+		return true
+	})
+	require.NoError(t, err)
+	require.Equal(t, []int{3, 1}, batchSizes)
+	require.Equal(t, old, listed)
+
+	// stop after the first batch
+	calls := 0
+	err = fsc.ListOldPaged(time.Minute, 2, func(fsvcs []*FuncSvc) bool {
+		calls++
+		require.Len(t, fsvcs, 2)
+		return false
+	})
+	require.NoError(t, err)
+	require.Equal(t, 1, calls)
+
+	err = fsc.ListOldPaged(time.Minute, 0, func(fsvcs []*FuncSvc) bool { return true })
+	require.Error(t, err)
+}
+
+func BenchmarkTouchByAddress(b *testing.B) {
+	logger := zap.NewNop()
+	for _, size := range []int{0, 16, 256} {
+		b.Run(fmt.Sprintf("buffer-%d", size), func(b *testing.B) {
+			fsc := MakeFunctionServiceCache(logger, WithRequestBufferSize(size))
+			_, err := fsc.Add(FuncSvc{
+				Function: &metav1.ObjectMeta{Name: "foo", UID: "1212"},
+				Address:  "xxx",
+			})
+			require.NoError(b, err)
+
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					if err := fsc.TouchByAddress("xxx"); err != nil {
+						b.Error(err)
+					}
+				}
+			})
+		})
+		// the non-blocking touch is only as fast as the touches it drops, so
+		// report the fraction of dropped touches along with the time
+		b.Run(fmt.Sprintf("try-buffer-%d", size), func(b *testing.B) {
+			fsc := MakeFunctionServiceCache(logger, WithRequestBufferSize(size))
+			_, err := fsc.Add(FuncSvc{
+				Function: &metav1.ObjectMeta{Name: "foo", UID: "1212"},
+				Address:  "xxx",
+			})
+			require.NoError(b, err)
+
+			var dropped atomic.Int64
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					if !fsc.TryTouchByAddress("xxx") {
+						dropped.Add(1)
+					}
+				}
+			})
+			b.ReportMetric(float64(dropped.Load())/float64(b.N), "dropped/op")
+		})
+	}
+}
+
+func BenchmarkAtimeSampling(b *testing.B) {
+	logger := zap.NewNop()
+	fn := &metav1.ObjectMeta{Name: "foo", UID: "1212"}
+	for _, test := range []struct {
+		name string
+		opts []FunctionServiceCacheOption
+	}{
+		{name: "every-access"},
+		{name: "sampled-1s", opts: []FunctionServiceCacheOption{WithAtimeSampling(time.Second)}},
+		{name: "disabled", opts: []FunctionServiceCacheOption{WithAtimeTrackingDisabled()}},
+	} {
+		b.Run(test.name, func(b *testing.B) {
+			fsc := MakeFunctionServiceCache(logger, test.opts...)
+			_, err := fsc.Add(FuncSvc{Function: fn, Address: "xxx"})
+			require.NoError(b, err)
+
+			// a read-heavy workload: mostly touches, with a lookup every 16 requests
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for i := 0; pb.Next(); i++ {
+					var err error
+					if i%16 == 0 {
+						_, err = fsc.GetByFunction(fn)
+					} else {
+						err = fsc.TouchByAddress("xxx")
+					}
+					if err != nil {
+						b.Error(err)
+					}
+				}
+			})
+		})
+	}
+}
+
+func TestWriteFnSvcCache(t *testing.T) {
+	logger, err := zap.NewDevelopment()
+	panicIf(err)
+
+	fsc := MakeFunctionServiceCache(logger)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	fsvc := FuncSvc{
+		Function: &metav1.ObjectMeta{Name: "foo", Namespace: "bar", UID: "1212"},
+		Address:  "xxx",
+		CPULimit: resource.MustParse("5m"),
+	}
+	fsc.AddFunc(ctx, fsvc, 10, 0)
+
+	var buf bytes.Buffer
+	err = fsc.WriteFnSvcCache(ctx, &buf, DumpFormatText)
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(buf.String(), "svc_waiting:0\tqueue_len:0\tfunction_name:foo\tfn_svc_address:xxx\tactive_req:1\t"), buf.String())
+	require.Equal(t, 1, strings.Count(buf.String(), "\n"))
+
+	buf.Reset()
+	err = fsc.WriteFnSvcCache(ctx, &buf, DumpFormatJSON)
+	require.NoError(t, err)
+	var records []poolSvcRecord
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &records))
+	require.Equal(t, []poolSvcRecord{
+		{
+			Key:               crd.CacheKeyURGFromMeta(fsvc.Function).String(),
+			FunctionName:      "foo",
+			FunctionNamespace: "bar",
+			Address:           "xxx",
+			CPUUsage:          "0",
+			CPULimit:          "5m",
+			Owner:             fsc.owner,
+		},
+	}, records)
+
+	err = fsc.WriteFnSvcCache(ctx, &buf, "yaml")
+	require.Error(t, err)
+	require.False(t, IsNotFoundError(err))
+}
+
+func TestFuncSvcOwner(t *testing.T) {
+	logger, err := zap.NewDevelopment()
+	panicIf(err)
+
+	hostname, err := os.Hostname()
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	fsc := MakeFunctionServiceCache(logger)
+	fn := &metav1.ObjectMeta{Name: "foo", Namespace: "bar", UID: "1212"}
+	_, err = fsc.Add(FuncSvc{Function: fn, Address: "xxx"})
+	require.NoError(t, err)
+	fsvc, err := fsc.GetByFunction(fn)
+	require.NoError(t, err)
+	require.Equal(t, hostname, fsvc.Owner)
+
+	fsc = MakeFunctionServiceCache(logger, WithOwner("executor-1"))
+	fsc.AddFunc(ctx, FuncSvc{Function: fn, Address: "xxx", CPULimit: resource.MustParse("5m")}, 10, 0)
+	fsc.AddFunc(ctx, FuncSvc{Function: &metav1.ObjectMeta{Name: "baz", Namespace: "bar", UID: "1313"},
+		Address: "yyy", CPULimit: resource.MustParse("5m"), Owner: "executor-2"}, 10, 0)
+
+	var buf bytes.Buffer
+	err = fsc.WriteFnSvcCache(ctx, &buf, DumpFormatText)
+	require.NoError(t, err)
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		switch {
+		case strings.Contains(line, "function_name:foo\t"):
+			require.True(t, strings.HasSuffix(line, "\towner:executor-1"), line)
+		case strings.Contains(line, "function_name:baz\t"):
+			require.True(t, strings.HasSuffix(line, "\towner:executor-2"), line)
+		default:
+			t.Fatalf("unexpected dump line: %v", line)
+		}
+	}
+
+	buf.Reset()
+	err = fsc.WriteFnSvcCache(ctx, &buf, DumpFormatJSON)
+	require.NoError(t, err)
+	var records []poolSvcRecord
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &records))
+	owners := make(map[string]string)
+	for _, record := range records {
+		owners[record.FunctionName] = record.Owner
+	}
+	require.Equal(t, map[string]string{"foo": "executor-1", "baz": "executor-2"}, owners)
+}
+
+func TestDumpGzip(t *testing.T) {
+	logger, err := zap.NewDevelopment()
+	panicIf(err)
+
+	dumpDir := t.TempDir()
+	t.Setenv("TMPDIR", dumpDir)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// a single function service, as the pool cache dump order is not stable
+	fsc := MakeFunctionServiceCache(logger)
+	fsc.AddFunc(ctx, FuncSvc{
+		Function: &metav1.ObjectMeta{Name: "fn", Namespace: "bar", UID: "uid"},
+		Address:  "addr",
+		CPULimit: resource.MustParse("5m"),
+	}, 10, 0)
+
+	// uncompressed by default
+	path, err := fsc.Dump(ctx)
+	require.NoError(t, err)
+	require.True(t, strings.HasSuffix(path, ".txt"), path)
+	plain, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.NotEmpty(t, plain)
+
+	// the same cache dumped compressed, as configured with WithDumpFileOptions
+	WithDumpFileOptions(util.DumpFileOptions{Gzip: true})(fsc)
+	path, err = fsc.Dump(ctx)
+	require.NoError(t, err)
+	require.True(t, strings.HasSuffix(path, ".txt.gz"), path)
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	require.NoError(t, err)
+	decompressed, err := io.ReadAll(zr)
+	require.NoError(t, err)
+	require.Equal(t, string(plain), string(decompressed))
+}
+
+func TestDumpDebugInfoMetrics(t *testing.T) {
+	logger, err := zap.NewDevelopment()
+	panicIf(err)
+
+	dumpDir := t.TempDir()
+	t.Setenv("TMPDIR", dumpDir)
+
+	fsc := MakeFunctionServiceCache(logger)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	fsc.AddFunc(ctx, FuncSvc{
+		Function: &metav1.ObjectMeta{Name: "foo", Namespace: "bar", UID: "1212"},
+		Address:  "xxx",
+		CPULimit: resource.MustParse("5m"),
+	}, 10, 0)
+
+	metrics.ResetForTest()
+	require.NoError(t, fsc.DumpDebugInfo(ctx))
+
+	entries, err := os.ReadDir(dumpDir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	info, err := entries[0].Info()
+	require.NoError(t, err)
+	require.NotZero(t, info.Size())
+
+	require.Equal(t, float64(1), testutil.ToFloat64(metrics.FscacheDumps))
+	var m dto.Metric
+	require.NoError(t, metrics.FscacheDumpBytes.Write(&m))
+	require.Equal(t, uint64(1), m.GetHistogram().GetSampleCount())
+	require.Equal(t, float64(info.Size()), m.GetHistogram().GetSampleSum())
+}
+
+// cancelAfterCtx is a context which is canceled after its Err method
+// was called after times, to cancel operations at a deterministic point.
+type cancelAfterCtx struct {
+	context.Context
+	after int32
+	calls atomic.Int32
+}
+
+func (c *cancelAfterCtx) Err() error {
+	if c.calls.Add(1) > c.after {
+		return context.Canceled
+	}
+	return nil
+}
+
+// blockingCtx is a context whose first Err call signals entered and then
+// blocks until release is closed, to hold an operation at a deterministic point.
+type blockingCtx struct {
+	context.Context
+	once    sync.Once
+	entered chan struct{}
+	release chan struct{}
+}
+
+func (c *blockingCtx) Err() error {
+	c.once.Do(func() {
+		close(c.entered)
+		<-c.release
+	})
+	return c.Context.Err()
+}
+
+func TestDumpConcurrent(t *testing.T) {
+	logger, err := zap.NewDevelopment()
+	panicIf(err)
+
+	dumpDir := t.TempDir()
+	t.Setenv("TMPDIR", dumpDir)
+
+	fsc := MakeFunctionServiceCache(logger)
+	fsc.AddFunc(context.Background(), FuncSvc{
+		Function: &metav1.ObjectMeta{Name: "foo", Namespace: "bar", UID: "1212"},
+		Address:  "10.0.0.1:8888",
+		CPULimit: resource.MustParse("5m"),
+	}, 10, 0)
+
+	// hold the first dump while the others are started
+	ctx := &blockingCtx{Context: context.Background(), entered: make(chan struct{}), release: make(chan struct{})}
+	const callers = 20
+	paths := make(chan string, callers+1)
+	errs := make(chan error, callers+1)
+	dump := func(ctx context.Context) {
+		path, err := fsc.Dump(ctx)
+		paths <- path
+		errs <- err
+	}
+	go dump(ctx)
+	<-ctx.entered
+
+	var wg sync.WaitGroup
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			dump(context.Background())
+		}()
+	}
+	time.Sleep(100 * time.Millisecond)
+	close(ctx.release)
+	wg.Wait()
+
+	var path string
+	for i := 0; i < callers+1; i++ {
+		require.NoError(t, <-errs)
+		p := <-paths
+		require.NotEmpty(t, p)
+		if i == 0 {
+			path = p
+		}
+		require.Equal(t, path, p, "concurrent dumps must share the same file")
+	}
+	entries, err := os.ReadDir(dumpDir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	require.Equal(t, filepath.Base(path), entries[0].Name())
+
+	// later dumps write a new file
+	require.NoError(t, fsc.DumpDebugInfo(context.Background()))
+	entries, err = os.ReadDir(dumpDir)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+}
+
+func TestDumpDebugInfoCancel(t *testing.T) {
+	logger, err := zap.NewDevelopment()
+	panicIf(err)
+
+	dumpDir := t.TempDir()
+	t.Setenv("TMPDIR", dumpDir)
+
+	fsc := MakeFunctionServiceCache(logger)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	const functions = 20
+	for i := 0; i < functions; i++ {
+		fsc.AddFunc(ctx, FuncSvc{
+			Function: &metav1.ObjectMeta{Name: fmt.Sprintf("foo-%d", i), Namespace: "bar", UID: types.UID(fmt.Sprintf("uid-%d", i))},
+			Address:  fmt.Sprintf("10.0.0.%d:8888", i),
+			CPULimit: resource.MustParse("5m"),
+		}, 10, 0)
+	}
+
+	for _, test := range []struct {
+		name  string
+		after int32
+	}{
+		{name: "before dump", after: 0},
+		{name: "while copying the cache", after: functions / 2},
+		// one check before the copy and one per group while copying
+		{name: "while writing the dump", after: 1 + functions + functions/2},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			err := fsc.DumpDebugInfo(&cancelAfterCtx{Context: ctx, after: test.after})
+			require.ErrorIs(t, err, context.Canceled)
+
+			entries, err := os.ReadDir(dumpDir)
+			require.NoError(t, err)
+			require.Empty(t, entries, "cancelled dump must not leave a file")
+		})
+	}
+
+	// the cancelled dumps don't affect later ones
+	require.NoError(t, fsc.DumpDebugInfo(ctx))
+	entries, err := os.ReadDir(dumpDir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	data, err := os.ReadFile(filepath.Join(dumpDir, entries[0].Name()))
+	require.NoError(t, err)
+	require.Equal(t, functions, strings.Count(string(data), "function_name:"))
+}
+
+func TestNilPoolCache(t *testing.T) {
+	logger, err := zap.NewDevelopment()
+	panicIf(err)
+
+	dumpDir := t.TempDir()
+	t.Setenv("TMPDIR", dumpDir)
+
+	fsc := MakeFunctionServiceCache(logger)
+	fsc.connFunctionCache = nil
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	requireInternal := func(err error) {
+		t.Helper()
+		require.Error(t, err)
+		fe, ok := err.(ferror.Error)
+		require.True(t, ok, "expected ferror.Error, got %v", err)
+		require.Equal(t, ferror.ErrorInternal, int(fe.Code))
+	}
+
+	fn := &metav1.ObjectMeta{Name: "foo", Namespace: "bar", UID: "1212"}
+	key := crd.CacheKeyURGFromMeta(fn)
+	fsvc := FuncSvc{Function: fn, Address: "xxx", CPULimit: resource.MustParse("5m")}
+
+	require.NotPanics(t, func() {
+		fsc.AddFunc(ctx, fsvc, 10, 0)
+		fsc.SetCPUUtilization(key, "xxx", resource.MustParse("1m"))
+		fsc.SetCPUUtilizaton(key, "xxx", resource.MustParse("1m"))
+		fsc.MarkAvailable(key, "xxx")
+		fsc.MarkSpecializationFailure(key)
+		fsc.MarkFuncDeleted(key)
+		fsc.DeleteFunctionSvc(ctx, &fsvc)
+		require.Zero(t, fsc.WaitingRequests(key))
+		require.Zero(t, fsc.ActiveRequests(key))
+		require.Zero(t, fsc.TotalActiveRequests())
+		require.Zero(t, fsc.Stats().Pool)
+	})
+
+	_, err = fsc.GetFuncSvc(ctx, fn, 10, 0)
+	requireInternal(err)
+
+	errs := fsc.SetCPUUtilizations([]CPUReading{{Function: key, Address: "xxx", CPUUsage: resource.MustParse("1m")}})
+	require.Len(t, errs, 1)
+	requireInternal(errs[0])
+
+	_, errs = fsc.AddFuncs(ctx, []FuncSvc{{Function: fn, Address: "xxx"}}, 10, 0)
+	require.Len(t, errs, 1)
+	requireInternal(errs[0])
+
+	err = fsc.WriteFnSvcCache(ctx, io.Discard, DumpFormatText)
+	requireInternal(err)
+	err = fsc.ForEachPoolService(ctx, func(string, string, *FuncSvc, resource.Quantity, resource.Quantity) {})
+	requireInternal(err)
+
+	err = fsc.DumpDebugInfo(ctx)
+	requireInternal(err)
+	entries, err := os.ReadDir(dumpDir)
+	require.NoError(t, err)
+	require.Empty(t, entries)
+
+	_, err = fsc.ListOldForPool(time.Second)
+	requireInternal(err)
+
+	_, err = fsc.Add(fsvc)
+	require.NoError(t, err)
+	err = fsc.DeleteByAddress("xxx")
+	requireInternal(err)
+	_, err = fsc.GetByFunction(fn)
+	require.NoError(t, err)
+}
+
+func TestPin(t *testing.T) {
+	logger, err := zap.NewDevelopment()
+	panicIf(err)
+
+	fsc := MakeFunctionServiceCache(logger)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	pinned := &metav1.ObjectMeta{Name: "pinned", Namespace: "bar", UID: "1212"}
+	other := &metav1.ObjectMeta{Name: "other", Namespace: "bar", UID: "1313"}
+	for _, fn := range []*metav1.ObjectMeta{pinned, other} {
+		_, err := fsc.Add(FuncSvc{Function: fn, Address: "addr-" + fn.Name})
+		require.NoError(t, err)
+		fsvc, err := fsc.byFunction.Get(crd.CacheKeyURFromMeta(fn))
+		require.NoError(t, err)
+		fsvc.Atime = time.Now().Add(-time.Hour)
+	}
+
+	err = fsc.Pin(&metav1.ObjectMeta{Name: "missing", Namespace: "bar", UID: "1414"}, true)
+	require.True(t, IsNotFoundError(err))
+
+	require.NoError(t, fsc.Pin(pinned, true))
+	// GetByFunction would update the access time
+	fsvc, err := fsc.byFunction.Get(crd.CacheKeyURFromMeta(pinned))
+	require.NoError(t, err)
+	require.True(t, fsvc.Pinned)
+
+	listedNames := func(fsvcs []*FuncSvc) []string {
+		names := make([]string, 0, len(fsvcs))
+		for _, fsvc := range fsvcs {
+			names = append(names, fsvc.Function.Name)
+		}
+		return names
+	}
+
+	// pinned entries survive reaping
+	fsvcs, err := fsc.ListOld(time.Minute)
+	require.NoError(t, err)
+	require.Equal(t, []string{"other"}, listedNames(fsvcs))
+
+	var paged []*FuncSvc
+	err = fsc.ListOldPaged(time.Minute, 10, func(fsvcs []*FuncSvc) bool {
+		paged = append(paged, fsvcs...)
+		return true
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{"other"}, listedNames(paged))
+
+	deleted, err := fsc.DeleteOld(fsvc, time.Minute)
+	require.NoError(t, err)
+	require.False(t, deleted)
+	_, err = fsc.byFunction.Get(crd.CacheKeyURFromMeta(pinned))
+	require.NoError(t, err)
+
+	// unpinning re-enables reaping
+	require.NoError(t, fsc.Pin(pinned, false))
+	fsvcs, err = fsc.ListOld(time.Minute)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"pinned", "other"}, listedNames(fsvcs))
+	deleted, err = fsc.DeleteOld(fsvc, time.Minute)
+	require.NoError(t, err)
+	require.True(t, deleted)
+	_, err = fsc.byFunction.Get(crd.CacheKeyURFromMeta(pinned))
+	require.True(t, IsNotFoundError(err))
+
+	// a zero minAge deletes pinned entries
+	require.NoError(t, fsc.Pin(other, true))
+	fsvc, err = fsc.GetByFunction(other)
+	require.NoError(t, err)
+	deleted, err = fsc.DeleteOld(fsvc, 0)
+	require.NoError(t, err)
+	require.True(t, deleted)
+
+	// pool cache entries
+	poolFsvc := FuncSvc{Function: pinned, Address: "pool-addr", CPULimit: resource.MustParse("5m"), Pinned: true}
+	fsc.AddFunc(ctx, poolFsvc, 10, 0)
+	fsc.MarkAvailable(crd.CacheKeyURGFromMeta(pinned), "pool-addr")
+	var buf bytes.Buffer
+	require.NoError(t, fsc.WriteFnSvcCache(ctx, &buf, DumpFormatText))
+	require.Contains(t, buf.String(), "\tpinned:true\t")
+	buf.Reset()
+	require.NoError(t, fsc.WriteFnSvcCache(ctx, &buf, DumpFormatJSON))
+	var records []poolSvcRecord
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &records))
+	require.Len(t, records, 1)
+	require.True(t, records[0].Pinned)
+
+	deleted, err = fsc.DeleteOldPoolCache(ctx, &poolFsvc, time.Nanosecond)
+	require.NoError(t, err)
+	require.False(t, deleted)
+}
+
+func TestPinPool(t *testing.T) {
+	fsc := MakeFunctionServiceCache(zap.NewNop(), WithPoolMaxAddresses(2, AddressCapEvictOldest))
+	ctx := context.Background()
+	pool := fsc.connFunctionCache
+
+	fn := &metav1.ObjectMeta{Name: "foo", Namespace: "bar", UID: "1212", ResourceVersion: "1"}
+	key := crd.CacheKeyURGFromMeta(fn)
+	require.True(t, IsNotFoundError(fsc.Pin(fn, true)))
+
+	for _, addr := range []string{"10.0.0.1:8888", "10.0.0.2:8888"} {
+		_, err := pool.SetSvcValue(ctx, key, addr, &FuncSvc{
+			Function: fn,
+			Address:  addr,
+			Executor: fv1.ExecutorTypePoolmgr,
+			Atime:    time.Now().Add(-time.Hour),
+		}, resource.MustParse("5m"), 10, 0)
+		require.NoError(t, err)
+		fsc.MarkAvailable(key, addr)
+	}
+	listed, err := fsc.ListOldForPool(time.Minute)
+	require.NoError(t, err)
+	require.Len(t, listed, 2)
+
+	// pinned pool entries survive reaping
+	require.NoError(t, fsc.Pin(fn, true))
+	fsvcs, err := fsc.ListOldForPool(time.Minute)
+	require.NoError(t, err)
+	require.Empty(t, fsvcs)
+	// the copy listed before pinning is not pinned itself
+	require.False(t, listed[0].Pinned)
+	deleted, err := fsc.DeleteOldPoolCache(ctx, listed[0], time.Minute)
+	require.NoError(t, err)
+	require.False(t, deleted)
+
+	// and are not evicted at the address cap
+	_, err = pool.SetSvcValue(ctx, key, "10.0.0.3:8888", &FuncSvc{Function: fn, Address: "10.0.0.3:8888"}, resource.MustParse("5m"), 10, 0)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "busy or pinned")
+	// the visitor runs inside the pool cache service loop, so only count there
+	pinnedAddrs := 0
+	require.NoError(t, fsc.ForEachPoolService(ctx, func(key, addr string, fsvc *FuncSvc, cpuUsage, cpuLimit resource.Quantity) {
+		if fsvc.Pinned {
+			pinnedAddrs++
+		}
+	}))
+	require.Equal(t, 2, pinnedAddrs)
+
+	// unpinning re-enables reaping
+	require.NoError(t, fsc.Pin(fn, false))
+	fsvcs, err = fsc.ListOldForPool(time.Minute)
+	require.NoError(t, err)
+	require.Len(t, fsvcs, 2)
+	deleted, err = fsc.DeleteOldPoolCache(ctx, listed[0], time.Minute)
+	require.NoError(t, err)
+	require.True(t, deleted)
+	fsvcs, err = fsc.ListOldForPool(time.Minute)
+	require.NoError(t, err)
+	require.Len(t, fsvcs, 1)
+}
+
+func TestReapPriority(t *testing.T) {
+	fsc := MakeFunctionServiceCache(zap.NewNop())
+	ctx := context.Background()
+
+	now := time.Now()
+	entries := []struct {
+		name     string
+		priority int
+		idle     time.Duration
+	}{
+		{"high-oldest", 10, 4 * time.Hour},
+		{"low-newest", -1, time.Hour},
+		{"default-old", 0, 3 * time.Hour},
+		{"low-oldest", -1, 2 * time.Hour},
+		{"default-new", 0, 2 * time.Hour},
+	}
+	for i, e := range entries {
+		fn := &metav1.ObjectMeta{Name: e.name, Namespace: "bar", UID: types.UID(fmt.Sprintf("uid-%d", i))}
+		_, err := fsc.Add(FuncSvc{Function: fn, Address: "addr-" + e.name, Priority: e.priority})
+		require.NoError(t, err)
+		fsvc, err := fsc.byFunction.Get(crd.CacheKeyURFromMeta(fn))
+		require.NoError(t, err)
+		fsvc.Atime = now.Add(-e.idle)
+
+		fsc.AddFunc(ctx, FuncSvc{
+			Function: fn,
+			Address:  "pool-addr-" + e.name,
+			CPULimit: resource.MustParse("5m"),
+			Priority: e.priority,
+		}, 10, 0)
+		fsc.MarkAvailable(crd.CacheKeyURGFromMeta(fn), "pool-addr-"+e.name)
+	}
+
+	listedNames := func(fsvcs []*FuncSvc) []string {
+		names := make([]string, 0, len(fsvcs))
+		for _, fsvc := range fsvcs {
+			names = append(names, fsvc.Function.Name)
+		}
+		return names
+	}
+	// by priority first, then by atime
+	expected := []string{"low-oldest", "low-newest", "default-old", "default-new", "high-oldest"}
+
+	fsvcs, err := fsc.ListOld(time.Minute)
+	require.NoError(t, err)
+	require.Equal(t, expected, listedNames(fsvcs))
+
+	fsvcs, err = fsc.ListOldInNamespace(time.Minute, "bar")
+	require.NoError(t, err)
+	require.Equal(t, expected, listedNames(fsvcs))
+
+	// AddFunc sets the atime, so pool entries of the same priority are in the order they were added
+	fsvcs, err = fsc.ListOldForPool(0)
+	require.NoError(t, err)
+	require.Equal(t, []string{"low-newest", "low-oldest", "default-old", "default-new", "high-oldest"}, listedNames(fsvcs))
+
+	// the age still decides which function services are listed
+	fsvcs, err = fsc.ListOld(150 * time.Minute)
+	require.NoError(t, err)
+	require.Equal(t, []string{"default-old", "high-oldest"}, listedNames(fsvcs))
+
+	var buf bytes.Buffer
+	require.NoError(t, fsc.WriteFnSvcCache(ctx, &buf, DumpFormatText))
+	require.Contains(t, buf.String(), "function_name:high-oldest\tfn_svc_address:pool-addr-high-oldest\t")
+	require.Contains(t, buf.String(), "\tpriority:10\t")
+	require.Contains(t, buf.String(), "\tpriority:-1\t")
+	buf.Reset()
+	require.NoError(t, fsc.WriteFnSvcCache(ctx, &buf, DumpFormatJSON))
+	var records []poolSvcRecord
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &records))
+	priorities := make(map[string]int)
+	for _, record := range records {
+		priorities[record.FunctionName] = record.Priority
+	}
+	require.Equal(t, map[string]int{"high-oldest": 10, "low-newest": -1, "default-old": 0, "low-oldest": -1, "default-new": 0}, priorities)
+}
+
+func TestEvents(t *testing.T) {
+	logger, err := zap.NewDevelopment()
+	panicIf(err)
+
+	fsc := MakeFunctionServiceCache(logger)
+	fn := &metav1.ObjectMeta{Name: "foo", Namespace: "bar", UID: "1212"}
+	old := &metav1.ObjectMeta{Name: "old", Namespace: "bar", UID: "1313"}
+
+	_, err = fsc.Add(FuncSvc{Function: fn, Address: "xxx"})
+	require.NoError(t, err)
+	require.NoError(t, fsc.TouchByAddress("xxx"))
+	fsvc, err := fsc.byFunction.Get(crd.CacheKeyURFromMeta(fn))
+	require.NoError(t, err)
+	require.NoError(t, fsc.DeleteEntry(fsvc))
+
+	_, err = fsc.Add(FuncSvc{Function: old, Address: "yyy"})
+	require.NoError(t, err)
+	fsvc, err = fsc.byFunction.Get(crd.CacheKeyURFromMeta(old))
+	require.NoError(t, err)
+	fsvc.Atime = time.Now().Add(-time.Hour)
+	deleted, err := fsc.DeleteOld(fsvc, time.Minute)
+	require.NoError(t, err)
+	require.True(t, deleted)
+
+	expected := []struct {
+		eventType CacheEventType
+		function  string
+		address   string
+	}{
+		{CacheEventAdded, "foo", "xxx"},
+		{CacheEventTouched, "foo", "xxx"},
+		{CacheEventDeleted, "foo", "xxx"},
+		{CacheEventAdded, "old", "yyy"},
+		{CacheEventEvicted, "old", "yyy"},
+	}
+	for _, e := range expected {
+		select {
+		case event := <-fsc.Events():
+			require.Equal(t, e.eventType, event.Type, "got %v event", event.Type)
+			require.Equal(t, e.function, event.Function.Name)
+			require.Equal(t, e.address, event.Address)
+			require.False(t, event.Time.IsZero())
+		case <-time.After(time.Second):
+			t.Fatalf("missing %v event", e.eventType)
+		}
+	}
+	select {
+	case event := <-fsc.Events():
+		t.Fatalf("unexpected %v event", event.Type)
+	default:
+	}
+}
+
+func TestEventsDropped(t *testing.T) {
+	logger, err := zap.NewDevelopment()
+	panicIf(err)
+
+	fsc := MakeFunctionServiceCache(logger, WithEventBufferSize(1))
+	dropped := testutil.ToFloat64(metrics.FscacheEventsDropped)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 3; i++ {
+			fn := &metav1.ObjectMeta{Name: fmt.Sprintf("fn-%d", i), UID: types.UID(fmt.Sprintf("uid-%d", i))}
+			_, err := fsc.Add(FuncSvc{Function: fn, Address: fmt.Sprintf("addr-%d", i)})
+			require.NoError(t, err)
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("a full event buffer must not block the cache")
+	}
+
+	require.Equal(t, dropped+2, testutil.ToFloat64(metrics.FscacheEventsDropped))
+	event := <-fsc.Events()
+	require.Equal(t, CacheEventAdded, event.Type)
+	require.Equal(t, "fn-0", event.Function.Name)
+	require.Empty(t, fsc.Events())
+}
+
+func TestCacheKeyResourceVersion(t *testing.T) {
+	logger, err := zap.NewDevelopment()
+	panicIf(err)
+
+	fsc := MakeFunctionServiceCache(logger)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	fn := &metav1.ObjectMeta{Name: "foo", Namespace: "bar", UID: "1212", ResourceVersion: "1", Generation: 1}
+	_, err = fsc.Add(FuncSvc{Function: fn, Address: "xxx"})
+	require.NoError(t, err)
+	fsc.AddFunc(ctx, FuncSvc{Function: fn, Address: "yyy", CPULimit: resource.MustParse("5m")}, 10, 0)
+	fsc.MarkAvailable(crd.CacheKeyURGFromMeta(fn), "yyy")
+
+	_, err = fsc.GetByFunction(fn)
+	require.NoError(t, err)
+	_, err = fsc.GetFuncSvc(ctx, fn, 10, 0)
+	require.NoError(t, err)
+
+	// an updated function misses the entries of its previous version
+	updated := fn.DeepCopy()
+	updated.ResourceVersion = "2"
+	updated.Generation = 2
+	_, err = fsc.GetByFunction(updated)
+	require.True(t, IsNotFoundError(err))
+	_, err = fsc.GetFuncSvc(ctx, updated, 10, 0)
+	require.Error(t, err)
+}
+
+func TestTouchByFunction(t *testing.T) {
+	logger, err := zap.NewDevelopment()
+	panicIf(err)
+
+	fsc := MakeFunctionServiceCache(logger)
+	fn := &metav1.ObjectMeta{Name: "foo", Namespace: "bar", UID: "1212"}
+	_, err = fsc.Add(FuncSvc{Function: fn, Address: "xxx"})
+	require.NoError(t, err)
+
+	fsvc, err := fsc.byFunction.Get(crd.CacheKeyURFromMeta(fn))
+	require.NoError(t, err)
+	aged := time.Now().Add(-time.Hour)
+	atime := func() time.Time {
+		fsvc, err := fsc.byFunction.Get(crd.CacheKeyURFromMeta(fn))
+		require.NoError(t, err)
+		return fsvc.Atime
+	}
+
+	// both touches refresh the same entry
+	fsvc.Atime = aged
+	require.NoError(t, fsc.TouchByAddress("xxx"))
+	byAddress := atime()
+	require.True(t, byAddress.After(aged))
+
+	fsvc.Atime = aged
+	require.NoError(t, fsc.TouchByFunction(fn))
+	byFunction := atime()
+	require.True(t, byFunction.After(aged))
+	require.False(t, byFunction.Before(byAddress))
+
+	fsvcs, err := fsc.ListOld(time.Minute)
+	require.NoError(t, err)
+	require.Empty(t, fsvcs)
+
+	err = fsc.TouchByFunction(&metav1.ObjectMeta{Name: "missing", Namespace: "bar", UID: "1313"})
+	require.True(t, IsNotFoundError(err))
+	err = fsc.TouchByAddress("missing")
+	require.True(t, IsNotFoundError(err))
+}
+
+func TestAtimeSampling(t *testing.T) {
+	logger, err := zap.NewDevelopment()
+	panicIf(err)
+
+	fsc := MakeFunctionServiceCache(logger, WithAtimeSampling(time.Hour))
+	fn := &metav1.ObjectMeta{Name: "foo", Namespace: "bar", UID: "1212"}
+	_, err = fsc.Add(FuncSvc{Function: fn, Address: "xxx"})
+	require.NoError(t, err)
+
+	fsvc, err := fsc.byFunction.Get(crd.CacheKeyURFromMeta(fn))
+	require.NoError(t, err)
+	aged := time.Now().Add(-2 * time.Hour)
+	atime := func() time.Time {
+		fsvc, err := fsc.byFunction.Get(crd.CacheKeyURFromMeta(fn))
+		require.NoError(t, err)
+		return fsvc.Atime
+	}
+
+	// the first touch of each key is sampled, the next ones within the interval are skipped
+	fsvc.Atime = aged
+	require.NoError(t, fsc.TouchByAddress("xxx"))
+	require.True(t, atime().After(aged))
+	fsvc.Atime = aged
+	require.NoError(t, fsc.TouchByAddress("xxx"))
+	require.True(t, fsc.TryTouchByAddress("xxx"))
+	require.Equal(t, aged, atime())
+
+	require.NoError(t, fsc.TouchByFunction(fn))
+	require.True(t, atime().After(aged))
+	fsvc.Atime = aged
+	require.NoError(t, fsc.TouchByFunction(fn))
+	require.Equal(t, aged, atime())
+
+	// lookups only update access times older than the interval
+	_, err = fsc.GetByFunction(fn)
+	require.NoError(t, err)
+	require.True(t, atime().After(aged))
+	recent := time.Now().Add(-time.Minute)
+	fsvc.Atime = recent
+	_, err = fsc.GetByFunction(fn)
+	require.NoError(t, err)
+	require.Equal(t, recent, atime())
+
+	// lookup errors are only reported by sampled touches
+	require.True(t, IsNotFoundError(fsc.TouchByAddress("missing")))
+	require.NoError(t, fsc.TouchByAddress("missing"))
+
+	require.NoError(t, fsc.DeleteEntry(fsvc))
+	_, ok := fsc.lastTouch.Load("xxx")
+	require.False(t, ok)
+	_, ok = fsc.lastTouch.Load(crd.CacheKeyURFromMeta(fn))
+	require.False(t, ok)
+}
+
+func TestAtimeTrackingDisabled(t *testing.T) {
+	logger, err := zap.NewDevelopment()
+	panicIf(err)
+
+	fsc := MakeFunctionServiceCache(logger, WithAtimeTrackingDisabled())
+	fn := &metav1.ObjectMeta{Name: "foo", Namespace: "bar", UID: "1212"}
+	_, err = fsc.Add(FuncSvc{Function: fn, Address: "xxx"})
+	require.NoError(t, err)
+
+	fsvc, err := fsc.byFunction.Get(crd.CacheKeyURFromMeta(fn))
+	require.NoError(t, err)
+	aged := time.Now().Add(-time.Hour)
+	fsvc.Atime = aged
+
+	require.NoError(t, fsc.TouchByAddress("xxx"))
+	require.NoError(t, fsc.TouchByFunction(fn))
+	got, err := fsc.GetByFunction(fn)
+	require.NoError(t, err)
+	require.Equal(t, aged, got.Atime)
+
+	// the function service is idle since it was added, regardless of its traffic
+	fsvcs, err := fsc.ListOld(time.Minute)
+	require.NoError(t, err)
+	require.Len(t, fsvcs, 1)
+}
+
+func TestTouchRateLimit(t *testing.T) {
+	logger, err := zap.NewDevelopment()
+	panicIf(err)
+
+	fsc := MakeFunctionServiceCache(logger, WithTouchRateLimit(100, 10), WithEventBufferSize(10000))
+	fn := &metav1.ObjectMeta{Name: "foo", Namespace: "bar", UID: "1212"}
+	_, err = fsc.Add(FuncSvc{Function: fn, Address: "xxx"})
+	require.NoError(t, err)
+	fsvc, err := fsc.byFunction.Get(crd.CacheKeyURFromMeta(fn))
+	require.NoError(t, err)
+	fsvc.Atime = time.Now().Add(-time.Hour)
+	dropped := testutil.ToFloat64(metrics.FscacheTouchesDropped)
+	<-fsc.Events()
+
+	// flood the service loop with touches, as a misbehaving router would
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				_ = fsc.TouchByAddress("xxx")
+				fsc.TryTouchByAddress("xxx")
+				runtime.Gosched()
+			}
+		}()
+	}
+
+	// the other requests are still serviced promptly
+	for i := 0; i < 20; i++ {
+		requestStart := time.Now()
+		_, err := fsc.ListOld(time.Minute)
+		require.NoError(t, err)
+		_ = fsc.Stats()
+		require.Less(t, time.Since(requestStart), time.Second)
+		time.Sleep(5 * time.Millisecond)
+	}
+	close(stop)
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	// only the touches within the limit reach the service loop
+	touched := len(fsc.Events())
+	require.Positive(t, touched)
+	require.LessOrEqual(t, float64(touched), 10+100*elapsed.Seconds()+1)
+	require.Greater(t, testutil.ToFloat64(metrics.FscacheTouchesDropped), dropped)
+	// touches within the limit still update the access time
+	fsvcs, err := fsc.ListOld(time.Minute)
+	require.NoError(t, err)
+	require.Empty(t, fsvcs)
+}
+
+func TestTombstones(t *testing.T) {
+	logger, err := zap.NewDevelopment()
+	panicIf(err)
+
+	fn := &metav1.ObjectMeta{Name: "foo", Namespace: "bar", UID: "1212"}
+	const grace = 200 * time.Millisecond
+	fsc := MakeFunctionServiceCache(logger, WithTombstones(grace))
+	_, err = fsc.Add(FuncSvc{Function: fn, Address: "xxx"})
+	require.NoError(t, err)
+	added, err := fsc.GetByFunction(fn)
+	require.NoError(t, err)
+	_, ok := fsc.Tombstoned(fn, "xxx")
+	require.False(t, ok)
+
+	t.Run("re-add within grace period", func(t *testing.T) {
+		require.NoError(t, fsc.DeleteEntry(added))
+		tombstone, ok := fsc.Tombstoned(fn, "xxx")
+		require.True(t, ok)
+		require.Equal(t, "xxx", tombstone.Address)
+		_, ok = fsc.Tombstoned(fn, "yyy")
+		require.False(t, ok)
+
+		_, err = fsc.Add(FuncSvc{Function: fn, Address: "xxx"})
+		require.NoError(t, err)
+		fsvc, err := fsc.GetByFunction(fn)
+		require.NoError(t, err)
+		require.Equal(t, added.Ctime, fsvc.Ctime)
+		// the tombstone is resumed by the re-added function service
+		_, ok = fsc.Tombstoned(fn, "xxx")
+		require.False(t, ok)
+	})
+
+	t.Run("re-add after grace period", func(t *testing.T) {
+		fsvc, err := fsc.GetByFunction(fn)
+		require.NoError(t, err)
+		require.NoError(t, fsc.DeleteEntry(fsvc))
+		time.Sleep(grace + 50*time.Millisecond)
+		_, ok := fsc.Tombstoned(fn, "xxx")
+		require.False(t, ok)
+
+		_, err = fsc.Add(FuncSvc{Function: fn, Address: "xxx"})
+		require.NoError(t, err)
+		fsvc, err = fsc.GetByFunction(fn)
+		require.NoError(t, err)
+		require.True(t, fsvc.Ctime.After(added.Ctime))
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		fsc := MakeFunctionServiceCache(logger)
+		_, err := fsc.Add(FuncSvc{Function: fn, Address: "xxx"})
+		require.NoError(t, err)
+		require.NoError(t, fsc.DeleteEntry(&FuncSvc{Function: fn, Address: "xxx"}))
+		_, ok := fsc.Tombstoned(fn, "xxx")
+		require.False(t, ok)
+	})
+}
+
+func TestIPv6Addresses(t *testing.T) {
+	logger, err := zap.NewDevelopment()
+	panicIf(err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	fn := &metav1.ObjectMeta{Name: "foo", Namespace: "bar", UID: "1212"}
+	address := net.JoinHostPort("2001:db8::1", "8080")
+	require.Equal(t, "[2001:db8::1]:8080", address)
+
+	for _, opts := range [][]FunctionServiceCacheOption{nil, {WithAddressNormalization()}} {
+		fsc := MakeFunctionServiceCache(logger, opts...)
+		_, err = fsc.Add(FuncSvc{Function: fn, Address: address})
+		require.NoError(t, err)
+		require.NoError(t, fsc.TouchByAddress(address))
+		require.True(t, IsNotFoundError(fsc.TouchByAddress("[2001:db8::1]:8888")))
+		require.NoError(t, fsc.DeleteByAddress(address))
+		require.True(t, IsNotFoundError(fsc.TouchByAddress(address)))
+	}
+
+	// equivalent forms of the address refer to the same entry with normalization
+	fsc := MakeFunctionServiceCache(logger, WithAddressNormalization())
+	_, err = fsc.Add(FuncSvc{Function: fn, Address: "[2001:0DB8:0:0::1]:8080"})
+	require.NoError(t, err)
+	require.NoError(t, fsc.TouchByAddress(address))
+	require.NoError(t, fsc.TouchByAddress("http://[2001:db8::1]:8080/"))
+
+	// the dump line of an IPv6 address parses back
+	fsc.AddFunc(ctx, FuncSvc{Function: fn, Address: address, CPULimit: resource.MustParse("5m")}, 10, 0)
+	var buf bytes.Buffer
+	require.NoError(t, fsc.WriteFnSvcCache(ctx, &buf, DumpFormatText))
+	fields := make(map[string]string)
+	for _, field := range strings.Split(strings.TrimSpace(buf.String()), "\t") {
+		key, value, ok := strings.Cut(field, ":")
+		require.True(t, ok, field)
+		fields[key] = value
+	}
+	require.Equal(t, address, fields["fn_svc_address"])
+	host, port, err := net.SplitHostPort(fields["fn_svc_address"])
+	require.NoError(t, err)
+	require.Equal(t, "2001:db8::1", host)
+	require.Equal(t, "8080", port)
+}
+
+func TestFuncSvcEqual(t *testing.T) {
+	now := time.Now()
+	newFsvc := func() *FuncSvc {
+		return &FuncSvc{
+			Name:     "svc",
+			Function: &metav1.ObjectMeta{Name: "foo", Namespace: "bar", UID: "1212", ResourceVersion: "1"},
+			Environment: &fv1.Environment{
+				ObjectMeta: metav1.ObjectMeta{Name: "nodejs", Namespace: "bar"},
+			},
+			Address: "10.0.0.1:8888",
+			KubernetesObjects: []apiv1.ObjectReference{
+				{Kind: "deployment", Name: "foo-deploy", Namespace: "bar"},
+			},
+			Executor: fv1.ExecutorTypeNewdeploy,
+			CPULimit: resource.MustParse("100m"),
+			Ctime:    now,
+			Atime:    now,
+		}
+	}
+
+	for _, test := range []struct {
+		name   string
+		modify func(fsvc *FuncSvc)
+		equal  bool
+	}{
+		{name: "equal", modify: func(fsvc *FuncSvc) {}, equal: true},
+		{name: "atime only", modify: func(fsvc *FuncSvc) { fsvc.Atime = now.Add(time.Minute) }, equal: true},
+		{name: "bookkeeping fields", modify: func(fsvc *FuncSvc) {
+			fsvc.Ctime = now.Add(-time.Minute)
+			fsvc.Owner = "executor-2"
+			fsvc.Pinned = true
+		}, equal: true},
+		{name: "equivalent cpu limit", modify: func(fsvc *FuncSvc) { fsvc.CPULimit = resource.MustParse("0.1") }, equal: true},
+		{name: "address changed", modify: func(fsvc *FuncSvc) { fsvc.Address = "10.0.0.2:8888" }},
+		{name: "function updated", modify: func(fsvc *FuncSvc) { fsvc.Function.ResourceVersion = "2" }},
+		{name: "environment changed", modify: func(fsvc *FuncSvc) { fsvc.Environment.Name = "python" }},
+		{name: "kubernetes objects changed", modify: func(fsvc *FuncSvc) { fsvc.KubernetesObjects = nil }},
+		{name: "executor changed", modify: func(fsvc *FuncSvc) { fsvc.Executor = fv1.ExecutorTypeContainer }},
+		{name: "cpu limit changed", modify: func(fsvc *FuncSvc) { fsvc.CPULimit = resource.MustParse("200m") }},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			fsvc := newFsvc()
+			other := newFsvc()
+			test.modify(other)
+			require.Equal(t, test.equal, fsvc.Equal(other))
+			require.Equal(t, test.equal, other.Equal(fsvc))
+		})
+	}
+
+	var nilFsvc *FuncSvc
+	require.True(t, nilFsvc.Equal(nil))
+	require.False(t, nilFsvc.Equal(newFsvc()))
+	require.False(t, newFsvc().Equal(nil))
+}
+
+func TestFuncSvcJSON(t *testing.T) {
+	ctime := time.Date(2024, 3, 1, 10, 30, 0, 123456789, time.FixedZone("CET", 3600))
+	fsvc := FuncSvc{
+		Name:     "svc",
+		Function: &metav1.ObjectMeta{Name: "foo", Namespace: "bar", UID: "1212", ResourceVersion: "1"},
+		Environment: &fv1.Environment{
+			ObjectMeta: metav1.ObjectMeta{Name: "nodejs", Namespace: "bar", UID: "3434"},
+			Spec: fv1.EnvironmentSpec{
+				Resources: apiv1.ResourceRequirements{
+					Limits: apiv1.ResourceList{apiv1.ResourceMemory: resource.MustParse("256Mi")},
+				},
+			},
+		},
+		Address: "10.0.0.1:8888",
+		KubernetesObjects: []apiv1.ObjectReference{
+			{Kind: "deployment", Name: "foo-deploy", Namespace: "bar"},
+		},
+		Executor: fv1.ExecutorTypeNewdeploy,
+		CPULimit: resource.MustParse("0.1"),
+		Owner:    "executor-1",
+		Pinned:   true,
+		Priority: 5,
+		Ctime:    ctime,
+		Atime:    ctime.Add(time.Minute),
+	}
+
+	data, err := json.Marshal(fsvc)
+	require.NoError(t, err)
+	require.JSONEq(t, `{
+		"name": "svc",
+		"function": {"namespace": "bar", "name": "foo", "uid": "1212", "resourceVersion": "1"},
+		"environment": {"namespace": "bar", "name": "nodejs", "uid": "3434"},
+		"address": "10.0.0.1:8888",
+		"kubernetesObjects": [{"kind": "deployment", "name": "foo-deploy", "namespace": "bar"}],
+		"executor": "newdeploy",
+		"cpuLimit": "100m",
+		"memoryLimit": "256Mi",
+		"owner": "executor-1",
+		"pinned": true,
+		"priority": 5,
+		"ctime": "2024-03-01T09:30:00.123456789Z",
+		"atime": "2024-03-01T09:31:00.123456789Z"
+	}`, string(data))
+
+	// pointers are encoded the same
+	ptrData, err := json.Marshal(&fsvc)
+	require.NoError(t, err)
+	require.Equal(t, data, ptrData)
+
+	var decoded FuncSvc
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	require.True(t, fsvc.Equal(&decoded))
+	require.Equal(t, "100m", decoded.CPULimit.String())
+	require.Equal(t, "256Mi", decoded.Environment.Spec.Resources.Limits.Memory().String())
+	require.Equal(t, crd.CacheKeyURFromMeta(fsvc.Function), crd.CacheKeyURFromMeta(decoded.Function))
+	require.Equal(t, "executor-1", decoded.Owner)
+	require.True(t, decoded.Pinned)
+	require.True(t, fsvc.Ctime.Equal(decoded.Ctime))
+	require.True(t, fsvc.Atime.Equal(decoded.Atime))
+
+	// encoding is stable across round trips
+	again, err := json.Marshal(decoded)
+	require.NoError(t, err)
+	require.Equal(t, data, again)
+
+	t.Run("zero values", func(t *testing.T) {
+		data, err := json.Marshal(FuncSvc{Address: "xxx"})
+		require.NoError(t, err)
+		require.JSONEq(t, `{"address": "xxx"}`, string(data))
+
+		var decoded FuncSvc
+		require.NoError(t, json.Unmarshal(data, &decoded))
+		require.Equal(t, FuncSvc{Address: "xxx"}, decoded)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		for _, test := range []struct {
+			data string
+			err  string
+		}{
+			{data: `{"cpuLimit": "lots"}`, err: `error parsing function service cpu limit "lots"`},
+			{data: `{"memoryLimit": "lots"}`, err: `error parsing function service memory limit "lots"`},
+			{data: `{"ctime": "yesterday"}`, err: `error parsing function service ctime "yesterday"`},
+			{data: `{"atime": 12}`, err: "cannot unmarshal number"},
+		} {
+			var decoded FuncSvc
+			require.ErrorContains(t, json.Unmarshal([]byte(test.data), &decoded), test.err)
+		}
+	})
+}
+
+func TestListOldInconsistentCache(t *testing.T) {
+	logger, err := zap.NewDevelopment()
+	panicIf(err)
+
+	fsc := MakeFunctionServiceCache(logger)
+	fn := &metav1.ObjectMeta{Name: "foo", Namespace: "bar", UID: "1212", ResourceVersion: "1"}
+	_, err = fsc.Add(FuncSvc{
+		Function: fn,
+		Address:  "10.0.0.1:8888",
+		Executor: fv1.ExecutorTypeNewdeploy,
+		CPULimit: resource.MustParse("5m"),
+	})
+	require.NoError(t, err)
+
+	// indexed by UID, but missing in byFunction
+	orphan := metav1.ObjectMeta{Name: "orphan", Namespace: "bar", UID: "3434", ResourceVersion: "1"}
+	_, err = fsc.byFunctionUID.Set(orphan.UID, orphan)
+	require.NoError(t, err)
+
+	for i := 0; i < 2; i++ {
+		old, err := fsc.ListOld(0)
+		require.NoError(t, err)
+		require.Len(t, old, 1)
+		require.Equal(t, fn.Name, old[0].Function.Name)
+	}
+
+	// the service loop still serves other requests
+	require.Equal(t, 1, fsc.Stats().Count)
+}
+
+func TestGetFuncSvcConcurrencyLimit(t *testing.T) {
+	logger, err := zap.NewDevelopment()
+	panicIf(err)
+
+	const concurrency = 2
+	fn := &metav1.ObjectMeta{Name: "foo", Namespace: "bar", UID: "1212", ResourceVersion: "1"}
+	key := crd.CacheKeyURGFromMeta(fn)
+	fsvc := FuncSvc{Function: fn, Address: "10.0.0.1:8888", CPULimit: resource.MustParse("5m")}
+
+	// newSaturatedCache returns a cache with all concurrency slots of the function taken
+	newSaturatedCache := func(t *testing.T, failFast bool) *FunctionServiceCache {
+		fsc := MakeFunctionServiceCache(logger, WithConcurrencyLimit(failFast))
+		ctx := context.Background()
+		fsc.AddFunc(ctx, fsvc, 10, 0)
+		// the specializing caller's service holds no slot
+		fsc.MarkAvailable(key, fsvc.Address)
+		for i := 0; i < concurrency; i++ {
+			_, err := fsc.GetFuncSvc(ctx, fn, 10, concurrency)
+			require.NoError(t, err)
+		}
+		return fsc
+	}
+
+	t.Run("fail fast", func(t *testing.T) {
+		fsc := newSaturatedCache(t, true)
+		_, err := fsc.GetFuncSvc(context.Background(), fn, 10, concurrency)
+		require.Error(t, err)
+		require.Equal(t, ferror.ErrorTooManyRequests, int(err.(ferror.Error).Code))
+
+		fsc.MarkAvailable(key, fsvc.Address)
+		_, err = fsc.GetFuncSvc(context.Background(), fn, 10, concurrency)
+		require.NoError(t, err)
+	})
+
+	t.Run("wait", func(t *testing.T) {
+		fsc := newSaturatedCache(t, false)
+		done := make(chan error)
+		go func() {
+			_, err := fsc.GetFuncSvc(context.Background(), fn, 10, concurrency)
+			done <- err
+		}()
+
+		select {
+		case err := <-done:
+			t.Fatalf("GetFuncSvc returned while the concurrency limit was reached: %v", err)
+		case <-time.After(100 * time.Millisecond):
+		}
+
+		fsc.MarkAvailable(key, fsvc.Address)
+		select {
+		case err := <-done:
+			require.NoError(t, err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("GetFuncSvc did not proceed after a function service was returned")
+		}
+	})
+
+	t.Run("wait cancelled", func(t *testing.T) {
+		fsc := newSaturatedCache(t, false)
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		_, err := fsc.GetFuncSvc(ctx, fn, 10, concurrency)
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+
+	t.Run("deleted service frees its slots", func(t *testing.T) {
+		fsc := newSaturatedCache(t, true)
+		fsc.DeleteFunctionSvc(context.Background(), &fsvc)
+		fsc.AddFunc(context.Background(), fsvc, 10, 0)
+		_, err := fsc.GetFuncSvc(context.Background(), fn, 10, concurrency)
+		require.NoError(t, err)
+	})
+
+	t.Run("no limit by default", func(t *testing.T) {
+		fsc := MakeFunctionServiceCache(logger)
+		fsc.AddFunc(context.Background(), fsvc, 10, 0)
+		for i := 0; i < concurrency+2; i++ {
+			_, err := fsc.GetFuncSvc(context.Background(), fn, 10, concurrency)
+			require.NoError(t, err)
+		}
+	})
+}
+
+func TestGetCopiesConcurrently(t *testing.T) {
+	logger, err := zap.NewDevelopment()
+	panicIf(err)
+
+	fsc := MakeFunctionServiceCache(logger)
+	fn := &metav1.ObjectMeta{Name: "foo", Namespace: "bar", UID: "1212", ResourceVersion: "1"}
+	key := crd.CacheKeyURGFromMeta(fn)
+	_, err = fsc.Add(FuncSvc{Function: fn, Address: "10.0.0.1:8888"})
+	require.NoError(t, err)
+	fsc.AddFunc(context.Background(), FuncSvc{Function: fn, Address: "10.0.0.2:8888", CPULimit: resource.MustParse("5m")}, 1000, 0)
+
+	// callers mutate the returned function services, which must not be shared with the cache
+	const n = 50
+	var wg sync.WaitGroup
+	errs := make(chan error, 3*n)
+	for i := 0; i < n; i++ {
+		wg.Add(3)
+		go func() {
+			defer wg.Done()
+			fsvc, err := fsc.GetByFunction(fn)
+			if err != nil {
+				errs <- err
+				return
+			}
+			fsvc.Atime = time.Time{}
+		}()
+		go func() {
+			defer wg.Done()
+			fsvc, err := fsc.GetByFunctionUID(fn.UID)
+			if err != nil {
+				errs <- err
+				return
+			}
+			fsvc.Atime = time.Time{}
+		}()
+		go func() {
+			defer wg.Done()
+			fsvc, err := fsc.GetFuncSvc(context.Background(), fn, 1000, 0)
+			if err != nil {
+				errs <- err
+				return
+			}
+			fsvc.Atime = time.Time{}
+			fsc.MarkAvailable(key, fsvc.Address)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		require.NoError(t, err)
+	}
+
+	// the cached entries keep the access time set by the cache
+	fsvc, err := fsc.GetByFunction(fn)
+	require.NoError(t, err)
+	require.False(t, fsvc.Atime.IsZero())
+	fsvc, err = fsc.GetByFunctionUID(fn.UID)
+	require.NoError(t, err)
+	require.False(t, fsvc.Atime.IsZero())
+	fsvc, err = fsc.GetFuncSvc(context.Background(), fn, 1000, 0)
+	require.NoError(t, err)
+	require.False(t, fsvc.Atime.IsZero())
+	require.Equal(t, "10.0.0.2:8888", fsvc.Address)
+
+	_, err = fsc.GetByFunctionUID("missing")
+	require.True(t, IsNotFoundError(err))
+}
+
+func TestNewFuncSvc(t *testing.T) {
+	fn := &metav1.ObjectMeta{Name: "foo", Namespace: "bar", UID: "1212"}
+	env := &fv1.Environment{ObjectMeta: metav1.ObjectMeta{Name: "nodejs", Namespace: "bar"}}
+
+	fsvc, err := NewFuncSvc(fn, env, "10.0.0.1:8888", fv1.ExecutorTypePoolmgr)
+	require.NoError(t, err)
+	require.Equal(t, fn, fsvc.Function)
+	require.Equal(t, env, fsvc.Environment)
+	require.Equal(t, "10.0.0.1:8888", fsvc.Address)
+	require.Equal(t, fv1.ExecutorTypePoolmgr, fsvc.Executor)
+
+	for _, test := range []struct {
+		name     string
+		fn       *metav1.ObjectMeta
+		env      *fv1.Environment
+		address  string
+		contains string
+	}{
+		{name: "nil function", env: env, address: "10.0.0.1:8888", contains: "has no function"},
+		{name: "empty address", fn: fn, env: env, contains: "has no address"},
+		{name: "nil environment", fn: fn, address: "10.0.0.1:8888", contains: "has no environment"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := NewFuncSvc(test.fn, test.env, test.address, fv1.ExecutorTypePoolmgr)
+			require.Error(t, err)
+			require.Equal(t, ferror.ErrorInvalidArgument, int(err.(ferror.Error).Code))
+			require.Contains(t, err.Error(), test.contains)
+		})
+	}
+}
+
+func TestAddInvalidFuncSvc(t *testing.T) {
+	logger, err := zap.NewDevelopment()
+	panicIf(err)
+
+	fsc := MakeFunctionServiceCache(logger)
+	fn := &metav1.ObjectMeta{Name: "foo", Namespace: "bar", UID: "1212"}
+
+	for _, fsvc := range []FuncSvc{
+		{Name: "no-function", Address: "10.0.0.1:8888"},
+		{Name: "no-address", Function: fn},
+	} {
+		_, err := fsc.Add(fsvc)
+		require.Error(t, err, fsvc.Name)
+		require.Equal(t, ferror.ErrorInvalidArgument, int(err.(ferror.Error).Code), fsvc.Name)
+
+		// AddFunc logs and drops invalid function services instead of panicking
+		fsc.AddFunc(context.Background(), fsvc, 10, 0)
+	}
+	require.Zero(t, fsc.Stats().Count)
+	require.Zero(t, fsc.Stats().Pool.Services)
+
+	// function services of container functions have no environment
+	_, err = fsc.Add(FuncSvc{Function: fn, Address: "10.0.0.1:8888", Executor: fv1.ExecutorTypeContainer})
+	require.NoError(t, err)
+}
+
+func TestGetFuncSvcMaxWait(t *testing.T) {
+	logger, err := zap.NewDevelopment()
+	panicIf(err)
+
+	require.Equal(t, DefaultGetFuncSvcMaxWait, MakeFunctionServiceCache(logger).getFuncSvcMaxWait)
+
+	const maxWait = 100 * time.Millisecond
+	fsc := MakeFunctionServiceCache(logger, WithGetFuncSvcMaxWait(maxWait))
+	fn := &metav1.ObjectMeta{Name: "foo", Namespace: "bar", UID: "1212", ResourceVersion: "1"}
+	ctx := context.Background()
+
+	// saturate the only function service and take the last specialization slot,
+	// so that further callers wait for a function service which never comes
+	const requestsPerPod, concurrency = 2, 2
+	fsc.AddFunc(ctx, FuncSvc{Function: fn, Address: "10.0.0.1:8888", CPULimit: resource.MustParse("5m")}, requestsPerPod, 0)
+	_, err = fsc.GetFuncSvc(ctx, fn, requestsPerPod, concurrency)
+	require.NoError(t, err)
+	_, err = fsc.GetFuncSvc(ctx, fn, requestsPerPod, concurrency)
+	require.True(t, IsNotFoundError(err))
+
+	done := make(chan error)
+	start := time.Now()
+	go func() {
+		_, err := fsc.GetFuncSvc(ctx, fn, requestsPerPod, concurrency)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		require.Error(t, err)
+		require.Equal(t, ferror.ErrorRequestTimeout, int(err.(ferror.Error).Code))
+		require.GreaterOrEqual(t, time.Since(start), maxWait)
+	case <-time.After(5 * time.Second):
+		t.Fatal("GetFuncSvc blocked beyond the max wait")
+	}
+}
+
+func TestAddFuncs(t *testing.T) {
+	logger, err := zap.NewDevelopment()
+	panicIf(err)
+
+	fsc := MakeFunctionServiceCache(logger)
+	ctx := context.Background()
+	fn := &metav1.ObjectMeta{Name: "foo", Namespace: "bar", UID: "1212", ResourceVersion: "1"}
+	created := time.Now().Add(-time.Hour).Truncate(time.Second)
+
+	_, errs := fsc.AddFuncs(ctx, []FuncSvc{
+		{Function: fn, Address: "10.0.0.1:8888", CPULimit: resource.MustParse("5m"), Ctime: created, Atime: created},
+		{Function: fn},
+		{Function: fn, Address: "10.0.0.2:8888"},
+	}, 10, 0)
+	require.Len(t, errs, 3)
+	require.NoError(t, errs[0])
+	require.Error(t, errs[1])
+	require.Equal(t, ferror.ErrorInvalidArgument, int(errs[1].(ferror.Error).Code))
+	require.NoError(t, errs[2])
+
+	// the batch is applied once AddFuncs returns
+	require.Equal(t, 2, fsc.Stats().Pool.Services)
+	require.Equal(t, 2, fsc.ActiveRequests(crd.CacheKeyURGFromMeta(fn)))
+
+	ctimes := make(map[string]time.Time)
+	err = fsc.ForEachPoolService(ctx, func(key string, addr string, fsvc *FuncSvc, cpuUsage, cpuLimit resource.Quantity) {
+		ctimes[addr] = fsvc.Ctime
+		if addr == "10.0.0.2:8888" {
+			require.Equal(t, DefaultCPULimit.String(), cpuLimit.String())
+		}
+	})
+	require.NoError(t, err)
+	require.Equal(t, created, ctimes["10.0.0.1:8888"])
+	require.True(t, ctimes["10.0.0.2:8888"].After(created))
+}
+
+func TestAddFuncMaxAddresses(t *testing.T) {
+	ctx := context.Background()
+	fn := &metav1.ObjectMeta{Name: "foo", Namespace: "bar", UID: "1212", ResourceVersion: "1"}
+	add := func(fsc *FunctionServiceCache, address string) (*FuncSvc, error) {
+		return fsc.AddFunc(ctx, FuncSvc{Name: "pod-" + address, Function: fn, Address: address, CPULimit: resource.MustParse("5m")}, 10, 0)
+	}
+
+	t.Run("reject", func(t *testing.T) {
+		fsc := MakeFunctionServiceCache(zap.NewNop(), WithPoolMaxAddresses(1, AddressCapReject))
+		evicted, err := add(fsc, "10.0.0.1:8888")
+		require.NoError(t, err)
+		require.Nil(t, evicted)
+		fsc.MarkAvailable(crd.CacheKeyURGFromMeta(fn), "10.0.0.1:8888")
+
+		evicted, err = add(fsc, "10.0.0.2:8888")
+		require.Error(t, err)
+		require.Nil(t, evicted)
+		require.Equal(t, ferror.ErrorTooManyRequests, int(err.(ferror.Error).Code))
+		require.Contains(t, err.Error(), "address limit 1 reached")
+		require.Equal(t, 1, fsc.Stats().Pool.Services)
+
+		batchEvicted, errs := fsc.AddFuncs(ctx, []FuncSvc{
+			{Function: fn},
+			{Function: fn, Address: "10.0.0.3:8888"},
+		}, 10, 0)
+		require.Empty(t, batchEvicted)
+		require.Equal(t, ferror.ErrorInvalidArgument, int(errs[0].(ferror.Error).Code))
+		require.Equal(t, ferror.ErrorTooManyRequests, int(errs[1].(ferror.Error).Code))
+	})
+
+	t.Run("evict oldest", func(t *testing.T) {
+		fsc := MakeFunctionServiceCache(zap.NewNop(), WithPoolMaxAddresses(1, AddressCapEvictOldest))
+		_, err := add(fsc, "10.0.0.1:8888")
+		require.NoError(t, err)
+		fsc.MarkAvailable(crd.CacheKeyURGFromMeta(fn), "10.0.0.1:8888")
+		evicted, err := add(fsc, "10.0.0.2:8888")
+		require.NoError(t, err)
+		require.NotNil(t, evicted)
+		require.Equal(t, "pod-10.0.0.1:8888", evicted.Name)
+		require.Equal(t, 1, fsc.Stats().Pool.Services)
+
+		select {
+		case event := <-fsc.Events():
+			require.Equal(t, CacheEventEvicted, event.Type)
+			require.Equal(t, "foo", event.Function.Name)
+			require.Equal(t, "10.0.0.1:8888", event.Address)
+		case <-time.After(time.Second):
+			t.Fatal("missing evicted event")
+		}
+
+		fsc.MarkAvailable(crd.CacheKeyURGFromMeta(fn), "10.0.0.2:8888")
+		batchEvicted, errs := fsc.AddFuncs(ctx, []FuncSvc{{Function: fn, Address: "10.0.0.3:8888"}}, 10, 0)
+		require.Equal(t, []error{nil}, errs)
+		require.Len(t, batchEvicted, 1)
+		require.Equal(t, "10.0.0.2:8888", batchEvicted[0].Address)
+
+		// the remaining address is busy
+		_, err = add(fsc, "10.0.0.4:8888")
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "all addresses are busy")
+	})
+}
+
+// BenchmarkAddFuncs compares adding 100 function services of a function one request
+// per service with a single batched request.
+func BenchmarkAddFuncs(b *testing.B) {
+	ctx := context.Background()
+	fn := &metav1.ObjectMeta{Name: "foo", Namespace: "bar", UID: "1212", ResourceVersion: "1"}
+	fsvcs := make([]FuncSvc, 0, 100)
+	for i := 0; i < 100; i++ {
+		fsvcs = append(fsvcs, FuncSvc{Function: fn, Address: fmt.Sprintf("10.0.0.%d:8888", i), CPULimit: resource.MustParse("5m")})
+	}
+
+	b.Run("single", func(b *testing.B) {
+		fsc := MakeFunctionServiceCache(zap.NewNop())
+		for i := 0; i < b.N; i++ {
+			for _, fsvc := range fsvcs {
+				fsc.AddFunc(ctx, fsvc, 10, 0)
+			}
+			// wait for the service loop to apply all services
+			fsc.Stats()
+		}
+	})
+
+	b.Run("batched", func(b *testing.B) {
+		fsc := MakeFunctionServiceCache(zap.NewNop())
+		for i := 0; i < b.N; i++ {
+			fsc.AddFuncs(ctx, fsvcs, 10, 0)
+		}
+	})
+}
@@ -19,9 +19,10 @@ package fscache
 import (
 	"bufio"
 	"context"
-	"errors"
 	"fmt"
 	"io"
+	"sort"
+	"time"
 
 	"go.uber.org/zap"
 	"k8s.io/apimachinery/pkg/api/resource"
@@ -34,6 +35,22 @@ import (
 
 type requestType int
 
+// AddressCapPolicy decides how the PoolCache handles a new address of a function
+// holding the maximum number of addresses, see WithMaxAddresses.
+type AddressCapPolicy int
+
+const (
+	// AddressCapReject refuses the new address.
+	AddressCapReject AddressCapPolicy = iota
+	// AddressCapEvictOldest evicts an idle, unpinned address of the function to make
+	// room for the new address, the one of lowest priority and then oldest access time,
+	// and refuses the new address if there is none.
+	AddressCapEvictOldest
+)
+
+// unknownFunctionName is dumped for function services whose function is unknown.
+const unknownFunctionName = "<unknown>"
+
 const (
 	getValue requestType = iota
 	listAvailableValue
@@ -44,6 +61,19 @@ const (
 	markSpecializationFailure
 	logFuncSvc
 	markDeleted
+	forEachSvc
+	waitingRequests
+	stats
+	setCPUUtilizations
+	activeRequests
+	totalActiveRequests
+	setValues
+	listOverCPULimit
+	touchValue
+	listStarved
+	setPinned
+	getPinned
+	deleteByAddress
 )
 
 type (
@@ -62,32 +92,92 @@ type (
 		deleted    bool
 	}
 
+	// funcSvcGroupSnapshot is a copy of a funcSvcGroup used to dump the cache
+	// without holding the service loop while writing.
+	funcSvcGroupSnapshot struct {
+		svcWaiting int
+		queueLen   int
+		svcs       []funcSvcSnapshot
+	}
+
+	funcSvcSnapshot struct {
+		functionName    string
+		address         string
+		activeRequests  int
+		currentCPUUsage resource.Quantity
+		cpuLimit        resource.Quantity
+		owner           string
+		pinned          bool
+		priority        int
+	}
+
+	// PoolSvcValue is a function service to add to the PoolCache with SetSvcValues.
+	PoolSvcValue struct {
+		Function crd.CacheKeyURG
+		Address  string
+		Value    *FuncSvc
+		CPULimit resource.Quantity
+	}
+
+	// CPUReading is the current CPU usage of the function service at Address.
+	CPUReading struct {
+		Function crd.CacheKeyURG
+		Address  string
+		CPUUsage resource.Quantity
+	}
+
+	// PoolServiceVisitor is called for every function service address held in the PoolCache.
+	PoolServiceVisitor func(key string, addr string, fsvc *FuncSvc, cpuUsage, cpuLimit resource.Quantity)
+
 	// PoolCache implements a simple cache implementation having values mapped by two keys [function][address].
 	// As of now PoolCache is only used by poolmanager executor
 	PoolCache struct {
-		cache          map[crd.CacheKeyURG]*funcSvcGroup
-		requestChannel chan *request
-		logger         *zap.Logger
+		cache            map[crd.CacheKeyURG]*funcSvcGroup
+		pinned           map[types.UID]bool // pinned functions, by UID to outlive function updates
+		requestChannel   chan *request
+		logger           *zap.Logger
+		maxAddresses     int // per function, unlimited if zero
+		addressCapPolicy AddressCapPolicy
 	}
 
+	// PoolCacheOption configures a PoolCache created by NewPoolCache.
+	PoolCacheOption func(c *PoolCache)
+
 	request struct {
 		requestType
 		ctx             context.Context
 		function        crd.CacheKeyURG
 		address         string
-		dumpWriter      io.Writer
+		visitor         PoolServiceVisitor
 		value           *FuncSvc
 		requestsPerPod  int
 		cpuUsage        resource.Quantity
 		responseChannel chan *response
 		concurrency     int
 		svcsRetain      int
+		readings        []CPUReading
+		values          []PoolSvcValue
+		uid             types.UID
+		pinned          bool
 	}
 	response struct {
 		error
 		allValues    []*FuncSvc
+		keys         []string
 		value        *FuncSvc
 		svcWaitValue *svcWait
+		count        int
+		groups       []funcSvcGroupSnapshot
+		stats        PoolCacheStats
+		errors       []error
+		pinned       bool
+	}
+
+	// PoolCacheStats holds aggregated counts of the PoolCache.
+	PoolCacheStats struct {
+		Groups          int // number of functions in the cache
+		Services        int // number of function service addresses across all functions
+		WaitingRequests int // number of requests queued for a function service
 	}
 	svcWait struct {
 		svcChannel chan *FuncSvc
@@ -95,13 +185,27 @@ type (
 	}
 )
 
+// WithMaxAddresses caps the number of addresses held per function at maxAddresses,
+// e.g. to contain runaway specialization. A new address beyond the cap is handled by
+// policy. A maxAddresses of zero, the default, leaves the number of addresses unlimited.
+func WithMaxAddresses(maxAddresses int, policy AddressCapPolicy) PoolCacheOption {
+	return func(c *PoolCache) {
+		c.maxAddresses = maxAddresses
+		c.addressCapPolicy = policy
+	}
+}
+
 // NewPoolCache create a Cache object
-func NewPoolCache(logger *zap.Logger) *PoolCache {
+func NewPoolCache(logger *zap.Logger, opts ...PoolCacheOption) *PoolCache {
 	c := &PoolCache{
 		cache:          make(map[crd.CacheKeyURG]*funcSvcGroup),
+		pinned:         make(map[types.UID]bool),
 		requestChannel: make(chan *request),
 		logger:         logger,
 	}
+	for _, opt := range opts {
+		opt(c)
+	}
 	go c.service()
 	return c
 }
@@ -141,7 +245,7 @@ func (c *PoolCache) service() {
 					if c.logger.Core().Enabled(zap.DebugLevel) {
 						otelUtils.LoggerWithTraceID(req.ctx, c.logger).Debug("Increase active requests with getValue", zap.String("function", req.function.String()), zap.String("address", addr), zap.Int("activeRequests", funcSvcGroup.svcs[addr].activeRequests))
 					}
-					resp.value = funcSvcGroup.svcs[addr].val
+					resp.value = funcSvcGroup.svcs[addr].val.touch()
 					found = true
 					break
 				}
@@ -184,40 +288,18 @@ func (c *PoolCache) service() {
 			}
 			req.responseChannel <- resp
 		case setValue:
-			if _, ok := c.cache[req.function]; !ok {
-				c.cache[req.function] = NewFuncSvcGroup()
-			}
-			if _, ok := c.cache[req.function].svcs[req.address]; !ok {
-				c.cache[req.function].svcs[req.address] = &funcSvcInfo{}
-			}
-			c.cache[req.function].svcRetain = req.svcsRetain
-			c.cache[req.function].svcs[req.address].val = req.value
-			c.cache[req.function].svcs[req.address].activeRequests++
-			if c.cache[req.function].svcWaiting > 0 {
-				c.cache[req.function].svcWaiting--
-				svcCapacity := req.requestsPerPod - c.cache[req.function].svcs[req.address].activeRequests
-				queueLen := c.cache[req.function].queue.Len()
-				if svcCapacity > queueLen {
-					svcCapacity = queueLen
-				}
-				for i := 0; i <= svcCapacity; {
-					popped := c.cache[req.function].queue.Pop()
-					if popped == nil {
-						break
-					}
-					if popped.ctx.Err() == nil {
-						popped.svcChannel <- req.value
-						c.cache[req.function].svcs[req.address].activeRequests++
-						i++
-					}
-					close(popped.svcChannel)
-					c.cache[req.function].svcWaiting--
+			resp.value, resp.error = c.setValue(req.ctx, req.function, req.address, req.value, req.cpuUsage, req.requestsPerPod, req.svcsRetain)
+			req.responseChannel <- resp
+		case setValues:
+			resp.errors = make([]error, len(req.values))
+			for i, v := range req.values {
+				var evicted *FuncSvc
+				evicted, resp.errors[i] = c.setValue(req.ctx, v.Function, v.Address, v.Value, v.CPULimit, req.requestsPerPod, req.svcsRetain)
+				if evicted != nil {
+					resp.allValues = append(resp.allValues, evicted)
 				}
 			}
-			if c.logger.Core().Enabled(zap.DebugLevel) {
-				otelUtils.LoggerWithTraceID(req.ctx, c.logger).Debug("Increase active requests with setValue", zap.String("function", req.function.String()), zap.String("address", req.address), zap.Int("activeRequests", c.cache[req.function].svcs[req.address].activeRequests))
-			}
-			c.cache[req.function].svcs[req.address].cpuLimit = req.cpuUsage
+			req.responseChannel <- resp
 		case markDeleted:
 			for key := range c.cache {
 				if key.UID == req.function.UID {
@@ -250,16 +332,30 @@ func (c *PoolCache) service() {
 				if svcCleanQuota <= 0 {
 					continue
 				}
-				for key2, value := range values.svcs {
+				// pick the function services to reap in reaping order
+				addrs := make([]string, 0, len(values.svcs))
+				for addr := range values.svcs {
+					addrs = append(addrs, addr)
+				}
+				sort.Slice(addrs, func(i, j int) bool {
+					return reapsBefore(addrs[i], values.svcs[addrs[i]], addrs[j], values.svcs[addrs[j]])
+				})
+				for _, key2 := range addrs {
+					value := values.svcs[key2]
 					debugLevel := c.logger.Core().Enabled(zap.DebugLevel)
 					if debugLevel {
 						otelUtils.LoggerWithTraceID(req.ctx, c.logger).Debug("Reading active requests", zap.String("function", key1.String()), zap.String("address", key2), zap.Int("activeRequests", value.activeRequests))
 					}
+					// pinned function services are never reaped for being idle
+					if value.val == nil || value.val.Pinned {
+						continue
+					}
 					if value.activeRequests == 0 && svcCleanQuota > 0 {
 						if debugLevel {
 							otelUtils.LoggerWithTraceID(req.ctx, c.logger).Debug("Function service with no active requests", zap.String("function", key1.String()), zap.String("address", key2), zap.Int("activeRequests", value.activeRequests))
 						}
-						vals = append(vals, value.val)
+						valCopy := *value.val
+						vals = append(vals, &valCopy)
 						svcCleanQuota--
 					}
 				}
@@ -273,6 +369,24 @@ func (c *PoolCache) service() {
 			if _, ok := c.cache[req.function].svcs[req.address]; ok {
 				c.cache[req.function].svcs[req.address].currentCPUUsage = req.cpuUsage
 			}
+		case setCPUUtilizations:
+			resp.errors = make([]error, len(req.readings))
+			for i, reading := range req.readings {
+				svcGroup, ok := c.cache[reading.Function]
+				if !ok {
+					resp.errors[i] = ferror.MakeError(ferror.ErrorNotFound,
+						fmt.Sprintf("function '%v' not found", reading.Function))
+					continue
+				}
+				fnSvc, ok := svcGroup.svcs[reading.Address]
+				if !ok {
+					resp.errors[i] = ferror.MakeError(ferror.ErrorNotFound,
+						fmt.Sprintf("address '%v' of function '%v' not found", reading.Address, reading.Function))
+					continue
+				}
+				fnSvc.currentCPUUsage = reading.CPUUsage
+			}
+			req.responseChannel <- resp
 		case markAvailable:
 			if _, ok := c.cache[req.function]; ok {
 				if _, ok = c.cache[req.function].svcs[req.address]; ok {
@@ -295,49 +409,159 @@ func (c *PoolCache) service() {
 				}
 			}
 		case deleteValue:
-			if funcSvcGroup, ok := c.cache[req.function]; ok {
-				delete(c.cache[req.function].svcs, req.address)
-				if funcSvcGroup.deleted && len(c.cache[req.function].svcs) == 0 {
-					delete(c.cache, req.function)
+			c.deleteSvc(req.function, req.address)
+			req.responseChannel <- resp
+		case deleteByAddress:
+			vals := make([]*FuncSvc, 0)
+			for key, funcSvcGroup := range c.cache {
+				if fnSvc, ok := funcSvcGroup.svcs[req.address]; ok {
+					if fnSvc.val != nil {
+						vals = append(vals, fnSvc.val)
+					}
+					c.deleteSvc(key, req.address)
 				}
 			}
+			if len(vals) == 0 {
+				resp.error = ferror.MakeError(ferror.ErrorNotFound,
+					fmt.Sprintf("function service with address '%s' not found", req.address))
+			}
+			resp.allValues = vals
 			req.responseChannel <- resp
 		case logFuncSvc:
-			datawriter := bufio.NewWriter(req.dumpWriter)
-
-			writefnSvcGrp := func(svcGrp *funcSvcGroup) error {
-				_, err := datawriter.WriteString(fmt.Sprintf("svc_waiting:%d\tqueue_len:%d", svcGrp.svcWaiting, svcGrp.queue.Len()))
-				if err != nil {
-					return err
+			// only copy the data here, formatting happens outside of the service loop
+			groups := make([]funcSvcGroupSnapshot, 0, len(c.cache))
+			for key, svcGrp := range c.cache {
+				if req.ctx.Err() != nil {
+					resp.error = req.ctx.Err()
+					break
 				}
-
-				if len(svcGrp.svcs) == 0 {
-					_, err := datawriter.WriteString("\n")
-					if err != nil {
-						return err
+				group := funcSvcGroupSnapshot{
+					svcWaiting: svcGrp.svcWaiting,
+					queueLen:   svcGrp.queue.Len(),
+					svcs:       make([]funcSvcSnapshot, 0, len(svcGrp.svcs)),
+				}
+				_ = visitSvcGroup(svcGrp, func(addr string, fnSvc *funcSvcInfo) error {
+					svc := funcSvcSnapshot{
+						functionName:    unknownFunctionName,
+						address:         addr,
+						activeRequests:  fnSvc.activeRequests,
+						currentCPUUsage: fnSvc.currentCPUUsage.DeepCopy(),
+						cpuLimit:        fnSvc.cpuLimit.DeepCopy(),
+					}
+					// entries may be partially initialized, which must not fail the dump
+					if fnSvc.val != nil {
+						svc.owner = fnSvc.val.Owner
+						svc.pinned = fnSvc.val.Pinned
+						svc.priority = fnSvc.val.Priority
 					}
+					if fnSvc.val != nil && fnSvc.val.Function != nil {
+						svc.functionName = fnSvc.val.Function.Name
+					} else {
+						c.logger.Warn("dumping function service without function",
+							zap.String("function", key.String()), zap.String("address", addr))
+					}
+					group.svcs = append(group.svcs, svc)
+					return nil
+				})
+				groups = append(groups, group)
+			}
+			if resp.error == nil {
+				resp.groups = groups
+			}
+			req.responseChannel <- resp
+		case forEachSvc:
+			for key, svcGrp := range c.cache {
+				if req.ctx.Err() != nil {
+					resp.error = req.ctx.Err()
+					break
 				}
-
-				for addr, fnSvc := range svcGrp.svcs {
-					_, err := datawriter.WriteString(fmt.Sprintf("\tfunction_name:%s\tfn_svc_address:%s\tactive_req:%d\tcurrent_cpu_usage:%v\tcpu_limit:%v\n",
-						fnSvc.val.Function.Name, addr, fnSvc.activeRequests, fnSvc.currentCPUUsage, fnSvc.cpuLimit))
-					if err != nil {
-						return err
+				_ = visitSvcGroup(svcGrp, func(addr string, fnSvc *funcSvcInfo) error {
+					req.visitor(key.String(), addr, fnSvc.val, fnSvc.currentCPUUsage, fnSvc.cpuLimit)
+					return nil
+				})
+			}
+			req.responseChannel <- resp
+		case waitingRequests:
+			if funcSvcGroup, ok := c.cache[req.function]; ok {
+				resp.count = funcSvcGroup.queue.Len()
+			}
+			req.responseChannel <- resp
+		case activeRequests:
+			if funcSvcGroup, ok := c.cache[req.function]; ok {
+				resp.count = funcSvcGroup.activeRequests()
+			}
+			req.responseChannel <- resp
+		case totalActiveRequests:
+			for _, funcSvcGroup := range c.cache {
+				resp.count += funcSvcGroup.activeRequests()
+			}
+			req.responseChannel <- resp
+		case touchValue:
+			var fnSvc *funcSvcInfo
+			if funcSvcGroup, ok := c.cache[req.function]; ok {
+				fnSvc = funcSvcGroup.svcs[req.address]
+			}
+			if fnSvc == nil || fnSvc.val == nil {
+				resp.error = ferror.MakeError(ferror.ErrorNotFound,
+					fmt.Sprintf("function '%s' address '%s' not found", req.function, req.address))
+			} else {
+				fnSvc.val.touch()
+			}
+			req.responseChannel <- resp
+		case listOverCPULimit:
+			vals := make([]*FuncSvc, 0)
+			for _, funcSvcGroup := range c.cache {
+				_ = visitSvcGroup(funcSvcGroup, func(addr string, fnSvc *funcSvcInfo) error {
+					if fnSvc.val != nil && fnSvc.currentCPUUsage.Cmp(fnSvc.cpuLimit) > 0 {
+						valCopy := *fnSvc.val
+						vals = append(vals, &valCopy)
 					}
+					return nil
+				})
+			}
+			resp.allValues = vals
+			req.responseChannel <- resp
+		case listStarved:
+			keys := make([]string, 0)
+			for key, funcSvcGroup := range c.cache {
+				if funcSvcGroup.svcWaiting > 0 && !funcSvcGroup.hasIdleSvc() {
+					keys = append(keys, key.String())
 				}
-				return nil
 			}
-
-			for _, fnSvcGrp := range c.cache {
-				err := writefnSvcGrp(fnSvcGrp)
-				if err != nil {
-					resp.error = err
-					break
+			sort.Strings(keys)
+			resp.keys = keys
+			req.responseChannel <- resp
+		case setPinned:
+			if !c.pinned[req.uid] && !c.hasGroup(req.uid) {
+				resp.error = ferror.MakeError(ferror.ErrorNotFound,
+					fmt.Sprintf("function with UID '%s' not found", req.uid))
+				req.responseChannel <- resp
+				continue
+			}
+			if req.pinned {
+				c.pinned[req.uid] = true
+			} else {
+				delete(c.pinned, req.uid)
+			}
+			for key, funcSvcGroup := range c.cache {
+				if key.UID != req.uid {
+					continue
+				}
+				for _, fnSvc := range funcSvcGroup.svcs {
+					if fnSvc.val != nil {
+						fnSvc.val.Pinned = req.pinned
+					}
 				}
 			}
-			err := datawriter.Flush()
-			if err != nil {
-				resp.error = errors.Join(resp.error, err)
+			req.responseChannel <- resp
+		case getPinned:
+			resp.pinned = c.pinned[req.uid]
+			req.responseChannel <- resp
+		case stats:
+			resp.stats.Groups = len(c.cache)
+			for _, funcSvcGroup := range c.cache {
+				resp.stats.Services += len(funcSvcGroup.svcs)
+				resp.stats.WaitingRequests += funcSvcGroup.queue.Len()
 			}
 			req.responseChannel <- resp
 		default:
@@ -348,6 +572,63 @@ func (c *PoolCache) service() {
 	}
 }
 
+// visitSvcGroup calls visit for each function service address of the group
+// and stops at the first error.
+func visitSvcGroup(svcGrp *funcSvcGroup, visit func(addr string, fnSvc *funcSvcInfo) error) error {
+	for addr, fnSvc := range svcGrp.svcs {
+		err := visit(addr, fnSvc)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// hasIdleSvc checks if the group has a function service serving no request within its CPU limit.
+func (svcGrp *funcSvcGroup) hasIdleSvc() bool {
+	for _, fnSvc := range svcGrp.svcs {
+		if fnSvc.activeRequests == 0 && fnSvc.currentCPUUsage.Cmp(fnSvc.cpuLimit) < 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// deleteSvc deletes the function service at address of function, and the group of
+// a deleted function once it is empty.
+func (c *PoolCache) deleteSvc(function crd.CacheKeyURG, address string) {
+	funcSvcGroup, ok := c.cache[function]
+	if !ok {
+		return
+	}
+	delete(funcSvcGroup.svcs, address)
+	if funcSvcGroup.deleted && len(funcSvcGroup.svcs) == 0 {
+		delete(c.cache, function)
+		if !c.hasGroup(function.UID) {
+			delete(c.pinned, function.UID)
+		}
+	}
+}
+
+// hasGroup checks if the cache holds function services of any generation of the function with uid.
+func (c *PoolCache) hasGroup(uid types.UID) bool {
+	for key := range c.cache {
+		if key.UID == uid {
+			return true
+		}
+	}
+	return false
+}
+
+// activeRequests returns the number of requests served by the function services of the group.
+func (svcGrp *funcSvcGroup) activeRequests() int {
+	total := 0
+	for _, fnSvc := range svcGrp.svcs {
+		total += fnSvc.activeRequests
+	}
+	return total
+}
+
 func (c *PoolCache) MarkFuncDeleted(function crd.CacheKeyURG) {
 	c.requestChannel <- &request{
 		requestType: markDeleted,
@@ -355,7 +636,119 @@ func (c *PoolCache) MarkFuncDeleted(function crd.CacheKeyURG) {
 	}
 }
 
+// setValue adds value at key [function][address] as active (being used) and hands it
+// to the requests waiting for a function service of function, and returns the function
+// service evicted to stay within the address cap of the cache, if any. It must only be
+// called from the service loop.
+func (c *PoolCache) setValue(ctx context.Context, function crd.CacheKeyURG, address string, value *FuncSvc, cpuLimit resource.Quantity, requestsPerPod, svcsRetain int) (*FuncSvc, error) {
+	if _, ok := c.cache[function]; !ok {
+		c.cache[function] = NewFuncSvcGroup()
+	}
+	var evicted *FuncSvc
+	if _, ok := c.cache[function].svcs[address]; !ok {
+		if c.maxAddresses > 0 && len(c.cache[function].svcs) >= c.maxAddresses {
+			var err error
+			evicted, err = c.evictAddress(ctx, function)
+			if err != nil {
+				return nil, err
+			}
+		}
+		c.cache[function].svcs[address] = &funcSvcInfo{}
+	}
+	c.cache[function].svcRetain = svcsRetain
+	if value != nil {
+		value.Pinned = value.Pinned || c.pinned[function.UID]
+	}
+	c.cache[function].svcs[address].val = value
+	c.cache[function].svcs[address].activeRequests++
+	if c.cache[function].svcWaiting > 0 {
+		c.cache[function].svcWaiting--
+		svcCapacity := requestsPerPod - c.cache[function].svcs[address].activeRequests
+		queueLen := c.cache[function].queue.Len()
+		if svcCapacity > queueLen {
+			svcCapacity = queueLen
+		}
+		for i := 0; i <= svcCapacity; {
+			popped := c.cache[function].queue.Pop()
+			if popped == nil {
+				break
+			}
+			if popped.ctx.Err() == nil {
+				popped.svcChannel <- value.touch()
+				c.cache[function].svcs[address].activeRequests++
+				i++
+			}
+			close(popped.svcChannel)
+			c.cache[function].svcWaiting--
+		}
+	}
+	if c.logger.Core().Enabled(zap.DebugLevel) {
+		otelUtils.LoggerWithTraceID(ctx, c.logger).Debug("Increase active requests with setValue", zap.String("function", function.String()), zap.String("address", address), zap.Int("activeRequests", c.cache[function].svcs[address].activeRequests))
+	}
+	c.cache[function].svcs[address].cpuLimit = cpuLimit
+	return evicted, nil
+}
+
+// evictAddress makes room for a new address of function, which holds the maximum
+// number of addresses, according to the address cap policy of the cache.
+func (c *PoolCache) evictAddress(ctx context.Context, function crd.CacheKeyURG) (*FuncSvc, error) {
+	group := c.cache[function]
+	if c.addressCapPolicy != AddressCapEvictOldest {
+		return nil, ferror.MakeError(ferror.ErrorTooManyRequests,
+			fmt.Sprintf("function '%s' address limit %d reached", function, c.maxAddresses))
+	}
+
+	// evict in reaping order, see sortReapOrder
+	var oldestAddr string
+	var oldest *funcSvcInfo
+	for addr, svc := range group.svcs {
+		if svc.activeRequests > 0 || (svc.val != nil && svc.val.Pinned) {
+			continue
+		}
+		if oldest == nil || reapsBefore(addr, svc, oldestAddr, oldest) {
+			oldestAddr, oldest = addr, svc
+		}
+	}
+	if oldest == nil {
+		return nil, ferror.MakeError(ferror.ErrorTooManyRequests,
+			fmt.Sprintf("function '%s' address limit %d reached and all addresses are busy or pinned", function, c.maxAddresses))
+	}
+	delete(group.svcs, oldestAddr)
+	otelUtils.LoggerWithTraceID(ctx, c.logger).Info("evicted function service at address limit",
+		zap.String("function", function.String()), zap.String("address", oldestAddr), zap.Int("maxAddresses", c.maxAddresses))
+	return oldest.val, nil
+}
+
+// svcAtime returns the access time of svc, or the zero time for partially initialized entries.
+func svcAtime(svc *funcSvcInfo) time.Time {
+	if svc.val == nil {
+		return time.Time{}
+	}
+	return svc.val.Atime
+}
+
+// svcPriority returns the priority of svc, or zero for partially initialized entries.
+func svcPriority(svc *funcSvcInfo) int {
+	if svc.val == nil {
+		return 0
+	}
+	return svc.val.Priority
+}
+
+// reapsBefore checks if svc at addr is reaped before other at otherAddr: by ascending
+// priority, by ascending access time for the same priority, then by address.
+func reapsBefore(addr string, svc *funcSvcInfo, otherAddr string, other *funcSvcInfo) bool {
+	if svcPriority(svc) != svcPriority(other) {
+		return svcPriority(svc) < svcPriority(other)
+	}
+	if !svcAtime(svc).Equal(svcAtime(other)) {
+		return svcAtime(svc).Before(svcAtime(other))
+	}
+	return addr < otherAddr
+}
+
 // GetValue returns a function service with status in Active else return error
+// The access time of the function service is updated and a copy of it is returned.
 func (c *PoolCache) GetSvcValue(ctx context.Context, function crd.CacheKeyURG, requestsPerPod int, concurrency int) (*FuncSvc, error) {
 	respChannel := make(chan *response)
 	c.requestChannel <- &request{
@@ -379,7 +772,9 @@ func (c *PoolCache) GetSvcValue(ctx context.Context, function crd.CacheKeyURG, r
 	return resp.value, resp.error
 }
 
-// ListAvailableValue returns a list of the available function services stored in the Cache
+// ListAvailableValue returns copies of the available function services stored in the
+// Cache beyond the function services to retain, leaving out pinned ones. Of each
+// function, those reaped first by priority and access time are picked.
 func (c *PoolCache) ListAvailableValue() []*FuncSvc {
 	respChannel := make(chan *response)
 	c.requestChannel <- &request{
@@ -390,8 +785,35 @@ func (c *PoolCache) ListAvailableValue() []*FuncSvc {
 	return resp.allValues
 }
 
+// ListOverCPULimit returns copies of the function services whose current CPU usage exceeds
+// their CPU limit, i.e. the throttled ones getValue skips, e.g. to scale their functions out.
+func (c *PoolCache) ListOverCPULimit() []*FuncSvc {
+	respChannel := make(chan *response)
+	c.requestChannel <- &request{
+		requestType:     listOverCPULimit,
+		responseChannel: respChannel,
+	}
+	resp := <-respChannel
+	return resp.allValues
+}
+
+// ListStarved returns the sorted keys of the functions with requests waiting for a
+// function service while none of their function services is idle, i.e. serving no
+// request within its CPU limit. Many starved functions hint at a cold start storm.
+func (c *PoolCache) ListStarved() []string {
+	respChannel := make(chan *response)
+	c.requestChannel <- &request{
+		requestType:     listStarved,
+		responseChannel: respChannel,
+	}
+	resp := <-respChannel
+	return resp.keys
+}
+
 // SetValue marks the value at key [function][address] as active(begin used)
-func (c *PoolCache) SetSvcValue(ctx context.Context, function crd.CacheKeyURG, address string, value *FuncSvc, cpuLimit resource.Quantity, requestsPerPod, svcsRetain int) {
+// A new address beyond the cap set with WithMaxAddresses is refused with an error, or
+// takes the place of the evicted function service returned, depending on the policy.
+func (c *PoolCache) SetSvcValue(ctx context.Context, function crd.CacheKeyURG, address string, value *FuncSvc, cpuLimit resource.Quantity, requestsPerPod, svcsRetain int) (*FuncSvc, error) {
 	respChannel := make(chan *response)
 	c.requestChannel <- &request{
 		ctx:             ctx,
@@ -404,6 +826,25 @@ func (c *PoolCache) SetSvcValue(ctx context.Context, function crd.CacheKeyURG, a
 		svcsRetain:      svcsRetain,
 		responseChannel: respChannel,
 	}
+	resp := <-respChannel
+	return resp.value, resp.error
+}
+
+// SetSvcValues adds a batch of function services, e.g. of a scaled up warm pool, in a
+// single request to the cache, marking each of them active like SetSvcValue. It returns
+// the function services evicted at the address cap, and errors indexed like values.
+func (c *PoolCache) SetSvcValues(ctx context.Context, values []PoolSvcValue, requestsPerPod, svcsRetain int) ([]*FuncSvc, []error) {
+	respChannel := make(chan *response)
+	c.requestChannel <- &request{
+		ctx:             ctx,
+		requestType:     setValues,
+		values:          values,
+		requestsPerPod:  requestsPerPod,
+		svcsRetain:      svcsRetain,
+		responseChannel: respChannel,
+	}
+	resp := <-respChannel
+	return resp.allValues, resp.errors
 }
 
 // SetCPUUtilization updates/sets the CPU utilization limit for the pod
@@ -417,6 +858,64 @@ func (c *PoolCache) SetCPUUtilization(function crd.CacheKeyURG, address string,
 	}
 }
 
+// SetCPUUtilizations applies a batch of CPU readings, e.g. of a whole metrics scrape,
+// in a single request to the cache. The returned errors are indexed like readings;
+// readings of unknown functions or addresses get an ErrorNotFound error.
+func (c *PoolCache) SetCPUUtilizations(readings []CPUReading) []error {
+	respChannel := make(chan *response)
+	c.requestChannel <- &request{
+		requestType:     setCPUUtilizations,
+		readings:        readings,
+		responseChannel: respChannel,
+	}
+	resp := <-respChannel
+	return resp.errors
+}
+
+// TouchValue updates the access time of the value at key [function][address], so that
+// a function service used without going through GetSvcValue is not considered idle.
+// It returns an ErrorNotFound error if there is no such value.
+func (c *PoolCache) TouchValue(function crd.CacheKeyURG, address string) error {
+	respChannel := make(chan *response)
+	c.requestChannel <- &request{
+		requestType:     touchValue,
+		function:        function,
+		address:         address,
+		responseChannel: respChannel,
+	}
+	resp := <-respChannel
+	return resp.error
+}
+
+// Pin sets whether the function services of the function with uid are pinned, for
+// all its generations and including the function services added later. Pinned
+// function services are neither listed by ListAvailableValue nor evicted at the
+// address cap, see WithMaxAddresses. It returns an ErrorNotFound error if the
+// cache has never been asked for a function service of the function.
+func (c *PoolCache) Pin(uid types.UID, pinned bool) error {
+	respChannel := make(chan *response)
+	c.requestChannel <- &request{
+		requestType:     setPinned,
+		uid:             uid,
+		pinned:          pinned,
+		responseChannel: respChannel,
+	}
+	resp := <-respChannel
+	return resp.error
+}
+
+// IsPinned checks if the function with uid is pinned, see Pin.
+func (c *PoolCache) IsPinned(uid types.UID) bool {
+	respChannel := make(chan *response)
+	c.requestChannel <- &request{
+		requestType:     getPinned,
+		uid:             uid,
+		responseChannel: respChannel,
+	}
+	resp := <-respChannel
+	return resp.pinned
+}
+
 // MarkAvailable marks the value at key [function][address] as available
 func (c *PoolCache) MarkAvailable(function crd.CacheKeyURG, address string) {
 	respChannel := make(chan *response)
@@ -442,6 +941,20 @@ func (c *PoolCache) DeleteValue(ctx context.Context, function crd.CacheKeyURG, a
 	return resp.error
 }
 
+// DeleteByAddress deletes the function services at address of any function, and
+// returns them. It returns an ErrorNotFound error if there is none.
+func (c *PoolCache) DeleteByAddress(ctx context.Context, address string) ([]*FuncSvc, error) {
+	respChannel := make(chan *response)
+	c.requestChannel <- &request{
+		ctx:             ctx,
+		requestType:     deleteByAddress,
+		address:         address,
+		responseChannel: respChannel,
+	}
+	resp := <-respChannel
+	return resp.allValues, resp.error
+}
+
 // ReduceSpecializationInProgress reduces the svcWaiting count
 func (c *PoolCache) MarkSpecializationFailure(function crd.CacheKeyURG) {
 	c.requestChannel <- &request{
@@ -451,13 +964,120 @@ func (c *PoolCache) MarkSpecializationFailure(function crd.CacheKeyURG) {
 	}
 }
 
+// LogFnSvcGroup writes the function service groups to file. The cache contents are
+// copied inside the service loop and written afterwards, so a slow writer or a large
+// cache does not block other cache requests. ctx is checked between groups and its
+// error is returned once it is done; the buffered output is not flushed then, but
+// output exceeding the buffer may already have been written to file.
 func (c *PoolCache) LogFnSvcGroup(ctx context.Context, file io.Writer) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	respChannel := make(chan *response)
 	c.requestChannel <- &request{
+		ctx:             ctx,
 		requestType:     logFuncSvc,
-		dumpWriter:      file,
+		responseChannel: respChannel,
+	}
+	resp := <-respChannel
+	if resp.error != nil {
+		return resp.error
+	}
+
+	datawriter := bufio.NewWriter(file)
+	err := writeFnSvcGroups(ctx, datawriter, resp.groups)
+	if err != nil {
+		return err
+	}
+	return datawriter.Flush()
+}
+
+func writeFnSvcGroups(ctx context.Context, datawriter *bufio.Writer, groups []funcSvcGroupSnapshot) error {
+	for _, svcGrp := range groups {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		_, err := datawriter.WriteString(fmt.Sprintf("svc_waiting:%d\tqueue_len:%d", svcGrp.svcWaiting, svcGrp.queueLen))
+		if err != nil {
+			return err
+		}
+
+		if len(svcGrp.svcs) == 0 {
+			_, err := datawriter.WriteString("\n")
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, fnSvc := range svcGrp.svcs {
+			_, err := datawriter.WriteString(fmt.Sprintf("\tfunction_name:%s\tfn_svc_address:%s\tactive_req:%d\tcurrent_cpu_usage:%v\tcpu_limit:%v\tpriority:%d\tpinned:%t\towner:%s\n",
+				fnSvc.functionName, fnSvc.address, fnSvc.activeRequests, fnSvc.currentCPUUsage, fnSvc.cpuLimit, fnSvc.priority, fnSvc.pinned, fnSvc.owner))
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ForEachSvc calls visitor for each function service address in the cache.
+// The visitor runs inside the cache service loop, so it must not call back into the PoolCache.
+func (c *PoolCache) ForEachSvc(ctx context.Context, visitor PoolServiceVisitor) error {
+	respChannel := make(chan *response)
+	c.requestChannel <- &request{
+		ctx:             ctx,
+		requestType:     forEachSvc,
+		visitor:         visitor,
 		responseChannel: respChannel,
 	}
 	resp := <-respChannel
 	return resp.error
 }
+
+// WaitingRequests returns the number of requests queued for a function service of the function.
+func (c *PoolCache) WaitingRequests(function crd.CacheKeyURG) int {
+	respChannel := make(chan *response)
+	c.requestChannel <- &request{
+		requestType:     waitingRequests,
+		function:        function,
+		responseChannel: respChannel,
+	}
+	resp := <-respChannel
+	return resp.count
+}
+
+// ActiveRequests returns the number of requests served by the function services of the function.
+func (c *PoolCache) ActiveRequests(function crd.CacheKeyURG) int {
+	respChannel := make(chan *response)
+	c.requestChannel <- &request{
+		requestType:     activeRequests,
+		function:        function,
+		responseChannel: respChannel,
+	}
+	resp := <-respChannel
+	return resp.count
+}
+
+// TotalActiveRequests returns the number of requests served by all function services in the cache.
+// The count is taken in a single request of the service loop, so it is consistent across functions.
+func (c *PoolCache) TotalActiveRequests() int {
+	respChannel := make(chan *response)
+	c.requestChannel <- &request{
+		requestType:     totalActiveRequests,
+		responseChannel: respChannel,
+	}
+	resp := <-respChannel
+	return resp.count
+}
+
+// Stats returns the number of functions, function services and waiting requests in the cache.
+func (c *PoolCache) Stats() PoolCacheStats {
+	respChannel := make(chan *response)
+	c.requestChannel <- &request{
+		requestType:     stats,
+		responseChannel: respChannel,
+	}
+	resp := <-respChannel
+	return resp.stats
+}
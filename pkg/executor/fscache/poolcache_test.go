@@ -1,16 +1,24 @@
 package fscache
 
 import (
+	"bufio"
+	"bytes"
 	"context"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
+	"sort"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 
 	"github.com/fission/fission/pkg/crd"
 	ferror "github.com/fission/fission/pkg/error"
@@ -289,3 +297,499 @@ func TestPoolCacheRequests(t *testing.T) {
 		})
 	}
 }
+
+func TestPoolCacheForEachSvc(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	keyFunc := crd.CacheKeyURG{UID: "func"}
+	keyFunc2 := crd.CacheKeyURG{UID: "func2"}
+
+	c := NewPoolCache(loggerfactory.GetLogger())
+	c.SetSvcValue(ctx, keyFunc, "ip1", &FuncSvc{Name: "value1"}, resource.MustParse("45m"), 10, 0)
+	c.SetSvcValue(ctx, keyFunc, "ip2", &FuncSvc{Name: "value2"}, resource.MustParse("45m"), 10, 0)
+	c.SetSvcValue(ctx, keyFunc2, "ip3", &FuncSvc{Name: "value3"}, resource.MustParse("50m"), 10, 0)
+	c.SetCPUUtilization(keyFunc2, "ip3", resource.MustParse("4m"))
+
+	visited := make(map[string]string)
+	err := c.ForEachSvc(ctx, func(key string, addr string, fsvc *FuncSvc, cpuUsage, cpuLimit resource.Quantity) {
+		visited[addr] = key
+		if addr == "ip3" {
+			require.Equal(t, "value3", fsvc.Name)
+			require.Equal(t, "4m", cpuUsage.String())
+			require.Equal(t, "50m", cpuLimit.String())
+		}
+	})
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{
+		"ip1": keyFunc.String(),
+		"ip2": keyFunc.String(),
+		"ip3": keyFunc2.String(),
+	}, visited)
+
+	cancelledCtx, cancelVisit := context.WithCancel(ctx)
+	cancelVisit()
+	err = c.ForEachSvc(cancelledCtx, func(key string, addr string, fsvc *FuncSvc, cpuUsage, cpuLimit resource.Quantity) {
+		t.Fatalf("visitor called with cancelled context")
+	})
+	require.ErrorIs(t, err, context.Canceled)
+}
+
+func TestPoolCacheWaitingRequests(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	key := crd.CacheKeyURG{UID: "func"}
+	c := NewPoolCache(loggerfactory.GetLogger())
+
+	// unknown function has no queued requests
+	require.Equal(t, 0, c.WaitingRequests(key))
+
+	// first request starts specialization
+	_, err := c.GetSvcValue(ctx, key, 2, 1)
+	require.Error(t, err)
+	require.Equal(t, 0, c.WaitingRequests(key))
+
+	// concurrency is exhausted, so the next request is queued until a service is available
+	svcCh := make(chan *FuncSvc)
+	go func() {
+		svc, _ := c.GetSvcValue(ctx, key, 2, 1)
+		svcCh <- svc
+	}()
+	require.Eventually(t, func() bool {
+		return c.WaitingRequests(key) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	c.SetSvcValue(ctx, key, "ip", &FuncSvc{Name: "value"}, resource.MustParse("45m"), 2, 0)
+	svc := <-svcCh
+	require.NotNil(t, svc)
+	require.Equal(t, "value", svc.Name)
+	require.Equal(t, 0, c.WaitingRequests(key))
+}
+
+func TestPoolCacheActiveRequests(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	key1 := crd.CacheKeyURG{UID: "func1"}
+	key2 := crd.CacheKeyURG{UID: "func2"}
+	key3 := crd.CacheKeyURG{UID: "func3"}
+	c := NewPoolCache(loggerfactory.GetLogger())
+
+	require.Equal(t, 0, c.TotalActiveRequests())
+	require.Equal(t, 0, c.ActiveRequests(key1))
+
+	// each new service serves the request which specialized it
+	c.SetSvcValue(ctx, key1, "ip1", &FuncSvc{Name: "value1"}, resource.MustParse("45m"), 10, 0)
+	c.SetSvcValue(ctx, key1, "ip2", &FuncSvc{Name: "value2"}, resource.MustParse("45m"), 10, 0)
+	c.SetSvcValue(ctx, key2, "ip3", &FuncSvc{Name: "value3"}, resource.MustParse("45m"), 10, 0)
+	c.SetSvcValue(ctx, key3, "ip4", &FuncSvc{Name: "value4"}, resource.MustParse("45m"), 10, 0)
+
+	for i := 0; i < 3; i++ {
+		_, err := c.GetSvcValue(ctx, key1, 10, 0)
+		require.NoError(t, err)
+	}
+	c.MarkAvailable(key2, "ip3")
+
+	require.Equal(t, 5, c.ActiveRequests(key1))
+	require.Equal(t, 0, c.ActiveRequests(key2))
+	require.Equal(t, 1, c.ActiveRequests(key3))
+	require.Equal(t, 0, c.ActiveRequests(crd.CacheKeyURG{UID: "unknown"}))
+	require.Equal(t, 6, c.TotalActiveRequests())
+
+	require.NoError(t, c.DeleteValue(ctx, key3, "ip4"))
+	require.Equal(t, 5, c.TotalActiveRequests())
+}
+
+func TestPoolCacheLogFnSvcGroup(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	c := NewPoolCache(loggerfactory.GetLogger())
+	c.SetSvcValue(ctx, crd.CacheKeyURG{UID: "func"}, "ip1", &FuncSvc{
+		Function: &metav1.ObjectMeta{Name: "fn"},
+	}, resource.MustParse("45m"), 10, 0)
+
+	var buf bytes.Buffer
+	require.NoError(t, c.LogFnSvcGroup(ctx, &buf))
+	require.True(t, strings.HasPrefix(buf.String(), "svc_waiting:0\tqueue_len:0\tfunction_name:fn\tfn_svc_address:ip1\tactive_req:1\t"))
+	require.Equal(t, 1, strings.Count(buf.String(), "\n"))
+}
+
+func TestPoolCacheLogFnSvcGroupPartialEntries(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	c := NewPoolCache(loggerfactory.GetLogger())
+	c.SetSvcValue(ctx, crd.CacheKeyURG{UID: "no-function"}, "ip1", &FuncSvc{Owner: "executor-1", Pinned: true},
+		resource.MustParse("45m"), 10, 0)
+	c.SetSvcValue(ctx, crd.CacheKeyURG{UID: "no-value"}, "ip2", nil, resource.MustParse("45m"), 10, 0)
+
+	var buf bytes.Buffer
+	require.NoError(t, c.LogFnSvcGroup(ctx, &buf))
+	require.Contains(t, buf.String(), "function_name:<unknown>\tfn_svc_address:ip1\tactive_req:1\t")
+	require.Contains(t, buf.String(), "pinned:true\towner:executor-1\n")
+	require.Contains(t, buf.String(), "function_name:<unknown>\tfn_svc_address:ip2\tactive_req:1\t")
+	require.Contains(t, buf.String(), "pinned:false\towner:\n")
+	require.Equal(t, 2, strings.Count(buf.String(), "\n"))
+}
+
+// BenchmarkLogFnSvcGroup compares how long the service loop is held while dumping
+// 10k entries: "snapshot" is the copy done by LogFnSvcGroup, "inline-format" writes
+// every entry from inside the loop as the dump used to do.
+func BenchmarkLogFnSvcGroup(b *testing.B) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	c := NewPoolCache(loggerfactory.GetLogger())
+	for i := 0; i < 10000; i++ {
+		c.SetSvcValue(ctx, crd.CacheKeyURG{UID: types.UID(fmt.Sprintf("func-%d", i))}, fmt.Sprintf("ip-%d", i), &FuncSvc{
+			Function: &metav1.ObjectMeta{Name: fmt.Sprintf("fn-%d", i)},
+		}, resource.MustParse("45m"), 10, 0)
+	}
+
+	b.Run("snapshot", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			respChannel := make(chan *response)
+			c.requestChannel <- &request{
+				requestType:     logFuncSvc,
+				responseChannel: respChannel,
+			}
+			<-respChannel
+		}
+	})
+
+	b.Run("inline-format", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			datawriter := bufio.NewWriter(io.Discard)
+			err := c.ForEachSvc(ctx, func(key string, addr string, fsvc *FuncSvc, cpuUsage, cpuLimit resource.Quantity) {
+				_, _ = datawriter.WriteString(fmt.Sprintf("\tfunction_name:%s\tfn_svc_address:%s\tactive_req:%d\tcurrent_cpu_usage:%v\tcpu_limit:%v\n",
+					fsvc.Function.Name, addr, 0, cpuUsage, cpuLimit))
+			})
+			require.NoError(b, err)
+			require.NoError(b, datawriter.Flush())
+		}
+	})
+
+	b.Run("full-dump", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			require.NoError(b, c.LogFnSvcGroup(ctx, io.Discard))
+		}
+	})
+}
+
+func TestPoolCacheSetCPUUtilizations(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	c := NewPoolCache(loggerfactory.GetLogger())
+	key := crd.CacheKeyURG{UID: "func"}
+	for _, addr := range []string{"ip1", "ip2"} {
+		c.SetSvcValue(ctx, key, addr, &FuncSvc{
+			Function: &metav1.ObjectMeta{Name: "fn"},
+		}, resource.MustParse("45m"), 10, 0)
+	}
+
+	errs := c.SetCPUUtilizations([]CPUReading{
+		{Function: key, Address: "ip1", CPUUsage: resource.MustParse("10m")},
+		{Function: crd.CacheKeyURG{UID: "missing"}, Address: "ip1", CPUUsage: resource.MustParse("20m")},
+		{Function: key, Address: "missing", CPUUsage: resource.MustParse("30m")},
+		{Function: key, Address: "ip2", CPUUsage: resource.MustParse("40m")},
+	})
+	require.Len(t, errs, 4)
+	require.NoError(t, errs[0])
+	require.NoError(t, errs[3])
+	for _, err := range errs[1:3] {
+		fe, ok := err.(ferror.Error)
+		require.True(t, ok, "expected ferror.Error, got %v", err)
+		require.Equal(t, ferror.ErrorNotFound, int(fe.Code))
+	}
+
+	usage := make(map[string]string)
+	err := c.ForEachSvc(ctx, func(key string, addr string, fsvc *FuncSvc, cpuUsage, cpuLimit resource.Quantity) {
+		usage[addr] = cpuUsage.String()
+	})
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"ip1": "10m", "ip2": "40m"}, usage)
+	// unknown functions are not added to the cache
+	require.Equal(t, 1, c.Stats().Groups)
+}
+
+func TestPoolCacheListOverCPULimit(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	c := NewPoolCache(loggerfactory.GetLogger())
+	require.Empty(t, c.ListOverCPULimit())
+
+	readings := make([]CPUReading, 0)
+	for addr, usage := range map[string]string{"above": "60m", "at": "45m", "below": "10m"} {
+		key := crd.CacheKeyURG{UID: types.UID("func-" + addr)}
+		c.SetSvcValue(ctx, key, addr, &FuncSvc{
+			Function: &metav1.ObjectMeta{Name: "fn-" + addr},
+			Address:  addr,
+		}, resource.MustParse("45m"), 10, 0)
+		readings = append(readings, CPUReading{Function: key, Address: addr, CPUUsage: resource.MustParse(usage)})
+	}
+	// an address of the same function above its limit, with a different limit
+	c.SetSvcValue(ctx, crd.CacheKeyURG{UID: "func-below"}, "below-throttled", &FuncSvc{
+		Function: &metav1.ObjectMeta{Name: "fn-below"},
+		Address:  "below-throttled",
+	}, resource.MustParse("5m"), 10, 0)
+	readings = append(readings, CPUReading{Function: crd.CacheKeyURG{UID: "func-below"}, Address: "below-throttled", CPUUsage: resource.MustParse("0.01")})
+	for _, err := range c.SetCPUUtilizations(readings) {
+		require.NoError(t, err)
+	}
+
+	addrs := make([]string, 0)
+	for _, fsvc := range c.ListOverCPULimit() {
+		addrs = append(addrs, fsvc.Address)
+		// the listed function services are copies
+		fsvc.Address = "changed"
+	}
+	require.ElementsMatch(t, []string{"above", "below-throttled"}, addrs)
+	addrs = addrs[:0]
+	for _, fsvc := range c.ListOverCPULimit() {
+		addrs = append(addrs, fsvc.Address)
+	}
+	require.ElementsMatch(t, []string{"above", "below-throttled"}, addrs)
+
+	// the function service cache lists the same services, and none without pool cache
+	fsc := MakeFunctionServiceCache(loggerfactory.GetLogger())
+	fsc.connFunctionCache = c
+	require.Len(t, fsc.ListOverCPULimit(), 2)
+	fsc.connFunctionCache = nil
+	require.Empty(t, fsc.ListOverCPULimit())
+}
+
+func TestPoolCacheListStarved(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	c := NewPoolCache(loggerfactory.GetLogger())
+	require.Empty(t, c.ListStarved())
+
+	starved := crd.CacheKeyURG{UID: "func-starved"}
+	healthy := crd.CacheKeyURG{UID: "func-healthy"}
+	for _, key := range []crd.CacheKeyURG{starved, healthy} {
+		// every request starts a specialization, within the concurrency
+		for i := 0; i < 3; i++ {
+			_, err := c.GetSvcValue(ctx, key, 1, 3)
+			require.Error(t, err)
+		}
+		c.SetSvcValue(ctx, key, "busy", &FuncSvc{Address: "busy"}, resource.MustParse("45m"), 1, 0)
+	}
+	require.ElementsMatch(t, []string{starved.String(), healthy.String()}, c.ListStarved())
+
+	// the healthy function has an idle function service, while a specialization is still waited for
+	c.SetSvcValue(ctx, healthy, "idle", &FuncSvc{Address: "idle"}, resource.MustParse("45m"), 1, 0)
+	c.MarkAvailable(healthy, "idle")
+	require.Equal(t, []string{starved.String()}, c.ListStarved())
+
+	// the function service cache lists the same keys, and none without pool cache
+	fsc := MakeFunctionServiceCache(loggerfactory.GetLogger())
+	fsc.connFunctionCache = c
+	require.Equal(t, []string{starved.String()}, fsc.ListStarved())
+	fsc.connFunctionCache = nil
+	require.Empty(t, fsc.ListStarved())
+}
+
+// BenchmarkSetCPUUtilization compares updating the CPU usage of 1000 addresses
+// one request per address with a single batched request.
+func BenchmarkSetCPUUtilization(b *testing.B) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	c := NewPoolCache(loggerfactory.GetLogger())
+	readings := make([]CPUReading, 0, 1000)
+	for i := 0; i < 1000; i++ {
+		key := crd.CacheKeyURG{UID: types.UID(fmt.Sprintf("func-%d", i%100))}
+		addr := fmt.Sprintf("ip-%d", i)
+		c.SetSvcValue(ctx, key, addr, &FuncSvc{
+			Function: &metav1.ObjectMeta{Name: fmt.Sprintf("fn-%d", i%100)},
+		}, resource.MustParse("45m"), 10, 0)
+		readings = append(readings, CPUReading{Function: key, Address: addr, CPUUsage: resource.MustParse("10m")})
+	}
+
+	b.Run("single", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for _, reading := range readings {
+				c.SetCPUUtilization(reading.Function, reading.Address, reading.CPUUsage)
+			}
+			// wait for the service loop to apply all readings
+			c.Stats()
+		}
+	})
+
+	b.Run("batched", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			c.SetCPUUtilizations(readings)
+		}
+	})
+}
+
+func TestPoolCacheMaxAddresses(t *testing.T) {
+	ctx := context.Background()
+	key := crd.CacheKeyURG{UID: "func"}
+	other := crd.CacheKeyURG{UID: "other"}
+	cpuLimit := resource.MustParse("45m")
+	now := time.Now()
+
+	addresses := func(t *testing.T, c *PoolCache) []string {
+		t.Helper()
+		addrs := make([]string, 0)
+		err := c.ForEachSvc(ctx, func(k string, addr string, fsvc *FuncSvc, cpuUsage, cpuLimit resource.Quantity) {
+			if k == key.String() {
+				addrs = append(addrs, addr)
+			}
+		})
+		require.NoError(t, err)
+		sort.Strings(addrs)
+		return addrs
+	}
+	requireCapError := func(t *testing.T, err error) {
+		t.Helper()
+		require.Error(t, err)
+		fe, ok := err.(ferror.Error)
+		require.True(t, ok, err)
+		require.Equal(t, ferror.ErrorTooManyRequests, int(fe.Code))
+		require.Contains(t, err.Error(), "address limit 2 reached")
+	}
+
+	t.Run("reject", func(t *testing.T) {
+		c := NewPoolCache(loggerfactory.GetLogger(), WithMaxAddresses(2, AddressCapReject))
+		for _, addr := range []string{"ip1", "ip2"} {
+			evicted, err := c.SetSvcValue(ctx, key, addr, &FuncSvc{Address: addr}, cpuLimit, 10, 0)
+			require.NoError(t, err)
+			require.Nil(t, evicted)
+			c.MarkAvailable(key, addr)
+		}
+
+		_, err := c.SetSvcValue(ctx, key, "ip3", &FuncSvc{Address: "ip3"}, cpuLimit, 10, 0)
+		requireCapError(t, err)
+		require.Equal(t, []string{"ip1", "ip2"}, addresses(t, c))
+		require.Equal(t, 0, c.ActiveRequests(key))
+
+		// known addresses and other functions are not capped
+		_, err = c.SetSvcValue(ctx, key, "ip1", &FuncSvc{Address: "ip1"}, cpuLimit, 10, 0)
+		require.NoError(t, err)
+		_, err = c.SetSvcValue(ctx, other, "ip3", &FuncSvc{Address: "ip3"}, cpuLimit, 10, 0)
+		require.NoError(t, err)
+
+		evicted, errs := c.SetSvcValues(ctx, []PoolSvcValue{
+			{Function: key, Address: "ip2", Value: &FuncSvc{Address: "ip2"}, CPULimit: cpuLimit},
+			{Function: key, Address: "ip4", Value: &FuncSvc{Address: "ip4"}, CPULimit: cpuLimit},
+		}, 10, 0)
+		require.Empty(t, evicted)
+		require.Len(t, errs, 2)
+		require.NoError(t, errs[0])
+		requireCapError(t, errs[1])
+	})
+
+	t.Run("evict oldest", func(t *testing.T) {
+		c := NewPoolCache(loggerfactory.GetLogger(), WithMaxAddresses(2, AddressCapEvictOldest))
+		_, err := c.SetSvcValue(ctx, key, "ip1", &FuncSvc{Address: "ip1", Atime: now.Add(-time.Hour)}, cpuLimit, 10, 0)
+		require.NoError(t, err)
+		c.MarkAvailable(key, "ip1")
+		_, err = c.SetSvcValue(ctx, key, "ip2", &FuncSvc{Address: "ip2", Atime: now.Add(-2 * time.Hour)}, cpuLimit, 10, 0)
+		require.NoError(t, err)
+		c.MarkAvailable(key, "ip2")
+
+		// the idle address with the oldest atime goes first
+		evicted, err := c.SetSvcValue(ctx, key, "ip3", &FuncSvc{Address: "ip3", Atime: now}, cpuLimit, 10, 0)
+		require.NoError(t, err)
+		require.NotNil(t, evicted)
+		require.Equal(t, "ip2", evicted.Address)
+		require.Equal(t, []string{"ip1", "ip3"}, addresses(t, c))
+
+		// busy addresses are kept even if older, ip3 still serves its request
+		batchEvicted, errs := c.SetSvcValues(ctx, []PoolSvcValue{
+			{Function: key, Address: "ip4", Value: &FuncSvc{Address: "ip4", Atime: now}, CPULimit: cpuLimit},
+		}, 10, 0)
+		require.Equal(t, []error{nil}, errs)
+		require.Len(t, batchEvicted, 1)
+		require.Equal(t, "ip1", batchEvicted[0].Address)
+		require.Equal(t, []string{"ip3", "ip4"}, addresses(t, c))
+
+		// refused once all addresses are busy
+		_, err = c.SetSvcValue(ctx, key, "ip5", &FuncSvc{Address: "ip5"}, cpuLimit, 10, 0)
+		requireCapError(t, err)
+		require.Contains(t, err.Error(), "all addresses are busy")
+		require.Equal(t, []string{"ip3", "ip4"}, addresses(t, c))
+	})
+
+	t.Run("evict in reaping order", func(t *testing.T) {
+		c := NewPoolCache(loggerfactory.GetLogger(), WithMaxAddresses(2, AddressCapEvictOldest))
+		_, err := c.SetSvcValue(ctx, key, "ip1", &FuncSvc{Address: "ip1", Priority: 1, Atime: now.Add(-2 * time.Hour)}, cpuLimit, 10, 0)
+		require.NoError(t, err)
+		c.MarkAvailable(key, "ip1")
+		_, err = c.SetSvcValue(ctx, key, "ip2", &FuncSvc{Address: "ip2", Atime: now.Add(-time.Hour)}, cpuLimit, 10, 0)
+		require.NoError(t, err)
+		c.MarkAvailable(key, "ip2")
+
+		// the address of lower priority goes first, even if accessed more recently
+		evicted, err := c.SetSvcValue(ctx, key, "ip3", &FuncSvc{Address: "ip3", Atime: now}, cpuLimit, 10, 0)
+		require.NoError(t, err)
+		require.NotNil(t, evicted)
+		require.Equal(t, "ip2", evicted.Address)
+		require.Equal(t, []string{"ip1", "ip3"}, addresses(t, c))
+		c.MarkAvailable(key, "ip3")
+
+		// pinned addresses are never evicted
+		require.NoError(t, c.Pin(key.UID, true))
+		_, err = c.SetSvcValue(ctx, key, "ip4", &FuncSvc{Address: "ip4"}, cpuLimit, 10, 0)
+		requireCapError(t, err)
+		require.Contains(t, err.Error(), "busy or pinned")
+		require.Equal(t, []string{"ip1", "ip3"}, addresses(t, c))
+	})
+}
+
+func TestPoolCacheListAvailableValueReapOrder(t *testing.T) {
+	ctx := context.Background()
+	key := crd.CacheKeyURG{UID: "func"}
+	cpuLimit := resource.MustParse("45m")
+	now := time.Now()
+
+	c := NewPoolCache(loggerfactory.GetLogger())
+	for _, fsvc := range []*FuncSvc{
+		{Address: "ip1", Priority: 5, Atime: now.Add(-3 * time.Hour)},
+		{Address: "ip2", Atime: now.Add(-time.Hour)},
+		{Address: "ip3", Atime: now.Add(-2 * time.Hour)},
+	} {
+		_, err := c.SetSvcValue(ctx, key, fsvc.Address, fsvc, cpuLimit, 10, 1)
+		require.NoError(t, err)
+		c.MarkAvailable(key, fsvc.Address)
+	}
+
+	// one function service is retained, the one of the highest priority
+	addrs := make([]string, 0)
+	for _, fsvc := range c.ListAvailableValue() {
+		addrs = append(addrs, fsvc.Address)
+	}
+	require.Equal(t, []string{"ip3", "ip2"}, addrs)
+}
+
+func TestPoolCacheDeleteByAddress(t *testing.T) {
+	ctx := context.Background()
+	cpuLimit := resource.MustParse("45m")
+	c := NewPoolCache(loggerfactory.GetLogger())
+
+	// the same address is held by two generations of a function
+	for _, key := range []crd.CacheKeyURG{{UID: "func", Generation: 1}, {UID: "func", Generation: 2}} {
+		_, err := c.SetSvcValue(ctx, key, "ip1", &FuncSvc{Address: "ip1"}, cpuLimit, 10, 0)
+		require.NoError(t, err)
+	}
+	_, err := c.SetSvcValue(ctx, crd.CacheKeyURG{UID: "other"}, "ip2", &FuncSvc{Address: "ip2"}, cpuLimit, 10, 0)
+	require.NoError(t, err)
+
+	deleted, err := c.DeleteByAddress(ctx, "ip1")
+	require.NoError(t, err)
+	require.Len(t, deleted, 2)
+	require.Equal(t, 1, c.Stats().Services)
+
+	_, err = c.DeleteByAddress(ctx, "ip1")
+	require.Error(t, err)
+	fe, ok := err.(ferror.Error)
+	require.True(t, ok, err)
+	require.Equal(t, ferror.ErrorNotFound, int(fe.Code))
+}
@@ -0,0 +1,129 @@
+/*
+Copyright 2024 The Fission Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fscache
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"github.com/fission/fission/pkg/crd"
+)
+
+// Cache indexes reported by Verify.
+const (
+	IndexByFunction    = "byFunction"
+	IndexByAddress     = "byAddress"
+	IndexByFunctionUID = "byFunctionUID"
+	IndexPool          = "pool"
+)
+
+// Inconsistency is an orphaned entry or a dangling reference between the cache indexes.
+type Inconsistency struct {
+	Index   string `json:"index"` // index holding the entry, e.g. IndexByAddress
+	Key     string `json:"key"`   // key of the entry in the index
+	Message string `json:"message"`
+}
+
+func (i Inconsistency) String() string {
+	return fmt.Sprintf("%v[%v]: %v", i.Index, i.Key, i.Message)
+}
+
+// Verify checks that the function service indexes reference each other and that the pool
+// cache entries are fully initialized, and returns the inconsistencies found, sorted by
+// index and key. It does not change the cache. The indexes are checked in a single request
+// of the service loop, the pool cache in a request of its own.
+func (fsc *FunctionServiceCache) Verify() []Inconsistency {
+	responseChannel := make(chan *fscResponse)
+	fsc.requestChannel <- &fscRequest{
+		requestType:     VERIFY,
+		responseChannel: responseChannel,
+	}
+	resp := <-responseChannel
+	inconsistencies := resp.inconsistencies
+
+	if pool, err := fsc.poolCache(); err == nil {
+		_ = pool.ForEachSvc(context.Background(), func(key string, addr string, fsvc *FuncSvc, cpuUsage, cpuLimit resource.Quantity) {
+			if fsvc == nil || fsvc.Function == nil {
+				inconsistencies = append(inconsistencies, Inconsistency{
+					Index:   IndexPool,
+					Key:     key + "/" + addr,
+					Message: "function service without function",
+				})
+			}
+		})
+	}
+
+	sort.Slice(inconsistencies, func(i, j int) bool {
+		if inconsistencies[i].Index != inconsistencies[j].Index {
+			return inconsistencies[i].Index < inconsistencies[j].Index
+		}
+		if inconsistencies[i].Key != inconsistencies[j].Key {
+			return inconsistencies[i].Key < inconsistencies[j].Key
+		}
+		return inconsistencies[i].Message < inconsistencies[j].Message
+	})
+	return inconsistencies
+}
+
+// _verify checks the function service indexes. It must only be called from the service loop.
+func (fsc *FunctionServiceCache) _verify() []Inconsistency {
+	inconsistencies := make([]Inconsistency, 0)
+	report := func(index string, key string, format string, args ...interface{}) {
+		inconsistencies = append(inconsistencies, Inconsistency{Index: index, Key: key, Message: fmt.Sprintf(format, args...)})
+	}
+
+	for key, fsvc := range fsc.byFunction.Copy() {
+		if fsvc == nil || fsvc.Function == nil {
+			report(IndexByFunction, key.String(), "function service without function")
+			continue
+		}
+		if crd.CacheKeyURFromMeta(fsvc.Function) != key {
+			report(IndexByFunction, key.String(), "function service of function %v/%v with uid %v and resource version %v",
+				fsvc.Function.Namespace, fsvc.Function.Name, fsvc.Function.UID, fsvc.Function.ResourceVersion)
+		}
+		if _, err := fsc.byAddress.Get(fsc.addressKey(fsvc.Address)); err != nil {
+			report(IndexByFunction, key.String(), "address %v is not indexed", fsvc.Address)
+		}
+		if _, err := fsc.byFunctionUID.Get(fsvc.Function.UID); err != nil {
+			report(IndexByFunction, key.String(), "function uid %v is not indexed", fsvc.Function.UID)
+		}
+	}
+
+	for address, m := range fsc.byAddress.Copy() {
+		fsvc, err := fsc.byFunction.Get(crd.CacheKeyURFromMeta(&m))
+		if err != nil {
+			report(IndexByAddress, address, "function %v/%v has no function service", m.Namespace, m.Name)
+			continue
+		}
+		if fsc.addressKey(fsvc.Address) != address {
+			report(IndexByAddress, address, "function service of function %v/%v is at address %v", m.Namespace, m.Name, fsvc.Address)
+		}
+	}
+
+	for uid, m := range fsc.byFunctionUID.Copy() {
+		if m.UID != uid {
+			report(IndexByFunctionUID, string(uid), "function %v/%v has uid %v", m.Namespace, m.Name, m.UID)
+		}
+		if _, err := fsc.byFunction.Get(crd.CacheKeyURFromMeta(&m)); err != nil {
+			report(IndexByFunctionUID, string(uid), "function %v/%v has no function service", m.Namespace, m.Name)
+		}
+	}
+	return inconsistencies
+}
@@ -0,0 +1,78 @@
+/*
+Copyright 2024 The Fission Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fscache
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/fission/fission/pkg/crd"
+)
+
+func TestVerify(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	fsc := MakeFunctionServiceCache(zap.NewNop())
+	for _, name := range []string{"foo", "bar"} {
+		_, err := fsc.Add(FuncSvc{
+			Function: &metav1.ObjectMeta{Name: name, Namespace: "default", UID: types.UID("uid-" + name)},
+			Address:  name + ".default",
+		})
+		require.NoError(t, err)
+	}
+	fsc.AddFunc(ctx, FuncSvc{
+		Function: &metav1.ObjectMeta{Name: "pooled", Namespace: "default", UID: "uid-pooled"},
+		Address:  "pooled.default",
+		CPULimit: resource.MustParse("5m"),
+	}, 10, 0)
+	require.Empty(t, fsc.Verify())
+
+	// an address without function service
+	_, err := fsc.byAddress.Set("ghost.default", metav1.ObjectMeta{Name: "ghost", Namespace: "default", UID: "uid-ghost"})
+	require.NoError(t, err)
+	// a function uid without function service
+	_, err = fsc.byFunctionUID.Set("uid-gone", metav1.ObjectMeta{Name: "gone", Namespace: "default", UID: "uid-gone"})
+	require.NoError(t, err)
+	// a function service whose address and uid are not indexed
+	require.NoError(t, fsc.byAddress.Delete("bar.default"))
+	require.NoError(t, fsc.byFunctionUID.Delete("uid-bar"))
+	// a pool function service without function
+	fsc.connFunctionCache.SetSvcValue(ctx, crd.CacheKeyURG{UID: "uid-partial"}, "partial.default", &FuncSvc{Address: "partial.default"},
+		resource.MustParse("5m"), 10, 0)
+
+	barKey := crd.CacheKeyURFromMeta(&metav1.ObjectMeta{Name: "bar", Namespace: "default", UID: "uid-bar"}).String()
+	require.Equal(t, []Inconsistency{
+		{Index: IndexByAddress, Key: "ghost.default", Message: "function default/ghost has no function service"},
+		{Index: IndexByFunction, Key: barKey, Message: "address bar.default is not indexed"},
+		{Index: IndexByFunction, Key: barKey, Message: "function uid uid-bar is not indexed"},
+		{Index: IndexByFunctionUID, Key: "uid-gone", Message: "function default/gone has no function service"},
+		{Index: IndexPool, Key: crd.CacheKeyURG{UID: "uid-partial"}.String() + "/partial.default", Message: "function service without function"},
+	}, fsc.Verify())
+
+	// verifying doesn't change the cache
+	_, err = fsc.byAddress.Get("ghost.default")
+	require.NoError(t, err)
+	_, err = fsc.GetByFunction(&metav1.ObjectMeta{Name: "bar", Namespace: "default", UID: "uid-bar"})
+	require.NoError(t, err)
+}
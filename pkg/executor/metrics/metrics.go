@@ -48,6 +48,20 @@ var (
 		},
 		functionLabels,
 	)
+	FscacheDumps         = newFscacheDumps()
+	FscacheDumpBytes     = newFscacheDumpBytes()
+	FscacheEventsDropped = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "fission_fscache_events_dropped_total",
+			Help: "How many function service cache events are dropped because of a slow consumer.",
+		},
+	)
+	FscacheTouchesDropped = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "fission_fscache_touches_dropped_total",
+			Help: "How many function service cache touches by address are dropped by the touch rate limit.",
+		},
+	)
 )
 
 func init() {
@@ -55,4 +69,41 @@ func init() {
 	registry.MustRegister(ColdStarts)
 	registry.MustRegister(FuncRunningSummary)
 	registry.MustRegister(ColdStartsError)
+	registry.MustRegister(FscacheDumps)
+	registry.MustRegister(FscacheDumpBytes)
+	registry.MustRegister(FscacheEventsDropped)
+	registry.MustRegister(FscacheTouchesDropped)
+}
+
+func newFscacheDumps() prometheus.Counter {
+	return prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "fission_fscache_dumps_total",
+			Help: "How many function service cache dumps are written.",
+		},
+	)
+}
+
+func newFscacheDumpBytes() prometheus.Histogram {
+	return prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			Name:    "fission_fscache_dump_size_bytes",
+			Help:    "The size in bytes of the written function service cache dumps.",
+			Buckets: prometheus.ExponentialBuckets(1024, 4, 8),
+		},
+	)
+}
+
+// ResetForTest replaces the function service cache dump metrics with zeroed
+// ones in the registry, since counters and histograms can't be reset, so that
+// tests can assert them regardless of dumps written by other tests. It must not
+// be called concurrently with dumps.
+func ResetForTest() {
+	registry := metrics.Registry
+	registry.Unregister(FscacheDumps)
+	registry.Unregister(FscacheDumpBytes)
+	FscacheDumps = newFscacheDumps()
+	FscacheDumpBytes = newFscacheDumpBytes()
+	registry.MustRegister(FscacheDumps)
+	registry.MustRegister(FscacheDumpBytes)
 }
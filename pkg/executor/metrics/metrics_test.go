@@ -0,0 +1,51 @@
+/*
+Copyright 2024 The Fission Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/require"
+
+	"github.com/fission/fission/pkg/utils/metrics"
+)
+
+func TestResetForTest(t *testing.T) {
+	dumpBytesCount := func() uint64 {
+		var m dto.Metric
+		require.NoError(t, FscacheDumpBytes.Write(&m))
+		return m.GetHistogram().GetSampleCount()
+	}
+
+	FscacheDumps.Inc()
+	FscacheDumpBytes.Observe(4096)
+	require.NotZero(t, testutil.ToFloat64(FscacheDumps))
+	require.NotZero(t, dumpBytesCount())
+
+	ResetForTest()
+	require.Zero(t, testutil.ToFloat64(FscacheDumps))
+	require.Zero(t, dumpBytesCount())
+
+	// the new metrics are registered in place of the old ones
+	FscacheDumps.Inc()
+	count, err := testutil.GatherAndCount(metrics.Registry, "fission_fscache_dumps_total", "fission_fscache_dump_size_bytes")
+	require.NoError(t, err)
+	require.Equal(t, 2, count)
+	require.Equal(t, float64(1), testutil.ToFloat64(FscacheDumps))
+}
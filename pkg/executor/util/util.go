@@ -17,10 +17,14 @@ limitations under the License.
 package util
 
 import (
+	"compress/gzip"
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -32,13 +36,33 @@ import (
 	"sigs.k8s.io/yaml"
 
 	fv1 "github.com/fission/fission/pkg/apis/core/v1"
+	ferror "github.com/fission/fission/pkg/error"
 	"github.com/fission/fission/pkg/utils"
 )
 
 const (
 	dumpFileName string = "fission-dump"
+
+	// DefaultDumpFileMode is the permission of dump files, which may contain internal addresses.
+	DefaultDumpFileMode os.FileMode = 0600
+	// DefaultDumpDirMode is the permission of the dump directory if it has to be created.
+	DefaultDumpDirMode os.FileMode = 0755
+	// DefaultMaxDumpFiles is the number of dump files kept by RotateDumpFiles.
+	DefaultMaxDumpFiles = 10
 )
 
+// DumpFileOptions sets the permissions of files created by CreateDumpFile
+// and the number of files kept by RotateDumpFiles. Zero values select
+// DefaultDumpFileMode, DefaultDumpDirMode and DefaultMaxDumpFiles; a negative
+// MaxFiles keeps all dump files. Gzip compresses the dumps written by
+// WriteDumpFile, in files with a .txt.gz extension.
+type DumpFileOptions struct {
+	FileMode os.FileMode
+	DirMode  os.FileMode
+	MaxFiles int
+	Gzip     bool
+}
+
 // ApplyImagePullSecret applies image pull secret to the give pod spec.
 // It's intentional not to check the existence of secret here.
 // First, Kubernetes will set Pod status to "ImagePullBackOff" once
@@ -162,9 +186,142 @@ func getExecutorEnvVarName(executor fv1.ExecutorType) string {
 }
 
 // CreateDumpFile => create dump file inside temp directory
-func CreateDumpFile(logger *zap.Logger) (*os.File, error) {
+func CreateDumpFile(logger *zap.Logger, opts DumpFileOptions) (*os.File, error) {
+	return createDumpFile(logger, opts, fmt.Sprintf("%s-%d.txt", dumpFileName, time.Now().UnixNano()))
+}
+
+// WriteDumpFile creates a dump file inside temp directory and fills it with write,
+// compressed with gzip if opts.Gzip is set. The dump is written to a temporary file,
+// which is renamed to the dump file on success and removed if write fails, so that
+// no partial dump is left behind. It returns the path of the dump file.
+func WriteDumpFile(logger *zap.Logger, opts DumpFileOptions, write func(io.Writer) error) (string, error) {
+	name := fmt.Sprintf("%s-%d.txt", dumpFileName, time.Now().UnixNano())
+	if opts.Gzip {
+		name += ".gz"
+	}
+	file, err := createDumpFile(logger, opts, name+".tmp")
+	if err != nil {
+		return "", err
+	}
+
+	if opts.Gzip {
+		zw := gzip.NewWriter(file)
+		err = write(zw)
+		// the gzip footer must be written before the file is closed
+		err = errors.Join(err, zw.Close())
+	} else {
+		err = write(file)
+	}
+	err = errors.Join(err, file.Close())
+	if err == nil {
+		path := filepath.Join(filepath.Dir(file.Name()), name)
+		err = os.Rename(file.Name(), path)
+		if err == nil {
+			return path, nil
+		}
+	}
+
+	if rmErr := os.Remove(file.Name()); rmErr != nil && !os.IsNotExist(rmErr) {
+		logger.Error("error removing partial dump file", zap.String("file", file.Name()), zap.Error(rmErr))
+	}
+	return "", err
+}
+
+func createDumpFile(logger *zap.Logger, opts DumpFileOptions, name string) (*os.File, error) {
+	if opts.FileMode == 0 {
+		opts.FileMode = DefaultDumpFileMode
+	}
+	if opts.DirMode == 0 {
+		opts.DirMode = DefaultDumpDirMode
+	}
+
 	dumpPath := os.TempDir()
 	logger.Info("creating dump file", zap.String("dump_path", dumpPath))
 
-	return os.Create(fmt.Sprintf("%s/%s-%d.txt", dumpPath, dumpFileName, time.Now().Unix()))
+	fi, err := os.Stat(dumpPath)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return nil, err
+		}
+		err = os.MkdirAll(dumpPath, opts.DirMode)
+		if err != nil {
+			return nil, err
+		}
+	} else if !fi.IsDir() {
+		return nil, ferror.MakeError(ferror.ErrorInvalidArgument,
+			fmt.Sprintf("dump path '%s' exists but is not a directory", dumpPath))
+	}
+
+	file, err := os.OpenFile(filepath.Join(dumpPath, name),
+		os.O_RDWR|os.O_CREATE|os.O_TRUNC, opts.FileMode)
+	if err != nil {
+		return nil, err
+	}
+	// the mode passed to OpenFile is subject to umask and not applied to existing files
+	err = file.Chmod(opts.FileMode)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return file, nil
+}
+
+// RotateDumpFiles removes all but the newest opts.MaxFiles dump files
+// created by CreateDumpFile and WriteDumpFile from the temp directory,
+// compressed or not.
+func RotateDumpFiles(logger *zap.Logger, opts DumpFileOptions) error {
+	if opts.MaxFiles == 0 {
+		opts.MaxFiles = DefaultMaxDumpFiles
+	}
+	if opts.MaxFiles < 0 {
+		return nil
+	}
+
+	dumpPath := os.TempDir()
+	entries, err := os.ReadDir(dumpPath)
+	if err != nil {
+		return err
+	}
+
+	type dumpFile struct {
+		name    string
+		modTime time.Time
+	}
+	files := make([]dumpFile, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || !strings.HasPrefix(entry.Name(), dumpFileName+"-") ||
+			!(strings.HasSuffix(entry.Name(), ".txt") || strings.HasSuffix(entry.Name(), ".txt.gz")) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return err
+		}
+		files = append(files, dumpFile{name: entry.Name(), modTime: info.ModTime()})
+	}
+	if len(files) <= opts.MaxFiles {
+		return nil
+	}
+
+	// newest first; names embed the creation time and break ties of coarse mtimes
+	sort.Slice(files, func(i, j int) bool {
+		if !files[i].modTime.Equal(files[j].modTime) {
+			return files[i].modTime.After(files[j].modTime)
+		}
+		return files[i].name > files[j].name
+	})
+
+	var errs error
+	for _, file := range files[opts.MaxFiles:] {
+		path := filepath.Join(dumpPath, file.name)
+		logger.Info("removing old dump file", zap.String("file", path))
+		err := os.Remove(path)
+		if err != nil && !os.IsNotExist(err) {
+			errs = errors.Join(errs, err)
+		}
+	}
+	return errs
 }
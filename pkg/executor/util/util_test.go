@@ -17,14 +17,20 @@ limitations under the License.
 package util
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
 	"reflect"
+	"sort"
+	"strings"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
 
 	fv1 "github.com/fission/fission/pkg/apis/core/v1"
+	ferror "github.com/fission/fission/pkg/error"
 	"github.com/fission/fission/pkg/utils/loggerfactory"
 	apiv1 "k8s.io/api/core/v1"
 )
@@ -155,3 +161,179 @@ func TestGetObjectReaperInterval(t *testing.T) {
 		t.Fatalf(`%d %d`, want, got)
 	}
 }
+
+func TestCreateDumpFile(t *testing.T) {
+	logger := loggerfactory.GetLogger()
+
+	dumpDir := t.TempDir()
+	t.Setenv("TMPDIR", dumpDir)
+	file, err := CreateDumpFile(logger, DumpFileOptions{})
+	if err != nil {
+		t.Fatalf("CreateDumpFile() error = %v", err)
+	}
+	file.Close()
+	if filepath.Dir(file.Name()) != dumpDir {
+		t.Fatalf("dump file %s not created in %s", file.Name(), dumpDir)
+	}
+	fi, err := os.Stat(file.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != DefaultDumpFileMode {
+		t.Fatalf("expected dump file mode %v, got %v", DefaultDumpFileMode, fi.Mode().Perm())
+	}
+
+	// configured modes are applied regardless of umask
+	newDir := filepath.Join(t.TempDir(), "dumps")
+	t.Setenv("TMPDIR", newDir)
+	file, err = CreateDumpFile(logger, DumpFileOptions{FileMode: 0640, DirMode: 0750})
+	if err != nil {
+		t.Fatalf("CreateDumpFile() error = %v", err)
+	}
+	file.Close()
+	fi, err = os.Stat(file.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != 0640 {
+		t.Fatalf("expected dump file mode %v, got %v", os.FileMode(0640), fi.Mode().Perm())
+	}
+	fi, err = os.Stat(newDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm()&^0750 != 0 {
+		t.Fatalf("expected dump dir mode at most %v, got %v", os.FileMode(0750), fi.Mode().Perm())
+	}
+
+	// dump path exists but is a regular file
+	dumpPath := filepath.Join(t.TempDir(), "not-a-dir")
+	err = os.WriteFile(dumpPath, []byte("x"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("TMPDIR", dumpPath)
+	_, err = CreateDumpFile(logger, DumpFileOptions{})
+	if err == nil {
+		t.Fatal("expected error when dump path is a file")
+	}
+	fe, ok := err.(ferror.Error)
+	if !ok || fe.Code != ferror.ErrorInvalidArgument {
+		t.Fatalf("expected invalid argument error, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "is not a directory") {
+		t.Fatalf("expected descriptive error, got %v", err)
+	}
+}
+
+func TestRotateDumpFiles(t *testing.T) {
+	logger := loggerfactory.GetLogger()
+
+	dumpDir := t.TempDir()
+	t.Setenv("TMPDIR", dumpDir)
+
+	otherFile := filepath.Join(dumpDir, "other.txt")
+	err := os.WriteFile(otherFile, []byte("x"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opts := DumpFileOptions{MaxFiles: 3}
+	var created []string
+	for i := 0; i < 5; i++ {
+		file, err := CreateDumpFile(logger, opts)
+		if err != nil {
+			t.Fatalf("CreateDumpFile() error = %v", err)
+		}
+		file.Close()
+		created = append(created, filepath.Base(file.Name()))
+
+		err = RotateDumpFiles(logger, opts)
+		if err != nil {
+			t.Fatalf("RotateDumpFiles() error = %v", err)
+		}
+	}
+
+	entries, err := os.ReadDir(dumpDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var remaining []string
+	for _, entry := range entries {
+		remaining = append(remaining, entry.Name())
+	}
+	expected := append([]string{}, created[2:]...)
+	expected = append(expected, filepath.Base(otherFile))
+	sort.Strings(expected)
+	if !reflect.DeepEqual(expected, remaining) {
+		t.Fatalf("expected dump dir to contain %v, got %v", expected, remaining)
+	}
+
+	// negative MaxFiles keeps all dump files
+	err = RotateDumpFiles(logger, DumpFileOptions{MaxFiles: -1})
+	if err != nil {
+		t.Fatalf("RotateDumpFiles() error = %v", err)
+	}
+	entries, err = os.ReadDir(dumpDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != len(expected) {
+		t.Fatalf("expected %d files, got %d", len(expected), len(entries))
+	}
+}
+
+func TestWriteDumpFile(t *testing.T) {
+	logger := loggerfactory.GetLogger()
+
+	dumpDir := t.TempDir()
+	t.Setenv("TMPDIR", dumpDir)
+
+	// a failed write leaves no file behind
+	writeErr := errors.New("write failed")
+	path, err := WriteDumpFile(logger, DumpFileOptions{}, func(w io.Writer) error {
+		_, err := w.Write([]byte("partial"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return writeErr
+	})
+	if !errors.Is(err, writeErr) {
+		t.Fatalf("expected write error, got %v", err)
+	}
+	if len(path) > 0 {
+		t.Fatalf("expected no dump file path, got %s", path)
+	}
+	entries, err := os.ReadDir(dumpDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Fatalf("expected empty dump dir, got %d files", len(entries))
+	}
+
+	path, err = WriteDumpFile(logger, DumpFileOptions{}, func(w io.Writer) error {
+		_, err := w.Write([]byte("dump"))
+		return err
+	})
+	if err != nil {
+		t.Fatalf("WriteDumpFile() error = %v", err)
+	}
+	if filepath.Dir(path) != dumpDir || filepath.Ext(path) != ".txt" {
+		t.Fatalf("unexpected dump file %s", path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "dump" {
+		t.Fatalf("expected dump file content %q, got %q", "dump", data)
+	}
+	entries, err = os.ReadDir(dumpDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("expected only the dump file, got %d files", len(entries))
+	}
+}
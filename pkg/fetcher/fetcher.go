@@ -349,11 +349,11 @@ func (fetcher *Fetcher) Fetch(ctx context.Context, pkg *fv1.Package, req Functio
 		}
 	}
 
-	// checking if file is a zip
-	if match, _ := utils.IsZip(tmpPath); match && !req.KeepArchive {
+	// checking if file is a zip, tar.gz or tar archive
+	if unarchiver := getUnarchiver(tmpPath); unarchiver != nil && !req.KeepArchive {
 		// unarchive tmp file to a tmp unarchive path
 		tmpUnarchivePath := filepath.Join(fetcher.sharedVolumePath, uuid.NewString())
-		err := fetcher.unarchive(tmpPath, tmpUnarchivePath)
+		err := fetcher.unarchive(unarchiver, tmpPath, tmpUnarchivePath)
 		if err != nil {
 			logger.Error("error unarchive",
 				zap.Error(err),
@@ -615,10 +615,26 @@ func (fetcher *Fetcher) archive(src string, dst string) error {
 }
 
 // unarchive is a function that unzips a zip file to destination
-func (fetcher *Fetcher) unarchive(src string, dst string) error {
-	err := archiver.DefaultZip.Unarchive(src, dst)
+func (fetcher *Fetcher) unarchive(unarchiver archiver.Unarchiver, src string, dst string) error {
+	err := unarchiver.Unarchive(src, dst)
 	if err != nil {
-		return fmt.Errorf("failed to unzip file: %w", err)
+		return fmt.Errorf("failed to unarchive %v file: %w", unarchiver, err)
+	}
+	return nil
+}
+
+// getUnarchiver returns an unarchiver for the zip, tar.gz or tar archive at path,
+// or nil if it is none of them. The unarchiver is not shared, as the tar.gz one
+// keeps the state of the archive it unarchives.
+func getUnarchiver(path string) archiver.Unarchiver {
+	if match, _ := utils.IsZip(path); match {
+		return archiver.NewZip()
+	}
+	if match, _ := utils.IsTarGz(path); match {
+		return archiver.NewTarGz()
+	}
+	if match, _ := utils.IsTar(path); match {
+		return archiver.NewTar()
 	}
 	return nil
 }
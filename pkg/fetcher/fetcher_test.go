@@ -0,0 +1,96 @@
+/*
+Copyright 2024 The Fission Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fetcher
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	fv1 "github.com/fission/fission/pkg/apis/core/v1"
+	"github.com/fission/fission/pkg/utils"
+)
+
+func TestFetchUnarchive(t *testing.T) {
+	srcDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, "main.py"), []byte("print('hello')"), 0644))
+
+	for _, format := range []utils.ArchiveFormat{utils.ArchiveFormatZip, utils.ArchiveFormatTarGz} {
+		t.Run(string(format), func(t *testing.T) {
+			archivePath, err := utils.MakeArchive(filepath.Join(t.TempDir(), "pkg"+format.Extension()),
+				utils.ArchiveOptions{Format: format}, filepath.Join(srcDir, "main.py"))
+			require.NoError(t, err)
+			literal, err := os.ReadFile(archivePath)
+			require.NoError(t, err)
+
+			fetcher := &Fetcher{
+				logger:           zap.NewNop(),
+				sharedVolumePath: t.TempDir(),
+			}
+			pkg := &fv1.Package{
+				Spec: fv1.PackageSpec{
+					Deployment: fv1.Archive{Type: fv1.ArchiveTypeLiteral, Literal: literal},
+				},
+				Status: fv1.PackageStatus{BuildStatus: fv1.BuildStatusSucceeded},
+			}
+
+			code, err := fetcher.Fetch(context.Background(), pkg, FunctionFetchRequest{
+				FetchType: fv1.FETCH_DEPLOYMENT,
+				Filename:  "user",
+			})
+			require.NoError(t, err)
+			require.Equal(t, http.StatusOK, code)
+
+			content, err := os.ReadFile(filepath.Join(fetcher.sharedVolumePath, "user", "main.py"))
+			require.NoError(t, err)
+			require.Equal(t, "print('hello')", string(content))
+		})
+	}
+
+	t.Run("keep archive", func(t *testing.T) {
+		archivePath, err := utils.MakeArchive(filepath.Join(t.TempDir(), "pkg.tar.gz"),
+			utils.ArchiveOptions{Format: utils.ArchiveFormatTarGz}, filepath.Join(srcDir, "main.py"))
+		require.NoError(t, err)
+		literal, err := os.ReadFile(archivePath)
+		require.NoError(t, err)
+
+		fetcher := &Fetcher{
+			logger:           zap.NewNop(),
+			sharedVolumePath: t.TempDir(),
+		}
+		pkg := &fv1.Package{
+			Spec: fv1.PackageSpec{
+				Source: fv1.Archive{Type: fv1.ArchiveTypeLiteral, Literal: literal},
+			},
+		}
+
+		_, err = fetcher.Fetch(context.Background(), pkg, FunctionFetchRequest{
+			FetchType:   fv1.FETCH_SOURCE,
+			Filename:    "src.tar.gz",
+			KeepArchive: true,
+		})
+		require.NoError(t, err)
+		content, err := os.ReadFile(filepath.Join(fetcher.sharedVolumePath, "src.tar.gz"))
+		require.NoError(t, err)
+		require.Equal(t, literal, content)
+	})
+}
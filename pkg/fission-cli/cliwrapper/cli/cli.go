@@ -85,3 +85,18 @@ type (
 		Stderr() io.Writer
 	}
 )
+
+type contextInput struct {
+	Input
+	ctx context.Context
+}
+
+func (i contextInput) Context() context.Context {
+	return i.ctx
+}
+
+// WithContext returns an Input which reads flags from input
+// and returns ctx as its context.
+func WithContext(input Input, ctx context.Context) Input {
+	return contextInput{Input: input, ctx: ctx}
+}
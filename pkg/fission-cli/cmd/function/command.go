@@ -41,6 +41,7 @@ func Commands() *cobra.Command {
 			// TODO retired pkg & trigger related flags from function cmd
 			flag.PkgCode, flag.PkgSrcArchive, flag.PkgDeployArchive,
 			flag.PkgSrcChecksum, flag.PkgDeployChecksum, flag.PkgInsecure,
+			flag.PkgArchiveAuthHeader, flag.PkgArchiveBasicAuth, flag.PkgArchiveBackend,
 			flag.FnBuildCmd,
 
 			flag.HtUrl, flag.HtPrefix, flag.HtMethod,
@@ -91,6 +92,7 @@ func Commands() *cobra.Command {
 
 			flag.PkgCode, flag.PkgSrcArchive, flag.PkgDeployArchive,
 			flag.PkgSrcChecksum, flag.PkgDeployChecksum, flag.PkgInsecure,
+			flag.PkgArchiveAuthHeader, flag.PkgArchiveBasicAuth, flag.PkgArchiveBackend,
 			flag.FnBuildCmd, flag.PkgForce,
 
 			flag.RunTimeMinCPU, flag.RunTimeMaxCPU, flag.RunTimeMinMemory,
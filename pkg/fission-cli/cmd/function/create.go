@@ -202,26 +202,21 @@ func (opts *CreateSubCommand) complete(input cli.Input) error {
 		}
 
 		srcArchiveFiles := input.StringSlice(flagkey.PkgSrcArchive)
-		var deployArchiveFiles []string
-		noZip := false
-		code := input.String(flagkey.PkgCode)
-		if len(code) == 0 {
+		deployArchiveFiles, noZip := _package.CodeArchiveFiles(input)
+		if len(deployArchiveFiles) == 0 {
 			deployArchiveFiles = input.StringSlice(flagkey.PkgDeployArchive)
-		} else {
-			deployArchiveFiles = append(deployArchiveFiles, input.String(flagkey.PkgCode))
-			noZip = true
 		}
 		// return error when both src & deploy archive are empty
 		if len(srcArchiveFiles) == 0 && len(deployArchiveFiles) == 0 {
 			return errors.New("need --code or --deploy or --src argument")
 		}
 
-		buildcmd := input.String(flagkey.PkgBuildCmd)
+		buildcmds := []string{input.String(flagkey.PkgBuildCmd)}
 		pkgName := generatePackageName(fnName, uuid.NewString())
 
 		// create new package in the same namespace as the function.
-		pkgMetadata, err = _package.CreatePackage(input, opts.Client(), pkgName, fnNamespace, envName,
-			srcArchiveFiles, deployArchiveFiles, buildcmd, specDir, opts.specFile, noZip, userProvidedNS)
+		pkgMetadata, _, err = _package.CreatePackage(input, opts.Client(), pkgName, fnNamespace, envName,
+			srcArchiveFiles, deployArchiveFiles, buildcmds, nil, nil, specDir, opts.specFile, noZip, userProvidedNS)
 		if err != nil {
 			return errors.Wrap(err, "error creating package")
 		}
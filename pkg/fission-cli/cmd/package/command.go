@@ -30,10 +30,13 @@ func Commands() *cobra.Command {
 		RunE:  wrapper.Wrapper(Create),
 	}
 	wrapper.SetFlags(createCmd, flag.FlagSet{
-		Required: []flag.Flag{flag.PkgEnvironment},
-		Optional: []flag.Flag{flag.PkgName, flag.PkgCode, flag.PkgSrcArchive, flag.PkgDeployArchive,
-			flag.PkgSrcChecksum, flag.PkgDeployChecksum, flag.PkgInsecure, flag.PkgBuildCmd,
-			flag.NamespacePackage, flag.SpecSave, flag.SpecDry},
+		Optional: []flag.Flag{flag.PkgEnvironment, flag.PkgFromConfig, flag.PkgName, flag.PkgCode, flag.PkgSrcArchive, flag.PkgDeployArchive,
+			flag.PkgSrcArchiveID, flag.PkgDeployArchiveID, flag.PkgSrcChecksum, flag.PkgDeployChecksum, flag.PkgInsecure, flag.PkgBuildCmd, flag.PkgBuildEnv, flag.PkgBuildEnvFromFile,
+			flag.PkgBuildCPU, flag.PkgBuildMemory, flag.NamespacePackage, flag.PkgEnvNamespace, flag.PkgArchiveFormat,
+			flag.PkgValidateOnly, flag.PkgPreserveMode, flag.PkgFollowSymlinks, flag.PkgIncludeFrom, flag.PkgCompressionLvl, flag.PkgTimeout,
+			flag.PkgArchiveAuthHeader, flag.PkgArchiveBasicAuth, flag.PkgArchiveBackend, flag.PkgMaxArchiveSize,
+			flag.PkgSourceCommit, flag.PkgSourceRepo, flag.PkgSourceRef, flag.PkgArchiveDryRun, flag.PkgArchiveOut, flag.PkgPrintSpec, flag.PkgFollowBuild, flag.PkgFollowTimeout,
+			flag.PkgSecret, flag.PkgCfgMap, flag.PkgCreateForce, flag.PkgReplaceIfChanged, flag.PkgImage, flag.SpecSave, flag.SpecDry},
 	})
 
 	getSrcCmd := &cobra.Command{
@@ -64,7 +67,8 @@ func Commands() *cobra.Command {
 	wrapper.SetFlags(updateCmd, flag.FlagSet{
 		Required: []flag.Flag{flag.PkgName},
 		Optional: []flag.Flag{flag.PkgEnvironment, flag.PkgCode, flag.PkgSrcArchive, flag.PkgDeployArchive,
-			flag.PkgSrcChecksum, flag.PkgDeployChecksum, flag.PkgInsecure, flag.PkgBuildCmd, flag.PkgForce,
+			flag.PkgSrcChecksum, flag.PkgDeployChecksum, flag.PkgInsecure, flag.PkgBuildCmd, flag.PkgBuildEnv, flag.PkgBuildEnvFromFile, flag.PkgForce,
+			flag.PkgArchiveAuthHeader, flag.PkgArchiveBasicAuth, flag.PkgArchiveBackend, flag.PkgMaxArchiveSize,
 			flag.NamespacePackage, flag.NamespaceEnvironment},
 	})
 
@@ -0,0 +1,163 @@
+/*
+Copyright 2024 The Fission Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package _package
+
+import (
+	"encoding/json"
+	"os"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/yaml"
+
+	ferror "github.com/fission/fission/pkg/error"
+	"github.com/fission/fission/pkg/fission-cli/cliwrapper/cli"
+	flagkey "github.com/fission/fission/pkg/fission-cli/flag/key"
+)
+
+type (
+	// createConfig holds the package create options read from a --from-config
+	// file. Keys are named after the corresponding flags.
+	createConfig struct {
+		Environment      *string             `json:"env,omitempty"`
+		EnvNamespace     *string             `json:"env-namespace,omitempty"`
+		BuildCommand     stringOrSlice       `json:"buildcmd,omitempty"`
+		SourceArchive    []string            `json:"sourcearchive,omitempty"`
+		DeployArchive    []string            `json:"deployarchive,omitempty"`
+		Insecure         *bool               `json:"insecure,omitempty"`
+		ArchiveFormat    *string             `json:"archive-format,omitempty"`
+		CompressionLevel *intstr.IntOrString `json:"compression-level,omitempty"`
+		PreserveMode     *bool               `json:"preserve-mode,omitempty"`
+		Timeout          *metav1.Duration    `json:"timeout,omitempty"`
+	}
+
+	// configInput returns the values of a createConfig for flags
+	// not set on the command line.
+	configInput struct {
+		cli.Input
+		values map[string]interface{}
+	}
+
+	// stringOrSlice is a list of strings which can also be given as a single string.
+	stringOrSlice []string
+)
+
+func (s *stringOrSlice) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err == nil {
+		*s = stringOrSlice{value}
+		return nil
+	}
+	var values []string
+	if err := json.Unmarshal(data, &values); err != nil {
+		return err
+	}
+	*s = values
+	return nil
+}
+
+// withCreateConfig reads the --from-config file and returns an Input
+// which falls back to its values for flags not given explicitly.
+func withCreateConfig(input cli.Input, configFile string) (cli.Input, error) {
+	data, err := os.ReadFile(configFile)
+	if err != nil {
+		return nil, packageError(ferror.ErrorInvalidArgument, err, "error reading --%v file", flagkey.PkgFromConfig)
+	}
+
+	var config createConfig
+	err = yaml.UnmarshalStrict(data, &config)
+	if err != nil {
+		return nil, packageError(ferror.ErrorInvalidArgument, err, "error parsing --%v file '%v'", flagkey.PkgFromConfig, configFile)
+	}
+
+	values := make(map[string]interface{})
+	setString := func(key string, value *string) {
+		if value != nil {
+			values[key] = *value
+		}
+	}
+	setBool := func(key string, value *bool) {
+		if value != nil {
+			values[key] = *value
+		}
+	}
+	setString(flagkey.PkgEnvironment, config.Environment)
+	setString(flagkey.PkgEnvNamespace, config.EnvNamespace)
+	setString(flagkey.PkgArchiveFormat, config.ArchiveFormat)
+	setBool(flagkey.PkgInsecure, config.Insecure)
+	setBool(flagkey.PkgPreserveMode, config.PreserveMode)
+	if len(config.BuildCommand) > 0 {
+		values[flagkey.PkgBuildCmd] = []string(config.BuildCommand)
+	}
+	if len(config.SourceArchive) > 0 {
+		values[flagkey.PkgSrcArchive] = config.SourceArchive
+	}
+	if len(config.DeployArchive) > 0 {
+		values[flagkey.PkgDeployArchive] = config.DeployArchive
+	}
+	if config.CompressionLevel != nil {
+		values[flagkey.PkgCompressionLvl] = config.CompressionLevel.String()
+	}
+	if config.Timeout != nil {
+		values[flagkey.PkgTimeout] = config.Timeout.Duration
+	}
+
+	return configInput{Input: input, values: values}, nil
+}
+
+// fromConfig returns the config value of key, unless the flag is set explicitly.
+func (i configInput) fromConfig(key string) (interface{}, bool) {
+	if i.Input.IsSet(key) {
+		return nil, false
+	}
+	val, ok := i.values[key]
+	return val, ok
+}
+
+func (i configInput) IsSet(key string) bool {
+	_, ok := i.values[key]
+	return ok || i.Input.IsSet(key)
+}
+
+func (i configInput) Bool(key string) bool {
+	if val, ok := i.fromConfig(key); ok {
+		return val.(bool)
+	}
+	return i.Input.Bool(key)
+}
+
+func (i configInput) String(key string) string {
+	if val, ok := i.fromConfig(key); ok {
+		return val.(string)
+	}
+	return i.Input.String(key)
+}
+
+func (i configInput) StringSlice(key string) []string {
+	if val, ok := i.fromConfig(key); ok {
+		return val.([]string)
+	}
+	return i.Input.StringSlice(key)
+}
+
+func (i configInput) Duration(key string) time.Duration {
+	if val, ok := i.fromConfig(key); ok {
+		return val.(time.Duration)
+	}
+	return i.Input.Duration(key)
+}
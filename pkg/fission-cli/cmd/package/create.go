@@ -17,25 +17,49 @@ limitations under the License.
 package _package
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"io"
+	"os"
 	"path"
+	"regexp"
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/dchest/uniuri"
+	"github.com/hashicorp/go-multierror"
 	"github.com/pkg/errors"
+	apiv1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
 
 	fv1 "github.com/fission/fission/pkg/apis/core/v1"
+	ferror "github.com/fission/fission/pkg/error"
 	"github.com/fission/fission/pkg/fission-cli/cliwrapper/cli"
 	"github.com/fission/fission/pkg/fission-cli/cmd"
 	"github.com/fission/fission/pkg/fission-cli/cmd/spec"
 	"github.com/fission/fission/pkg/fission-cli/console"
 	flagkey "github.com/fission/fission/pkg/fission-cli/flag/key"
 	"github.com/fission/fission/pkg/fission-cli/util"
+	"github.com/fission/fission/pkg/utils"
 	"github.com/fission/fission/pkg/utils/uuid"
 )
 
+// commitSHARegex loosely matches abbreviated and full git commit SHAs.
+var commitSHARegex = regexp.MustCompile(`^[0-9a-fA-F]{7,64}$`)
+
+// imageRefRegex matches container image references [domain[:port]/]path[:tag][@digest],
+// capturing the name, tag and digest, following the distribution reference grammar.
+var imageRefRegex = regexp.MustCompile(`^((?:(?:[a-zA-Z0-9]|[a-zA-Z0-9][a-zA-Z0-9-]*[a-zA-Z0-9])(?:\.(?:[a-zA-Z0-9]|[a-zA-Z0-9][a-zA-Z0-9-]*[a-zA-Z0-9]))*(?::[0-9]+)?/)?` +
+	`[a-z0-9]+(?:(?:[._]|__|-+)[a-z0-9]+)*(?:/[a-z0-9]+(?:(?:[._]|__|-+)[a-z0-9]+)*)*)` +
+	`(?::([\w][\w.-]{0,127}))?` +
+	`(?:@([A-Za-z][A-Za-z0-9]*(?:[-_+.][A-Za-z][A-Za-z0-9]*)*:[0-9a-fA-F]{32,}))?$`)
+
 type CreateSubCommand struct {
 	cmd.CommandActioner
 }
@@ -53,16 +77,60 @@ func (opts *CreateSubCommand) do(input cli.Input) error {
 }
 
 func (opts *CreateSubCommand) run(input cli.Input) error {
+	if input.IsSet(flagkey.PkgFromConfig) {
+		var err error
+		input, err = withCreateConfig(input, input.String(flagkey.PkgFromConfig))
+		if err != nil {
+			return err
+		}
+	}
+
+	if input.Bool(flagkey.PkgPrintSpec) && (input.Bool(flagkey.SpecSave) || input.Bool(flagkey.SpecDry) || input.Bool(flagkey.PkgArchiveDryRun)) {
+		return ferror.MakeError(ferror.ErrorInvalidArgument,
+			fmt.Sprintf("--%v cannot be used with --%v, --%v or --%v", flagkey.PkgPrintSpec, flagkey.SpecSave, flagkey.SpecDry, flagkey.PkgArchiveDryRun))
+	}
+
+	if input.Bool(flagkey.PkgFollowBuild) && (input.Bool(flagkey.PkgPrintSpec) || input.Bool(flagkey.SpecDry) ||
+		input.Bool(flagkey.PkgArchiveDryRun) || input.Bool(flagkey.PkgValidateOnly)) {
+		return ferror.MakeError(ferror.ErrorInvalidArgument,
+			fmt.Sprintf("--%v cannot be used with --%v, --%v, --%v or --%v", flagkey.PkgFollowBuild,
+				flagkey.PkgPrintSpec, flagkey.SpecDry, flagkey.PkgArchiveDryRun, flagkey.PkgValidateOnly))
+	}
+
+	if len(input.String(flagkey.PkgArchiveOut)) > 0 && (input.Bool(flagkey.SpecSave) || input.Bool(flagkey.SpecDry) ||
+		input.Bool(flagkey.PkgPrintSpec) || input.Bool(flagkey.PkgArchiveDryRun) || input.Bool(flagkey.PkgFollowBuild) ||
+		input.Bool(flagkey.PkgReplaceIfChanged)) {
+		return ferror.MakeError(ferror.ErrorInvalidArgument,
+			fmt.Sprintf("--%v cannot be used with --%v, --%v, --%v, --%v, --%v or --%v", flagkey.PkgArchiveOut,
+				flagkey.SpecSave, flagkey.SpecDry, flagkey.PkgPrintSpec, flagkey.PkgArchiveDryRun, flagkey.PkgFollowBuild, flagkey.PkgReplaceIfChanged))
+	}
+
+	if input.Bool(flagkey.PkgReplaceIfChanged) && (input.Bool(flagkey.SpecSave) || input.Bool(flagkey.SpecDry) ||
+		input.Bool(flagkey.PkgPrintSpec) || input.Bool(flagkey.PkgArchiveDryRun) || input.Bool(flagkey.PkgValidateOnly)) {
+		return ferror.MakeError(ferror.ErrorInvalidArgument,
+			fmt.Sprintf("--%v cannot be used with --%v, --%v, --%v, --%v or --%v", flagkey.PkgReplaceIfChanged,
+				flagkey.SpecSave, flagkey.SpecDry, flagkey.PkgPrintSpec, flagkey.PkgArchiveDryRun, flagkey.PkgValidateOnly))
+	}
+
 	pkgName := input.String(flagkey.PkgName)
+	if len(pkgName) == 0 && input.Bool(flagkey.PkgReplaceIfChanged) {
+		return ferror.MakeError(ferror.ErrorInvalidArgument,
+			fmt.Sprintf("--%v is necessary with --%v", flagkey.PkgName, flagkey.PkgReplaceIfChanged))
+	}
 	if len(pkgName) == 0 {
 		if input.Bool(flagkey.SpecSave) && len(input.String(flagkey.PkgName)) == 0 {
-			return errors.Errorf("--%v is necessary when creating spec file", flagkey.PkgName)
+			return ferror.MakeError(ferror.ErrorInvalidArgument,
+				fmt.Sprintf("--%v is necessary when creating spec file", flagkey.PkgName))
 		} else {
 			console.Warn(fmt.Sprintf("--%v will be soon marked as required flag, see 'help' for details", flagkey.HtName))
 		}
 	}
 
 	envName := input.String(flagkey.PkgEnvironment)
+	if len(envName) == 0 {
+		return ferror.MakeError(ferror.ErrorInvalidArgument,
+			fmt.Sprintf("need --%v argument or env in --%v file", flagkey.PkgEnvironment, flagkey.PkgFromConfig))
+	}
 
 	userProvidedNS, pkgNamespace, err := opts.GetResourceNamespace(input, flagkey.NamespacePackage)
 	if err != nil {
@@ -71,19 +139,63 @@ func (opts *CreateSubCommand) run(input cli.Input) error {
 
 	srcArchiveFiles := input.StringSlice(flagkey.PkgSrcArchive)
 	deployArchiveFiles := input.StringSlice(flagkey.PkgDeployArchive)
-	buildcmd := input.String(flagkey.PkgBuildCmd)
+	buildcmds := input.StringSlice(flagkey.PkgBuildCmd)
+
+	if input.IsSet(flagkey.PkgImage) {
+		if len(srcArchiveFiles) > 0 || len(deployArchiveFiles) > 0 || len(buildcmds) > 0 || input.IsSet(flagkey.PkgCode) ||
+			input.IsSet(flagkey.PkgIncludeFrom) || input.IsSet(flagkey.PkgSrcArchiveID) || input.IsSet(flagkey.PkgDeployArchiveID) {
+			return ferror.MakeError(ferror.ErrorInvalidArgument,
+				fmt.Sprintf("--%v cannot be used with --%v, --%v, --%v, --%v, --%v, --%v or --%v", flagkey.PkgImage,
+					flagkey.PkgSrcArchive, flagkey.PkgDeployArchive, flagkey.PkgCode, flagkey.PkgIncludeFrom,
+					flagkey.PkgSrcArchiveID, flagkey.PkgDeployArchiveID, flagkey.PkgBuildCmd))
+		}
+		if _, err := imageAnnotations(input); err != nil {
+			return err
+		}
+	}
 
-	noZip := false
-	code := input.String(flagkey.PkgCode)
-	if len(code) == 0 {
-		deployArchiveFiles = input.StringSlice(flagkey.PkgDeployArchive)
-	} else {
-		deployArchiveFiles = append(deployArchiveFiles, input.String(flagkey.PkgCode))
-		noZip = true
+	secrets, cfgmaps, err := getBuildReferences(input, opts.Client(), pkgNamespace, userProvidedNS)
+	if err != nil {
+		return err
 	}
 
-	if len(srcArchiveFiles) == 0 && len(deployArchiveFiles) == 0 {
-		return errors.Errorf("need --%v or --%v or --%v argument", flagkey.PkgCode, flagkey.PkgSrcArchive, flagkey.PkgDeployArchive)
+	codes, noZip := CodeArchiveFiles(input)
+	deployArchiveFiles = append(deployArchiveFiles, codes...)
+
+	if input.IsSet(flagkey.PkgIncludeFrom) {
+		includeFiles, err := readIncludeFile(input.String(flagkey.PkgIncludeFrom))
+		if err != nil {
+			return err
+		}
+		deployArchiveFiles = append(deployArchiveFiles, includeFiles...)
+	}
+
+	srcArchiveID := input.String(flagkey.PkgSrcArchiveID)
+	deployArchiveID := input.String(flagkey.PkgDeployArchiveID)
+	if len(srcArchiveID) > 0 && len(srcArchiveFiles) > 0 {
+		return ferror.MakeError(ferror.ErrorInvalidArgument,
+			fmt.Sprintf("--%v cannot be used with --%v", flagkey.PkgSrcArchiveID, flagkey.PkgSrcArchive))
+	}
+	if len(deployArchiveID) > 0 && len(deployArchiveFiles) > 0 {
+		return ferror.MakeError(ferror.ErrorInvalidArgument,
+			fmt.Sprintf("--%v cannot be used with --%v or --%v", flagkey.PkgDeployArchiveID, flagkey.PkgDeployArchive, flagkey.PkgCode))
+	}
+
+	if len(srcArchiveFiles) == 0 && len(deployArchiveFiles) == 0 && len(srcArchiveID) == 0 && len(deployArchiveID) == 0 &&
+		!input.IsSet(flagkey.PkgImage) {
+		return ferror.MakeError(ferror.ErrorInvalidArgument,
+			fmt.Sprintf("need --%v or --%v or --%v or --%v argument", flagkey.PkgCode, flagkey.PkgSrcArchive, flagkey.PkgDeployArchive, flagkey.PkgImage))
+	}
+
+	if len(input.String(flagkey.PkgArchiveOut)) > 0 && (len(srcArchiveFiles) > 0) == (len(deployArchiveFiles) > 0) {
+		return ferror.MakeError(ferror.ErrorInvalidArgument,
+			fmt.Sprintf("--%v needs a single archive, given with --%v or with --%v or --%v", flagkey.PkgArchiveOut,
+				flagkey.PkgSrcArchive, flagkey.PkgDeployArchive, flagkey.PkgCode))
+	}
+
+	if input.Bool(flagkey.PkgValidateOnly) {
+		return validatePackage(input, opts.Client(), pkgName, pkgNamespace, envName, userProvidedNS,
+			srcArchiveFiles, deployArchiveFiles)
 	}
 
 	var specDir, specFile string
@@ -91,19 +203,24 @@ func (opts *CreateSubCommand) run(input cli.Input) error {
 	if input.Bool(flagkey.SpecSave) {
 		// since package CRD created using --spec, not validate by k8s. So we need to validate it and make sure package name is not more than 63 characters.
 		if len(pkgName) > 63 {
-			return errors.Errorf("error creating package: package name %v, must be no more than 63 characters", pkgName)
+			return ferror.MakeError(ferror.ErrorInvalidArgument,
+				fmt.Sprintf("error creating package: package name %v, must be no more than 63 characters", pkgName))
 		}
 
 		specDir = util.GetSpecDir(input)
 		specIgnore := util.GetSpecIgnore(input)
 		fr, err := spec.ReadSpecs(specDir, specIgnore, false)
 		if err != nil {
-			return errors.Wrap(err, fmt.Sprintf("error reading spec in '%v'", specDir))
+			return packageError(ferror.ErrorInternal, err, "error reading spec in '%v'", specDir)
+		}
+		envNamespace := userProvidedNS
+		if input.IsSet(flagkey.PkgEnvNamespace) {
+			envNamespace = input.String(flagkey.PkgEnvNamespace)
 		}
 		exists, err := fr.ExistsInSpecs(fv1.Environment{
 			ObjectMeta: metav1.ObjectMeta{
 				Name:      envName,
-				Namespace: userProvidedNS,
+				Namespace: envNamespace,
 			},
 		})
 		if err != nil {
@@ -111,61 +228,157 @@ func (opts *CreateSubCommand) run(input cli.Input) error {
 		}
 		if !exists {
 			console.Warn(fmt.Sprintf("Package '%s' references unknown Environment '%s' in Namespace '%s', please create it before applying spec",
-				pkgName, envName, userProvidedNS))
+				pkgName, envName, envNamespace))
 		}
 
 		specDir = util.GetSpecDir(input)
 		specFile = fmt.Sprintf("package-%s.yaml", pkgName)
 	}
 
-	_, err = CreatePackage(input, opts.Client(), pkgName, pkgNamespace, envName,
-		srcArchiveFiles, deployArchiveFiles, buildcmd, specDir, specFile, noZip, userProvidedNS)
+	m, _, err := CreatePackage(input, opts.Client(), pkgName, pkgNamespace, envName,
+		srcArchiveFiles, deployArchiveFiles, buildcmds, secrets, cfgmaps, specDir, specFile, noZip, userProvidedNS)
+	if err != nil {
+		return err
+	}
 
-	return err
+	if input.Bool(flagkey.PkgFollowBuild) {
+		return followBuild(input.Context(), opts.Client(), os.Stdout, pkgNamespace, m.Name, input.Duration(flagkey.PkgFollowTimeout))
+	}
+	return nil
 }
 
+// CreatePackage creates a package, or its spec if specFile is given. Besides the
+// package metadata, it returns the path of the spec file written with --spec,
+// which is empty if no spec file was written.
 // TODO: get all necessary value from CLI input directly
 func CreatePackage(input cli.Input, client cmd.Client, pkgName string, pkgNamespace string, envName string,
-	srcArchiveFiles []string, deployArchiveFiles []string, buildcmd string, specDir string, specFile string, noZip bool, userProvidedNS string) (*metav1.ObjectMeta, error) {
+	srcArchiveFiles []string, deployArchiveFiles []string, buildcmds []string,
+	secrets []fv1.SecretReference, cfgmaps []fv1.ConfigMapReference, specDir string, specFile string, noZip bool, userProvidedNS string) (*metav1.ObjectMeta, string, error) {
+
+	timeout := input.Duration(flagkey.PkgTimeout)
+	if timeout <= 0 {
+		return createPackage(input, client, pkgName, pkgNamespace, envName,
+			srcArchiveFiles, deployArchiveFiles, buildcmds, secrets, cfgmaps, specDir, specFile, noZip, userProvidedNS)
+	}
+
+	ctx, cancel := context.WithTimeout(input.Context(), timeout)
+	defer cancel()
+
+	m, specPath, err := createPackage(cli.WithContext(input, ctx), client, pkgName, pkgNamespace, envName,
+		srcArchiveFiles, deployArchiveFiles, buildcmds, secrets, cfgmaps, specDir, specFile, noZip, userProvidedNS)
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return nil, "", packageError(ferror.ErrorRequestTimeout, err, "package creation did not finish within --%v %v", flagkey.PkgTimeout, timeout)
+	}
+	return m, specPath, err
+}
+
+func createPackage(input cli.Input, client cmd.Client, pkgName string, pkgNamespace string, envName string,
+	srcArchiveFiles []string, deployArchiveFiles []string, buildcmds []string,
+	secrets []fv1.SecretReference, cfgmaps []fv1.ConfigMapReference, specDir string, specFile string, noZip bool, userProvidedNS string) (*metav1.ObjectMeta, string, error) {
 
 	insecure := input.Bool(flagkey.PkgInsecure)
 	deployChecksum := input.String(flagkey.PkgDeployChecksum)
 	srcChecksum := input.String(flagkey.PkgSrcChecksum)
 
+	annotations, err := sourceAnnotations(input)
+	if err != nil {
+		return nil, "", err
+	}
+	imgAnnotations, err := imageAnnotations(input)
+	if err != nil {
+		return nil, "", err
+	}
+	for key, value := range imgAnnotations {
+		if annotations == nil {
+			annotations = make(map[string]string)
+		}
+		annotations[key] = value
+	}
+
+	env, err := buildEnv(input)
+	if err != nil {
+		return nil, "", err
+	}
+
+	resources, err := buildResources(input)
+	if err != nil {
+		return nil, "", err
+	}
+
+	envRef, err := getEnvironmentReference(input, client, envName, pkgNamespace, userProvidedNS)
+	if err != nil {
+		return nil, "", err
+	}
 	pkgSpec := fv1.PackageSpec{
-		Environment: fv1.EnvironmentReference{
-			Namespace: pkgNamespace,
-			Name:      envName,
-		},
+		Environment:    envRef,
+		BuildEnv:       env,
+		BuildResources: resources,
+		Secrets:        secrets,
+		ConfigMaps:     cfgmaps,
 	}
-	if input.Bool(flagkey.SpecSave) || input.Bool(flagkey.SpecDry) {
-		pkgSpec = fv1.PackageSpec{
-			Environment: fv1.EnvironmentReference{
-				Namespace: userProvidedNS,
-				Name:      envName,
-			},
+	setBuildCommands(&pkgSpec, buildcmds)
+
+	// with --replace-if-changed, the package is updated if it exists, unless its
+	// content is unchanged, in which case no archive is uploaded
+	var existing *fv1.Package
+	if input.Bool(flagkey.PkgReplaceIfChanged) {
+		contentHash, err := packageContentHash(input, &pkgSpec, srcArchiveFiles, deployArchiveFiles, noZip)
+		if err != nil {
+			return nil, "", err
+		}
+		existing, err = client.FissionClientSet.CoreV1().Packages(pkgNamespace).Get(input.Context(), pkgName, metav1.GetOptions{})
+		if k8serrors.IsNotFound(err) {
+			existing = nil
+		} else if err != nil {
+			return nil, "", packageError(ferror.ErrorInternal, err, "error getting package '%v' in namespace '%v'", pkgName, pkgNamespace)
+		} else if existing.Annotations[fv1.ANNOTATION_CONTENT_HASH] == contentHash {
+			fmt.Printf("Package '%v' unchanged, skipped\n", pkgName)
+			return &existing.ObjectMeta, "", nil
+		}
+		if annotations == nil {
+			annotations = make(map[string]string)
 		}
+		annotations[fv1.ANNOTATION_CONTENT_HASH] = contentHash
 	}
 
 	var pkgStatus fv1.BuildStatus = fv1.BuildStatusSucceeded
 
-	if len(deployArchiveFiles) > 0 {
+	if deployArchiveID := input.String(flagkey.PkgDeployArchiveID); len(deployArchiveID) > 0 {
+		deployment, err := archiveFromID(input, client, deployArchiveID, deployChecksum)
+		if err != nil {
+			return nil, "", errors.Wrap(err, "error referencing deploy archive")
+		}
+		pkgSpec.Deployment = *deployment
+		if len(pkgName) == 0 {
+			pkgName = util.KubifyName(fmt.Sprintf("%v-%v", deployArchiveID, uniuri.NewLen(4)))
+		}
+	} else if len(deployArchiveFiles) > 0 {
 		if len(specFile) > 0 { // we should do this in all cases, i think
 			pkgStatus = fv1.BuildStatusNone
 		}
 		deployment, err := CreateArchive(client, input, deployArchiveFiles, noZip, insecure, deployChecksum, specDir, specFile)
 		if err != nil {
-			return nil, errors.Wrap(err, "error creating source archive")
+			return nil, "", errors.Wrap(err, "error creating deploy archive")
 		}
 		pkgSpec.Deployment = *deployment
 		if len(pkgName) == 0 {
 			pkgName = util.KubifyName(fmt.Sprintf("%v-%v", path.Base(deployArchiveFiles[0]), uniuri.NewLen(4)))
 		}
 	}
-	if len(srcArchiveFiles) > 0 {
+	if srcArchiveID := input.String(flagkey.PkgSrcArchiveID); len(srcArchiveID) > 0 {
+		source, err := archiveFromID(input, client, srcArchiveID, srcChecksum)
+		if err != nil {
+			return nil, "", errors.Wrap(err, "error referencing source archive")
+		}
+		pkgSpec.Source = *source
+		pkgStatus = fv1.BuildStatusPending // set package build status to pending
+		if len(pkgName) == 0 {
+			pkgName = util.KubifyName(fmt.Sprintf("%v-%v", srcArchiveID, uniuri.NewLen(4)))
+		}
+	} else if len(srcArchiveFiles) > 0 {
 		source, err := CreateArchive(client, input, srcArchiveFiles, false, insecure, srcChecksum, specDir, specFile)
 		if err != nil {
-			return nil, errors.Wrap(err, "error creating deploy archive")
+			return nil, "", errors.Wrap(err, "error creating source archive")
 		}
 		pkgSpec.Source = *source
 		pkgStatus = fv1.BuildStatusPending // set package build status to pending
@@ -173,9 +386,10 @@ func CreatePackage(input cli.Input, client cmd.Client, pkgName string, pkgNamesp
 			pkgName = util.KubifyName(fmt.Sprintf("%v-%v", path.Base(srcArchiveFiles[0]), uniuri.NewLen(4)))
 		}
 	}
-
-	if len(buildcmd) > 0 {
-		pkgSpec.BuildCommand = buildcmd
+	// packages of a prebuilt image have no archive to upload or build
+	if image := imgAnnotations[fv1.ANNOTATION_IMAGE]; len(pkgName) == 0 && len(image) > 0 {
+		name := imageRefRegex.FindStringSubmatch(image)[1]
+		pkgName = util.KubifyName(fmt.Sprintf("%v-%v", path.Base(name), uniuri.NewLen(4)))
 	}
 
 	if len(pkgName) == 0 {
@@ -184,8 +398,9 @@ func CreatePackage(input cli.Input, client cmd.Client, pkgName string, pkgNamesp
 
 	pkg := &fv1.Package{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      pkgName,
-			Namespace: userProvidedNS,
+			Name:        pkgName,
+			Namespace:   userProvidedNS,
+			Annotations: annotations,
 		},
 		Spec: pkgSpec,
 		Status: fv1.PackageStatus{
@@ -194,37 +409,409 @@ func CreatePackage(input cli.Input, client cmd.Client, pkgName string, pkgNamesp
 		},
 	}
 
+	if input.Bool(flagkey.PkgArchiveDryRun) {
+		fmt.Printf("Dry run, package '%v' is not created\n", pkg.ObjectMeta.Name)
+		return &pkg.ObjectMeta, "", nil
+	}
+	if len(input.String(flagkey.PkgArchiveOut)) > 0 {
+		fmt.Printf("Archive written, package '%v' is not created\n", pkg.ObjectMeta.Name)
+		return &pkg.ObjectMeta, "", nil
+	}
+
+	if input.Bool(flagkey.PkgPrintSpec) {
+		pkg.ObjectMeta.Namespace = pkgNamespace
+		err = printPackageSpec(os.Stdout, pkg)
+		if err != nil {
+			return nil, "", packageError(ferror.ErrorInternal, err, "error printing package spec")
+		}
+		return &pkg.ObjectMeta, "", nil
+	}
+
 	if input.Bool(flagkey.SpecDry) {
-		return &pkg.ObjectMeta, spec.SpecDry(*pkg)
+		return &pkg.ObjectMeta, "", spec.SpecDry(*pkg)
 	}
 
 	if input.Bool(flagkey.SpecSave) {
 		// if a package with the same spec exists, don't create a new spec file
 		fr, err := spec.ReadSpecs(util.GetSpecDir(input), util.GetSpecIgnore(input), false)
 		if err != nil {
-			return nil, errors.Wrap(err, "error reading specs")
+			return nil, "", packageError(ferror.ErrorInternal, err, "error reading specs")
 		}
 
 		obj := fr.SpecExists(pkg, true, true)
 		if obj != nil {
 			pkg := obj.(*fv1.Package)
 			fmt.Printf("Re-using previously created package %v\n", pkg.ObjectMeta.Name)
-			return &pkg.ObjectMeta, nil
+			return &pkg.ObjectMeta, "", nil
 		}
 
 		err = spec.SpecSave(*pkg, specFile, false)
 		if err != nil {
-			return nil, errors.Wrap(err, "error saving package spec")
+			return nil, "", packageError(ferror.ErrorInternal, err, "error saving package spec")
 		}
-		return &pkg.ObjectMeta, nil
+		return &pkg.ObjectMeta, spec.SpecFilePath(specFile), nil
+	} else if existing != nil {
+		return replacePackage(input.Context(), client, existing, pkg)
 	} else {
 		pkg.ObjectMeta.Namespace = pkgNamespace
 
 		pkgMetadata, err := client.FissionClientSet.CoreV1().Packages(pkgNamespace).Create(input.Context(), pkg, metav1.CreateOptions{})
 		if err != nil {
-			return nil, errors.Wrap(err, "error creating package")
+			if errors.Is(input.Context().Err(), context.DeadlineExceeded) {
+				// the request may have reached the server before the deadline
+				deleteTimedOutPackage(input.Context(), client, pkg)
+			}
+			if k8serrors.IsAlreadyExists(err) {
+				return nil, "", packageError(ferror.ErrorNameExists, err, "error creating package")
+			}
+			return nil, "", packageError(ferror.ErrorInternal, err, "error creating package")
 		}
 		fmt.Printf("Package '%v' created\n", pkgMetadata.GetName())
-		return &pkgMetadata.ObjectMeta, nil
+		return &pkgMetadata.ObjectMeta, "", nil
+	}
+}
+
+// replacePackage updates the existing package to the spec, status and annotations of
+// pkg, and points the functions using it at the new package version.
+func replacePackage(ctx context.Context, client cmd.Client, existing *fv1.Package, pkg *fv1.Package) (*metav1.ObjectMeta, string, error) {
+	if existing.Annotations == nil {
+		existing.Annotations = make(map[string]string)
+	}
+	for key, value := range pkg.Annotations {
+		existing.Annotations[key] = value
+	}
+	existing.Spec = pkg.Spec
+	existing.Status = pkg.Status
+
+	fnList, err := GetFunctionsByPackage(ctx, client, existing.Name, existing.Namespace)
+	if err != nil {
+		return nil, "", packageError(ferror.ErrorInternal, err, "error getting functions of package '%v'", existing.Name)
+	}
+
+	pkgMetadata, err := client.FissionClientSet.CoreV1().Packages(existing.Namespace).Update(ctx, existing, metav1.UpdateOptions{})
+	if err != nil {
+		return nil, "", packageError(ferror.ErrorInternal, err, "error updating package '%v'", existing.Name)
+	}
+	err = UpdateFunctionPackageResourceVersion(ctx, client, &pkgMetadata.ObjectMeta, fnList...)
+	if err != nil {
+		return nil, "", packageError(ferror.ErrorInternal, err, "error updating function package reference resource version")
 	}
+	fmt.Printf("Package '%v' updated\n", pkgMetadata.GetName())
+	return &pkgMetadata.ObjectMeta, "", nil
+}
+
+// packageContentHash returns the hash of what a package is made of: its archives,
+// environment, build commands, build environment, build references and image. Local archive
+// files are hashed by content, so that the hash does not change with their modification
+// times. URLs and archive IDs are hashed together with the checksum given for them.
+func packageContentHash(input cli.Input, pkgSpec *fv1.PackageSpec, srcArchiveFiles []string, deployArchiveFiles []string, noZip bool) (string, error) {
+	archiveOpts, err := getArchiveOptions(input)
+	if err != nil {
+		return "", err
+	}
+	source, err := archiveContentHash(archiveOpts, srcArchiveFiles, input.String(flagkey.PkgSrcArchiveID), input.String(flagkey.PkgSrcChecksum))
+	if err != nil {
+		return "", errors.Wrap(err, "error hashing source archive")
+	}
+	deployment, err := archiveContentHash(archiveOpts, deployArchiveFiles, input.String(flagkey.PkgDeployArchiveID), input.String(flagkey.PkgDeployChecksum))
+	if err != nil {
+		return "", errors.Wrap(err, "error hashing deploy archive")
+	}
+
+	data, err := json.Marshal(struct {
+		Environment   fv1.EnvironmentReference
+		BuildCommand  string
+		BuildCommands []string
+		BuildEnv      []fv1.BuildEnvVar
+		Resources     *apiv1.ResourceRequirements
+		Secrets       []fv1.SecretReference
+		ConfigMaps    []fv1.ConfigMapReference
+		Image         string
+		ArchiveFormat utils.ArchiveFormat
+		PreserveMode  bool
+		NoZip         bool
+		Source        string
+		Deployment    string
+	}{
+		Environment:   pkgSpec.Environment,
+		BuildCommand:  pkgSpec.BuildCommand,
+		BuildCommands: pkgSpec.BuildCommands,
+		BuildEnv:      pkgSpec.BuildEnv,
+		Resources:     pkgSpec.BuildResources,
+		Secrets:       pkgSpec.Secrets,
+		ConfigMaps:    pkgSpec.ConfigMaps,
+		Image:         strings.TrimSpace(input.String(flagkey.PkgImage)),
+		ArchiveFormat: archiveOpts.Format,
+		PreserveMode:  archiveOpts.PreserveMode,
+		NoZip:         noZip,
+		Source:        source,
+		Deployment:    deployment,
+	})
+	if err != nil {
+		return "", packageError(ferror.ErrorInternal, err, "error hashing package content")
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// archiveContentHash returns a string identifying the content of the archive made of
+// files or referencing the archive id.
+func archiveContentHash(opts utils.ArchiveOptions, files []string, id string, checksum string) (string, error) {
+	switch {
+	case len(id) > 0:
+		return fmt.Sprintf("id:%v:%v", id, checksum), nil
+	case len(files) == 0:
+		return "", nil
+	case len(files) == 1 && utils.IsURL(files[0]):
+		return fmt.Sprintf("url:%v:%v", files[0], checksum), nil
+	case slices.Contains(files, StdinArchive):
+		// hashing would consume the archive before it is uploaded
+		return "", ferror.MakeError(ferror.ErrorInvalidArgument,
+			fmt.Sprintf("--%v cannot be used with an archive read from stdin", flagkey.PkgReplaceIfChanged))
+	}
+	csum, err := utils.ArchiveContentChecksum(opts, files...)
+	if err != nil {
+		return "", packageError(ferror.ErrorInvalidArgument, err, "error reading archive files")
+	}
+	return fmt.Sprintf("%v:%v", csum.Type, csum.Sum), nil
+}
+
+// printPackageSpec writes pkg as YAML to w, e.g. to be piped into kubectl apply.
+func printPackageSpec(w io.Writer, pkg *fv1.Package) error {
+	pkg.TypeMeta = metav1.TypeMeta{
+		APIVersion: fv1.CRD_VERSION,
+		Kind:       "Package",
+	}
+	data, err := yaml.Marshal(pkg)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// sourceAnnotations returns the provenance annotations given with --source-commit,
+// --source-repo and --source-ref, or nil if none is given.
+func sourceAnnotations(input cli.Input) (map[string]string, error) {
+	annotations := make(map[string]string)
+
+	commit := strings.TrimSpace(input.String(flagkey.PkgSourceCommit))
+	if len(commit) > 0 {
+		if !commitSHARegex.MatchString(commit) {
+			return nil, ferror.MakeError(ferror.ErrorInvalidArgument,
+				fmt.Sprintf("--%v '%v' is not a commit SHA, must be 7 to 64 hex characters", flagkey.PkgSourceCommit, commit))
+		}
+		annotations[fv1.ANNOTATION_SOURCE_COMMIT] = strings.ToLower(commit)
+	}
+	if repo := strings.TrimSpace(input.String(flagkey.PkgSourceRepo)); len(repo) > 0 {
+		annotations[fv1.ANNOTATION_SOURCE_REPO] = repo
+	}
+	if ref := strings.TrimSpace(input.String(flagkey.PkgSourceRef)); len(ref) > 0 {
+		annotations[fv1.ANNOTATION_SOURCE_REF] = ref
+	}
+
+	if len(annotations) == 0 {
+		return nil, nil
+	}
+	return annotations, nil
+}
+
+// imageAnnotations returns the annotations referencing the prebuilt container image
+// given with --image, or nil if none is given. The digest of a reference pinned to a
+// digest is also recorded on its own.
+func imageAnnotations(input cli.Input) (map[string]string, error) {
+	image := strings.TrimSpace(input.String(flagkey.PkgImage))
+	if len(image) == 0 {
+		if input.IsSet(flagkey.PkgImage) {
+			return nil, ferror.MakeError(ferror.ErrorInvalidArgument, fmt.Sprintf("--%v must not be empty", flagkey.PkgImage))
+		}
+		return nil, nil
+	}
+
+	match := imageRefRegex.FindStringSubmatch(image)
+	if match == nil || len(match[1]) > 255 {
+		return nil, ferror.MakeError(ferror.ErrorInvalidArgument,
+			fmt.Sprintf("--%v '%v' is not a valid container image reference, must be [registry/]repository[:tag][@digest]", flagkey.PkgImage, image))
+	}
+	annotations := map[string]string{fv1.ANNOTATION_IMAGE: image}
+	if digest := match[3]; len(digest) > 0 {
+		annotations[fv1.ANNOTATION_IMAGE_DIGEST] = digest
+	}
+	return annotations, nil
+}
+
+// deleteTimedOutPackage removes a package whose create request ran into the
+// deadline, so that a timed out command does not leave a package behind.
+func deleteTimedOutPackage(ctx context.Context, client cmd.Client, pkg *fv1.Package) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+	defer cancel()
+
+	err := client.FissionClientSet.CoreV1().Packages(pkg.Namespace).Delete(ctx, pkg.Name, metav1.DeleteOptions{})
+	if err != nil && !k8serrors.IsNotFound(err) {
+		console.Warn(fmt.Sprintf("Package '%v' may have been created, please delete it: %v", pkg.Name, err))
+	}
+}
+
+// getEnvironmentReference returns the environment reference of a new package.
+// The environment lives in the package namespace unless --env-namespace is given,
+// in which case the environment must exist in that namespace. The existence check
+// is skipped when generating specs, since the environment may only exist in the
+// spec directory.
+func getEnvironmentReference(input cli.Input, client cmd.Client, envName string, pkgNamespace string, userProvidedNS string) (fv1.EnvironmentReference, error) {
+	specMode := input.Bool(flagkey.SpecSave) || input.Bool(flagkey.SpecDry)
+
+	envRef := fv1.EnvironmentReference{
+		Namespace: pkgNamespace,
+		Name:      envName,
+	}
+	if specMode {
+		envRef.Namespace = userProvidedNS
+	}
+
+	if !input.IsSet(flagkey.PkgEnvNamespace) {
+		return envRef, nil
+	}
+
+	envNamespace := input.String(flagkey.PkgEnvNamespace)
+	if len(envNamespace) == 0 {
+		return envRef, ferror.MakeError(ferror.ErrorInvalidArgument, fmt.Sprintf("--%v must not be empty", flagkey.PkgEnvNamespace))
+	}
+	envRef.Namespace = envNamespace
+
+	if !specMode {
+		err := checkEnvironmentExists(input.Context(), client, envRef)
+		if err != nil {
+			return envRef, err
+		}
+	}
+
+	return envRef, nil
+}
+
+// getBuildReferences returns the references to the secrets and configmaps given with
+// --secret and --configmap, which live in the package namespace. Unless --force is
+// given, they must exist. The existence check is skipped when generating specs.
+func getBuildReferences(input cli.Input, client cmd.Client, pkgNamespace string, userProvidedNS string) ([]fv1.SecretReference, []fv1.ConfigMapReference, error) {
+	specMode := input.Bool(flagkey.SpecSave) || input.Bool(flagkey.SpecDry)
+	namespace := pkgNamespace
+	if specMode {
+		namespace = userProvidedNS
+	}
+
+	check := func(kind string, name string, err error) error {
+		if err == nil {
+			return nil
+		}
+		if !k8serrors.IsNotFound(err) {
+			return packageError(ferror.ErrorInternal, err, "error getting %v '%v' in namespace '%v'", kind, name, namespace)
+		}
+		if input.Bool(flagkey.PkgForce) {
+			console.Warn(fmt.Sprintf("%v '%v' not found in namespace '%v', it needs to be created before the package is built", kind, name, namespace))
+			return nil
+		}
+		return packageError(ferror.ErrorNotFound, err, "%v '%v' not found in namespace '%v', use --%v to create the package anyway",
+			kind, name, namespace, flagkey.PkgForce)
+	}
+
+	var secrets []fv1.SecretReference
+	for _, name := range input.StringSlice(flagkey.PkgSecret) {
+		if !specMode {
+			err := check("Secret", name, util.SecretExists(input.Context(), &metav1.ObjectMeta{Namespace: namespace, Name: name}, client.KubernetesClient))
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+		secrets = append(secrets, fv1.SecretReference{Name: name, Namespace: namespace})
+	}
+
+	var cfgmaps []fv1.ConfigMapReference
+	for _, name := range input.StringSlice(flagkey.PkgCfgMap) {
+		if !specMode {
+			err := check("ConfigMap", name, util.ConfigMapExists(input.Context(), &metav1.ObjectMeta{Namespace: namespace, Name: name}, client.KubernetesClient))
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+		cfgmaps = append(cfgmaps, fv1.ConfigMapReference{Name: name, Namespace: namespace})
+	}
+
+	return secrets, cfgmaps, nil
+}
+
+func checkEnvironmentExists(ctx context.Context, client cmd.Client, envRef fv1.EnvironmentReference) error {
+	_, err := client.FissionClientSet.CoreV1().Environments(envRef.Namespace).Get(ctx, envRef.Name, metav1.GetOptions{})
+	if err != nil {
+		code := ferror.ErrorInternal
+		if k8serrors.IsNotFound(err) {
+			code = ferror.ErrorNotFound
+		}
+		return packageError(code, err, "error getting environment '%v' in namespace '%v'", envRef.Name, envRef.Namespace)
+	}
+	return nil
+}
+
+// packageError returns a ferror.Error with the given code, so that callers can
+// tell failure classes apart, and a message describing err in the given context.
+func packageError(code int, err error, format string, args ...interface{}) error {
+	return ferror.MakeError(code, errors.Wrapf(err, format, args...).Error())
+}
+
+// validatePackage runs all checks of package creation, without creating
+// the package or writing any spec, and prints a summary of the result.
+func validatePackage(input cli.Input, client cmd.Client, pkgName string, pkgNamespace string, envName string, userProvidedNS string,
+	srcArchiveFiles []string, deployArchiveFiles []string) error {
+
+	errs := utils.MultiErrorWithFormat()
+
+	if len(pkgName) > 63 {
+		errs = multierror.Append(errs, errors.Errorf("package name %v, must be no more than 63 characters", pkgName))
+	}
+
+	envRef, err := getEnvironmentReference(input, client, envName, pkgNamespace, userProvidedNS)
+	if err != nil {
+		errs = multierror.Append(errs, err)
+	} else if !input.IsSet(flagkey.PkgEnvNamespace) && !input.Bool(flagkey.SpecSave) && !input.Bool(flagkey.SpecDry) {
+		// getEnvironmentReference only checks environments given with --env-namespace
+		err = checkEnvironmentExists(input.Context(), client, envRef)
+		if err != nil {
+			errs = multierror.Append(errs, err)
+		}
+	}
+
+	for _, path := range append(append([]string{}, srcArchiveFiles...), deployArchiveFiles...) {
+		if utils.IsURL(path) || path == StdinArchive {
+			continue
+		}
+		files, err := utils.FindAllGlobs(path)
+		if err != nil {
+			errs = multierror.Append(errs, errors.Wrap(err, "error finding all globs"))
+			continue
+		}
+		if len(files) == 0 {
+			errs = multierror.Append(errs, errors.Errorf("Error finding any files with path \"%v\"", path))
+		}
+	}
+
+	for _, key := range []string{flagkey.PkgSrcChecksum, flagkey.PkgDeployChecksum} {
+		checksum := input.String(key)
+		if len(checksum) == 0 {
+			continue
+		}
+		if _, err := hex.DecodeString(checksum); err != nil || len(checksum) != sha256.Size*2 {
+			errs = multierror.Append(errs, errors.Errorf("--%v '%v' is not a valid SHA256 checksum", key, checksum))
+		}
+	}
+
+	name := pkgName
+	if len(name) == 0 {
+		name = "<generated>"
+	}
+	fmt.Printf("Package '%v': environment '%v' in namespace '%v', %v source archive(s), %v deploy archive(s)\n",
+		name, envRef.Name, envRef.Namespace, len(srcArchiveFiles), len(deployArchiveFiles))
+
+	if errs.ErrorOrNil() != nil {
+		return errors.Wrapf(errs, "package '%v' failed validation", name)
+	}
+	fmt.Printf("Package '%v' is valid\n", name)
+	return nil
 }
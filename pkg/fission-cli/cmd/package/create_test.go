@@ -0,0 +1,1469 @@
+package _package
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"sigs.k8s.io/yaml"
+
+	fv1 "github.com/fission/fission/pkg/apis/core/v1"
+	ferror "github.com/fission/fission/pkg/error"
+	"github.com/fission/fission/pkg/fission-cli/cliwrapper/driver/dummy"
+	"github.com/fission/fission/pkg/fission-cli/cmd"
+	flagkey "github.com/fission/fission/pkg/fission-cli/flag/key"
+	"github.com/fission/fission/pkg/generated/clientset/versioned/fake"
+	"github.com/fission/fission/pkg/utils"
+)
+
+func newTestClient(objects ...*fv1.Environment) cmd.Client {
+	fissionClient := fake.NewSimpleClientset()
+	for _, env := range objects {
+		err := fissionClient.Tracker().Add(env)
+		if err != nil {
+			panic(err)
+		}
+	}
+	return cmd.Client{FissionClientSet: fissionClient}
+}
+
+func writeTestFile(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	return path
+}
+
+func TestGetEnvironmentReference(t *testing.T) {
+	env := &fv1.Environment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "nodejs",
+			Namespace: "shared-envs",
+		},
+	}
+
+	for _, test := range []struct {
+		name        string
+		args        map[string]interface{}
+		expectedNS  string
+		expectError bool
+	}{
+		{
+			name:       "environment defaults to package namespace",
+			args:       map[string]interface{}{},
+			expectedNS: "pkg-ns",
+		},
+		{
+			name:       "environment defaults to user provided namespace in spec mode",
+			args:       map[string]interface{}{flagkey.SpecDry: true},
+			expectedNS: "",
+		},
+		{
+			name:       "env-namespace overrides package namespace",
+			args:       map[string]interface{}{flagkey.PkgEnvNamespace: "shared-envs"},
+			expectedNS: "shared-envs",
+		},
+		{
+			name:       "env-namespace overrides user provided namespace in spec mode",
+			args:       map[string]interface{}{flagkey.PkgEnvNamespace: "other-envs", flagkey.SpecDry: true},
+			expectedNS: "other-envs",
+		},
+		{
+			name:        "env-namespace must contain the environment",
+			args:        map[string]interface{}{flagkey.PkgEnvNamespace: "missing"},
+			expectError: true,
+		},
+		{
+			name:        "env-namespace must not be empty",
+			args:        map[string]interface{}{flagkey.PkgEnvNamespace: ""},
+			expectError: true,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			flags := dummy.TestFlagSet()
+			for k, v := range test.args {
+				flags.Set(k, v)
+			}
+
+			envRef, err := getEnvironmentReference(flags, newTestClient(env), "nodejs", "pkg-ns", "")
+			if test.expectError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, "nodejs", envRef.Name)
+			require.Equal(t, test.expectedNS, envRef.Namespace)
+		})
+	}
+}
+
+func TestCreatePackageWithEnvNamespace(t *testing.T) {
+	env := &fv1.Environment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "nodejs",
+			Namespace: "shared-envs",
+		},
+	}
+	client := newTestClient(env)
+	code := writeTestFile(t, "hello.js", "module.exports = async function(context) {}")
+
+	flags := dummy.TestFlagSet()
+	flags.Set(flagkey.PkgEnvNamespace, "shared-envs")
+
+	meta, _, err := CreatePackage(flags, client, "hello-pkg", "pkg-ns", "nodejs",
+		nil, []string{code}, nil, nil, nil, "", "", true, "")
+	require.NoError(t, err)
+
+	pkg, err := client.FissionClientSet.CoreV1().Packages(meta.Namespace).Get(flags.Context(), meta.Name, metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Equal(t, "pkg-ns", pkg.Namespace)
+	require.Equal(t, "shared-envs", pkg.Spec.Environment.Namespace)
+	require.NotEqual(t, pkg.Namespace, pkg.Spec.Environment.Namespace)
+}
+
+func TestValidatePackage(t *testing.T) {
+	env := &fv1.Environment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "nodejs",
+			Namespace: "default",
+		},
+	}
+	code := writeTestFile(t, "hello.js", "module.exports = async function(context) {}")
+
+	for _, test := range []struct {
+		name        string
+		pkgName     string
+		envName     string
+		deploy      []string
+		args        map[string]interface{}
+		expectError string
+	}{
+		{
+			name:    "valid package",
+			pkgName: "hello-pkg",
+			envName: "nodejs",
+			deploy:  []string{code},
+		},
+		{
+			name:        "missing environment",
+			pkgName:     "hello-pkg",
+			envName:     "python",
+			deploy:      []string{code},
+			expectError: "error getting environment 'python' in namespace 'default'",
+		},
+		{
+			name:        "package name too long",
+			pkgName:     strings.Repeat("a", 64),
+			envName:     "nodejs",
+			deploy:      []string{code},
+			expectError: "must be no more than 63 characters",
+		},
+		{
+			name:        "missing archive file",
+			pkgName:     "hello-pkg",
+			envName:     "nodejs",
+			deploy:      []string{filepath.Join(t.TempDir(), "missing.js")},
+			expectError: "Error finding any files",
+		},
+		{
+			name:        "invalid checksum",
+			pkgName:     "hello-pkg",
+			envName:     "nodejs",
+			deploy:      []string{code},
+			args:        map[string]interface{}{flagkey.PkgDeployChecksum: "abc"},
+			expectError: "is not a valid SHA256 checksum",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			client := newTestClient(env)
+			flags := dummy.TestFlagSet()
+			for k, v := range test.args {
+				flags.Set(k, v)
+			}
+
+			err := validatePackage(flags, client, test.pkgName, "default", test.envName, "", nil, test.deploy)
+			if len(test.expectError) > 0 {
+				require.ErrorContains(t, err, test.expectError)
+			} else {
+				require.NoError(t, err)
+			}
+
+			fakeClient := client.FissionClientSet.(*fake.Clientset)
+			for _, action := range fakeClient.Actions() {
+				require.NotEqual(t, "create", action.GetVerb(), "validation must not create any resource")
+			}
+		})
+	}
+}
+
+func requireErrorCode(t *testing.T, err error, code int) {
+	t.Helper()
+	require.Error(t, err)
+	var fe ferror.Error
+	require.True(t, errors.As(err, &fe), "expected ferror.Error, got %v", err)
+	require.EqualValues(t, code, fe.Code, "unexpected error code: %v", err)
+}
+
+func TestCreatePackageErrorCodes(t *testing.T) {
+	env := &fv1.Environment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "nodejs",
+			Namespace: "default",
+		},
+	}
+	code := writeTestFile(t, "hello.js", "module.exports = async function(context) {}")
+
+	t.Run("package name too long", func(t *testing.T) {
+		flags := dummy.TestFlagSet()
+		flags.Set(flagkey.PkgName, strings.Repeat("a", 64))
+		flags.Set(flagkey.PkgEnvironment, "nodejs")
+		flags.Set(flagkey.PkgCode, []string{code})
+		flags.Set(flagkey.SpecSave, true)
+		err := (&CreateSubCommand{}).run(flags)
+		requireErrorCode(t, err, ferror.ErrorInvalidArgument)
+	})
+
+	t.Run("no environment given", func(t *testing.T) {
+		flags := dummy.TestFlagSet()
+		flags.Set(flagkey.PkgName, "hello-pkg")
+		flags.Set(flagkey.PkgCode, []string{code})
+		err := (&CreateSubCommand{}).run(flags)
+		requireErrorCode(t, err, ferror.ErrorInvalidArgument)
+	})
+
+	t.Run("invalid config file", func(t *testing.T) {
+		flags := dummy.TestFlagSet()
+		flags.Set(flagkey.PkgName, "hello-pkg")
+		flags.Set(flagkey.PkgFromConfig, writeTestFile(t, "package.yaml", "environment: nodejs\n"))
+		err := (&CreateSubCommand{}).run(flags)
+		requireErrorCode(t, err, ferror.ErrorInvalidArgument)
+	})
+
+	t.Run("no archive given", func(t *testing.T) {
+		flags := dummy.TestFlagSet()
+		flags.Set(flagkey.PkgName, "hello-pkg")
+		flags.Set(flagkey.PkgEnvironment, "nodejs")
+		err := (&CreateSubCommand{}).run(flags)
+		requireErrorCode(t, err, ferror.ErrorInvalidArgument)
+	})
+
+	t.Run("environment missing", func(t *testing.T) {
+		flags := dummy.TestFlagSet()
+		flags.Set(flagkey.PkgEnvNamespace, "shared-envs")
+		_, _, err := CreatePackage(flags, newTestClient(env), "hello-pkg", "default", "nodejs",
+			nil, []string{code}, nil, nil, nil, "", "", true, "")
+		requireErrorCode(t, err, ferror.ErrorNotFound)
+	})
+
+	t.Run("empty environment namespace", func(t *testing.T) {
+		flags := dummy.TestFlagSet()
+		flags.Set(flagkey.PkgEnvNamespace, "")
+		_, _, err := CreatePackage(flags, newTestClient(env), "hello-pkg", "default", "nodejs",
+			nil, []string{code}, nil, nil, nil, "", "", true, "")
+		requireErrorCode(t, err, ferror.ErrorInvalidArgument)
+	})
+
+	t.Run("archive file missing", func(t *testing.T) {
+		_, _, err := CreatePackage(dummy.TestFlagSet(), newTestClient(env), "hello-pkg", "default", "nodejs",
+			nil, []string{filepath.Join(t.TempDir(), "missing.js")}, nil, nil, nil, "", "", true, "")
+		requireErrorCode(t, err, ferror.ErrorInvalidArgument)
+	})
+
+	t.Run("invalid archive format", func(t *testing.T) {
+		flags := dummy.TestFlagSet()
+		flags.Set(flagkey.PkgArchiveFormat, "rar")
+		_, _, err := CreatePackage(flags, newTestClient(env), "hello-pkg", "default", "nodejs",
+			nil, []string{code}, nil, nil, nil, "", "", true, "")
+		requireErrorCode(t, err, ferror.ErrorInvalidArgument)
+	})
+
+	t.Run("upload failed", func(t *testing.T) {
+		server := httptest.NewServer(http.NotFoundHandler())
+		server.Close()
+		t.Setenv("FISSION_STORAGESVC_URL", server.URL)
+
+		large := writeTestFile(t, "large.js", strings.Repeat("x", int(fv1.ArchiveLiteralSizeLimit)+1))
+		_, _, err := CreatePackage(dummy.TestFlagSet(), newTestClient(env), "hello-pkg", "default", "nodejs",
+			nil, []string{large}, nil, nil, nil, "", "", true, "")
+		requireErrorCode(t, err, ferror.ErrorInternal)
+	})
+
+	t.Run("package already exists", func(t *testing.T) {
+		client := newTestClient(env)
+		_, err := client.FissionClientSet.CoreV1().Packages("default").Create(context.TODO(), &fv1.Package{
+			ObjectMeta: metav1.ObjectMeta{Name: "hello-pkg", Namespace: "default"},
+		}, metav1.CreateOptions{})
+		require.NoError(t, err)
+
+		_, _, err = CreatePackage(dummy.TestFlagSet(), client, "hello-pkg", "default", "nodejs",
+			nil, []string{code}, nil, nil, nil, "", "", true, "")
+		requireErrorCode(t, err, ferror.ErrorNameExists)
+	})
+}
+
+func TestCreatePackageTimeout(t *testing.T) {
+	env := &fv1.Environment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "nodejs",
+			Namespace: "default",
+		},
+	}
+
+	t.Run("slow upload", func(t *testing.T) {
+		release := make(chan struct{})
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			<-release
+		}))
+		defer server.Close()
+		defer close(release)
+		t.Setenv("FISSION_STORAGESVC_URL", server.URL)
+
+		client := newTestClient(env)
+		flags := dummy.TestFlagSet()
+		flags.Set(flagkey.PkgTimeout, 200*time.Millisecond)
+
+		large := writeTestFile(t, "large.js", strings.Repeat("x", int(fv1.ArchiveLiteralSizeLimit)+1))
+		start := time.Now()
+		_, _, err := CreatePackage(flags, client, "hello-pkg", "default", "nodejs",
+			nil, []string{large}, nil, nil, nil, "", "", true, "")
+		requireErrorCode(t, err, ferror.ErrorRequestTimeout)
+		require.Less(t, time.Since(start), 5*time.Second)
+
+		pkgs, err := client.FissionClientSet.CoreV1().Packages("default").List(context.TODO(), metav1.ListOptions{})
+		require.NoError(t, err)
+		require.Empty(t, pkgs.Items)
+	})
+
+	t.Run("slow package create", func(t *testing.T) {
+		fissionClient := fake.NewSimpleClientset(env)
+		fissionClient.PrependReactor("create", "packages", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			// the package is stored, but the response arrives after the deadline
+			obj := action.(k8stesting.CreateAction).GetObject()
+			err := fissionClient.Tracker().Create(action.GetResource(), obj, action.GetNamespace())
+			require.NoError(t, err)
+			time.Sleep(300 * time.Millisecond)
+			return true, nil, context.DeadlineExceeded
+		})
+		client := cmd.Client{FissionClientSet: fissionClient}
+
+		flags := dummy.TestFlagSet()
+		flags.Set(flagkey.PkgTimeout, 200*time.Millisecond)
+		code := writeTestFile(t, "hello.js", "module.exports = async function(context) {}")
+		_, _, err := CreatePackage(flags, client, "hello-pkg", "default", "nodejs",
+			nil, []string{code}, nil, nil, nil, "", "", true, "")
+		requireErrorCode(t, err, ferror.ErrorRequestTimeout)
+
+		pkgs, err := client.FissionClientSet.CoreV1().Packages("default").List(context.TODO(), metav1.ListOptions{})
+		require.NoError(t, err)
+		require.Empty(t, pkgs.Items)
+	})
+}
+
+func TestCreatePackageSpecFile(t *testing.T) {
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	dir := t.TempDir()
+	require.NoError(t, os.Chdir(dir))
+	defer func() {
+		require.NoError(t, os.Chdir(wd))
+	}()
+
+	require.NoError(t, os.Mkdir("specs", 0755))
+	require.NoError(t, os.WriteFile(filepath.Join("specs", "fission-deployment-config.yaml"), []byte(`apiVersion: fission.io/v1
+kind: DeploymentConfig
+name: test
+uid: 8c2f7d3a-6d7e-4b6a-9a55-0b1f1e4b7d12
+`), 0644))
+	require.NoError(t, os.WriteFile("hello.js", []byte("module.exports = async function(context) {}"), 0644))
+
+	flags := dummy.TestFlagSet()
+	flags.Set(flagkey.SpecSave, true)
+	meta, specPath, err := CreatePackage(flags, newTestClient(), "hello-pkg", "default", "nodejs",
+		nil, []string{"hello.js"}, nil, nil, nil, "specs", "package-hello-pkg.yaml", false, "")
+	require.NoError(t, err)
+	require.Equal(t, "hello-pkg", meta.Name)
+	require.Equal(t, filepath.Join("specs", "package-hello-pkg.yaml"), specPath)
+
+	data, err := os.ReadFile(specPath)
+	require.NoError(t, err)
+	require.Contains(t, string(data), "name: hello-pkg")
+
+	// no spec file is written without --spec
+	_, specPath, err = CreatePackage(dummy.TestFlagSet(), newTestClient(), "other-pkg", "default", "nodejs",
+		nil, []string{"hello.js"}, nil, nil, nil, "", "", true, "")
+	require.NoError(t, err)
+	require.Empty(t, specPath)
+}
+
+func TestCreatePackageSourceAnnotations(t *testing.T) {
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	dir := t.TempDir()
+	require.NoError(t, os.Chdir(dir))
+	defer func() {
+		require.NoError(t, os.Chdir(wd))
+	}()
+
+	require.NoError(t, os.Mkdir("specs", 0755))
+	require.NoError(t, os.WriteFile(filepath.Join("specs", "fission-deployment-config.yaml"), []byte(`apiVersion: fission.io/v1
+kind: DeploymentConfig
+name: test
+uid: 8c2f7d3a-6d7e-4b6a-9a55-0b1f1e4b7d12
+`), 0644))
+	require.NoError(t, os.WriteFile("hello.js", []byte("module.exports = async function(context) {}"), 0644))
+
+	const commit = "0123456789abcdef0123456789ABCDEF01234567"
+	expected := map[string]string{
+		fv1.ANNOTATION_SOURCE_COMMIT: strings.ToLower(commit),
+		fv1.ANNOTATION_SOURCE_REPO:   "https://github.com/fission/fission",
+		fv1.ANNOTATION_SOURCE_REF:    "refs/heads/main",
+	}
+	setSourceFlags := func(flags dummy.Cli) {
+		flags.Set(flagkey.PkgSourceCommit, commit)
+		flags.Set(flagkey.PkgSourceRepo, "https://github.com/fission/fission")
+		flags.Set(flagkey.PkgSourceRef, "refs/heads/main")
+	}
+
+	t.Run("spec", func(t *testing.T) {
+		flags := dummy.TestFlagSet()
+		flags.Set(flagkey.SpecSave, true)
+		setSourceFlags(flags)
+		meta, specPath, err := CreatePackage(flags, newTestClient(), "hello-pkg", "default", "nodejs",
+			nil, []string{"hello.js"}, nil, nil, nil, "specs", "package-hello-pkg.yaml", false, "")
+		require.NoError(t, err)
+		require.Equal(t, expected, meta.Annotations)
+
+		data, err := os.ReadFile(specPath)
+		require.NoError(t, err)
+		for key, value := range expected {
+			require.Contains(t, string(data), fmt.Sprintf("%v: %v", key, value))
+		}
+	})
+
+	t.Run("cluster", func(t *testing.T) {
+		client := newTestClient()
+		flags := dummy.TestFlagSet()
+		setSourceFlags(flags)
+		_, _, err := CreatePackage(flags, client, "hello-pkg", "default", "nodejs",
+			nil, []string{"hello.js"}, nil, nil, nil, "", "", true, "")
+		require.NoError(t, err)
+
+		pkg, err := client.FissionClientSet.CoreV1().Packages("default").Get(context.Background(), "hello-pkg", metav1.GetOptions{})
+		require.NoError(t, err)
+		require.Equal(t, expected, pkg.Annotations)
+	})
+
+	t.Run("no source flags", func(t *testing.T) {
+		client := newTestClient()
+		_, _, err := CreatePackage(dummy.TestFlagSet(), client, "plain-pkg", "default", "nodejs",
+			nil, []string{"hello.js"}, nil, nil, nil, "", "", true, "")
+		require.NoError(t, err)
+
+		pkg, err := client.FissionClientSet.CoreV1().Packages("default").Get(context.Background(), "plain-pkg", metav1.GetOptions{})
+		require.NoError(t, err)
+		require.Empty(t, pkg.Annotations)
+	})
+
+	t.Run("invalid commit", func(t *testing.T) {
+		for _, commit := range []string{"abc", "not-a-sha", "0123456789abcdefg"} {
+			flags := dummy.TestFlagSet()
+			flags.Set(flagkey.PkgSourceCommit, commit)
+			_, _, err := CreatePackage(flags, newTestClient(), "hello-pkg", "default", "nodejs",
+				nil, []string{"hello.js"}, nil, nil, nil, "", "", true, "")
+			requireErrorCode(t, err, ferror.ErrorInvalidArgument)
+		}
+	})
+}
+
+func TestCreatePackageBuildEnv(t *testing.T) {
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	dir := t.TempDir()
+	require.NoError(t, os.Chdir(dir))
+	defer func() {
+		require.NoError(t, os.Chdir(wd))
+	}()
+
+	require.NoError(t, os.Mkdir("specs", 0755))
+	require.NoError(t, os.WriteFile(filepath.Join("specs", "fission-deployment-config.yaml"), []byte(`apiVersion: fission.io/v1
+kind: DeploymentConfig
+name: test
+uid: 8c2f7d3a-6d7e-4b6a-9a55-0b1f1e4b7d12
+`), 0644))
+	require.NoError(t, os.WriteFile("hello.js", []byte("module.exports = async function(context) {}"), 0644))
+
+	expected := []fv1.BuildEnvVar{{Name: "NPM_TOKEN", Value: "secret"}, {Name: "GOFLAGS", Value: "-mod=vendor"}}
+	setBuildEnv := func(flags dummy.Cli) {
+		flags.Set(flagkey.PkgBuildEnv, []string{"NPM_TOKEN=secret", "GOFLAGS=-mod=vendor"})
+	}
+
+	t.Run("spec", func(t *testing.T) {
+		flags := dummy.TestFlagSet()
+		flags.Set(flagkey.SpecSave, true)
+		setBuildEnv(flags)
+		_, specPath, err := CreatePackage(flags, newTestClient(), "hello-pkg", "default", "nodejs",
+			[]string{"hello.js"}, nil, nil, nil, nil, "specs", "package-hello-pkg.yaml", false, "")
+		require.NoError(t, err)
+
+		data, err := os.ReadFile(specPath)
+		require.NoError(t, err)
+		// the spec file holds the archive upload spec followed by the package
+		docs := strings.Split(string(data), "\n---\n")
+		var pkg fv1.Package
+		require.NoError(t, yaml.Unmarshal([]byte(docs[len(docs)-1]), &pkg))
+		require.Equal(t, "Package", pkg.Kind)
+		require.Equal(t, expected, pkg.Spec.BuildEnv)
+		require.NoError(t, pkg.Spec.Validate())
+	})
+
+	t.Run("cluster", func(t *testing.T) {
+		client := newTestClient()
+		flags := dummy.TestFlagSet()
+		setBuildEnv(flags)
+		_, _, err := CreatePackage(flags, client, "hello-pkg", "default", "nodejs",
+			[]string{"hello.js"}, nil, nil, nil, nil, "", "", false, "")
+		require.NoError(t, err)
+
+		pkg, err := client.FissionClientSet.CoreV1().Packages("default").Get(context.Background(), "hello-pkg", metav1.GetOptions{})
+		require.NoError(t, err)
+		require.Equal(t, expected, pkg.Spec.BuildEnv)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		flags := dummy.TestFlagSet()
+		flags.Set(flagkey.PkgBuildEnv, []string{"NPM-TOKEN"})
+		_, _, err := CreatePackage(flags, newTestClient(), "hello-pkg", "default", "nodejs",
+			[]string{"hello.js"}, nil, nil, nil, nil, "", "", false, "")
+		requireErrorCode(t, err, ferror.ErrorInvalidArgument)
+	})
+}
+
+func TestCreatePackageBuildResources(t *testing.T) {
+	hello := writeTestFile(t, "hello.js", "module.exports = async function(context) {}")
+	quantities := func(list corev1.ResourceList) map[corev1.ResourceName]string {
+		out := make(map[corev1.ResourceName]string, len(list))
+		for name, q := range list {
+			out[name] = q.String()
+		}
+		return out
+	}
+
+	t.Run("cluster", func(t *testing.T) {
+		client := newTestClient()
+		flags := dummy.TestFlagSet()
+		flags.Set(flagkey.PkgBuildCPU, "500m:2")
+		flags.Set(flagkey.PkgBuildMemory, "1Gi")
+		_, _, err := CreatePackage(flags, client, "hello-pkg", "default", "nodejs",
+			[]string{hello}, nil, nil, nil, nil, "", "", false, "")
+		require.NoError(t, err)
+
+		pkg, err := client.FissionClientSet.CoreV1().Packages("default").Get(context.Background(), "hello-pkg", metav1.GetOptions{})
+		require.NoError(t, err)
+		require.NotNil(t, pkg.Spec.BuildResources)
+		require.Equal(t, map[corev1.ResourceName]string{corev1.ResourceCPU: "500m", corev1.ResourceMemory: "1Gi"},
+			quantities(pkg.Spec.BuildResources.Requests))
+		require.Equal(t, map[corev1.ResourceName]string{corev1.ResourceCPU: "2", corev1.ResourceMemory: "1Gi"},
+			quantities(pkg.Spec.BuildResources.Limits))
+	})
+
+	t.Run("unset", func(t *testing.T) {
+		client := newTestClient()
+		_, _, err := CreatePackage(dummy.TestFlagSet(), client, "hello-pkg", "default", "nodejs",
+			[]string{hello}, nil, nil, nil, nil, "", "", false, "")
+		require.NoError(t, err)
+
+		pkg, err := client.FissionClientSet.CoreV1().Packages("default").Get(context.Background(), "hello-pkg", metav1.GetOptions{})
+		require.NoError(t, err)
+		require.Nil(t, pkg.Spec.BuildResources)
+	})
+
+	for _, test := range []struct {
+		flag  string
+		value string
+		msg   string
+	}{
+		{flag: flagkey.PkgBuildCPU, value: "lots", msg: "invalid --build-cpu 'lots', must be a positive quantity"},
+		{flag: flagkey.PkgBuildCPU, value: "1:", msg: "invalid --build-cpu '1:', must be a positive quantity"},
+		{flag: flagkey.PkgBuildCPU, value: "0", msg: "invalid --build-cpu '0', must be a positive quantity"},
+		{flag: flagkey.PkgBuildMemory, value: "2Gi:1Gi", msg: "invalid --build-memory '2Gi:1Gi', the request 2Gi is greater than the limit 1Gi"},
+	} {
+		t.Run("invalid "+test.value, func(t *testing.T) {
+			flags := dummy.TestFlagSet()
+			flags.Set(test.flag, test.value)
+			_, _, err := CreatePackage(flags, newTestClient(), "hello-pkg", "default", "nodejs",
+				[]string{hello}, nil, nil, nil, nil, "", "", false, "")
+			requireErrorCode(t, err, ferror.ErrorInvalidArgument)
+			require.Contains(t, err.Error(), test.msg)
+		})
+	}
+}
+
+func TestCreatePackageBuildCommands(t *testing.T) {
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	dir := t.TempDir()
+	require.NoError(t, os.Chdir(dir))
+	defer func() {
+		require.NoError(t, os.Chdir(wd))
+	}()
+
+	require.NoError(t, os.Mkdir("specs", 0755))
+	require.NoError(t, os.WriteFile(filepath.Join("specs", "fission-deployment-config.yaml"), []byte(`apiVersion: fission.io/v1
+kind: DeploymentConfig
+name: test
+uid: 8c2f7d3a-6d7e-4b6a-9a55-0b1f1e4b7d12
+`), 0644))
+	require.NoError(t, os.WriteFile("hello.js", []byte("module.exports = async function(context) {}"), 0644))
+
+	buildcmds := []string{"npm ci", "npm run lint", "npm run build"}
+
+	t.Run("spec", func(t *testing.T) {
+		flags := dummy.TestFlagSet()
+		flags.Set(flagkey.SpecSave, true)
+		_, specPath, err := CreatePackage(flags, newTestClient(), "hello-pkg", "default", "nodejs",
+			[]string{"hello.js"}, nil, buildcmds, nil, nil, "specs", "package-hello-pkg.yaml", false, "")
+		require.NoError(t, err)
+
+		data, err := os.ReadFile(specPath)
+		require.NoError(t, err)
+		docs := strings.Split(string(data), "\n---\n")
+		require.Contains(t, docs[len(docs)-1], "  buildcmds:\n  - npm ci\n  - npm run lint\n  - npm run build\n")
+		var pkg fv1.Package
+		require.NoError(t, yaml.UnmarshalStrict([]byte(docs[len(docs)-1]), &pkg))
+		require.Empty(t, pkg.Spec.BuildCommand)
+		require.Equal(t, buildcmds, pkg.Spec.BuildCommands)
+		require.NoError(t, pkg.Spec.Validate())
+	})
+
+	t.Run("cluster", func(t *testing.T) {
+		client := newTestClient()
+		_, _, err := CreatePackage(dummy.TestFlagSet(), client, "hello-pkg", "default", "nodejs",
+			[]string{"hello.js"}, nil, buildcmds, nil, nil, "", "", false, "")
+		require.NoError(t, err)
+
+		pkg, err := client.FissionClientSet.CoreV1().Packages("default").Get(context.Background(), "hello-pkg", metav1.GetOptions{})
+		require.NoError(t, err)
+		require.Equal(t, buildcmds, pkg.Spec.BuildCommands)
+	})
+
+	t.Run("single", func(t *testing.T) {
+		client := newTestClient()
+		_, _, err := CreatePackage(dummy.TestFlagSet(), client, "hello-pkg", "default", "nodejs",
+			[]string{"hello.js"}, nil, []string{"./build.sh"}, nil, nil, "", "", false, "")
+		require.NoError(t, err)
+
+		// a single build command is kept in buildcmd for compatibility
+		pkg, err := client.FissionClientSet.CoreV1().Packages("default").Get(context.Background(), "hello-pkg", metav1.GetOptions{})
+		require.NoError(t, err)
+		require.Equal(t, "./build.sh", pkg.Spec.BuildCommand)
+		require.Empty(t, pkg.Spec.BuildCommands)
+	})
+}
+
+func TestCreatePackageBuildReferences(t *testing.T) {
+	code := writeTestFile(t, "hello.js", "module.exports = async function(context) {}")
+	client := newTestClient()
+	client.KubernetesClient = kubefake.NewSimpleClientset(
+		&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "npm-token", Namespace: "default"}},
+		&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "npmrc", Namespace: "default"}},
+	)
+
+	t.Run("existing", func(t *testing.T) {
+		flags := dummy.TestFlagSet()
+		flags.Set(flagkey.PkgSecret, []string{"npm-token"})
+		flags.Set(flagkey.PkgCfgMap, []string{"npmrc"})
+		secrets, cfgmaps, err := getBuildReferences(flags, client, "default", "")
+		require.NoError(t, err)
+		require.Equal(t, []fv1.SecretReference{{Name: "npm-token", Namespace: "default"}}, secrets)
+		require.Equal(t, []fv1.ConfigMapReference{{Name: "npmrc", Namespace: "default"}}, cfgmaps)
+
+		_, _, err = CreatePackage(flags, client, "hello-pkg", "default", "nodejs",
+			nil, []string{code}, nil, secrets, cfgmaps, "", "", true, "")
+		require.NoError(t, err)
+		pkg, err := client.FissionClientSet.CoreV1().Packages("default").Get(context.Background(), "hello-pkg", metav1.GetOptions{})
+		require.NoError(t, err)
+		require.Equal(t, secrets, pkg.Spec.Secrets)
+		require.Equal(t, cfgmaps, pkg.Spec.ConfigMaps)
+		require.NoError(t, pkg.Spec.Validate())
+	})
+
+	for _, key := range []string{flagkey.PkgSecret, flagkey.PkgCfgMap} {
+		t.Run("missing "+key, func(t *testing.T) {
+			flags := dummy.TestFlagSet()
+			flags.Set(key, []string{"missing"})
+			_, _, err := getBuildReferences(flags, client, "default", "")
+			requireErrorCode(t, err, ferror.ErrorNotFound)
+
+			flags.Set(flagkey.PkgForce, true)
+			secrets, cfgmaps, err := getBuildReferences(flags, client, "default", "")
+			require.NoError(t, err)
+			require.Len(t, secrets, len(flags.StringSlice(flagkey.PkgSecret)))
+			require.Len(t, cfgmaps, len(flags.StringSlice(flagkey.PkgCfgMap)))
+		})
+	}
+
+	t.Run("spec", func(t *testing.T) {
+		// specs may be applied to any namespace, so the references are not checked
+		flags := dummy.TestFlagSet()
+		flags.Set(flagkey.SpecSave, true)
+		flags.Set(flagkey.PkgSecret, []string{"missing"})
+		secrets, _, err := getBuildReferences(flags, client, "default", "")
+		require.NoError(t, err)
+		require.Equal(t, []fv1.SecretReference{{Name: "missing"}}, secrets)
+	})
+}
+
+func TestCreatePackageDeployChecksum(t *testing.T) {
+	env := &fv1.Environment{ObjectMeta: metav1.ObjectMeta{Name: "nodejs", Namespace: "default"}}
+	content := []byte("module.exports = async function(context) {}")
+	code := writeTestFile(t, "hello.js", string(content))
+	sum := sha256.Sum256(content)
+	checksum := hex.EncodeToString(sum[:])
+
+	for _, test := range []struct {
+		name      string
+		checksum  string
+		errorCode int
+	}{
+		{name: "matching checksum", checksum: checksum},
+		{name: "matching checksum in upper case", checksum: strings.ToUpper(checksum)},
+		{name: "mismatching checksum", checksum: strings.Repeat("0", sha256.Size*2), errorCode: ferror.ErrorInvalidArgument},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			client := newTestClient(env)
+			flags := dummy.TestFlagSet()
+			flags.Set(flagkey.PkgDeployChecksum, test.checksum)
+
+			_, _, err := CreatePackage(flags, client, "hello-pkg", "default", "nodejs",
+				nil, []string{code}, nil, nil, nil, "", "", true, "")
+			if test.errorCode != 0 {
+				requireErrorCode(t, err, test.errorCode)
+				require.ErrorContains(t, err, "checksum mismatch")
+				_, err = client.FissionClientSet.CoreV1().Packages("default").Get(context.Background(), "hello-pkg", metav1.GetOptions{})
+				require.Error(t, err, "package must not be created on checksum mismatch")
+				return
+			}
+
+			require.NoError(t, err)
+			pkg, err := client.FissionClientSet.CoreV1().Packages("default").Get(context.Background(), "hello-pkg", metav1.GetOptions{})
+			require.NoError(t, err)
+			require.EqualValues(t, fv1.BuildStatusSucceeded, pkg.Status.BuildStatus)
+		})
+	}
+}
+
+func TestArchiveDryRun(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "lib"), 0755))
+	files := []string{
+		filepath.Join(dir, "main.js"),
+		filepath.Join(dir, "lib", "util.js"),
+	}
+	for _, file := range files {
+		require.NoError(t, os.WriteFile(file, []byte(file), 0644))
+	}
+
+	archivePath, err := makeArchiveFile("", []string{dir}, false, utils.ArchiveOptions{Format: utils.ArchiveFormatZip})
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	archive, err := archiveDryRun(&buf, archivePath, []string{dir})
+	require.NoError(t, err)
+	csum, err := utils.GetFileChecksum(archivePath)
+	require.NoError(t, err)
+	require.Equal(t, csum.Sum, archive.Checksum.Sum)
+	require.Empty(t, archive.URL)
+	require.Empty(t, archive.Literal)
+
+	var listed []string
+	for _, line := range strings.Split(buf.String(), "\n") {
+		if strings.HasPrefix(line, "  ") {
+			listed = append(listed, strings.TrimSpace(line))
+		}
+	}
+	require.ElementsMatch(t, files, listed)
+	require.Contains(t, buf.String(), fmt.Sprintf("Files: 2, size: %v bytes, SHA256 checksum: %v", mustFileSize(t, archivePath), csum.Sum))
+
+	// no upload and no package with --archive-dry-run, even for archives
+	// too large to be embedded as literal
+	storage := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request to storage service: %v %v", r.Method, r.URL)
+	}))
+	defer storage.Close()
+	t.Setenv("FISSION_STORAGESVC_URL", storage.URL)
+
+	large := writeTestFile(t, "large.bin", strings.Repeat("x", int(fv1.ArchiveLiteralSizeLimit)+1))
+	client := newTestClient(&fv1.Environment{ObjectMeta: metav1.ObjectMeta{Name: "nodejs", Namespace: "default"}})
+	flags := dummy.TestFlagSet()
+	flags.Set(flagkey.PkgArchiveDryRun, true)
+	meta, _, err := CreatePackage(flags, client, "hello-pkg", "default", "nodejs",
+		nil, []string{large}, nil, nil, nil, "", "", true, "")
+	require.NoError(t, err)
+	require.Equal(t, "hello-pkg", meta.Name)
+
+	fakeClient := client.FissionClientSet.(*fake.Clientset)
+	for _, action := range fakeClient.Actions() {
+		require.NotEqual(t, "create", action.GetVerb(), "dry run must not create any resource")
+	}
+}
+
+func mustFileSize(t *testing.T, path string) int64 {
+	t.Helper()
+	size, err := utils.FileSize(path)
+	require.NoError(t, err)
+	return size
+}
+
+func TestCreatePackagePrintSpec(t *testing.T) {
+	code := writeTestFile(t, "hello.js", "module.exports = async function(context) {}")
+	client := newTestClient(&fv1.Environment{ObjectMeta: metav1.ObjectMeta{Name: "nodejs", Namespace: "default"}})
+	flags := dummy.TestFlagSet()
+	flags.Set(flagkey.PkgPrintSpec, true)
+	flags.Set(flagkey.PkgSourceCommit, "0123456789abcdef")
+	flags.Set(flagkey.PkgBuildEnv, []string{"NPM_TOKEN=secret"})
+
+	r, w, err := os.Pipe()
+	require.NoError(t, err)
+	stdout := os.Stdout
+	os.Stdout = w
+	_, _, err = CreatePackage(flags, client, "hello-pkg", "default", "nodejs",
+		nil, []string{code}, nil, nil, nil, "", "", true, "")
+	os.Stdout = stdout
+	require.NoError(t, w.Close())
+	require.NoError(t, err)
+	data, err := io.ReadAll(r)
+	require.NoError(t, err)
+
+	var pkg fv1.Package
+	require.NoError(t, yaml.UnmarshalStrict(data, &pkg))
+	require.Equal(t, "Package", pkg.Kind)
+	require.Equal(t, fv1.CRD_VERSION, pkg.APIVersion)
+	require.Equal(t, "hello-pkg", pkg.Name)
+	require.Equal(t, "default", pkg.Namespace)
+	require.Equal(t, "0123456789abcdef", pkg.Annotations[fv1.ANNOTATION_SOURCE_COMMIT])
+	require.Equal(t, []fv1.BuildEnvVar{{Name: "NPM_TOKEN", Value: "secret"}}, pkg.Spec.BuildEnv)
+	require.Equal(t, fv1.ArchiveTypeLiteral, pkg.Spec.Deployment.Type)
+
+	fakeClient := client.FissionClientSet.(*fake.Clientset)
+	for _, action := range fakeClient.Actions() {
+		require.NotEqual(t, "create", action.GetVerb(), "--print-spec must not create any resource")
+	}
+
+	flags.Set(flagkey.SpecDry, true)
+	requireErrorCode(t, Create(flags), ferror.ErrorInvalidArgument)
+}
+
+func TestCreatePackageArchiveID(t *testing.T) {
+	const archiveID = "f9a3e1b2-archive"
+	storage := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodHead {
+			t.Errorf("unexpected request to storage service: %v %v", r.Method, r.URL)
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		if r.URL.Query().Get("id") != archiveID {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		w.Header().Add("X-FISSION-STORAGETYPE", "local")
+	}))
+	defer storage.Close()
+	t.Setenv("FISSION_STORAGESVC_URL", storage.URL)
+
+	env := &fv1.Environment{ObjectMeta: metav1.ObjectMeta{Name: "nodejs", Namespace: "default"}}
+	checksum := strings.Repeat("ab", sha256.Size)
+
+	t.Run("deploy", func(t *testing.T) {
+		client := newTestClient(env)
+		flags := dummy.TestFlagSet()
+		flags.Set(flagkey.PkgDeployArchiveID, archiveID)
+		flags.Set(flagkey.PkgDeployChecksum, checksum)
+		_, _, err := CreatePackage(flags, client, "hello-pkg", "default", "nodejs",
+			nil, nil, nil, nil, nil, "", "", false, "")
+		require.NoError(t, err)
+
+		pkg, err := client.FissionClientSet.CoreV1().Packages("default").Get(context.Background(), "hello-pkg", metav1.GetOptions{})
+		require.NoError(t, err)
+		require.Equal(t, fv1.ArchiveTypeUrl, pkg.Spec.Deployment.Type)
+		require.Equal(t, storage.URL+"/v1/archive?id="+archiveID, pkg.Spec.Deployment.URL)
+		require.Equal(t, fv1.Checksum{Type: fv1.ChecksumTypeSHA256, Sum: checksum}, pkg.Spec.Deployment.Checksum)
+		require.Empty(t, pkg.Spec.Source.URL)
+		require.EqualValues(t, fv1.BuildStatusSucceeded, pkg.Status.BuildStatus)
+	})
+
+	t.Run("source", func(t *testing.T) {
+		client := newTestClient(env)
+		flags := dummy.TestFlagSet()
+		flags.Set(flagkey.PkgSrcArchiveID, archiveID)
+		_, _, err := CreatePackage(flags, client, "hello-pkg", "default", "nodejs",
+			nil, nil, nil, nil, nil, "", "", false, "")
+		require.NoError(t, err)
+
+		pkg, err := client.FissionClientSet.CoreV1().Packages("default").Get(context.Background(), "hello-pkg", metav1.GetOptions{})
+		require.NoError(t, err)
+		require.Equal(t, storage.URL+"/v1/archive?id="+archiveID, pkg.Spec.Source.URL)
+		require.Empty(t, pkg.Spec.Source.Checksum.Sum)
+		require.EqualValues(t, fv1.BuildStatusPending, pkg.Status.BuildStatus)
+	})
+
+	t.Run("missing archive", func(t *testing.T) {
+		client := newTestClient(env)
+		flags := dummy.TestFlagSet()
+		flags.Set(flagkey.PkgDeployArchiveID, "missing")
+		_, _, err := CreatePackage(flags, client, "hello-pkg", "default", "nodejs",
+			nil, nil, nil, nil, nil, "", "", false, "")
+		requireErrorCode(t, err, ferror.ErrorNotFound)
+		_, err = client.FissionClientSet.CoreV1().Packages("default").Get(context.Background(), "hello-pkg", metav1.GetOptions{})
+		require.Error(t, err, "package must not be created for a missing archive")
+	})
+
+	t.Run("with archive files", func(t *testing.T) {
+		flags := dummy.TestFlagSet()
+		flags.Set(flagkey.PkgEnvironment, "nodejs")
+		flags.Set(flagkey.PkgDeployArchiveID, archiveID)
+		flags.Set(flagkey.PkgDeployArchive, []string{"hello.js"})
+		requireErrorCode(t, Create(flags), ferror.ErrorInvalidArgument)
+	})
+}
+
+func TestFollowBuild(t *testing.T) {
+	newPackage := func(status fv1.BuildStatus, buildLog string) *fv1.Package {
+		return &fv1.Package{
+			ObjectMeta: metav1.ObjectMeta{Name: "hello-pkg", Namespace: "default"},
+			Status:     fv1.PackageStatus{BuildStatus: status, BuildLog: buildLog},
+		}
+	}
+	// followWatch runs followBuild against a fake watch, which is fed by feed
+	followWatch := func(t *testing.T, timeout time.Duration, feed func(watcher *watch.FakeWatcher)) (string, error) {
+		client := newTestClient()
+		watcher := watch.NewFake()
+		client.FissionClientSet.(*fake.Clientset).PrependWatchReactor("packages", k8stesting.DefaultWatchReactor(watcher, nil))
+
+		var buf bytes.Buffer
+		done := make(chan error)
+		go func() {
+			done <- followBuild(context.Background(), client, &buf, "default", "hello-pkg", timeout)
+		}()
+		feed(watcher)
+		select {
+		case err := <-done:
+			return buf.String(), err
+		case <-time.After(5 * time.Second):
+			t.Fatal("followBuild did not return")
+			return "", nil
+		}
+	}
+
+	t.Run("created then built", func(t *testing.T) {
+		out, err := followWatch(t, time.Minute, func(watcher *watch.FakeWatcher) {
+			watcher.Add(newPackage(fv1.BuildStatusPending, ""))
+			watcher.Modify(newPackage(fv1.BuildStatusRunning, ""))
+			watcher.Modify(newPackage(fv1.BuildStatusSucceeded, `npm install\nadded 42 packages\n`))
+		})
+		require.NoError(t, err)
+		require.Equal(t, `Waiting for package 'hello-pkg' to be created, e.g. with 'fission spec apply'
+Package 'hello-pkg' build status: pending
+Package 'hello-pkg' build status: running
+Package 'hello-pkg' build status: succeeded
+Build Logs:
+npm install
+added 42 packages
+`, out)
+	})
+
+	t.Run("build failed", func(t *testing.T) {
+		out, err := followWatch(t, time.Minute, func(watcher *watch.FakeWatcher) {
+			watcher.Add(newPackage(fv1.BuildStatusFailed, "npm ERR! missing script: build"))
+		})
+		requireErrorCode(t, err, ferror.ErrorInternal)
+		require.Contains(t, out, "npm ERR! missing script: build")
+	})
+
+	t.Run("deleted", func(t *testing.T) {
+		_, err := followWatch(t, time.Minute, func(watcher *watch.FakeWatcher) {
+			watcher.Add(newPackage(fv1.BuildStatusPending, ""))
+			watcher.Delete(newPackage(fv1.BuildStatusPending, ""))
+		})
+		requireErrorCode(t, err, ferror.ErrorNotFound)
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		_, err := followWatch(t, 50*time.Millisecond, func(watcher *watch.FakeWatcher) {})
+		requireErrorCode(t, err, ferror.ErrorRequestTimeout)
+	})
+
+	t.Run("already built", func(t *testing.T) {
+		client := newTestClient()
+		_, err := client.FissionClientSet.CoreV1().Packages("default").Create(context.Background(),
+			newPackage(fv1.BuildStatusSucceeded, "done"), metav1.CreateOptions{})
+		require.NoError(t, err)
+
+		var buf bytes.Buffer
+		require.NoError(t, followBuild(context.Background(), client, &buf, "default", "hello-pkg", time.Minute))
+		require.Equal(t, "Package 'hello-pkg' build status: succeeded\nBuild Logs:\ndone\n", buf.String())
+	})
+}
+
+func TestWaitForPackageBuild(t *testing.T) {
+	newPackage := func(status fv1.BuildStatus, buildLog string) *fv1.Package {
+		return &fv1.Package{
+			ObjectMeta: metav1.ObjectMeta{Name: "hello-pkg", Namespace: "default"},
+			Status:     fv1.PackageStatus{BuildStatus: status, BuildLog: buildLog},
+		}
+	}
+	type result struct {
+		status   fv1.BuildStatus
+		buildLog string
+		err      error
+	}
+	wait := func(t *testing.T, timeout time.Duration, feed func(watcher *watch.FakeWatcher)) result {
+		client := newTestClient()
+		watcher := watch.NewFake()
+		client.FissionClientSet.(*fake.Clientset).PrependWatchReactor("packages", k8stesting.DefaultWatchReactor(watcher, nil))
+
+		done := make(chan result)
+		go func() {
+			status, buildLog, err := WaitForPackageBuild(context.Background(), client, "hello-pkg", "default", timeout)
+			done <- result{status: status, buildLog: buildLog, err: err}
+		}()
+		feed(watcher)
+		select {
+		case r := <-done:
+			return r
+		case <-time.After(5 * time.Second):
+			t.Fatal("WaitForPackageBuild did not return")
+			return result{}
+		}
+	}
+
+	t.Run("succeeded", func(t *testing.T) {
+		r := wait(t, time.Minute, func(watcher *watch.FakeWatcher) {
+			watcher.Add(newPackage(fv1.BuildStatusPending, ""))
+			watcher.Modify(newPackage(fv1.BuildStatusRunning, ""))
+			watcher.Modify(newPackage(fv1.BuildStatusSucceeded, `npm install\nadded 42 packages\n`))
+		})
+		require.NoError(t, r.err)
+		require.EqualValues(t, fv1.BuildStatusSucceeded, r.status)
+		require.Equal(t, "npm install\nadded 42 packages", r.buildLog)
+	})
+
+	t.Run("failed", func(t *testing.T) {
+		var buildLog []string
+		for i := 1; i <= buildLogTailLines+5; i++ {
+			buildLog = append(buildLog, fmt.Sprintf("line %d", i))
+		}
+		r := wait(t, time.Minute, func(watcher *watch.FakeWatcher) {
+			watcher.Add(newPackage(fv1.BuildStatusPending, ""))
+			watcher.Modify(newPackage(fv1.BuildStatusRunning, ""))
+			watcher.Modify(newPackage(fv1.BuildStatusFailed, strings.Join(buildLog, "\n")))
+		})
+		require.NoError(t, r.err)
+		require.EqualValues(t, fv1.BuildStatusFailed, r.status)
+		require.Equal(t, strings.Join(buildLog[5:], "\n"), r.buildLog)
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		r := wait(t, 50*time.Millisecond, func(watcher *watch.FakeWatcher) {
+			watcher.Add(newPackage(fv1.BuildStatusRunning, ""))
+		})
+		requireErrorCode(t, r.err, ferror.ErrorRequestTimeout)
+		require.Empty(t, r.status)
+	})
+}
+
+func TestCreatePackageReplaceIfChanged(t *testing.T) {
+	dir := t.TempDir()
+	code := filepath.Join(dir, "hello.js")
+	require.NoError(t, os.WriteFile(code, []byte("module.exports = async function(context) {}"), 0644))
+	client := newTestClient(&fv1.Environment{ObjectMeta: metav1.ObjectMeta{Name: "nodejs", Namespace: "default"}})
+	_, err := client.FissionClientSet.CoreV1().Functions("default").Create(context.Background(), &fv1.Function{
+		ObjectMeta: metav1.ObjectMeta{Name: "hello", Namespace: "default"},
+		Spec: fv1.FunctionSpec{
+			Package: fv1.FunctionPackageRef{PackageRef: fv1.PackageRef{Name: "hello-pkg", Namespace: "default", ResourceVersion: "old"}},
+		},
+	}, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	create := func(flags dummy.Cli) (*fv1.Package, string) {
+		t.Helper()
+		r, w, err := os.Pipe()
+		require.NoError(t, err)
+		stdout := os.Stdout
+		os.Stdout = w
+		_, _, err = CreatePackage(flags, client, "hello-pkg", "default", "nodejs",
+			nil, []string{dir}, flags.StringSlice(flagkey.PkgBuildCmd), nil, nil, "", "", false, "")
+		os.Stdout = stdout
+		require.NoError(t, w.Close())
+		require.NoError(t, err)
+		out, err := io.ReadAll(r)
+		require.NoError(t, err)
+
+		pkg, err := client.FissionClientSet.CoreV1().Packages("default").Get(context.Background(), "hello-pkg", metav1.GetOptions{})
+		require.NoError(t, err)
+		return pkg, string(out)
+	}
+	newFlags := func() dummy.Cli {
+		flags := dummy.TestFlagSet()
+		flags.Set(flagkey.PkgReplaceIfChanged, true)
+		return flags
+	}
+
+	pkg, out := create(newFlags())
+	require.Equal(t, "Package 'hello-pkg' created\n", out)
+	contentHash := pkg.Annotations[fv1.ANNOTATION_CONTENT_HASH]
+	require.Len(t, contentHash, sha256.Size*2)
+
+	t.Run("unchanged", func(t *testing.T) {
+		// archives of the same files are identical regardless of modification times
+		mtime := time.Now().Add(time.Hour)
+		require.NoError(t, os.Chtimes(code, mtime, mtime))
+
+		unchanged, out := create(newFlags())
+		require.Equal(t, "Package 'hello-pkg' unchanged, skipped\n", out)
+		require.Equal(t, pkg, unchanged)
+	})
+
+	t.Run("changed build command", func(t *testing.T) {
+		flags := newFlags()
+		flags.Set(flagkey.PkgBuildCmd, []string{"npm ci"})
+		updated, out := create(flags)
+		require.Equal(t, "Package 'hello-pkg' updated\n", out)
+		require.Equal(t, "npm ci", updated.Spec.BuildCommand)
+		require.NotEqual(t, contentHash, updated.Annotations[fv1.ANNOTATION_CONTENT_HASH])
+		contentHash = updated.Annotations[fv1.ANNOTATION_CONTENT_HASH]
+	})
+
+	t.Run("changed archive", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(code, []byte("module.exports = async function(context) { return 'hi' }"), 0644))
+		flags := newFlags()
+		flags.Set(flagkey.PkgBuildCmd, []string{"npm ci"})
+		updated, out := create(flags)
+		require.Equal(t, "Package 'hello-pkg' updated\n", out)
+		require.NotEqual(t, contentHash, updated.Annotations[fv1.ANNOTATION_CONTENT_HASH])
+		require.NotEqual(t, pkg.Spec.Deployment, updated.Spec.Deployment)
+
+		// functions using the package reference the updated version
+		fn, err := client.FissionClientSet.CoreV1().Functions("default").Get(context.Background(), "hello", metav1.GetOptions{})
+		require.NoError(t, err)
+		require.Equal(t, updated.ResourceVersion, fn.Spec.Package.PackageRef.ResourceVersion)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		flags := newFlags()
+		flags.Set(flagkey.SpecDry, true)
+		requireErrorCode(t, Create(flags), ferror.ErrorInvalidArgument)
+
+		flags = newFlags()
+		flags.Set(flagkey.PkgEnvironment, "nodejs")
+		flags.Set(flagkey.PkgCode, []string{code})
+		requireErrorCode(t, Create(flags), ferror.ErrorInvalidArgument)
+	})
+}
+
+func TestCreatePackageImage(t *testing.T) {
+	env := &fv1.Environment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "nodejs",
+			Namespace: "default",
+		},
+	}
+	// no archive must be uploaded for image packages
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected storage request %v %v", r.Method, r.URL)
+	}))
+	defer server.Close()
+	t.Setenv("FISSION_STORAGESVC_URL", server.URL)
+
+	const digest = "sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+
+	for _, test := range []struct {
+		name     string
+		image    string
+		expected map[string]string
+	}{
+		{
+			name:     "tag",
+			image:    "ghcr.io/fission/hello:v1.2.0",
+			expected: map[string]string{fv1.ANNOTATION_IMAGE: "ghcr.io/fission/hello:v1.2.0"},
+		},
+		{
+			name:  "digest",
+			image: "localhost:5000/hello@" + digest,
+			expected: map[string]string{
+				fv1.ANNOTATION_IMAGE:        "localhost:5000/hello@" + digest,
+				fv1.ANNOTATION_IMAGE_DIGEST: digest,
+			},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			client := newTestClient(env)
+			flags := dummy.TestFlagSet()
+			flags.Set(flagkey.PkgImage, test.image)
+			meta, _, err := CreatePackage(flags, client, "", "default", "nodejs",
+				nil, nil, nil, nil, nil, "", "", false, "")
+			require.NoError(t, err)
+			require.True(t, strings.HasPrefix(meta.Name, "hello-"), meta.Name)
+
+			pkg, err := client.FissionClientSet.CoreV1().Packages("default").Get(context.Background(), meta.Name, metav1.GetOptions{})
+			require.NoError(t, err)
+			require.Equal(t, test.expected, pkg.Annotations)
+			require.EqualValues(t, fv1.BuildStatusSucceeded, pkg.Status.BuildStatus)
+			require.Equal(t, fv1.Archive{}, pkg.Spec.Source)
+			require.Equal(t, fv1.Archive{}, pkg.Spec.Deployment)
+
+			var created []string
+			for _, action := range client.FissionClientSet.(*fake.Clientset).Actions() {
+				if action.GetVerb() != "get" && action.GetVerb() != "list" && action.GetVerb() != "watch" {
+					created = append(created, action.GetVerb()+" "+action.GetResource().Resource)
+				}
+			}
+			require.Equal(t, []string{"create packages"}, created)
+		})
+	}
+
+	t.Run("invalid reference", func(t *testing.T) {
+		for _, image := range []string{"", "Hello/World", "hello:", "hello@sha256:abc", "-hello", "hello:" + strings.Repeat("x", 129)} {
+			flags := dummy.TestFlagSet()
+			flags.Set(flagkey.PkgName, "hello-pkg")
+			flags.Set(flagkey.PkgEnvironment, "nodejs")
+			flags.Set(flagkey.PkgImage, image)
+			err := (&CreateSubCommand{}).run(flags)
+			requireErrorCode(t, err, ferror.ErrorInvalidArgument)
+		}
+	})
+
+	t.Run("conflicting flags", func(t *testing.T) {
+		code := writeTestFile(t, "hello.js", "module.exports = async function(context) {}")
+		for _, args := range []map[string]interface{}{
+			{flagkey.PkgCode: []string{code}},
+			{flagkey.PkgDeployArchive: []string{code}},
+			{flagkey.PkgSrcArchive: []string{code}},
+			{flagkey.PkgDeployArchiveID: "archive-id"},
+			{flagkey.PkgBuildCmd: []string{"build.sh"}},
+		} {
+			flags := dummy.TestFlagSet()
+			flags.Set(flagkey.PkgName, "hello-pkg")
+			flags.Set(flagkey.PkgEnvironment, "nodejs")
+			flags.Set(flagkey.PkgImage, "ghcr.io/fission/hello:v1")
+			for k, v := range args {
+				flags.Set(k, v)
+			}
+			err := (&CreateSubCommand{}).run(flags)
+			requireErrorCode(t, err, ferror.ErrorInvalidArgument)
+		}
+	})
+}
+
+func TestCreatePackageReusesUploadedArchive(t *testing.T) {
+	uploadedArchives = newArchiveCache()
+	uploads := 0
+	storage := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			uploads++
+		}
+		fmt.Fprintf(w, `{"id":"archive-%d"}`, uploads)
+	}))
+	defer storage.Close()
+	t.Setenv("FISSION_STORAGESVC_URL", storage.URL)
+
+	// a shared package source, large enough to be uploaded even when compressed
+	dir := t.TempDir()
+	content := make([]byte, fv1.ArchiveLiteralSizeLimit+1)
+	_, err := rand.New(rand.NewSource(1)).Read(content)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "main.js"), content, 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "package.json"), []byte(`{"name": "hello"}`), 0644))
+
+	client := newTestClient(&fv1.Environment{ObjectMeta: metav1.ObjectMeta{Name: "nodejs", Namespace: "default"}})
+	for _, name := range []string{"hello-a", "hello-b"} {
+		_, _, err = CreatePackage(dummy.TestFlagSet(), client, name, "default", "nodejs",
+			[]string{dir}, nil, nil, nil, nil, "", "", false, "")
+		require.NoError(t, err)
+	}
+	require.Equal(t, 1, uploads)
+
+	a, err := client.FissionClientSet.CoreV1().Packages("default").Get(context.Background(), "hello-a", metav1.GetOptions{})
+	require.NoError(t, err)
+	b, err := client.FissionClientSet.CoreV1().Packages("default").Get(context.Background(), "hello-b", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Equal(t, a.Spec.Source, b.Spec.Source)
+	require.Equal(t, fv1.ArchiveTypeUrl, b.Spec.Source.Type)
+
+	// changed sources are uploaded again
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "package.json"), []byte(`{"name": "changed"}`), 0644))
+	_, _, err = CreatePackage(dummy.TestFlagSet(), client, "hello-c", "default", "nodejs",
+		[]string{dir}, nil, nil, nil, nil, "", "", false, "")
+	require.NoError(t, err)
+	require.Equal(t, 2, uploads)
+}
+
+func TestCreatePackageCode(t *testing.T) {
+	dir := t.TempDir()
+	codes := []string{filepath.Join(dir, "a.py"), filepath.Join(dir, "b.py")}
+	for _, code := range codes {
+		require.NoError(t, os.WriteFile(code, []byte("def main():\n    return '"+filepath.Base(code)+"'\n"), 0644))
+	}
+
+	create := func(t *testing.T, codes []string) *fv1.Package {
+		t.Helper()
+		client := newTestClient(&fv1.Environment{ObjectMeta: metav1.ObjectMeta{Name: "python", Namespace: "default"}})
+		flags := dummy.TestFlagSet()
+		flags.Set(flagkey.PkgCode, codes)
+		deployArchiveFiles, noZip := CodeArchiveFiles(flags)
+		_, _, err := CreatePackage(flags, client, "hello-pkg", "default", "python",
+			nil, deployArchiveFiles, nil, nil, nil, "", "", noZip, "")
+		require.NoError(t, err)
+
+		pkg, err := client.FissionClientSet.CoreV1().Packages("default").Get(context.Background(), "hello-pkg", metav1.GetOptions{})
+		require.NoError(t, err)
+		require.EqualValues(t, fv1.BuildStatusSucceeded, pkg.Status.BuildStatus)
+		require.Equal(t, fv1.ArchiveTypeLiteral, pkg.Spec.Deployment.Type)
+		return pkg
+	}
+
+	t.Run("single file", func(t *testing.T) {
+		pkg := create(t, codes[:1])
+		content, err := os.ReadFile(codes[0])
+		require.NoError(t, err)
+		require.Equal(t, content, pkg.Spec.Deployment.Literal)
+	})
+
+	t.Run("multiple files", func(t *testing.T) {
+		pkg := create(t, codes)
+		literal := pkg.Spec.Deployment.Literal
+		r, err := zip.NewReader(bytes.NewReader(literal), int64(len(literal)))
+		require.NoError(t, err)
+		var names []string
+		for _, f := range r.File {
+			names = append(names, f.Name)
+		}
+		require.ElementsMatch(t, []string{"a.py", "b.py"}, names)
+	})
+}
+
+func TestCreatePackageStdinArchive(t *testing.T) {
+	var tarStream bytes.Buffer
+	tw := tar.NewWriter(&tarStream)
+	content := []byte("module.exports = async function(context) {}")
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: "hello.js", Mode: 0644, Size: int64(len(content))}))
+	_, err := tw.Write(content)
+	require.NoError(t, err)
+	require.NoError(t, tw.Close())
+
+	defer func(r io.Reader) { stdin = r }(stdin)
+	create := func(t *testing.T, in []byte, flags dummy.Cli, deployArchiveFiles ...string) (*fv1.Package, error) {
+		t.Helper()
+		stdin = bytes.NewReader(in)
+		client := newTestClient()
+		_, _, err := CreatePackage(flags, client, "hello-pkg", "default", "nodejs",
+			nil, deployArchiveFiles, nil, nil, nil, "", "", false, "")
+		if err != nil {
+			return nil, err
+		}
+		return client.FissionClientSet.CoreV1().Packages("default").Get(context.Background(), "hello-pkg", metav1.GetOptions{})
+	}
+
+	t.Run("tar stream", func(t *testing.T) {
+		pkg, err := create(t, tarStream.Bytes(), dummy.TestFlagSet(), StdinArchive)
+		require.NoError(t, err)
+		// the stream is stored as is, without being compressed again
+		require.Equal(t, fv1.ArchiveTypeLiteral, pkg.Spec.Deployment.Type)
+		require.Equal(t, tarStream.Bytes(), pkg.Spec.Deployment.Literal)
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		_, err := create(t, nil, dummy.TestFlagSet(), StdinArchive)
+		requireErrorCode(t, err, ferror.ErrorInvalidArgument)
+		require.Contains(t, err.Error(), "no input")
+	})
+
+	t.Run("not an archive", func(t *testing.T) {
+		_, err := create(t, content, dummy.TestFlagSet(), StdinArchive)
+		requireErrorCode(t, err, ferror.ErrorInvalidArgument)
+		require.Contains(t, err.Error(), "not a zip, tar or tar.gz archive")
+	})
+
+	t.Run("with files", func(t *testing.T) {
+		_, err := create(t, tarStream.Bytes(), dummy.TestFlagSet(), StdinArchive, writeTestFile(t, "hello.js", string(content)))
+		requireErrorCode(t, err, ferror.ErrorInvalidArgument)
+	})
+
+	t.Run("replace if changed", func(t *testing.T) {
+		flags := dummy.TestFlagSet()
+		flags.Set(flagkey.PkgReplaceIfChanged, true)
+		_, err := create(t, tarStream.Bytes(), flags, StdinArchive)
+		requireErrorCode(t, err, ferror.ErrorInvalidArgument)
+	})
+}
+
+func TestCreatePackageArchiveOut(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"main.js":  "module.exports = async function(context) {}",
+		"large.js": strings.Repeat("x", int(fv1.ArchiveLiteralSizeLimit)+1),
+	}
+	for name, content := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+
+	storage := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request to storage service: %v %v", r.Method, r.URL)
+	}))
+	defer storage.Close()
+	t.Setenv("FISSION_STORAGESVC_URL", storage.URL)
+
+	out := filepath.Join(t.TempDir(), "hello.zip")
+	client := newTestClient(&fv1.Environment{ObjectMeta: metav1.ObjectMeta{Name: "nodejs", Namespace: "default"}})
+	flags := dummy.TestFlagSet()
+	flags.Set(flagkey.PkgArchiveOut, out)
+	meta, _, err := CreatePackage(flags, client, "hello-pkg", "default", "nodejs",
+		nil, []string{filepath.Join(dir, "main.js"), filepath.Join(dir, "large.js")}, nil, nil, nil, "", "", true, "")
+	require.NoError(t, err)
+	require.Equal(t, "hello-pkg", meta.Name)
+
+	// the written archive holds the inputs
+	reader, err := zip.OpenReader(out)
+	require.NoError(t, err)
+	defer reader.Close()
+	written := make(map[string]string)
+	for _, f := range reader.File {
+		rc, err := f.Open()
+		require.NoError(t, err)
+		content, err := io.ReadAll(rc)
+		require.NoError(t, err)
+		rc.Close()
+		written[f.Name] = string(content)
+	}
+	require.Equal(t, files, written)
+
+	fakeClient := client.FissionClientSet.(*fake.Clientset)
+	for _, action := range fakeClient.Actions() {
+		require.NotEqual(t, "create", action.GetVerb(), "--archive-out must not create any resource")
+	}
+
+	t.Run("archive URL", func(t *testing.T) {
+		_, _, err := CreatePackage(flags, newTestClient(), "hello-pkg", "default", "nodejs",
+			nil, []string{"https://example.com/hello.zip"}, nil, nil, nil, "", "", true, "")
+		requireErrorCode(t, err, ferror.ErrorInvalidArgument)
+	})
+}
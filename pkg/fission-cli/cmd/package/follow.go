@@ -0,0 +1,213 @@
+/*
+Copyright 2024 The Fission Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package _package
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/watch"
+
+	fv1 "github.com/fission/fission/pkg/apis/core/v1"
+	ferror "github.com/fission/fission/pkg/error"
+	"github.com/fission/fission/pkg/fission-cli/cmd"
+	flagkey "github.com/fission/fission/pkg/fission-cli/flag/key"
+)
+
+// buildLogTailLines is the number of build log lines returned by WaitForPackageBuild.
+const buildLogTailLines = 20
+
+// WaitForPackageBuild waits for the package name to exist and its build to be done,
+// and returns the terminal build status with the last lines of the build log. A
+// failed build is no error, callers check the returned status. It returns an
+// ErrorRequestTimeout error if the build is not done within timeout, unless the
+// timeout is zero, and an ErrorNotFound error if the package is deleted meanwhile.
+func WaitForPackageBuild(ctx context.Context, client cmd.Client, name string, namespace string, timeout time.Duration) (fv1.BuildStatus, string, error) {
+	pkg, timedOut, err := waitForBuild(ctx, client, namespace, name, timeout, nil)
+	if timedOut {
+		return "", "", packageError(ferror.ErrorRequestTimeout, err, "build of package '%v' did not finish within %v", name, timeout)
+	}
+	if err != nil {
+		return "", "", err
+	}
+	return pkg.Status.BuildStatus, buildLogTail(pkg.Status.BuildLog, buildLogTailLines), nil
+}
+
+// followBuild waits for the package name to exist, e.g. once a saved spec is applied
+// by another process, and writes its build status changes and build log to w until
+// the build is done. It returns an ErrorRequestTimeout error if the build is not
+// done within timeout, and an error if the build failed.
+func followBuild(ctx context.Context, client cmd.Client, w io.Writer, namespace string, name string, timeout time.Duration) error {
+	pkg, timedOut, err := waitForBuild(ctx, client, namespace, name, timeout, func(pkg *fv1.Package) {
+		if pkg == nil {
+			fmt.Fprintf(w, "Waiting for package '%v' to be created, e.g. with 'fission spec apply'\n", name)
+			return
+		}
+		fmt.Fprintf(w, "Package '%v' build status: %v\n", pkg.Name, pkg.Status.BuildStatus)
+	})
+	if timedOut {
+		return packageError(ferror.ErrorRequestTimeout, err, "build of package '%v' did not finish within --%v %v",
+			name, flagkey.PkgFollowTimeout, timeout)
+	}
+	if err != nil {
+		return err
+	}
+
+	if buildLog := unescapeBuildLog(pkg.Status.BuildLog); len(buildLog) > 0 {
+		fmt.Fprintf(w, "Build Logs:\n%v\n", buildLog)
+	}
+	if pkg.Status.BuildStatus == fv1.BuildStatusFailed {
+		return ferror.MakeError(ferror.ErrorInternal, fmt.Sprintf("build of package '%v' failed", pkg.Name))
+	}
+	return nil
+}
+
+// waitForBuild returns the package name once its build is done, or whether it timed
+// out. Progress, if not nil, is called with the package on each build status change,
+// and with nil if the package does not exist yet.
+func waitForBuild(ctx context.Context, client cmd.Client, namespace string, name string, timeout time.Duration,
+	progress func(pkg *fv1.Package)) (*fv1.Package, bool, error) {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	if progress == nil {
+		progress = func(*fv1.Package) {}
+	}
+
+	pkg, err := watchBuild(ctx, client, namespace, name, progress)
+	if err != nil {
+		return nil, errors.Is(ctx.Err(), context.DeadlineExceeded), err
+	}
+	return pkg, false, nil
+}
+
+func watchBuild(ctx context.Context, client cmd.Client, namespace string, name string, progress func(pkg *fv1.Package)) (*fv1.Package, error) {
+	packages := client.FissionClientSet.CoreV1().Packages(namespace)
+	selector := fields.OneTermEqualSelector("metadata.name", name).String()
+	var status fv1.BuildStatus
+
+	for {
+		// list before watching, so that no change between both is missed
+		list, err := packages.List(ctx, metav1.ListOptions{FieldSelector: selector})
+		if err != nil {
+			return nil, errors.Wrapf(err, "error getting package '%v'", name)
+		}
+		found := false
+		for i := range list.Items {
+			if list.Items[i].Name != name {
+				continue
+			}
+			found = true
+			if buildProgress(&list.Items[i], &status, progress) {
+				return &list.Items[i], nil
+			}
+		}
+		if !found && len(status) == 0 {
+			progress(nil)
+		}
+
+		watcher, err := packages.Watch(ctx, metav1.ListOptions{
+			FieldSelector:   selector,
+			ResourceVersion: list.ResourceVersion,
+		})
+		if err != nil {
+			return nil, errors.Wrapf(err, "error watching package '%v'", name)
+		}
+		pkg, err := watchBuildEvents(ctx, watcher, name, &status, progress)
+		watcher.Stop()
+		if pkg != nil || err != nil {
+			return pkg, err
+		}
+		// the watch was closed by the server, start over
+	}
+}
+
+// watchBuildEvents handles the events of watcher until the build of the package is
+// done, and returns the package. It returns nil without error if the watch is
+// closed before.
+func watchBuildEvents(ctx context.Context, watcher watch.Interface, name string, status *fv1.BuildStatus,
+	progress func(pkg *fv1.Package)) (*fv1.Package, error) {
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case event, ok := <-watcher.ResultChan():
+			if !ok {
+				return nil, nil
+			}
+			switch event.Type {
+			case watch.Added, watch.Modified:
+				pkg, ok := event.Object.(*fv1.Package)
+				if !ok || pkg.Name != name {
+					continue
+				}
+				if buildProgress(pkg, status, progress) {
+					return pkg, nil
+				}
+			case watch.Deleted:
+				pkg, ok := event.Object.(*fv1.Package)
+				if ok && pkg.Name == name {
+					return nil, ferror.MakeError(ferror.ErrorNotFound, fmt.Sprintf("package '%v' was deleted during the build", name))
+				}
+			case watch.Error:
+				return nil, errors.Errorf("error watching package '%v': %v", name, event.Object)
+			}
+		}
+	}
+}
+
+// buildProgress calls progress with pkg if its build status changed from status,
+// and returns whether the build is done.
+func buildProgress(pkg *fv1.Package, status *fv1.BuildStatus, progress func(pkg *fv1.Package)) bool {
+	if pkg.Status.BuildStatus == *status {
+		return false
+	}
+	*status = pkg.Status.BuildStatus
+	progress(pkg)
+
+	switch pkg.Status.BuildStatus {
+	case fv1.BuildStatusSucceeded, fv1.BuildStatusFailed, fv1.BuildStatusNone:
+		return true
+	}
+	return false
+}
+
+// unescapeBuildLog replaces the escaped line breaks of a build log.
+func unescapeBuildLog(buildLog string) string {
+	return strings.TrimRight(strings.ReplaceAll(buildLog, `\n`, "\n"), "\n")
+}
+
+// buildLogTail returns the last lines of a build log.
+func buildLogTail(buildLog string, lines int) string {
+	buildLog = unescapeBuildLog(buildLog)
+	if len(buildLog) == 0 {
+		return ""
+	}
+	all := strings.Split(buildLog, "\n")
+	if len(all) > lines {
+		all = all[len(all)-lines:]
+	}
+	return strings.Join(all, "\n")
+}
@@ -19,17 +19,27 @@ package _package
 import (
 	"context"
 	"fmt"
+	"io"
+	"io/fs"
 	"net/http"
 	"os"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/dchest/uniuri"
 	"github.com/hashicorp/go-multierror"
 	"github.com/pkg/errors"
+	ignore "github.com/sabhiram/go-gitignore"
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
 
 	fv1 "github.com/fission/fission/pkg/apis/core/v1"
+	ferror "github.com/fission/fission/pkg/error"
 	"github.com/fission/fission/pkg/fission-cli/cliwrapper/cli"
 	"github.com/fission/fission/pkg/fission-cli/cmd"
 	pkgutil "github.com/fission/fission/pkg/fission-cli/cmd/package/util"
@@ -42,6 +52,110 @@ import (
 	"github.com/fission/fission/pkg/utils/uuid"
 )
 
+// FissionIgnoreFile lists the gitignore style patterns of paths
+// which are left out when archiving the directory containing it.
+const FissionIgnoreFile = ".fissionignore"
+
+// StdinArchive is the archive path given with --src-archive or --deploy-archive
+// to read the archive from stdin.
+const StdinArchive = "-"
+
+// stdin is read by CreateArchive for StdinArchive, replaced by tests.
+var stdin io.Reader = os.Stdin
+
+// uploadedArchives holds the archives uploaded by this CLI invocation, keyed by
+// archive backend and SHA256 checksum, so that identical archives, e.g. the shared
+// source of several packages, are uploaded once.
+var uploadedArchives = newArchiveCache()
+
+type archiveCache struct {
+	mu       sync.Mutex
+	archives map[string]fv1.Archive
+}
+
+func newArchiveCache() *archiveCache {
+	return &archiveCache{archives: make(map[string]fv1.Archive)}
+}
+
+func (c *archiveCache) get(backend string, checksum fv1.Checksum) (*fv1.Archive, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	archive, ok := c.archives[backend+"/"+checksum.Sum]
+	if !ok {
+		return nil, false
+	}
+	return archive.DeepCopy(), true
+}
+
+func (c *archiveCache) set(backend string, checksum fv1.Checksum, archive *fv1.Archive) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.archives[backend+"/"+checksum.Sum] = *archive.DeepCopy()
+}
+
+// archiveAuthHeader returns the headers sent when downloading remote archives,
+// taken from --archive-auth-header and --archive-basic-auth or their environment
+// variables. Error messages never contain the credentials themselves.
+func archiveAuthHeader(input cli.Input) (http.Header, error) {
+	header := make(http.Header)
+
+	authHeader := input.String(flagkey.PkgArchiveAuthHeader)
+	if len(authHeader) == 0 {
+		authHeader = os.Getenv(util.ENV_FISSION_ARCHIVE_AUTH_HEADER)
+	}
+	if len(authHeader) > 0 {
+		name, value, ok := strings.Cut(authHeader, ":")
+		name = strings.TrimSpace(name)
+		if !ok || len(name) == 0 {
+			return nil, ferror.MakeError(ferror.ErrorInvalidArgument,
+				fmt.Sprintf("--%v must be of the form 'Name: value'", flagkey.PkgArchiveAuthHeader))
+		}
+		header.Set(name, strings.TrimSpace(value))
+	}
+
+	basicAuth := input.String(flagkey.PkgArchiveBasicAuth)
+	if len(basicAuth) == 0 {
+		basicAuth = os.Getenv(util.ENV_FISSION_ARCHIVE_BASIC_AUTH)
+	}
+	if len(basicAuth) > 0 {
+		user, password, ok := strings.Cut(basicAuth, ":")
+		if !ok || len(user) == 0 {
+			return nil, ferror.MakeError(ferror.ErrorInvalidArgument,
+				fmt.Sprintf("--%v must be of the form 'user:password'", flagkey.PkgArchiveBasicAuth))
+		}
+		if len(header.Get("Authorization")) > 0 {
+			return nil, ferror.MakeError(ferror.ErrorInvalidArgument,
+				fmt.Sprintf("--%v and an Authorization header in --%v cannot be used together",
+					flagkey.PkgArchiveBasicAuth, flagkey.PkgArchiveAuthHeader))
+		}
+		req := &http.Request{Header: header}
+		req.SetBasicAuth(user, password)
+	}
+
+	return header, nil
+}
+
+// downloadArchive downloads url to localPath, sending header with the request.
+// A non-200 response is returned as an error with the matching error code.
+func downloadArchive(ctx context.Context, url string, localPath string, header http.Header) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header = header.Clone()
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return ferror.MakeErrorFromHTTP(resp)
+	}
+
+	return pkgutil.WriteArchiveToFile(localPath, resp.Body)
+}
+
 // CreateArchive returns a fv1.Archive made from an archive .  If specFile, then
 // create an archive upload spec in the specs directory; otherwise
 // upload the archive using client.  noZip avoids zipping the
@@ -54,9 +168,30 @@ func CreateArchive(client cmd.Client, input cli.Input, includeFiles []string, no
 	if len(specFile) > 0 {
 		rootDir, err = filepath.Abs(specDir + "/..")
 		if err != nil {
-			return nil, errors.Wrapf(err, "error getting root directory of spec directory")
+			return nil, packageError(ferror.ErrorInternal, err, "error getting root directory of spec directory")
 		}
 	}
+	archiveOpts, err := getArchiveOptions(input)
+	if err != nil {
+		return nil, err
+	}
+
+	if slices.Contains(includeFiles, StdinArchive) {
+		if len(includeFiles) > 1 {
+			return nil, ferror.MakeError(ferror.ErrorInvalidArgument, "unable to create an archive that contains both stdin and files")
+		}
+		if len(specFile) > 0 || input.Bool(flagkey.SpecDry) {
+			return nil, ferror.MakeError(ferror.ErrorInvalidArgument, "an archive read from stdin cannot be saved in specs")
+		}
+		archivePath, err := readStdinArchive(stdin)
+		if err != nil {
+			return nil, err
+		}
+		// the archive is used as is, it is neither compressed again nor wrapped in a zip file
+		includeFiles = []string{archivePath}
+		noZip = true
+	}
+
 	errs := utils.MultiErrorWithFormat()
 	fileURL := ""
 
@@ -66,7 +201,7 @@ func CreateArchive(client cmd.Client, input cli.Input, includeFiles []string, no
 		if utils.IsURL(path) {
 			if len(includeFiles) > 1 {
 				// It's intentional to disallow the user to provide file and URL at the same time.
-				return nil, errors.New("unable to create an archive that contains both file and URL")
+				return nil, ferror.MakeError(ferror.ErrorInvalidArgument, "unable to create an archive that contains both file and URL")
 			}
 			fileURL = path
 			break
@@ -97,10 +232,14 @@ func CreateArchive(client cmd.Client, input cli.Input, includeFiles []string, no
 	}
 
 	if errs.ErrorOrNil() != nil {
-		return nil, errs.ErrorOrNil()
+		return nil, ferror.MakeError(ferror.ErrorInvalidArgument, errs.Error())
 	}
 
 	if len(fileURL) > 0 {
+		if len(input.String(flagkey.PkgArchiveOut)) > 0 {
+			return nil, ferror.MakeError(ferror.ErrorInvalidArgument,
+				fmt.Sprintf("--%v cannot be used with an archive URL", flagkey.PkgArchiveOut))
+		}
 		if insecure {
 			return &fv1.Archive{
 				Type: fv1.ArchiveTypeUrl,
@@ -120,19 +259,28 @@ func CreateArchive(client cmd.Client, input cli.Input, includeFiles []string, no
 				flagkey.PkgSrcChecksum, flagkey.PkgDeployChecksum, flagkey.PkgInsecure))
 
 			tmpDir, err := utils.GetTempDir()
+			if err != nil {
+				return nil, packageError(ferror.ErrorInternal, err, "error creating temporary directory")
+			}
+
+			header, err := archiveAuthHeader(input)
 			if err != nil {
 				return nil, err
 			}
 
 			file := filepath.Join(tmpDir, uuid.NewString())
-			err = utils.DownloadUrl(input.Context(), http.DefaultClient, fileURL, file)
+			err = downloadArchive(input.Context(), fileURL, file, header)
 			if err != nil {
-				return nil, errors.Wrap(err, "error downloading file from the given URL")
+				code := ferror.ErrorInternal
+				if fe, ok := errors.Cause(err).(ferror.Error); ok {
+					code = int(fe.Code)
+				}
+				return nil, packageError(code, err, "error downloading file from the given URL")
 			}
 
 			csum, err = utils.GetFileChecksum(file)
 			if err != nil {
-				return nil, errors.Wrap(err, "error generating file SHA256 checksum")
+				return nil, packageError(ferror.ErrorInternal, err, "error generating file SHA256 checksum")
 			}
 		}
 
@@ -143,6 +291,34 @@ func CreateArchive(client cmd.Client, input cli.Input, includeFiles []string, no
 		}, nil
 	}
 
+	if input.Bool(flagkey.PkgArchiveDryRun) {
+		archivePath, err := makeArchiveFile("", includeFiles, noZip, archiveOpts)
+		if err != nil {
+			return nil, err
+		}
+		if len(checksum) > 0 {
+			err = verifyArchiveChecksum(archivePath, checksum)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return archiveDryRun(os.Stdout, archivePath, includeFiles)
+	}
+
+	if out := input.String(flagkey.PkgArchiveOut); len(out) > 0 {
+		archivePath, err := makeArchiveFile("", includeFiles, noZip, archiveOpts)
+		if err != nil {
+			return nil, err
+		}
+		if len(checksum) > 0 {
+			err = verifyArchiveChecksum(archivePath, checksum)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return writeArchiveOut(os.Stdout, archivePath, out)
+	}
+
 	if input.Bool(flagkey.SpecSave) || input.Bool(flagkey.SpecDry) {
 		// create an ArchiveUploadSpec and reference it from the archive
 		aus := &spectypes.ArchiveUploadSpec{
@@ -153,14 +329,14 @@ func CreateArchive(client cmd.Client, input cli.Input, includeFiles []string, no
 		if input.Bool(flagkey.SpecDry) {
 			err := spec.SpecDry(*aus)
 			if err != nil {
-				return nil, err
+				return nil, packageError(ferror.ErrorInternal, err, "error printing archive spec")
 			}
 		} else if input.Bool(flagkey.SpecSave) {
 			// check if this AUS exists in the specs; if so, don't create a new one
 			specIgnore := util.GetSpecIgnore(input)
 			fr, err := spec.ReadSpecs(specDir, specIgnore, false)
 			if err != nil {
-				return nil, errors.Wrap(err, "error reading specs")
+				return nil, packageError(ferror.ErrorInternal, err, "error reading specs")
 			}
 
 			obj := fr.SpecExists(aus, true, true)
@@ -172,7 +348,7 @@ func CreateArchive(client cmd.Client, input cli.Input, includeFiles []string, no
 				// save the uploadspec
 				err := spec.SpecSave(*aus, specFile, false)
 				if err != nil {
-					return nil, errors.Wrap(err, "error saving archive spec")
+					return nil, packageError(ferror.ErrorInternal, err, "error saving archive spec")
 				}
 			}
 		}
@@ -185,41 +361,367 @@ func CreateArchive(client cmd.Client, input cli.Input, includeFiles []string, no
 		return &archive, nil
 	}
 
-	archivePath, err := makeArchiveFile("", includeFiles, noZip)
+	backend := input.String(flagkey.PkgArchiveBackend)
+	uploader, err := pkgutil.GetArchiveUploader(client, backend)
+	if err != nil {
+		return nil, packageError(ferror.ErrorInvalidArgument, err, "error getting archive backend")
+	}
+	maxSize, err := maxArchiveSize(input)
+	if err != nil {
+		return nil, err
+	}
+
+	archivePath, err := makeArchiveFile("", includeFiles, noZip, archiveOpts)
+	if err != nil {
+		return nil, err
+	}
+	err = checkArchiveSize(archivePath, maxSize)
 	if err != nil {
 		return nil, err
 	}
 
-	return pkgutil.UploadArchiveFile(input.Context(), client, archivePath)
+	if len(checksum) > 0 {
+		err = verifyArchiveChecksum(archivePath, checksum)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	csum, err := utils.GetFileChecksum(archivePath)
+	if err != nil {
+		return nil, packageError(ferror.ErrorInternal, err, "error generating file SHA256 checksum")
+	}
+	if archive, ok := uploadedArchives.get(backend, *csum); ok {
+		return archive, nil
+	}
+
+	archive, err := pkgutil.UploadArchive(input.Context(), uploader, archivePath)
+	if err != nil {
+		return nil, packageError(ferror.ErrorInternal, err, "error uploading archive")
+	}
+	uploadedArchives.set(backend, *csum, archive)
+	return archive, nil
 }
 
-// makeArchiveFile creates a zip file from the given list of input files,
-// unless that list has only one item and that item is a zip file.
+// readStdinArchive copies the archive read from r, a zip, tar or gzip compressed
+// tarball, to a temporary file and returns its path.
+func readStdinArchive(r io.Reader) (string, error) {
+	tmpDir, err := utils.GetTempDir()
+	if err != nil {
+		return "", packageError(ferror.ErrorInternal, err, "error creating temporary directory")
+	}
+	archivePath := filepath.Join(tmpDir, "stdin")
+	f, err := os.Create(archivePath)
+	if err != nil {
+		return "", packageError(ferror.ErrorInternal, err, "error creating archive file")
+	}
+	n, err := io.Copy(f, r)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", packageError(ferror.ErrorInternal, err, "error reading archive from stdin")
+	}
+	if n == 0 {
+		return "", ferror.MakeError(ferror.ErrorInvalidArgument, "error reading archive from stdin: no input")
+	}
+
+	for _, isArchive := range []func(string) (bool, error){utils.IsZip, utils.IsTarGz, utils.IsTar} {
+		if match, _ := isArchive(archivePath); match {
+			return archivePath, nil
+		}
+	}
+	return "", ferror.MakeError(ferror.ErrorInvalidArgument, "the input read from stdin is not a zip, tar or tar.gz archive")
+}
+
+// writeArchiveOut copies the archive at archivePath to out, reports its checksum
+// to w and returns the archive without uploading it.
+func writeArchiveOut(w io.Writer, archivePath string, out string) (*fv1.Archive, error) {
+	csum, err := utils.GetFileChecksum(archivePath)
+	if err != nil {
+		return nil, packageError(ferror.ErrorInternal, err, "error generating file SHA256 checksum")
+	}
+
+	src, err := os.Open(archivePath)
+	if err != nil {
+		return nil, packageError(ferror.ErrorInternal, err, "error opening archive")
+	}
+	defer src.Close()
+	dst, err := os.Create(out)
+	if err != nil {
+		return nil, packageError(ferror.ErrorInvalidArgument, err, "error creating --%v file", flagkey.PkgArchiveOut)
+	}
+	_, err = io.Copy(dst, src)
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, packageError(ferror.ErrorInternal, err, "error writing --%v file", flagkey.PkgArchiveOut)
+	}
+
+	fmt.Fprintf(w, "Archive written to '%v', SHA256 checksum: %v\n", out, csum.Sum)
+	return &fv1.Archive{Checksum: *csum}, nil
+}
+
+// archiveDryRun writes the files, size and checksum of the archive at archivePath
+// to w and returns the archive without uploading it.
+func archiveDryRun(w io.Writer, archivePath string, includeFiles []string) (*fv1.Archive, error) {
+	files, err := archiveContents(includeFiles)
+	if err != nil {
+		return nil, packageError(ferror.ErrorInvalidArgument, err, "error listing archive files")
+	}
+	size, err := utils.FileSize(archivePath)
+	if err != nil {
+		return nil, packageError(ferror.ErrorInternal, err, "error getting archive size")
+	}
+	csum, err := utils.GetFileChecksum(archivePath)
+	if err != nil {
+		return nil, packageError(ferror.ErrorInternal, err, "error generating file SHA256 checksum")
+	}
+
+	fmt.Fprintf(w, "Archive '%v' (dry run, not uploaded):\n", filepath.Base(archivePath))
+	for _, file := range files {
+		fmt.Fprintf(w, "  %v\n", file)
+	}
+	fmt.Fprintf(w, "Files: %v, size: %v bytes, SHA256 checksum: %v\n", len(files), size, csum.Sum)
+
+	return &fv1.Archive{Checksum: *csum}, nil
+}
+
+// archiveContents returns the regular files matched by includeFiles,
+// walking matched directories.
+func archiveContents(includeFiles []string) ([]string, error) {
+	globs, err := utils.FindAllGlobs(includeFiles...)
+	if err != nil {
+		return nil, err
+	}
+	files := make([]string, 0, len(globs))
+	for _, glob := range globs {
+		err := filepath.WalkDir(glob, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.Type().IsRegular() {
+				files = append(files, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// archiveFromID returns the archive referencing the archive id in the fission storage
+// service, with the SHA256 checksum if given, without uploading anything.
+func archiveFromID(input cli.Input, client cmd.Client, id string, checksum string) (*fv1.Archive, error) {
+	var csum fv1.Checksum
+	if len(checksum) > 0 {
+		csum = fv1.Checksum{
+			Type: fv1.ChecksumTypeSHA256,
+			Sum:  strings.ToLower(checksum),
+		}
+	}
+	archive, err := pkgutil.ArchiveFromID(input.Context(), client, id, csum)
+	if err != nil {
+		code := ferror.ErrorInternal
+		if fe, ok := errors.Cause(err).(ferror.Error); ok {
+			code = int(fe.Code)
+		}
+		return nil, packageError(code, err, "error getting archive '%v'", id)
+	}
+	return archive, nil
+}
+
+// verifyArchiveChecksum checks that the SHA256 checksum of the archive at
+// archivePath matches the checksum given by the user.
+func verifyArchiveChecksum(archivePath string, checksum string) error {
+	csum, err := utils.GetFileChecksum(archivePath)
+	if err != nil {
+		return packageError(ferror.ErrorInternal, err, "error generating file SHA256 checksum")
+	}
+	if !strings.EqualFold(csum.Sum, checksum) {
+		return ferror.MakeError(ferror.ErrorInvalidArgument,
+			fmt.Sprintf("checksum mismatch of archive '%v': expected %v, got %v", filepath.Base(archivePath), checksum, csum.Sum))
+	}
+	return nil
+}
+
+// maxArchiveSize returns the maximum size in bytes of archives to upload given with
+// --max-archive-size, fv1.ArchiveUploadSizeLimit if not given, or 0 for no limit.
+func maxArchiveSize(input cli.Input) (int64, error) {
+	value := input.String(flagkey.PkgMaxArchiveSize)
+	if len(value) == 0 {
+		return fv1.ArchiveUploadSizeLimit, nil
+	}
+	size, err := resource.ParseQuantity(value)
+	if err != nil || size.Sign() < 0 {
+		return 0, ferror.MakeError(ferror.ErrorInvalidArgument,
+			fmt.Sprintf("invalid --%v '%v', must be a non-negative size, e.g. 512Mi", flagkey.PkgMaxArchiveSize, value))
+	}
+	return size.Value(), nil
+}
+
+// checkArchiveSize fails with an ErrorInvalidArgument error if the archive at archivePath
+// is larger than maxSize bytes, so that it is not uploaded just to be rejected.
+func checkArchiveSize(archivePath string, maxSize int64) error {
+	if maxSize == 0 {
+		return nil
+	}
+	size, err := utils.FileSize(archivePath)
+	if err != nil {
+		return packageError(ferror.ErrorInternal, err, "error getting archive size")
+	}
+	if size > maxSize {
+		return ferror.MakeError(ferror.ErrorInvalidArgument,
+			fmt.Sprintf("archive '%v' is %v bytes, larger than the maximum archive size of %v bytes, see --%v",
+				filepath.Base(archivePath), size, maxSize, flagkey.PkgMaxArchiveSize))
+	}
+	return nil
+}
+
+// getArchiveOptions returns the archive options selected with --archive-format,
+// --preserve-mode, --follow-symlinks and --compression-level. Archives default
+// to zip with file modes preserved, symlinks archived as links and the default
+// compression level.
+func getArchiveOptions(input cli.Input) (utils.ArchiveOptions, error) {
+	opts := utils.ArchiveOptions{
+		Format:         utils.ArchiveFormat(input.String(flagkey.PkgArchiveFormat)),
+		PreserveMode:   !input.IsSet(flagkey.PkgPreserveMode) || input.Bool(flagkey.PkgPreserveMode),
+		FollowSymlinks: input.Bool(flagkey.PkgFollowSymlinks),
+		IgnoreFile:     FissionIgnoreFile,
+	}
+	switch opts.Format {
+	case "":
+		opts.Format = utils.ArchiveFormatZip
+	case utils.ArchiveFormatZip, utils.ArchiveFormatTarGz:
+	default:
+		return opts, ferror.MakeError(ferror.ErrorInvalidArgument, fmt.Sprintf("invalid --%v '%v', must be one of: %v, %v",
+			flagkey.PkgArchiveFormat, opts.Format, utils.ArchiveFormatZip, utils.ArchiveFormatTarGz))
+	}
+
+	if input.IsSet(flagkey.PkgCompressionLvl) {
+		level, err := parseCompressionLevel(input.String(flagkey.PkgCompressionLvl))
+		if err != nil {
+			return opts, err
+		}
+		opts.CompressionLevel = &level
+	}
+	return opts, nil
+}
+
+func parseCompressionLevel(level string) (int, error) {
+	switch level {
+	case "store":
+		return 0, nil
+	case "fast":
+		return 1, nil
+	case "best":
+		return 9, nil
+	}
+	l, err := strconv.Atoi(level)
+	if err != nil || l < 0 || l > 9 {
+		return 0, ferror.MakeError(ferror.ErrorInvalidArgument, fmt.Sprintf("invalid --%v '%v', must be 0-9 or one of: store, fast, best",
+			flagkey.PkgCompressionLvl, level))
+	}
+	return l, nil
+}
+
+// readIncludeFile returns the files listed in the --include-from manifest. Each
+// line is a path or glob relative to the manifest directory; empty lines and
+// lines starting with '#' are skipped. Lines starting with '!' are gitignore
+// style patterns removing matching paths from the list.
+func readIncludeFile(manifest string) ([]string, error) {
+	data, err := os.ReadFile(manifest)
+	if err != nil {
+		return nil, packageError(ferror.ErrorInvalidArgument, err, "error reading --%v file", flagkey.PkgIncludeFrom)
+	}
+
+	baseDir := filepath.Dir(manifest)
+	var includes, excludes []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "!") {
+			excludes = append(excludes, strings.TrimPrefix(line, "!"))
+			continue
+		}
+		if !filepath.IsAbs(line) {
+			line = filepath.Join(baseDir, line)
+		}
+		includes = append(includes, line)
+	}
+
+	files, err := utils.FindAllGlobs(includes...)
+	if err != nil {
+		return nil, packageError(ferror.ErrorInvalidArgument, err, "error finding files listed in '%v'", manifest)
+	}
+
+	absBaseDir, err := filepath.Abs(baseDir)
+	if err != nil {
+		return nil, packageError(ferror.ErrorInternal, err, "error getting absolute path of '%v'", baseDir)
+	}
+	ignoreParser := ignore.CompileIgnoreLines(excludes...)
+
+	result := make([]string, 0, len(files))
+	for _, file := range files {
+		rel, err := filepath.Rel(absBaseDir, file)
+		if err != nil {
+			return nil, packageError(ferror.ErrorInternal, err, "error getting relative path of '%v'", file)
+		}
+		if ignoreParser.MatchesPath(rel) {
+			continue
+		}
+		result = append(result, file)
+	}
+
+	if len(result) == 0 {
+		return nil, ferror.MakeError(ferror.ErrorInvalidArgument, fmt.Sprintf("no files found in --%v file '%v'", flagkey.PkgIncludeFrom, manifest))
+	}
+	return result, nil
+}
+
+// makeArchiveFile creates an archive from the given list of input files,
+// unless that list has only one item and that item is already an archive
+// of the requested format.
 //
 // If the inputs have only one file and noZip is true, the file is
 // returned as-is with no zipping.  (This is used for compatibility
 // with v1 envs.)  noZip is IGNORED if there is more than one input
 // file.
-func makeArchiveFile(archiveNameHint string, archiveInput []string, noZip bool) (string, error) {
+func makeArchiveFile(archiveNameHint string, archiveInput []string, noZip bool, opts utils.ArchiveOptions) (string, error) {
+
+	isArchive := utils.IsZip
+	if opts.Format == utils.ArchiveFormatTarGz {
+		isArchive = utils.IsTarGz
+	}
 
 	// Unique name for the archive
-	archiveFileName := archiveName(archiveNameHint, archiveInput) + ".zip"
+	archiveFileName := archiveName(archiveNameHint, archiveInput) + opts.Format.Extension()
 
 	// Get files from inputs as number of files decide next steps
 	files, err := utils.FindAllGlobs(archiveInput...)
 	if err != nil {
-		return "", errors.Wrap(err, "error finding all globs")
+		return "", packageError(ferror.ErrorInvalidArgument, err, "error finding all globs")
 	}
 
 	// We have one file; if it's a zip file, no need to archive it
 	if len(files) == 1 {
 		// make sure it exists
-		if _, err := os.Stat(files[0]); err != nil {
-			return "", errors.Wrapf(err, "open input file %v", files[0])
+		info, err := os.Stat(files[0])
+		if err != nil {
+			return "", packageError(ferror.ErrorInvalidArgument, err, "open input file %v", files[0])
+		}
+		if info.IsDir() && noZip {
+			return "", ferror.MakeError(ferror.ErrorInvalidArgument,
+				fmt.Sprintf("'%v' is a directory, which can only be used in a zipped archive", files[0]))
 		}
 
-		// if it's an existing zip file OR we're not supposed to zip it, don't do anything
-		if match, _ := utils.IsZip(files[0]); match || noZip {
+		// if it's an existing archive OR we're not supposed to zip it, don't do anything
+		if match, _ := isArchive(files[0]); match || noZip {
 			return files[0], nil
 		}
 	}
@@ -227,17 +729,35 @@ func makeArchiveFile(archiveNameHint string, archiveInput []string, noZip bool)
 	// For anything else, create a new archive
 	tmpDir, err := utils.GetTempDir()
 	if err != nil {
-		return "", errors.Wrap(err, "error create temporary archive directory")
+		return "", packageError(ferror.ErrorInternal, err, "error create temporary archive directory")
 	}
 
-	archivePath, err := utils.MakeZipArchive(filepath.Join(tmpDir, archiveFileName), archiveInput...)
+	archivePath, err := utils.MakeArchive(filepath.Join(tmpDir, archiveFileName), opts, archiveInput...)
 	if err != nil {
-		return "", errors.Wrap(err, "create archive file")
+		return "", packageError(ferror.ErrorInternal, err, "create archive file")
 	}
 
 	return archivePath, nil
 }
 
+// CodeNoZip reports whether the --code path is used as is instead of being
+// zipped. Single files are used as is, directories are zipped and their
+// FissionIgnoreFile patterns applied.
+func CodeNoZip(code string) bool {
+	if utils.IsURL(code) {
+		return true
+	}
+	info, err := os.Stat(code)
+	return err != nil || !info.IsDir()
+}
+
+// CodeArchiveFiles returns the paths of the --code flags and whether they are
+// used as is, see CodeNoZip. Multiple paths are always zipped together.
+func CodeArchiveFiles(input cli.Input) ([]string, bool) {
+	codes := input.StringSlice(flagkey.PkgCode)
+	return codes, len(codes) == 1 && CodeNoZip(codes[0])
+}
+
 // Name an archive
 func archiveName(givenNameHint string, includedFiles []string) string {
 	if len(givenNameHint) > 0 {
@@ -249,6 +769,122 @@ func archiveName(givenNameHint string, includedFiles []string) string {
 	return fmt.Sprintf("%v-%v", util.KubifyName(includedFiles[0]), uniuri.NewLen(4))
 }
 
+// buildEnv returns the build environment variables read from --build-env-from-file
+// and --build-env, where variables given with --build-env take precedence.
+func buildEnv(input cli.Input) ([]fv1.BuildEnvVar, error) {
+	var lines []string
+	if file := input.String(flagkey.PkgBuildEnvFromFile); len(file) > 0 {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, packageError(ferror.ErrorInvalidArgument, err, "error reading --%v file", flagkey.PkgBuildEnvFromFile)
+		}
+		for _, line := range strings.Split(string(data), "\n") {
+			line = strings.TrimSpace(line)
+			if len(line) == 0 || strings.HasPrefix(line, "#") {
+				continue
+			}
+			lines = append(lines, line)
+		}
+	}
+	lines = append(lines, input.StringSlice(flagkey.PkgBuildEnv)...)
+
+	var env []fv1.BuildEnvVar
+	index := make(map[string]int)
+	for _, line := range lines {
+		name, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, ferror.MakeError(ferror.ErrorInvalidArgument,
+				fmt.Sprintf("build environment variable '%v' must be of the form KEY=VALUE", name))
+		}
+		if errs := validation.IsEnvVarName(name); len(errs) > 0 {
+			return nil, ferror.MakeError(ferror.ErrorInvalidArgument,
+				fmt.Sprintf("invalid build environment variable name '%v': %v", name, strings.Join(errs, "; ")))
+		}
+		if i, ok := index[name]; ok {
+			env[i].Value = value
+			continue
+		}
+		index[name] = len(env)
+		env = append(env, fv1.BuildEnvVar{Name: name, Value: value})
+	}
+	return env, nil
+}
+
+// buildResources returns the build resources given with --build-cpu and --build-memory,
+// or nil if neither is given.
+func buildResources(input cli.Input) (*apiv1.ResourceRequirements, error) {
+	var resources *apiv1.ResourceRequirements
+	for _, r := range []struct {
+		flag string
+		name apiv1.ResourceName
+	}{
+		{flagkey.PkgBuildCPU, apiv1.ResourceCPU},
+		{flagkey.PkgBuildMemory, apiv1.ResourceMemory},
+	} {
+		value := input.String(r.flag)
+		if len(value) == 0 {
+			continue
+		}
+		request, limit, err := parseBuildQuantities(r.flag, value)
+		if err != nil {
+			return nil, err
+		}
+		if resources == nil {
+			resources = &apiv1.ResourceRequirements{
+				Requests: apiv1.ResourceList{},
+				Limits:   apiv1.ResourceList{},
+			}
+		}
+		resources.Requests[r.name] = request
+		resources.Limits[r.name] = limit
+	}
+	return resources, nil
+}
+
+// parseBuildQuantities parses the value of a build resource flag, either a single
+// quantity used as request and limit, or REQUEST:LIMIT.
+func parseBuildQuantities(flag, value string) (request resource.Quantity, limit resource.Quantity, err error) {
+	requestValue, limitValue, ok := strings.Cut(value, ":")
+	if !ok {
+		limitValue = requestValue
+	}
+	request, err = resource.ParseQuantity(requestValue)
+	if err == nil {
+		limit, err = resource.ParseQuantity(limitValue)
+	}
+	if err != nil || request.Sign() <= 0 || limit.Sign() <= 0 {
+		return request, limit, ferror.MakeError(ferror.ErrorInvalidArgument,
+			fmt.Sprintf("invalid --%v '%v', must be a positive quantity or REQUEST:LIMIT, e.g. 500m or 500m:2", flag, value))
+	}
+	if request.Cmp(limit) > 0 {
+		return request, limit, ferror.MakeError(ferror.ErrorInvalidArgument,
+			fmt.Sprintf("invalid --%v '%v', the request %v is greater than the limit %v", flag, value, request.String(), limit.String()))
+	}
+	return request, limit, nil
+}
+
+// setBuildCommands sets the build commands of spec, keeping a single build command
+// in BuildCommand for compatibility with builders which don't know BuildCommands.
+// Empty commands are ignored.
+func setBuildCommands(spec *fv1.PackageSpec, buildcmds []string) {
+	var cmds []string
+	for _, c := range buildcmds {
+		if len(strings.TrimSpace(c)) > 0 {
+			cmds = append(cmds, c)
+		}
+	}
+
+	spec.BuildCommand = ""
+	spec.BuildCommands = nil
+	switch len(cmds) {
+	case 0:
+	case 1:
+		spec.BuildCommand = cmds[0]
+	default:
+		spec.BuildCommands = cmds
+	}
+}
+
 func GetFunctionsByPackage(ctx context.Context, client cmd.Client, pkgName, pkgNamespace string) ([]fv1.Function, error) {
 	fnList, err := client.FissionClientSet.CoreV1().Functions(pkgNamespace).List(ctx, v1.ListOptions{})
 	if err != nil {
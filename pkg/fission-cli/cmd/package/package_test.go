@@ -0,0 +1,650 @@
+package _package
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mholt/archiver/v3"
+	"github.com/stretchr/testify/require"
+
+	fv1 "github.com/fission/fission/pkg/apis/core/v1"
+	ferror "github.com/fission/fission/pkg/error"
+	"github.com/fission/fission/pkg/fission-cli/cliwrapper/cli"
+	"github.com/fission/fission/pkg/fission-cli/cliwrapper/driver/dummy"
+	"github.com/fission/fission/pkg/fission-cli/cmd"
+	pkgutil "github.com/fission/fission/pkg/fission-cli/cmd/package/util"
+	flagkey "github.com/fission/fission/pkg/fission-cli/flag/key"
+	"github.com/fission/fission/pkg/fission-cli/util"
+	"github.com/fission/fission/pkg/utils"
+)
+
+func TestMakeArchiveFileFormats(t *testing.T) {
+	srcDir := t.TempDir()
+	files := map[string]string{
+		"main.py":          "print('hello')",
+		"requirements.txt": "requests",
+	}
+	for name, content := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(srcDir, name), []byte(content), 0644))
+	}
+
+	for _, test := range []struct {
+		format     utils.ArchiveFormat
+		ext        string
+		unarchiver archiver.Unarchiver
+	}{
+		{format: utils.ArchiveFormatZip, ext: ".zip", unarchiver: archiver.NewZip()},
+		{format: utils.ArchiveFormatTarGz, ext: ".tar.gz", unarchiver: archiver.NewTarGz()},
+	} {
+		t.Run(string(test.format), func(t *testing.T) {
+			archivePath, err := makeArchiveFile("", []string{filepath.Join(srcDir, "*")}, false,
+				utils.ArchiveOptions{Format: test.format, PreserveMode: true})
+			require.NoError(t, err)
+			require.True(t, strings.HasSuffix(archivePath, test.ext), "unexpected archive name %v", archivePath)
+
+			dst := t.TempDir()
+			require.NoError(t, test.unarchiver.Unarchive(archivePath, dst))
+			for name, content := range files {
+				data, err := os.ReadFile(filepath.Join(dst, name))
+				require.NoError(t, err)
+				require.Equal(t, content, string(data))
+			}
+		})
+	}
+}
+
+func TestMakeArchiveFilePreserveMode(t *testing.T) {
+	srcDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, "build.sh"), []byte("#!/bin/sh\n"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, "main.py"), []byte("print('hello')"), 0644))
+
+	for _, test := range []struct {
+		name         string
+		preserveMode bool
+		expectedMode os.FileMode
+	}{
+		{name: "preserve mode", preserveMode: true, expectedMode: 0755},
+		{name: "normalize mode", preserveMode: false, expectedMode: 0644},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			archivePath, err := makeArchiveFile("", []string{srcDir}, false,
+				utils.ArchiveOptions{Format: utils.ArchiveFormatZip, PreserveMode: test.preserveMode})
+			require.NoError(t, err)
+
+			dst := t.TempDir()
+			require.NoError(t, archiver.NewZip().Unarchive(archivePath, dst))
+
+			fi, err := os.Stat(filepath.Join(dst, filepath.Base(srcDir), "build.sh"))
+			require.NoError(t, err)
+			require.Equal(t, test.expectedMode, fi.Mode().Perm())
+
+			fi, err = os.Stat(filepath.Join(dst, filepath.Base(srcDir), "main.py"))
+			require.NoError(t, err)
+			require.Equal(t, os.FileMode(0644), fi.Mode().Perm())
+		})
+	}
+}
+
+func TestMakeArchiveFileSymlinks(t *testing.T) {
+	srcDir := t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(srcDir, "data"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, "data", "config.json"), []byte("{}"), 0644))
+	require.NoError(t, os.Symlink(filepath.Join("data", "config.json"), filepath.Join(srcDir, "config.json")))
+	require.NoError(t, os.Symlink("data", filepath.Join(srcDir, "conf.d")))
+
+	unarchive := func(t *testing.T, followSymlinks bool) string {
+		archivePath, err := makeArchiveFile("", []string{srcDir}, false,
+			utils.ArchiveOptions{Format: utils.ArchiveFormatZip, FollowSymlinks: followSymlinks})
+		require.NoError(t, err)
+		dst := t.TempDir()
+		require.NoError(t, archiver.NewZip().Unarchive(archivePath, dst))
+		return filepath.Join(dst, filepath.Base(srcDir))
+	}
+
+	t.Run("follow symlinks", func(t *testing.T) {
+		dst := unarchive(t, true)
+		for _, name := range []string{"config.json", filepath.Join("conf.d", "config.json")} {
+			fi, err := os.Lstat(filepath.Join(dst, name))
+			require.NoError(t, err)
+			require.True(t, fi.Mode().IsRegular(), name)
+			content, err := os.ReadFile(filepath.Join(dst, name))
+			require.NoError(t, err)
+			require.Equal(t, "{}", string(content))
+		}
+	})
+
+	t.Run("preserve symlinks", func(t *testing.T) {
+		dst := unarchive(t, false)
+		for name, target := range map[string]string{"config.json": filepath.Join("data", "config.json"), "conf.d": "data"} {
+			fi, err := os.Lstat(filepath.Join(dst, name))
+			require.NoError(t, err)
+			require.NotZero(t, fi.Mode()&os.ModeSymlink, name)
+			link, err := os.Readlink(filepath.Join(dst, name))
+			require.NoError(t, err)
+			require.Equal(t, target, link)
+		}
+	})
+
+	outsideDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(outsideDir, "secret"), []byte("secret"), 0600))
+	for _, test := range []struct {
+		name   string
+		target string
+	}{
+		{name: "relative symlink outside", target: filepath.Join("..", filepath.Base(outsideDir), "secret")},
+		{name: "absolute symlink outside", target: filepath.Join(outsideDir, "secret")},
+		{name: "symlink to parent", target: ".."},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			require.NoError(t, os.Symlink(test.target, filepath.Join(dir, "link")))
+			for _, followSymlinks := range []bool{true, false} {
+				_, err := makeArchiveFile("", []string{dir}, false,
+					utils.ArchiveOptions{Format: utils.ArchiveFormatZip, FollowSymlinks: followSymlinks})
+				require.Error(t, err)
+			}
+		})
+	}
+
+	t.Run("symlink cycle", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, os.Mkdir(filepath.Join(dir, "sub"), 0755))
+		require.NoError(t, os.Symlink("..", filepath.Join(dir, "sub", "loop")))
+		_, err := makeArchiveFile("", []string{dir}, false,
+			utils.ArchiveOptions{Format: utils.ArchiveFormatZip, FollowSymlinks: true})
+		require.Error(t, err)
+	})
+}
+
+func TestGetArchiveOptions(t *testing.T) {
+	flags := dummy.TestFlagSet()
+	opts, err := getArchiveOptions(flags)
+	require.NoError(t, err)
+	require.Equal(t, utils.ArchiveFormatZip, opts.Format)
+	require.True(t, opts.PreserveMode)
+
+	flags.Set(flagkey.PkgArchiveFormat, string(utils.ArchiveFormatTarGz))
+	flags.Set(flagkey.PkgPreserveMode, false)
+	opts, err = getArchiveOptions(flags)
+	require.NoError(t, err)
+	require.Equal(t, utils.ArchiveFormatTarGz, opts.Format)
+	require.False(t, opts.PreserveMode)
+	require.False(t, opts.FollowSymlinks)
+
+	flags.Set(flagkey.PkgFollowSymlinks, true)
+	opts, err = getArchiveOptions(flags)
+	require.NoError(t, err)
+	require.True(t, opts.FollowSymlinks)
+
+	flags.Set(flagkey.PkgArchiveFormat, "rar")
+	_, err = getArchiveOptions(flags)
+	require.Error(t, err)
+
+	flags = dummy.TestFlagSet()
+	opts, err = getArchiveOptions(flags)
+	require.NoError(t, err)
+	require.Nil(t, opts.CompressionLevel)
+
+	for level, expected := range map[string]int{"0": 0, "5": 5, "9": 9, "store": 0, "fast": 1, "best": 9} {
+		flags.Set(flagkey.PkgCompressionLvl, level)
+		opts, err = getArchiveOptions(flags)
+		require.NoError(t, err)
+		require.NotNil(t, opts.CompressionLevel)
+		require.Equal(t, expected, *opts.CompressionLevel)
+	}
+
+	for _, level := range []string{"-1", "10", "fastest"} {
+		flags.Set(flagkey.PkgCompressionLvl, level)
+		_, err = getArchiveOptions(flags)
+		require.Error(t, err)
+	}
+}
+
+func TestMakeArchiveFileCompressionLevel(t *testing.T) {
+	srcDir := t.TempDir()
+	files := map[string]string{
+		"index.js":     strings.Repeat("module.exports = async function(context) {}\n", 1000),
+		"package.json": strings.Repeat(`{"name": "hello"}`, 1000),
+	}
+	for name, content := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(srcDir, name), []byte(content), 0644))
+	}
+
+	for _, test := range []struct {
+		format     utils.ArchiveFormat
+		unarchiver func() archiver.Unarchiver
+	}{
+		{format: utils.ArchiveFormatZip, unarchiver: func() archiver.Unarchiver { return archiver.NewZip() }},
+		{format: utils.ArchiveFormatTarGz, unarchiver: func() archiver.Unarchiver { return archiver.NewTarGz() }},
+	} {
+		t.Run(string(test.format), func(t *testing.T) {
+			sizes := make(map[int]int64)
+			for level := 0; level <= 9; level++ {
+				level := level
+				archivePath, err := makeArchiveFile("", []string{filepath.Join(srcDir, "*")}, false,
+					utils.ArchiveOptions{Format: test.format, CompressionLevel: &level})
+				require.NoError(t, err)
+
+				info, err := os.Stat(archivePath)
+				require.NoError(t, err)
+				sizes[level] = info.Size()
+
+				dst := t.TempDir()
+				require.NoError(t, test.unarchiver().Unarchive(archivePath, dst))
+				for name, content := range files {
+					data, err := os.ReadFile(filepath.Join(dst, name))
+					require.NoError(t, err)
+					require.Equal(t, content, string(data), "level %d", level)
+				}
+			}
+			// level 0 stores the files without compressing them
+			require.Greater(t, sizes[0], int64(len(files["index.js"])+len(files["package.json"])))
+			require.Greater(t, sizes[0], sizes[1])
+			require.GreaterOrEqual(t, sizes[1], sizes[9])
+		})
+	}
+}
+
+func TestReadIncludeFile(t *testing.T) {
+	srcDir := t.TempDir()
+	for _, name := range []string{"src/a.js", "src/b.js", "src/b_test.js", "lib/util.js", "README.md"} {
+		path := filepath.Join(srcDir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(name), 0644))
+	}
+
+	manifest := filepath.Join(srcDir, "include.txt")
+	require.NoError(t, os.WriteFile(manifest, []byte(`# sources
+src/*.js
+!*_test.js
+
+  lib
+`), 0644))
+
+	files, err := readIncludeFile(manifest)
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		filepath.Join(srcDir, "src", "a.js"),
+		filepath.Join(srcDir, "src", "b.js"),
+		filepath.Join(srcDir, "lib"),
+	}, files)
+
+	archivePath, err := makeArchiveFile("", files, false, utils.ArchiveOptions{Format: utils.ArchiveFormatZip})
+	require.NoError(t, err)
+
+	var names []string
+	err = archiver.NewZip().Walk(archivePath, func(f archiver.File) error {
+		if !f.IsDir() {
+			names = append(names, f.Name())
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"a.js", "b.js", "util.js"}, names)
+
+	require.NoError(t, os.WriteFile(manifest, []byte("# nothing\n!src\n"), 0644))
+	_, err = readIncludeFile(manifest)
+	require.Error(t, err)
+
+	_, err = readIncludeFile(filepath.Join(srcDir, "missing.txt"))
+	require.Error(t, err)
+}
+
+func TestWithCreateConfig(t *testing.T) {
+	config := filepath.Join(t.TempDir(), "package.yaml")
+	require.NoError(t, os.WriteFile(config, []byte(`env: nodejs
+env-namespace: shared-envs
+buildcmd: ./build.sh
+deployarchive:
+- index.js
+- lib/*.js
+compression-level: 9
+preserve-mode: false
+timeout: 5m
+`), 0644))
+
+	flags := dummy.TestFlagSet()
+	flags.Set(flagkey.PkgBuildCmd, []string{"make"})
+	flags.Set(flagkey.PkgEnvNamespace, "")
+	input, err := withCreateConfig(flags, config)
+	require.NoError(t, err)
+
+	// values from the config file
+	require.Equal(t, "nodejs", input.String(flagkey.PkgEnvironment))
+	require.Equal(t, []string{"index.js", "lib/*.js"}, input.StringSlice(flagkey.PkgDeployArchive))
+	require.Equal(t, 5*time.Minute, input.Duration(flagkey.PkgTimeout))
+	require.True(t, input.IsSet(flagkey.PkgPreserveMode))
+	require.False(t, input.Bool(flagkey.PkgPreserveMode))
+
+	opts, err := getArchiveOptions(input)
+	require.NoError(t, err)
+	require.False(t, opts.PreserveMode)
+	require.NotNil(t, opts.CompressionLevel)
+	require.Equal(t, 9, *opts.CompressionLevel)
+
+	// explicit flags take precedence
+	require.Equal(t, []string{"make"}, input.StringSlice(flagkey.PkgBuildCmd))
+	require.Equal(t, "", input.String(flagkey.PkgEnvNamespace))
+
+	// flags missing from both are unset
+	require.False(t, input.IsSet(flagkey.PkgSrcArchive))
+	require.Empty(t, input.StringSlice(flagkey.PkgSrcArchive))
+	require.False(t, input.Bool(flagkey.PkgInsecure))
+
+	require.NoError(t, os.WriteFile(config, []byte("env: nodejs\nchecksum: md5\n"), 0644))
+	_, err = withCreateConfig(flags, config)
+	require.Error(t, err)
+
+	_, err = withCreateConfig(flags, filepath.Join(t.TempDir(), "missing.yaml"))
+	require.Error(t, err)
+}
+
+func TestCreateArchiveAuth(t *testing.T) {
+	const archiveContent = "archive content"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, password, ok := r.BasicAuth()
+		if r.Header.Get("X-Archive-Token") != "secret-token" && !(ok && user == "user" && password == "secret-password") {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(archiveContent))
+	}))
+	defer server.Close()
+
+	fileURL := server.URL + "/archive.zip"
+	createArchive := func(flags cli.Input) (*fv1.Archive, error) {
+		return CreateArchive(cmd.Client{}, flags, []string{fileURL}, false, false, "", "", "")
+	}
+	expected := fmt.Sprintf("%x", sha256.Sum256([]byte(archiveContent)))
+
+	t.Run("without credentials", func(t *testing.T) {
+		_, err := createArchive(dummy.TestFlagSet())
+		requireErrorCode(t, err, ferror.ErrorNotAuthorized)
+	})
+
+	t.Run("wrong credentials", func(t *testing.T) {
+		flags := dummy.TestFlagSet()
+		flags.Set(flagkey.PkgArchiveBasicAuth, "user:wrong-password")
+		_, err := createArchive(flags)
+		requireErrorCode(t, err, ferror.ErrorNotAuthorized)
+		require.NotContains(t, err.Error(), "wrong-password")
+	})
+
+	t.Run("basic auth", func(t *testing.T) {
+		flags := dummy.TestFlagSet()
+		flags.Set(flagkey.PkgArchiveBasicAuth, "user:secret-password")
+		archive, err := createArchive(flags)
+		require.NoError(t, err)
+		require.Equal(t, fileURL, archive.URL)
+		require.Equal(t, expected, archive.Checksum.Sum)
+	})
+
+	t.Run("auth header", func(t *testing.T) {
+		flags := dummy.TestFlagSet()
+		flags.Set(flagkey.PkgArchiveAuthHeader, "X-Archive-Token: secret-token")
+		archive, err := createArchive(flags)
+		require.NoError(t, err)
+		require.Equal(t, expected, archive.Checksum.Sum)
+	})
+
+	t.Run("env", func(t *testing.T) {
+		t.Setenv(util.ENV_FISSION_ARCHIVE_BASIC_AUTH, "user:secret-password")
+		archive, err := createArchive(dummy.TestFlagSet())
+		require.NoError(t, err)
+		require.Equal(t, expected, archive.Checksum.Sum)
+	})
+
+	t.Run("invalid flags", func(t *testing.T) {
+		flags := dummy.TestFlagSet()
+		flags.Set(flagkey.PkgArchiveBasicAuth, "secret-password")
+		_, err := createArchive(flags)
+		requireErrorCode(t, err, ferror.ErrorInvalidArgument)
+		require.NotContains(t, err.Error(), "secret-password")
+
+		flags = dummy.TestFlagSet()
+		flags.Set(flagkey.PkgArchiveAuthHeader, "secret-token")
+		_, err = createArchive(flags)
+		requireErrorCode(t, err, ferror.ErrorInvalidArgument)
+		require.NotContains(t, err.Error(), "secret-token")
+	})
+}
+
+type fakeUploader struct {
+	content  []byte
+	metadata pkgutil.ArchiveMetadata
+}
+
+func (u *fakeUploader) Upload(ctx context.Context, reader io.Reader, metadata pkgutil.ArchiveMetadata) (*fv1.Archive, error) {
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	u.content = content
+	u.metadata = metadata
+	return &fv1.Archive{
+		Type:     fv1.ArchiveTypeUrl,
+		URL:      "fake://archives/" + metadata.Name,
+		Checksum: metadata.Checksum,
+	}, nil
+}
+
+func TestCreateArchiveBackend(t *testing.T) {
+	uploader := &fakeUploader{}
+	require.NoError(t, pkgutil.RegisterArchiveUploader("fake", func(client cmd.Client) (pkgutil.ArchiveUploader, error) {
+		return uploader, nil
+	}))
+	require.Error(t, pkgutil.RegisterArchiveUploader("fake", func(client cmd.Client) (pkgutil.ArchiveUploader, error) {
+		return uploader, nil
+	}))
+	require.Equal(t, []string{"fake", pkgutil.DefaultArchiveBackend}, pkgutil.ArchiveBackends())
+
+	content := strings.Repeat("a", int(fv1.ArchiveLiteralSizeLimit))
+	file := filepath.Join(t.TempDir(), "main.py")
+	require.NoError(t, os.WriteFile(file, []byte(content), 0644))
+
+	t.Run("fake backend", func(t *testing.T) {
+		flags := dummy.TestFlagSet()
+		flags.Set(flagkey.PkgArchiveBackend, "fake")
+		archive, err := CreateArchive(cmd.Client{}, flags, []string{file}, true, false, "", "", "")
+		require.NoError(t, err)
+
+		expected := fv1.Checksum{
+			Type: fv1.ChecksumTypeSHA256,
+			Sum:  fmt.Sprintf("%x", sha256.Sum256([]byte(content))),
+		}
+		require.Equal(t, content, string(uploader.content))
+		require.Equal(t, pkgutil.ArchiveMetadata{Name: "main.py", Size: int64(len(content)), Checksum: expected}, uploader.metadata)
+		require.Equal(t, &fv1.Archive{Type: fv1.ArchiveTypeUrl, URL: "fake://archives/main.py", Checksum: expected}, archive)
+	})
+
+	t.Run("unknown backend", func(t *testing.T) {
+		flags := dummy.TestFlagSet()
+		flags.Set(flagkey.PkgArchiveBackend, "unknown")
+		_, err := CreateArchive(cmd.Client{}, flags, []string{file}, true, false, "", "", "")
+		requireErrorCode(t, err, ferror.ErrorInvalidArgument)
+	})
+}
+
+func TestCreateArchiveMaxSize(t *testing.T) {
+	uploads := 0
+	storage := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			uploads++
+		}
+		fmt.Fprint(w, `{"id":"archive-id"}`)
+	}))
+	defer storage.Close()
+	t.Setenv("FISSION_STORAGESVC_URL", storage.URL)
+
+	// large enough not to be embedded as literal
+	size := fv1.ArchiveLiteralSizeLimit + 1
+	file := filepath.Join(t.TempDir(), "main.py")
+	require.NoError(t, os.WriteFile(file, []byte(strings.Repeat("a", int(size))), 0644))
+
+	t.Run("oversized", func(t *testing.T) {
+		flags := dummy.TestFlagSet()
+		flags.Set(flagkey.PkgMaxArchiveSize, fmt.Sprint(size-1))
+		_, err := CreateArchive(cmd.Client{}, flags, []string{file}, true, false, "", "", "")
+		requireErrorCode(t, err, ferror.ErrorInvalidArgument)
+		require.Contains(t, err.Error(), fmt.Sprintf("archive 'main.py' is %v bytes, larger than the maximum archive size of %v bytes", size, size-1))
+		require.Zero(t, uploads, "oversized archives must fail before the upload")
+	})
+
+	t.Run("at limit", func(t *testing.T) {
+		for _, maxSize := range []string{fmt.Sprint(size), "1Mi", "0", ""} {
+			uploads = 0
+			// the archive is uploaded again rather than reused
+			uploadedArchives = newArchiveCache()
+			flags := dummy.TestFlagSet()
+			flags.Set(flagkey.PkgMaxArchiveSize, maxSize)
+			archive, err := CreateArchive(cmd.Client{}, flags, []string{file}, true, false, "", "", "")
+			require.NoError(t, err, maxSize)
+			require.Equal(t, fv1.ArchiveTypeUrl, archive.Type)
+			require.Equal(t, 1, uploads)
+		}
+	})
+
+	t.Run("invalid size", func(t *testing.T) {
+		for _, maxSize := range []string{"-1", "big"} {
+			flags := dummy.TestFlagSet()
+			flags.Set(flagkey.PkgMaxArchiveSize, maxSize)
+			_, err := CreateArchive(cmd.Client{}, flags, []string{file}, true, false, "", "", "")
+			requireErrorCode(t, err, ferror.ErrorInvalidArgument)
+		}
+	})
+}
+
+func TestBuildEnv(t *testing.T) {
+	envFile := filepath.Join(t.TempDir(), "build.env")
+	require.NoError(t, os.WriteFile(envFile, []byte(`# registry credentials
+NPM_TOKEN=file-token
+
+GOFLAGS=-mod=vendor
+  EMPTY=
+`), 0644))
+
+	for _, test := range []struct {
+		name     string
+		env      []string
+		file     string
+		expected []fv1.BuildEnvVar
+		invalid  bool
+	}{
+		{name: "no flags"},
+		{
+			name:     "flags",
+			env:      []string{"NPM_TOKEN=secret", "OPTS=a=b,c"},
+			expected: []fv1.BuildEnvVar{{Name: "NPM_TOKEN", Value: "secret"}, {Name: "OPTS", Value: "a=b,c"}},
+		},
+		{
+			name: "file",
+			file: envFile,
+			expected: []fv1.BuildEnvVar{
+				{Name: "NPM_TOKEN", Value: "file-token"},
+				{Name: "GOFLAGS", Value: "-mod=vendor"},
+				{Name: "EMPTY"},
+			},
+		},
+		{
+			name: "flags take precedence over file",
+			env:  []string{"NPM_TOKEN=flag-token", "CGO_ENABLED=0"},
+			file: envFile,
+			expected: []fv1.BuildEnvVar{
+				{Name: "NPM_TOKEN", Value: "flag-token"},
+				{Name: "GOFLAGS", Value: "-mod=vendor"},
+				{Name: "EMPTY"},
+				{Name: "CGO_ENABLED", Value: "0"},
+			},
+		},
+		{name: "missing value", env: []string{"NPM_TOKEN"}, invalid: true},
+		{name: "invalid name", env: []string{"1TOKEN=x"}, invalid: true},
+		{name: "empty name", env: []string{"=x"}, invalid: true},
+		{name: "missing file", file: filepath.Join(t.TempDir(), "missing.env"), invalid: true},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			flags := dummy.TestFlagSet()
+			if test.env != nil {
+				flags.Set(flagkey.PkgBuildEnv, test.env)
+			}
+			if len(test.file) > 0 {
+				flags.Set(flagkey.PkgBuildEnvFromFile, test.file)
+			}
+
+			env, err := buildEnv(flags)
+			if test.invalid {
+				requireErrorCode(t, err, ferror.ErrorInvalidArgument)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, test.expected, env)
+		})
+	}
+}
+
+func TestCodeArchive(t *testing.T) {
+	opts := utils.ArchiveOptions{Format: utils.ArchiveFormatZip, IgnoreFile: FissionIgnoreFile}
+
+	t.Run("single file", func(t *testing.T) {
+		code := filepath.Join(t.TempDir(), "hello.js")
+		require.NoError(t, os.WriteFile(code, []byte("module.exports = async function(context) {}"), 0644))
+		require.True(t, CodeNoZip(code))
+
+		archivePath, err := makeArchiveFile("", []string{code}, CodeNoZip(code), opts)
+		require.NoError(t, err)
+		require.Equal(t, code, archivePath)
+	})
+
+	t.Run("directory with ignore file", func(t *testing.T) {
+		code := t.TempDir()
+		for name, content := range map[string]string{
+			"index.js":                  "module.exports = async function(context) {}",
+			"lib/util.js":               "module.exports = {}",
+			"debug.log":                 "log",
+			"node_modules/dep/index.js": "module.exports = {}",
+			FissionIgnoreFile:           "*.log\nnode_modules/\n",
+		} {
+			path := filepath.Join(code, name)
+			require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+			require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+		}
+		require.False(t, CodeNoZip(code))
+
+		archivePath, err := makeArchiveFile("", []string{code}, CodeNoZip(code), opts)
+		require.NoError(t, err)
+		require.NotEqual(t, code, archivePath)
+
+		dst := t.TempDir()
+		require.NoError(t, archiver.NewZip().Unarchive(archivePath, dst))
+		var archived []string
+		root := filepath.Join(dst, filepath.Base(code))
+		require.NoError(t, filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if err != nil || info.IsDir() {
+				return err
+			}
+			rel, err := filepath.Rel(root, path)
+			archived = append(archived, filepath.ToSlash(rel))
+			return err
+		}))
+		require.ElementsMatch(t, []string{FissionIgnoreFile, "index.js", "lib/util.js"}, archived)
+	})
+
+	t.Run("directory without zip", func(t *testing.T) {
+		code := t.TempDir()
+		_, err := makeArchiveFile("", []string{code}, true, opts)
+		requireErrorCode(t, err, ferror.ErrorInvalidArgument)
+		require.ErrorContains(t, err, "is a directory")
+	})
+
+	t.Run("url", func(t *testing.T) {
+		require.True(t, CodeNoZip("https://example.com/hello.js"))
+	})
+}
@@ -102,19 +102,17 @@ func UpdatePackage(input cli.Input, client cmd.Client, specFile string, pkg *fv1
 	envName := input.String(flagkey.PkgEnvironment)
 	srcArchiveFiles := input.StringSlice(flagkey.PkgSrcArchive)
 	deployArchiveFiles := input.StringSlice(flagkey.PkgDeployArchive)
-	buildcmd := input.String(flagkey.PkgBuildCmd)
+	buildcmds := input.StringSlice(flagkey.PkgBuildCmd)
 	insecure := input.Bool(flagkey.PkgInsecure)
 	deployChecksum := input.String(flagkey.PkgDeployChecksum)
 	srcChecksum := input.String(flagkey.PkgSrcChecksum)
-	code := input.String(flagkey.PkgCode)
+	codes, noZip := CodeArchiveFiles(input)
 
-	noZip := false
 	needToRebuild := false
 	needToUpdate := false
 
 	if input.IsSet(flagkey.PkgCode) {
-		deployArchiveFiles = append(deployArchiveFiles, code)
-		noZip = true
+		deployArchiveFiles = append(deployArchiveFiles, codes...)
 		needToUpdate = true
 	}
 
@@ -125,7 +123,17 @@ func UpdatePackage(input cli.Input, client cmd.Client, specFile string, pkg *fv1
 	}
 
 	if input.IsSet(flagkey.PkgBuildCmd) {
-		pkg.Spec.BuildCommand = buildcmd
+		setBuildCommands(&pkg.Spec, buildcmds)
+		needToRebuild = true
+		needToUpdate = true
+	}
+
+	if input.IsSet(flagkey.PkgBuildEnv) || input.IsSet(flagkey.PkgBuildEnvFromFile) {
+		env, err := buildEnv(input)
+		if err != nil {
+			return nil, err
+		}
+		pkg.Spec.BuildEnv = env
 		needToRebuild = true
 		needToUpdate = true
 	}
@@ -0,0 +1,224 @@
+/*
+Copyright 2024 The Fission Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	fv1 "github.com/fission/fission/pkg/apis/core/v1"
+	"github.com/fission/fission/pkg/fission-cli/cmd"
+	"github.com/fission/fission/pkg/fission-cli/util"
+	"github.com/fission/fission/pkg/storagesvc"
+	storageSvcClient "github.com/fission/fission/pkg/storagesvc/client"
+	"github.com/fission/fission/pkg/utils"
+	"github.com/fission/fission/pkg/utils/uuid"
+)
+
+// DefaultArchiveBackend is the archive backend used when none is given,
+// uploading archives to the fission storage service.
+const DefaultArchiveBackend = "storagesvc"
+
+type (
+	// ArchiveMetadata describes the archive passed to an ArchiveUploader.
+	ArchiveMetadata struct {
+		// Name is the file name of the archive
+		Name string
+		// Size is the archive size in bytes
+		Size int64
+		// Checksum is the SHA256 checksum of the archive
+		Checksum fv1.Checksum
+	}
+
+	// ArchiveUploader stores archive contents in a backend and returns
+	// the archive referencing the stored contents.
+	ArchiveUploader interface {
+		Upload(ctx context.Context, reader io.Reader, metadata ArchiveMetadata) (*fv1.Archive, error)
+	}
+
+	// ArchiveUploaderFactory creates an ArchiveUploader for a fission client.
+	ArchiveUploaderFactory func(client cmd.Client) (ArchiveUploader, error)
+
+	storageSvcUploader struct {
+		client cmd.Client
+	}
+)
+
+var (
+	archiveUploadersLock sync.RWMutex
+	archiveUploaders     = map[string]ArchiveUploaderFactory{
+		DefaultArchiveBackend: newStorageSvcUploader,
+	}
+)
+
+// RegisterArchiveUploader makes an archive backend available under name,
+// e.g. for --archive-backend. It returns an error if name is already registered.
+func RegisterArchiveUploader(name string, factory ArchiveUploaderFactory) error {
+	archiveUploadersLock.Lock()
+	defer archiveUploadersLock.Unlock()
+
+	if len(name) == 0 || factory == nil {
+		return errors.New("archive backend name and factory are required")
+	}
+	if _, ok := archiveUploaders[name]; ok {
+		return errors.Errorf("archive backend %q is already registered", name)
+	}
+	archiveUploaders[name] = factory
+	return nil
+}
+
+// ArchiveBackends returns the sorted names of the registered archive backends.
+func ArchiveBackends() []string {
+	archiveUploadersLock.RLock()
+	defer archiveUploadersLock.RUnlock()
+
+	names := make([]string, 0, len(archiveUploaders))
+	for name := range archiveUploaders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// GetArchiveUploader returns the uploader of the archive backend name,
+// or of the default backend if name is empty.
+func GetArchiveUploader(client cmd.Client, name string) (ArchiveUploader, error) {
+	if len(name) == 0 {
+		name = DefaultArchiveBackend
+	}
+
+	archiveUploadersLock.RLock()
+	factory, ok := archiveUploaders[name]
+	archiveUploadersLock.RUnlock()
+
+	if !ok {
+		return nil, errors.Errorf("unknown archive backend %q, must be one of: %v", name, strings.Join(ArchiveBackends(), ", "))
+	}
+	return factory(client)
+}
+
+// UploadArchive returns a literal archive for files smaller than
+// fv1.ArchiveLiteralSizeLimit, otherwise it uploads the file with uploader.
+func UploadArchive(ctx context.Context, uploader ArchiveUploader, fileName string) (*fv1.Archive, error) {
+	size, err := utils.FileSize(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	if size < fv1.ArchiveLiteralSizeLimit {
+		literal, err := GetContents(fileName)
+		if err != nil {
+			return nil, err
+		}
+		return &fv1.Archive{
+			Type:    fv1.ArchiveTypeLiteral,
+			Literal: literal,
+		}, nil
+	}
+
+	csum, err := utils.GetFileChecksum(fileName)
+	if err != nil {
+		return nil, errors.Wrapf(err, "calculate checksum for file %v", fileName)
+	}
+
+	f, err := os.Open(fileName)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error opening %v", fileName)
+	}
+	defer f.Close()
+
+	return uploader.Upload(ctx, f, ArchiveMetadata{
+		Name:     filepath.Base(fileName),
+		Size:     size,
+		Checksum: *csum,
+	})
+}
+
+func newStorageSvcUploader(client cmd.Client) (ArchiveUploader, error) {
+	return &storageSvcUploader{client: client}, nil
+}
+
+func (u *storageSvcUploader) Upload(ctx context.Context, reader io.Reader, metadata ArchiveMetadata) (*fv1.Archive, error) {
+	// the storage service client uploads files, so spool other readers to disk
+	fileName := ""
+	if f, ok := reader.(*os.File); ok {
+		fileName = f.Name()
+	} else {
+		tmpDir, err := utils.GetTempDir()
+		if err != nil {
+			return nil, errors.Wrap(err, "error creating temporary directory")
+		}
+		fileName = filepath.Join(tmpDir, fmt.Sprintf("%v-%v", uuid.NewString(), metadata.Name))
+		err = WriteArchiveToFile(fileName, reader)
+		if err != nil {
+			return nil, err
+		}
+		defer os.Remove(fileName)
+	}
+
+	storagesvcURL, err := util.GetStorageURL(ctx, u.client)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error getting fission storage service URL")
+	}
+
+	storageClient := storageSvcClient.MakeClient(storagesvcURL.String())
+	// TODO add a progress bar
+	ur, err := storageClient.UploadArchive(ctx, fileName, nil)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error uploading to fission storage service")
+	}
+	err = verifyStoredArchive(ur, metadata)
+	if err != nil {
+		// best effort, the storage service prunes unreferenced archives eventually
+		_ = storageClient.Delete(ctx, ur.ID)
+		return nil, err
+	}
+
+	archiveURL, err := getArchiveURL(ctx, u.client, ur.ID, storagesvcURL)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not get URL of archive")
+	}
+
+	return &fv1.Archive{
+		Type:     fv1.ArchiveTypeUrl,
+		URL:      archiveURL,
+		Checksum: metadata.Checksum,
+	}, nil
+}
+
+// verifyStoredArchive checks that the size and checksum of the archive stored by the
+// storage service match the uploaded archive. Storage services not reporting them
+// are not checked.
+func verifyStoredArchive(ur *storagesvc.UploadResponse, metadata ArchiveMetadata) error {
+	if ur.Size > 0 && metadata.Size > 0 && ur.Size != metadata.Size {
+		return errors.Errorf("archive integrity check failed: fission storage service stored %v bytes of archive %v, uploaded %v bytes",
+			ur.Size, metadata.Name, metadata.Size)
+	}
+	if len(ur.Checksum) > 0 && len(metadata.Checksum.Sum) > 0 && ur.Checksum != metadata.Checksum.Sum {
+		return errors.Errorf("archive integrity check failed: fission storage service stored archive %v with SHA256 checksum %v, uploaded %v",
+			metadata.Name, ur.Checksum, metadata.Checksum.Sum)
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+/*
+Copyright 2024 The Fission Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	fv1 "github.com/fission/fission/pkg/apis/core/v1"
+	"github.com/fission/fission/pkg/fission-cli/cmd"
+	"github.com/fission/fission/pkg/storagesvc"
+	"github.com/fission/fission/pkg/utils"
+)
+
+func TestStorageSvcUploaderIntegrity(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "archive.zip")
+	content := strings.Repeat("x", int(fv1.ArchiveLiteralSizeLimit)+1)
+	require.NoError(t, os.WriteFile(fileName, []byte(content), 0644))
+	csum, err := utils.GetFileChecksum(fileName)
+	require.NoError(t, err)
+
+	for _, test := range []struct {
+		name     string
+		response storagesvc.UploadResponse
+		valid    bool
+	}{
+		{name: "matching", response: storagesvc.UploadResponse{Size: int64(len(content)), Checksum: csum.Sum}, valid: true},
+		{name: "not reported", valid: true},
+		{name: "checksum mismatch", response: storagesvc.UploadResponse{Size: int64(len(content)), Checksum: strings.Repeat("0", 64)}},
+		{name: "truncated", response: storagesvc.UploadResponse{Size: int64(len(content)) - 1, Checksum: csum.Sum}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var deleted []string
+			storage := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.Method {
+				case http.MethodPost:
+					ur := test.response
+					ur.ID = "archive-id"
+					require.NoError(t, json.NewEncoder(w).Encode(ur))
+				case http.MethodHead:
+					w.Header().Set("X-FISSION-STORAGETYPE", "local")
+				case http.MethodDelete:
+					deleted = append(deleted, r.URL.Query().Get("id"))
+				}
+			}))
+			defer storage.Close()
+			t.Setenv("FISSION_STORAGESVC_URL", storage.URL)
+
+			uploader, err := GetArchiveUploader(cmd.Client{}, "")
+			require.NoError(t, err)
+			archive, err := UploadArchive(context.Background(), uploader, fileName)
+			if test.valid {
+				require.NoError(t, err)
+				require.Equal(t, fv1.ArchiveTypeUrl, archive.Type)
+				require.Equal(t, *csum, archive.Checksum)
+				require.Empty(t, deleted)
+				return
+			}
+			require.ErrorContains(t, err, "archive integrity check failed")
+			require.Equal(t, []string{"archive-id"}, deleted)
+		})
+	}
+}
@@ -29,6 +29,7 @@ import (
 	"github.com/pkg/errors"
 
 	fv1 "github.com/fission/fission/pkg/apis/core/v1"
+	ferror "github.com/fission/fission/pkg/error"
 	"github.com/fission/fission/pkg/fission-cli/cmd"
 	"github.com/fission/fission/pkg/fission-cli/util"
 	storageSvcClient "github.com/fission/fission/pkg/storagesvc/client"
@@ -36,50 +37,37 @@ import (
 	"github.com/fission/fission/pkg/utils/uuid"
 )
 
+// UploadArchiveFile uploads the archive fileName with the default archive backend.
 func UploadArchiveFile(ctx context.Context, client cmd.Client, fileName string) (*fv1.Archive, error) {
-	var archive fv1.Archive
-
-	size, err := utils.FileSize(fileName)
+	uploader, err := GetArchiveUploader(client, DefaultArchiveBackend)
 	if err != nil {
 		return nil, err
 	}
+	return UploadArchive(ctx, uploader, fileName)
+}
 
-	if size < fv1.ArchiveLiteralSizeLimit {
-		archive.Type = fv1.ArchiveTypeLiteral
-		archive.Literal, err = GetContents(fileName)
-		if err != nil {
-			return nil, err
-		}
-	} else {
-		storagesvcURL, err := util.GetStorageURL(ctx, client)
-		if err != nil {
-			return nil, errors.Wrapf(err, "error getting fission storage service URL")
-		}
-
-		storageClient := storageSvcClient.MakeClient(storagesvcURL.String())
-		// TODO add a progress bar
-		id, err := storageClient.Upload(ctx, fileName, nil)
-		if err != nil {
-			return nil, errors.Wrapf(err, "error uploading to fission storage service")
-		}
-
-		archiveURL, err := getArchiveURL(ctx, client, id, storagesvcURL)
-		if err != nil {
-			return nil, errors.Wrapf(err, "could not get URL of archive")
-		}
-
-		archive.Type = fv1.ArchiveTypeUrl
-		archive.URL = archiveURL
-
-		csum, err := utils.GetFileChecksum(fileName)
-		if err != nil {
-			return nil, errors.Wrapf(err, "calculate checksum for file %v", fileName)
-		}
+// ArchiveFromID returns the archive referencing the archive id already uploaded to
+// the fission storage service, without uploading it again. It returns an ErrorNotFound
+// error if the storage service has no archive id.
+func ArchiveFromID(ctx context.Context, client cmd.Client, id string, checksum fv1.Checksum) (*fv1.Archive, error) {
+	storagesvcURL, err := util.GetStorageURL(ctx, client)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error getting fission storage service URL")
+	}
 
-		archive.Checksum = *csum
+	archiveURL, err := getArchiveURL(ctx, client, id, storagesvcURL)
+	if err != nil {
+		return nil, err
+	}
+	if len(archiveURL) == 0 {
+		return nil, errors.Errorf("could not get URL of archive '%v'", id)
 	}
 
-	return &archive, nil
+	return &fv1.Archive{
+		Type:     fv1.ArchiveTypeUrl,
+		URL:      archiveURL,
+		Checksum: checksum,
+	}, nil
 }
 
 func getArchiveURL(ctx context.Context, client cmd.Client, archiveID string, serverURL *url.URL) (archiveURL string, err error) {
@@ -97,6 +85,9 @@ func getArchiveURL(ctx context.Context, client cmd.Client, archiveID string, ser
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusNotFound {
+		return "", ferror.MakeError(ferror.ErrorNotFound, fmt.Sprintf("archive '%v' not found in fission storage service", archiveID))
+	}
 	if resp.StatusCode != http.StatusOK {
 		return "", fmt.Errorf("error getting URL. Exited with Status:  %s", resp.Status)
 	}
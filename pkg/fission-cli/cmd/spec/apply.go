@@ -696,7 +696,12 @@ func applyPackages(ctx context.Context, fclient cmd.Client, fr *FissionResources
 			} else if reflect.DeepEqual(existingObj.Spec.Environment, o.Spec.Environment) &&
 				!reflect.DeepEqual(existingObj.Spec.Source, fv1.Archive{}) &&
 				reflect.DeepEqual(existingObj.Spec.Source, o.Spec.Source) &&
-				existingObj.Spec.BuildCommand == o.Spec.BuildCommand {
+				existingObj.Spec.BuildCommand == o.Spec.BuildCommand &&
+				reflect.DeepEqual(existingObj.Spec.BuildCommands, o.Spec.BuildCommands) &&
+				reflect.DeepEqual(existingObj.Spec.BuildEnv, o.Spec.BuildEnv) &&
+				reflect.DeepEqual(existingObj.Spec.BuildResources, o.Spec.BuildResources) &&
+				reflect.DeepEqual(existingObj.Spec.Secrets, o.Spec.Secrets) &&
+				reflect.DeepEqual(existingObj.Spec.ConfigMaps, o.Spec.ConfigMaps) {
 
 				keep = true
 			}
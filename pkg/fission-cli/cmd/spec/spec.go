@@ -175,8 +175,16 @@ func save(data []byte, specDir string, specFile string, truncate bool) error {
 }
 
 // called from `fission * create --spec`
+// specSaveDir is the directory SpecSave writes spec files to.
+const specSaveDir = "specs"
+
+// SpecFilePath returns the path of specFile as written by SpecSave.
+func SpecFilePath(specFile string) string {
+	return filepath.Join(specSaveDir, specFile)
+}
+
 func SpecSave(resource interface{}, specFile string, update bool) error {
-	var specDir = "specs"
+	var specDir = specSaveDir
 
 	meta, kind, data, err := crdToYaml(resource)
 	if err != nil {
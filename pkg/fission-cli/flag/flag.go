@@ -195,19 +195,51 @@ var (
 	KwObjType   = Flag{Type: String, Name: flagkey.KwObjType, Usage: "Type of resource to watch (Pod, Service, etc.)", DefaultValue: "pod"}
 	KwLabels    = Flag{Type: String, Name: flagkey.KwLabels, Usage: "Label selector of the form a=b,c=d"}
 
-	PkgName           = Flag{Type: String, Name: flagkey.PkgName, Usage: "Package name"}
-	PkgForce          = Flag{Type: Bool, Name: flagkey.PkgForce, Short: "f", Usage: "Force update a package even if it is used by one or more functions"}
-	PkgEnvironment    = Flag{Type: String, Name: flagkey.PkgEnvironment, Usage: "Environment name"}
-	PkgBuildCmd       = Flag{Type: String, Name: flagkey.PkgBuildCmd, Usage: "Build command for builder to run with"}
-	PkgOutput         = Flag{Type: String, Name: flagkey.PkgOutput, Short: "o", Usage: "Output filename to save archive content"}
-	PkgStatus         = Flag{Type: String, Name: flagkey.PkgStatus, Usage: `Filter packages by status`}
-	PkgOrphan         = Flag{Type: Bool, Name: flagkey.PkgOrphan, Usage: "Orphan packages that are not referenced by any function"}
-	PkgCode           = Flag{Type: String, Name: flagkey.PkgCode, Usage: "URL or local path for single file source code"}
-	PkgDeployArchive  = Flag{Type: StringSlice, Name: flagkey.PkgDeployArchive, Aliases: []string{"deploy"}, Usage: "URL or local paths for binary archive"}
-	PkgDeployChecksum = Flag{Type: String, Name: flagkey.PkgDeployChecksum, Usage: "SHA256 checksum of deploy archive when providing URL"}
-	PkgSrcArchive     = Flag{Type: StringSlice, Name: flagkey.PkgSrcArchive, Aliases: []string{"source", "src"}, Usage: "URL or local paths for source archive"}
-	PkgSrcChecksum    = Flag{Type: String, Name: flagkey.PkgSrcChecksum, Usage: "SHA256 checksum of source archive when providing URL"}
-	PkgInsecure       = Flag{Type: Bool, Name: flagkey.PkgInsecure, Usage: "Skip generating SHA256 checksum for file integrity validation"}
+	PkgName              = Flag{Type: String, Name: flagkey.PkgName, Usage: "Package name"}
+	PkgForce             = Flag{Type: Bool, Name: flagkey.PkgForce, Short: "f", Usage: "Force update a package even if it is used by one or more functions"}
+	PkgEnvironment       = Flag{Type: String, Name: flagkey.PkgEnvironment, Usage: "Environment name"}
+	PkgBuildCmd          = Flag{Type: StringSlice, Name: flagkey.PkgBuildCmd, Usage: "Build command for builder to run with. Can be given multiple times to run several commands in order, stopping at the first failing one"}
+	PkgBuildEnv          = Flag{Type: StringSlice, Name: flagkey.PkgBuildEnv, Usage: "Environment variable set for the build command, in the form KEY=VALUE. Can be given multiple times"}
+	PkgBuildEnvFromFile  = Flag{Type: String, Name: flagkey.PkgBuildEnvFromFile, Usage: "File with environment variables set for the build command, one KEY=VALUE per line; lines starting with '#' are comments. Variables given with --build-env take precedence"}
+	PkgBuildCPU          = Flag{Type: String, Name: flagkey.PkgBuildCPU, Usage: "CPU requested and limited for the build, as a quantity used for both, e.g. 500m, or as REQUEST:LIMIT, e.g. 500m:2"}
+	PkgBuildMemory       = Flag{Type: String, Name: flagkey.PkgBuildMemory, Usage: "Memory requested and limited for the build, as a quantity used for both, e.g. 1Gi, or as REQUEST:LIMIT, e.g. 512Mi:2Gi"}
+	PkgOutput            = Flag{Type: String, Name: flagkey.PkgOutput, Short: "o", Usage: "Output filename to save archive content"}
+	PkgStatus            = Flag{Type: String, Name: flagkey.PkgStatus, Usage: `Filter packages by status`}
+	PkgOrphan            = Flag{Type: Bool, Name: flagkey.PkgOrphan, Usage: "Orphan packages that are not referenced by any function"}
+	PkgCode              = Flag{Type: StringSlice, Name: flagkey.PkgCode, Usage: "URL or local path for single file source code. A local directory is zipped, leaving out the paths matching the patterns of its .fissionignore file. Multiple files given with multiple --code flags are zipped together"}
+	PkgDeployArchive     = Flag{Type: StringSlice, Name: flagkey.PkgDeployArchive, Aliases: []string{"deploy"}, Usage: "URL or local paths for binary archive, or - to read the archive from stdin"}
+	PkgDeployChecksum    = Flag{Type: String, Name: flagkey.PkgDeployChecksum, Usage: "SHA256 checksum of deploy archive. Required to match when providing a local archive, skips the download when providing URL"}
+	PkgSrcArchive        = Flag{Type: StringSlice, Name: flagkey.PkgSrcArchive, Aliases: []string{"source", "src"}, Usage: "URL or local paths for source archive, or - to read the archive from stdin"}
+	PkgSrcChecksum       = Flag{Type: String, Name: flagkey.PkgSrcChecksum, Usage: "SHA256 checksum of source archive. Required to match when providing a local archive, skips the download when providing URL"}
+	PkgInsecure          = Flag{Type: Bool, Name: flagkey.PkgInsecure, Usage: "Skip generating SHA256 checksum for file integrity validation"}
+	PkgEnvNamespace      = Flag{Type: String, Name: flagkey.PkgEnvNamespace, Usage: "Namespace of the environment, if it differs from the package namespace"}
+	PkgArchiveFormat     = Flag{Type: String, Name: flagkey.PkgArchiveFormat, Usage: "Format of the archive created when bundling multiple files: zip|targz", DefaultValue: "zip"}
+	PkgValidateOnly      = Flag{Type: Bool, Name: flagkey.PkgValidateOnly, Usage: "Only validate the package inputs, without creating the package or spec"}
+	PkgPreserveMode      = Flag{Type: Bool, Name: flagkey.PkgPreserveMode, Usage: "Preserve file permissions, e.g. executable bits, in created archives", DefaultValue: true}
+	PkgFollowSymlinks    = Flag{Type: Bool, Name: flagkey.PkgFollowSymlinks, Usage: "Archive symlinks as the files or directories they point to instead of as links. Symlinks must point into the archived directory either way"}
+	PkgCompressionLvl    = Flag{Type: String, Name: flagkey.PkgCompressionLvl, Usage: "Compression level of created archives: 0-9 or store|fast|best, where 0 (store) disables compression"}
+	PkgFromConfig        = Flag{Type: String, Name: flagkey.PkgFromConfig, Usage: "YAML file with default values of package create flags, e.g. env and buildcmd; explicitly given flags take precedence"}
+	PkgArchiveAuthHeader = Flag{Type: String, Name: flagkey.PkgArchiveAuthHeader, Usage: "HTTP header sent when downloading remote archives, in the form 'Name: value'. Can also be set with env FISSION_ARCHIVE_AUTH_HEADER"}
+	PkgArchiveBasicAuth  = Flag{Type: String, Name: flagkey.PkgArchiveBasicAuth, Usage: "Basic auth credentials used when downloading remote archives, in the form 'user:password'. Can also be set with env FISSION_ARCHIVE_BASIC_AUTH"}
+	PkgSourceCommit      = Flag{Type: String, Name: flagkey.PkgSourceCommit, Usage: "Git commit SHA the package is built from, recorded as package annotation"}
+	PkgSourceRepo        = Flag{Type: String, Name: flagkey.PkgSourceRepo, Usage: "Repository URL the package is built from, recorded as package annotation"}
+	PkgSourceRef         = Flag{Type: String, Name: flagkey.PkgSourceRef, Usage: "Git ref (branch or tag) the package is built from, recorded as package annotation"}
+	PkgArchiveDryRun     = Flag{Type: Bool, Name: flagkey.PkgArchiveDryRun, Usage: "Build the archives and print their files, size and checksum, without uploading them or creating the package"}
+	PkgArchiveOut        = Flag{Type: String, Name: flagkey.PkgArchiveOut, Usage: "Write the archive to this path instead of uploading it, without creating the package, e.g. to upload it with 'fission archive upload' in an air-gapped cluster and reference it with --deploy-archive-id or --src-archive-id"}
+	PkgPrintSpec         = Flag{Type: Bool, Name: flagkey.PkgPrintSpec, Usage: "Print the package YAML to stdout, e.g. to pipe into kubectl, without creating the package or saving a spec"}
+	PkgSrcArchiveID      = Flag{Type: String, Name: flagkey.PkgSrcArchiveID, Usage: "ID of a source archive already uploaded to the fission storage service, used instead of uploading --src; the checksum can be given with --srcchecksum"}
+	PkgDeployArchiveID   = Flag{Type: String, Name: flagkey.PkgDeployArchiveID, Usage: "ID of a deploy archive already uploaded to the fission storage service, used instead of uploading --deploy or --code; the checksum can be given with --deploychecksum"}
+	PkgFollowBuild       = Flag{Type: Bool, Name: flagkey.PkgFollowBuild, Usage: "Wait for the package build and print its status and logs. With --spec, wait for the package to be created from the spec, e.g. by 'fission spec apply'"}
+	PkgFollowTimeout     = Flag{Type: Duration, Name: flagkey.PkgFollowTimeout, Usage: "Maximum time to wait for the package build with --follow-build. If set to zero, no timeout is set", DefaultValue: 10 * time.Minute}
+	PkgSecret            = Flag{Type: StringSlice, Name: flagkey.PkgSecret, Usage: "Secret available to the build of the package, must exist in the package namespace. Can be given multiple times"}
+	PkgCfgMap            = Flag{Type: StringSlice, Name: flagkey.PkgCfgMap, Usage: "ConfigMap available to the build of the package, must exist in the package namespace. Can be given multiple times"}
+	PkgReplaceIfChanged  = Flag{Type: Bool, Name: flagkey.PkgReplaceIfChanged, Usage: "Skip creating the package if an existing package of the same name has the same archives, environment, build commands and build references, and update it if they changed"}
+	PkgImage             = Flag{Type: String, Name: flagkey.PkgImage, Usage: "Reference of a prebuilt container image of the function, e.g. registry.example.com/hello:v1 or registry.example.com/hello@sha256:<digest>, used instead of archives. The package needs no build"}
+	PkgCreateForce       = Flag{Type: Bool, Name: flagkey.PkgForce, Short: "f", Usage: "Create the package even if the secrets or configmaps it references don't exist"}
+	PkgArchiveBackend    = Flag{Type: String, Name: flagkey.PkgArchiveBackend, Usage: "Backend used to upload archives too large to be stored in the package", DefaultValue: "storagesvc"}
+	PkgMaxArchiveSize    = Flag{Type: String, Name: flagkey.PkgMaxArchiveSize, Usage: "Maximum size of uploaded archives, e.g. 512Mi. Larger archives fail before the upload starts. 0 disables the limit", DefaultValue: "256Mi"}
+	PkgTimeout           = Flag{Type: Duration, Name: flagkey.PkgTimeout, Usage: "Maximum time to create the archives and the package, e.g. 5m. If set to zero, no timeout is set", DefaultValue: time.Duration(0)}
+	PkgIncludeFrom       = Flag{Type: String, Name: flagkey.PkgIncludeFrom, Usage: "File listing the paths or globs to add to the deploy archive, one per line; lines starting with '#' are comments and lines starting with '!' exclude matching paths"}
 
 	SpecSave             = Flag{Type: Bool, Name: flagkey.SpecSave, Usage: "Save to the spec directory instead of creating on cluster"}
 	SpecDir              = Flag{Type: String, Name: flagkey.SpecDir, Usage: "Directory to store specs, defaults to ./specs"}
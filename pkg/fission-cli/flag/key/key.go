@@ -148,19 +148,50 @@ const (
 	KwObjType   = "type"
 	KwLabels    = "labels"
 
-	PkgName           = resourceName
-	PkgForce          = force
-	PkgEnvironment    = "env"
-	PkgCode           = "code"
-	PkgSrcArchive     = "sourcearchive"
-	PkgDeployArchive  = "deployarchive"
-	PkgSrcChecksum    = "srcchecksum"
-	PkgDeployChecksum = "deploychecksum"
-	PkgInsecure       = "insecure"
-	PkgBuildCmd       = "buildcmd"
-	PkgOutput         = Output
-	PkgStatus         = "status"
-	PkgOrphan         = "orphan"
+	PkgName              = resourceName
+	PkgForce             = force
+	PkgEnvironment       = "env"
+	PkgCode              = "code"
+	PkgSrcArchive        = "sourcearchive"
+	PkgDeployArchive     = "deployarchive"
+	PkgSrcChecksum       = "srcchecksum"
+	PkgDeployChecksum    = "deploychecksum"
+	PkgInsecure          = "insecure"
+	PkgBuildCmd          = "buildcmd"
+	PkgOutput            = Output
+	PkgStatus            = "status"
+	PkgOrphan            = "orphan"
+	PkgEnvNamespace      = "env-namespace"
+	PkgArchiveFormat     = "archive-format"
+	PkgValidateOnly      = "validate-only"
+	PkgPreserveMode      = "preserve-mode"
+	PkgFollowSymlinks    = "follow-symlinks"
+	PkgIncludeFrom       = "include-from"
+	PkgTimeout           = "timeout"
+	PkgCompressionLvl    = "compression-level"
+	PkgFromConfig        = "from-config"
+	PkgArchiveAuthHeader = "archive-auth-header"
+	PkgArchiveBasicAuth  = "archive-basic-auth"
+	PkgSourceCommit      = "source-commit"
+	PkgSourceRepo        = "source-repo"
+	PkgSourceRef         = "source-ref"
+	PkgArchiveDryRun     = "archive-dry-run"
+	PkgArchiveOut        = "archive-out"
+	PkgArchiveBackend    = "archive-backend"
+	PkgMaxArchiveSize    = "max-archive-size"
+	PkgBuildEnv          = "build-env"
+	PkgBuildEnvFromFile  = "build-env-from-file"
+	PkgBuildCPU          = "build-cpu"
+	PkgBuildMemory       = "build-memory"
+	PkgPrintSpec         = "print-spec"
+	PkgSrcArchiveID      = "src-archive-id"
+	PkgDeployArchiveID   = "deploy-archive-id"
+	PkgFollowBuild       = "follow-build"
+	PkgFollowTimeout     = "follow-build-timeout"
+	PkgSecret            = "secret"
+	PkgCfgMap            = "configmap"
+	PkgReplaceIfChanged  = "replace-if-changed"
+	PkgImage             = "image"
 
 	SpecSave             = "spec"
 	SpecDir              = "specdir"
@@ -37,24 +37,56 @@ import (
 
 type LogDBOptions struct {
 	Client cmd.Client
+	// LogStreamer opens the pod log streams of the kubernetes log database,
+	// defaults to streaming from the kubernetes API of Client.
+	LogStreamer PodLogStreamer
+}
+
+// PodLogStreamer opens the log stream of a pod container.
+type PodLogStreamer interface {
+	StreamPodLog(ctx context.Context, namespace string, pod string, opts *v1.PodLogOptions) (io.ReadCloser, error)
+}
+
+type kubernetesLogStreamer struct {
+	client kubernetes.Interface
+}
+
+// NewKubernetesLogStreamer returns a PodLogStreamer streaming pod logs from the kubernetes API.
+func NewKubernetesLogStreamer(client kubernetes.Interface) PodLogStreamer {
+	return kubernetesLogStreamer{client: client}
+}
+
+func (s kubernetesLogStreamer) StreamPodLog(ctx context.Context, namespace string, pod string, opts *v1.PodLogOptions) (io.ReadCloser, error) {
+	return s.client.CoreV1().Pods(namespace).GetLogs(pod, opts).Stream(ctx)
 }
 
 type kubernetesLogs struct {
-	client cmd.Client
+	client   cmd.Client
+	streamer PodLogStreamer
 }
 
 func (k kubernetesLogs) GetLogs(ctx context.Context, logFilter LogFilter, podLogs *bytes.Buffer) (err error) {
-	err = GetFunctionPodLogs(ctx, k.client, logFilter, podLogs)
+	err = getFunctionPodLogs(ctx, k.client, k.streamer, logFilter, podLogs)
 	return err
 }
 
 func NewKubernetesEndpoint(logDBOptions LogDBOptions) (kubernetesLogs, error) {
+	streamer := logDBOptions.LogStreamer
+	if streamer == nil {
+		streamer = NewKubernetesLogStreamer(logDBOptions.Client.KubernetesClient)
+	}
 	return kubernetesLogs{
-		client: logDBOptions.Client}, nil
+		client:   logDBOptions.Client,
+		streamer: streamer,
+	}, nil
 }
 
 // FunctionPodLogs : Get logs for a function directly from pod
 func GetFunctionPodLogs(ctx context.Context, client cmd.Client, logFilter LogFilter, podLogs *bytes.Buffer) (err error) {
+	return getFunctionPodLogs(ctx, client, NewKubernetesLogStreamer(client.KubernetesClient), logFilter, podLogs)
+}
+
+func getFunctionPodLogs(ctx context.Context, client cmd.Client, streamer PodLogStreamer, logFilter LogFilter, podLogs *bytes.Buffer) (err error) {
 
 	f := logFilter.FunctionObject
 
@@ -85,7 +117,7 @@ func GetFunctionPodLogs(ctx context.Context, client cmd.Client, logFilter LogFil
 	if logFilter.AllPods {
 		for _, pod := range pods {
 			// get the pod with highest resource version
-			err = streamContainerLog(ctx, client.KubernetesClient, &pod, logFilter, podLogs)
+			err = streamContainerLog(ctx, streamer, &pod, logFilter, podLogs)
 			if err != nil {
 				return errors.Wrapf(err, "error getting container logs")
 			}
@@ -99,7 +131,7 @@ func GetFunctionPodLogs(ctx context.Context, client cmd.Client, logFilter LogFil
 		})
 
 		// get the pod with highest resource version
-		err = streamContainerLog(ctx, client.KubernetesClient, &pods[0], logFilter, podLogs)
+		err = streamContainerLog(ctx, streamer, &pods[0], logFilter, podLogs)
 		if err != nil {
 			return errors.Wrapf(err, "error getting container logs")
 		}
@@ -108,7 +140,7 @@ func GetFunctionPodLogs(ctx context.Context, client cmd.Client, logFilter LogFil
 	return err
 }
 
-func streamContainerLog(ctx context.Context, kubernetesClient kubernetes.Interface, pod *v1.Pod, logFilter LogFilter, output *bytes.Buffer) (err error) {
+func streamContainerLog(ctx context.Context, streamer PodLogStreamer, pod *v1.Pod, logFilter LogFilter, output *bytes.Buffer) (err error) {
 	FETCHER := "fetcher"
 	for _, container := range pod.Spec.Containers {
 		if container.Name == FETCHER {
@@ -121,9 +153,7 @@ func streamContainerLog(ctx context.Context, kubernetesClient kubernetes.Interfa
 			TailLines: &tailLines,
 		}
 
-		podLogsReq := kubernetesClient.CoreV1().Pods(pod.Namespace).GetLogs(pod.ObjectMeta.Name, &podLogOpts)
-
-		podLogs, err := podLogsReq.Stream(ctx)
+		podLogs, err := streamer.StreamPodLog(ctx, pod.Namespace, pod.ObjectMeta.Name, &podLogOpts)
 		if err != nil {
 			return errors.Wrapf(err, "error streaming pod log")
 		}
@@ -133,16 +163,16 @@ func streamContainerLog(ctx context.Context, kubernetesClient kubernetes.Interfa
 			msg := fmt.Sprintf("\n=== Function=%s Environment=%s Namespace=%s Pod=%s Container=%s Node=%s\n",
 				fn.ObjectMeta.Name, fn.Spec.Environment.Name, pod.Namespace, pod.Name, container.Name, pod.Spec.NodeName)
 			if _, err := output.WriteString(msg); err != nil {
+				podLogs.Close()
 				return errors.Wrapf(err, "error copying pod log")
 			}
 		}
 
 		_, err = io.Copy(output, podLogs)
+		podLogs.Close()
 		if err != nil {
 			return errors.Wrapf(err, "error copying pod log")
 		}
-
-		podLogs.Close()
 	}
 
 	return nil
@@ -0,0 +1,210 @@
+/*
+Copyright 2024 The Fission Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logdb
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+
+	fv1 "github.com/fission/fission/pkg/apis/core/v1"
+	"github.com/fission/fission/pkg/fission-cli/cmd"
+)
+
+type (
+	fakeLogStreamer struct {
+		// logs by pod/container, errs take precedence
+		logs map[string]string
+		errs map[string]error
+		// calls records the streamed pod/container and the options of each call
+		calls []fakeLogCall
+	}
+
+	fakeLogCall struct {
+		key  string
+		opts v1.PodLogOptions
+	}
+
+	// readerStreamer streams the reader it returns for any container
+	readerStreamer func() io.ReadCloser
+
+	trackedReader struct {
+		io.Reader
+		closed *int
+	}
+
+	errReader struct{}
+)
+
+func (s *fakeLogStreamer) StreamPodLog(ctx context.Context, namespace string, pod string, opts *v1.PodLogOptions) (io.ReadCloser, error) {
+	key := pod + "/" + opts.Container
+	s.calls = append(s.calls, fakeLogCall{key: key, opts: *opts})
+	if err, ok := s.errs[key]; ok {
+		return nil, err
+	}
+	return io.NopCloser(strings.NewReader(s.logs[key])), nil
+}
+
+func (f readerStreamer) StreamPodLog(ctx context.Context, namespace string, pod string, opts *v1.PodLogOptions) (io.ReadCloser, error) {
+	return f(), nil
+}
+
+func (r trackedReader) Close() error {
+	*r.closed++
+	return nil
+}
+
+func (errReader) Read([]byte) (int, error) {
+	return 0, errors.New("connection reset by peer")
+}
+
+func newTestFunction() *fv1.Function {
+	return &fv1.Function{
+		ObjectMeta: metav1.ObjectMeta{Name: "hello", Namespace: "test-ns", UID: types.UID("fn-uid")},
+		Spec: fv1.FunctionSpec{
+			Environment: fv1.EnvironmentReference{Name: "nodejs", Namespace: "test-ns"},
+		},
+	}
+}
+
+func newTestPod(fn *fv1.Function, name string, resourceVersion string) *v1.Pod {
+	return &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            name,
+			Namespace:       fn.Namespace,
+			ResourceVersion: resourceVersion,
+			Labels: map[string]string{
+				fv1.FUNCTION_UID:          string(fn.UID),
+				fv1.ENVIRONMENT_NAME:      fn.Spec.Environment.Name,
+				fv1.ENVIRONMENT_NAMESPACE: fn.Spec.Environment.Namespace,
+			},
+		},
+		Spec: v1.PodSpec{
+			Containers: []v1.Container{{Name: "nodejs"}, {Name: "fetcher"}},
+		},
+	}
+}
+
+func newTestLogDB(t *testing.T, streamer PodLogStreamer, pods ...*v1.Pod) LogDatabase {
+	kubeClient := fake.NewSimpleClientset()
+	for _, pod := range pods {
+		require.NoError(t, kubeClient.Tracker().Add(pod))
+	}
+	logDB, err := GetLogDB(KUBERNETES, context.Background(), LogDBOptions{
+		Client:      cmd.Client{KubernetesClient: kubeClient},
+		LogStreamer: streamer,
+	})
+	require.NoError(t, err)
+	return logDB
+}
+
+func TestKubernetesLogs(t *testing.T) {
+	fn := newTestFunction()
+
+	t.Run("latest pod", func(t *testing.T) {
+		streamer := &fakeLogStreamer{logs: map[string]string{
+			"pod-old/nodejs": "old line\n",
+			"pod-new/nodejs": "first line\nsecond line\n",
+		}}
+		logDB := newTestLogDB(t, streamer, newTestPod(fn, "pod-old", "1"), newTestPod(fn, "pod-new", "2"))
+
+		since := time.Now().Add(-time.Minute).Truncate(time.Second)
+		buf := new(bytes.Buffer)
+		err := logDB.GetLogs(context.Background(), LogFilter{FunctionObject: fn, Since: since, RecordLimit: 20}, buf)
+		require.NoError(t, err)
+		require.Equal(t, "first line\nsecond line\n", buf.String())
+
+		// the fetcher container is skipped
+		require.Len(t, streamer.calls, 1)
+		require.Equal(t, "pod-new/nodejs", streamer.calls[0].key)
+		require.Equal(t, int64(20), *streamer.calls[0].opts.TailLines)
+		require.True(t, since.Equal(streamer.calls[0].opts.SinceTime.Time))
+	})
+
+	t.Run("all pods with details", func(t *testing.T) {
+		streamer := &fakeLogStreamer{logs: map[string]string{
+			"pod-a/nodejs": "a\n",
+			"pod-b/nodejs": "b\n",
+		}}
+		logDB := newTestLogDB(t, streamer, newTestPod(fn, "pod-a", "1"), newTestPod(fn, "pod-b", "2"))
+
+		buf := new(bytes.Buffer)
+		err := logDB.GetLogs(context.Background(), LogFilter{FunctionObject: fn, AllPods: true, Details: true}, buf)
+		require.NoError(t, err)
+		require.Contains(t, buf.String(), "Function=hello Environment=nodejs Namespace=test-ns Pod=pod-a Container=nodejs")
+		require.Contains(t, buf.String(), "Pod=pod-b Container=nodejs")
+		require.Len(t, streamer.calls, 2)
+	})
+
+	t.Run("reconnect", func(t *testing.T) {
+		// following logs reopens the stream from the time of the last query
+		streamer := &fakeLogStreamer{logs: map[string]string{"pod/nodejs": "line\n"}}
+		logDB := newTestLogDB(t, streamer, newTestPod(fn, "pod", "1"))
+
+		var since time.Time
+		for i := 0; i < 2; i++ {
+			buf := new(bytes.Buffer)
+			err := logDB.GetLogs(context.Background(), LogFilter{FunctionObject: fn, Since: since}, buf)
+			require.NoError(t, err)
+			require.Equal(t, "line\n", buf.String())
+			since = time.Now().Truncate(time.Second)
+		}
+		require.Len(t, streamer.calls, 2)
+		require.True(t, since.Equal(streamer.calls[1].opts.SinceTime.Time))
+	})
+
+	t.Run("no pods", func(t *testing.T) {
+		streamer := &fakeLogStreamer{}
+		logDB := newTestLogDB(t, streamer)
+
+		err := logDB.GetLogs(context.Background(), LogFilter{FunctionObject: fn}, new(bytes.Buffer))
+		require.ErrorContains(t, err, "no active pods found for function in namespace test-ns")
+		require.Empty(t, streamer.calls)
+	})
+
+	t.Run("stream error", func(t *testing.T) {
+		streamer := &fakeLogStreamer{errs: map[string]error{"pod/nodejs": errors.New("container is waiting to start")}}
+		logDB := newTestLogDB(t, streamer, newTestPod(fn, "pod", "1"))
+
+		err := logDB.GetLogs(context.Background(), LogFilter{FunctionObject: fn}, new(bytes.Buffer))
+		require.ErrorContains(t, err, "error streaming pod log: container is waiting to start")
+	})
+
+	t.Run("read error", func(t *testing.T) {
+		closed := 0
+		streamer := readerStreamer(func() io.ReadCloser {
+			return trackedReader{Reader: io.MultiReader(strings.NewReader("partial\n"), errReader{}), closed: &closed}
+		})
+		logDB := newTestLogDB(t, streamer, newTestPod(fn, "pod", "1"))
+
+		buf := new(bytes.Buffer)
+		err := logDB.GetLogs(context.Background(), LogFilter{FunctionObject: fn}, buf)
+		require.ErrorContains(t, err, "error copying pod log")
+		require.Equal(t, "partial\n", buf.String())
+		require.Equal(t, 1, closed, "the log stream must be closed on errors")
+	})
+}
@@ -51,12 +51,14 @@ import (
 )
 
 const (
-	ENV_FISSION_NAMESPACE  string = "FISSION_NAMESPACE"
-	ENV_FISSION_URL        string = "FISSION_URL"
-	ENV_FISSION_AUTH_TOKEN string = "FISSION_AUTH_TOKEN"
-	localhostURL           string = "http://127.0.0.1:"
-	authHeader             string = "Authorization"
-	tokenType              string = "Bearer"
+	ENV_FISSION_NAMESPACE           string = "FISSION_NAMESPACE"
+	ENV_FISSION_URL                 string = "FISSION_URL"
+	ENV_FISSION_AUTH_TOKEN          string = "FISSION_AUTH_TOKEN"
+	ENV_FISSION_ARCHIVE_AUTH_HEADER string = "FISSION_ARCHIVE_AUTH_HEADER"
+	ENV_FISSION_ARCHIVE_BASIC_AUTH  string = "FISSION_ARCHIVE_BASIC_AUTH"
+	localhostURL                    string = "http://127.0.0.1:"
+	authHeader                      string = "Authorization"
+	tokenType                       string = "Bearer"
 )
 
 func GetFissionNamespace() string {
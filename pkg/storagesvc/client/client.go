@@ -24,6 +24,7 @@ import (
 	"io"
 	"mime/multipart"
 	"net/http"
+	"net/textproto"
 	"net/url"
 	"os"
 	"strings"
@@ -33,11 +34,16 @@ import (
 	"golang.org/x/net/context/ctxhttp"
 
 	"github.com/fission/fission/pkg/storagesvc"
+	"github.com/fission/fission/pkg/utils"
 )
 
+// quoteEscaper escapes the file name of the upload like mime/multipart does.
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
 type (
 	ClientInterface interface {
 		Upload(ctx context.Context, filePath string, metadata *map[string]string) (string, error)
+		UploadArchive(ctx context.Context, filePath string, metadata *map[string]string) (*storagesvc.UploadResponse, error)
 		GetUrl(id string) string
 		List(ctx context.Context) ([]string, error)
 		Download(ctx context.Context, id string, filePath string) error
@@ -63,27 +69,44 @@ func MakeClient(url string) ClientInterface {
 // service, along with the metadata.  It returns a file ID that can be
 // used to retrieve the file.
 func (c *client) Upload(ctx context.Context, filePath string, metadata *map[string]string) (string, error) {
-	fi, err := os.Stat(filePath)
+	ur, err := c.UploadArchive(ctx, filePath, metadata)
 	if err != nil {
 		return "", err
 	}
+	return ur.ID, nil
+}
+
+// UploadArchive uploads the file like Upload, and returns the response of the
+// storage service with the file ID and, if reported by the storage service, the
+// size and SHA256 checksum of the stored file.
+func (c *client) UploadArchive(ctx context.Context, filePath string, metadata *map[string]string) (*storagesvc.UploadResponse, error) {
+	fi, err := os.Stat(filePath)
+	if err != nil {
+		return nil, err
+	}
 	fileSize := fi.Size()
 
 	buf := &bytes.Buffer{}
 	bodyWriter := multipart.NewWriter(buf)
-	fileWriter, err := bodyWriter.CreateFormFile("uploadfile", filePath)
+	// like CreateFormFile, but with the media type of the archive, which the
+	// storage service keeps with the stored file
+	partHeader := make(textproto.MIMEHeader)
+	partHeader.Set("Content-Disposition",
+		fmt.Sprintf(`form-data; name="uploadfile"; filename="%s"`, quoteEscaper.Replace(filePath)))
+	partHeader.Set("Content-Type", utils.ArchiveContentType(filePath))
+	fileWriter, err := bodyWriter.CreatePart(partHeader)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	f, err := os.Open(filePath)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	_, err = io.Copy(fileWriter, f)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	contentType := bodyWriter.FormDataContentType()
@@ -91,32 +114,32 @@ func (c *client) Upload(ctx context.Context, filePath string, metadata *map[stri
 
 	req, err := http.NewRequest(http.MethodPost, c.url+"/archive", buf)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	req.Header["X-File-Size"] = []string{fmt.Sprintf("%v", fileSize)}
 	req.Header["Content-Type"] = []string{contentType}
 
 	resp, err := ctxhttp.Do(ctx, c.httpClient, req)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	defer resp.Body.Close()
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	if resp.StatusCode != http.StatusOK {
 		msg := fmt.Sprintf("Upload error %v", resp.Status)
-		return "", errors.New(msg)
+		return nil, errors.New(msg)
 	}
 
 	var ur storagesvc.UploadResponse
 	err = json.Unmarshal(body, &ur)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	return ur.ID, nil
+	return &ur, nil
 }
 
 // GetUrl returns an HTTP URL that can be used to download the file pointed to by ID
@@ -23,6 +23,7 @@ import (
 	"io"
 	"log"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
@@ -35,6 +36,7 @@ import (
 
 	"github.com/fission/fission/pkg/crd"
 	"github.com/fission/fission/pkg/storagesvc"
+	"github.com/fission/fission/pkg/utils"
 	"github.com/fission/fission/pkg/utils/manager"
 )
 
@@ -276,6 +278,27 @@ func TestLocalStorageService(t *testing.T) {
 		log.Panic("Download succeeded but file isn't supposed to exist")
 	}
 
+	// archives are stored with the extension and content type of their format
+	for _, format := range []utils.ArchiveFormat{utils.ArchiveFormatZip, utils.ArchiveFormatTarGz} {
+		archivePath, err := utils.MakeArchive(fmt.Sprintf("%v%v", tmpfile.Name(), format.Extension()),
+			utils.ArchiveOptions{Format: format}, tmpfile.Name())
+		failTest(t, err)
+		defer os.Remove(archivePath)
+
+		archiveID, err := client.Upload(ctx, archivePath, &metadata)
+		failTest(t, err)
+		if !strings.HasSuffix(archiveID, format.Extension()) {
+			t.Fatalf("Expected archive ID with extension %v, got %v", format.Extension(), archiveID)
+		}
+		resp, err := client.GetFile(ctx, archiveID)
+		failTest(t, err)
+		resp.Body.Close()
+		if contentType := resp.Header.Get("Content-Type"); contentType != utils.ArchiveContentType(archivePath) {
+			t.Fatalf("Expected content type %v, got %v", utils.ArchiveContentType(archivePath), contentType)
+		}
+		failTest(t, client.Delete(ctx, archiveID))
+	}
+
 	// // cleanup /tmp
 	os.RemoveAll(fmt.Sprintf("/tmp/%v", testID))
 }
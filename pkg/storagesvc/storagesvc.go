@@ -18,8 +18,11 @@ package storagesvc
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"os"
 	"strconv"
@@ -31,6 +34,7 @@ import (
 	"go.uber.org/zap"
 
 	"github.com/fission/fission/pkg/crd"
+	"github.com/fission/fission/pkg/utils"
 	"github.com/fission/fission/pkg/utils/httpserver"
 	"github.com/fission/fission/pkg/utils/manager"
 	"github.com/fission/fission/pkg/utils/metrics"
@@ -56,6 +60,10 @@ type (
 
 	UploadResponse struct {
 		ID string `json:"id"`
+		// Size is the size in bytes of the stored archive
+		Size int64 `json:"size,omitempty"`
+		// Checksum is the hex encoded SHA256 checksum of the stored archive
+		Checksum string `json:"checksum,omitempty"`
 	}
 )
 
@@ -135,10 +143,14 @@ func (ss *StorageService) uploadHandler(w http.ResponseWriter, r *http.Request)
 	}
 
 	// TODO: allow headers to add more metadata (e.g. environment and function metadata)
+	contentType := handler.Header.Get("Content-Type")
 	logger.Debug("handling upload",
-		zap.String("filename", handler.Filename))
+		zap.String("filename", handler.Filename),
+		zap.String("content_type", contentType))
 
-	id, err := ss.storageClient.putFile(file, int64(fileSize))
+	// hash the contents as they are stored, so that clients can verify them
+	h := sha256.New()
+	id, size, err := ss.storageClient.putFile(io.TeeReader(file, h), int64(fileSize), utils.ArchiveExtension(contentType))
 	if err != nil {
 		logger.Error("error saving uploaded file",
 			zap.Error(err),
@@ -151,7 +163,9 @@ func (ss *StorageService) uploadHandler(w http.ResponseWriter, r *http.Request)
 
 	// respond with an ID that can be used to retrieve the file
 	ur := &UploadResponse{
-		ID: id,
+		ID:       id,
+		Size:     size,
+		Checksum: hex.EncodeToString(h.Sum(nil)),
 	}
 	resp, err := json.Marshal(ur)
 	if err != nil {
@@ -219,6 +233,7 @@ func (ss *StorageService) downloadHandler(w http.ResponseWriter, r *http.Request
 
 	// Get the file (called "item" in stow's jargon), open it,
 	// stream it to response
+	w.Header().Set("Content-Type", utils.ArchiveContentTypeByName(fileId))
 	err = ss.storageClient.copyFileToStream(fileId, w)
 	if err != nil {
 		logger.Error("error getting file from storage client", zap.Error(err), zap.String("file_id", fileId))
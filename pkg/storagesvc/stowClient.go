@@ -19,7 +19,6 @@ package storagesvc
 import (
 	"fmt"
 	"io"
-	"mime/multipart"
 	"os"
 	"strings"
 	"time"
@@ -131,12 +130,15 @@ func MakeStowClient(logger *zap.Logger, storage Storage) (*StowClient, error) {
 	return stowClient, nil
 }
 
-// putFile writes the file on the storage
-func (client *StowClient) putFile(file multipart.File, fileSize int64) (string, error) {
+// putFile writes the file on the storage and returns its id and stored size
+func (client *StowClient) putFile(file io.Reader, fileSize int64, extension string) (string, int64, error) {
 	uploadName, err := client.config.storage.getUploadFileName()
 	if err != nil {
-		return "", err
+		return "", 0, err
 	}
+	// not every storage backend stores metadata, so the file extension
+	// records the archive format of the file
+	uploadName += extension
 
 	// save the file to the storage backend
 	item, err := client.container.Put(uploadName, file, fileSize, nil)
@@ -144,11 +146,18 @@ func (client *StowClient) putFile(file multipart.File, fileSize int64) (string,
 		client.logger.Error("error writing file on storage",
 			zap.Error(err),
 			zap.String("file", uploadName))
-		return "", ErrWritingFile
+		return "", 0, ErrWritingFile
+	}
+	size, err := item.Size()
+	if err != nil {
+		client.logger.Error("error getting size of file on storage",
+			zap.Error(err),
+			zap.String("file", uploadName))
+		return "", 0, ErrWritingFile
 	}
 
 	client.logger.Debug("successfully wrote file on storage", zap.String("file", uploadName))
-	return item.ID(), nil
+	return item.ID(), size, nil
 }
 
 // copyFileToStream gets the file contents into a stream
@@ -0,0 +1,388 @@
+/*
+Copyright 2024 The Fission Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mholt/archiver/v3"
+	"github.com/pkg/errors"
+	ignore "github.com/sabhiram/go-gitignore"
+
+	fv1 "github.com/fission/fission/pkg/apis/core/v1"
+)
+
+type ArchiveFormat string
+
+const (
+	ArchiveFormatZip   ArchiveFormat = "zip"
+	ArchiveFormatTarGz ArchiveFormat = "targz"
+)
+
+type (
+	// ArchiveOptions controls how MakeArchive creates an archive.
+	ArchiveOptions struct {
+		// Format of the archive, defaults to zip.
+		Format ArchiveFormat
+
+		// PreserveMode keeps the permission bits of the archived files, e.g. the
+		// executable bit of build scripts. Otherwise regular files are archived
+		// with mode 0644 and directories with mode 0755.
+		PreserveMode bool
+
+		// CompressionLevel from 0 (no compression) to 9 (best compression).
+		// If nil, the default compression level of the format is used.
+		CompressionLevel *int
+
+		// IgnoreFile is the name of a file with gitignore style patterns, which is
+		// looked up in each archived directory. Paths of the directory matching its
+		// patterns are not archived.
+		IgnoreFile string
+
+		// FollowSymlinks archives symlinks as the files or directories they point
+		// to. Otherwise they are archived as links. Either way, symlinks must point
+		// into the archived directory.
+		FollowSymlinks bool
+	}
+
+	// modeFileInfo overrides the mode of an archived file.
+	modeFileInfo struct {
+		archiver.FileInfo
+		mode os.FileMode
+	}
+
+	// checksumWriter is an archiver.Writer hashing the name, mode and content of
+	// the archived files instead of writing an archive.
+	checksumWriter struct {
+		h hash.Hash
+	}
+)
+
+func (fi modeFileInfo) Mode() os.FileMode {
+	return fi.mode
+}
+
+// Extension returns the file extension of archives of the given format.
+func (f ArchiveFormat) Extension() string {
+	if f == ArchiveFormatTarGz {
+		return ".tar.gz"
+	}
+	return ".zip"
+}
+
+func (opts ArchiveOptions) writer() archiver.Writer {
+	if opts.Format == ArchiveFormatTarGz {
+		tgz := archiver.NewTarGz()
+		if opts.CompressionLevel != nil {
+			tgz.CompressionLevel = *opts.CompressionLevel
+		}
+		return tgz
+	}
+
+	z := archiver.NewZip()
+	if opts.CompressionLevel != nil {
+		z.CompressionLevel = *opts.CompressionLevel
+		if z.CompressionLevel == 0 {
+			z.FileMethod = archiver.Store
+		}
+	}
+	return z
+}
+
+// MakeArchive creates an archive of the files matching globs. Directories are
+// archived recursively under their base name.
+func MakeArchive(targetName string, opts ArchiveOptions, globs ...string) (string, error) {
+	files, err := FindAllGlobs(globs...)
+	if err != nil {
+		return "", err
+	}
+
+	out, err := os.Create(targetName)
+	if err != nil {
+		return "", errors.Wrapf(err, "error creating archive %v", targetName)
+	}
+	defer out.Close()
+
+	w := opts.writer()
+	err = w.Create(out)
+	if err != nil {
+		return "", errors.Wrap(err, "error creating archive writer")
+	}
+
+	for _, source := range files {
+		err = writeArchiveSource(w, opts, source)
+		if err != nil {
+			w.Close()
+			return "", errors.Wrapf(err, "error archiving %v", source)
+		}
+	}
+
+	err = w.Close()
+	if err != nil {
+		return "", errors.Wrap(err, "error closing archive writer")
+	}
+
+	return filepath.Abs(targetName)
+}
+
+// ArchiveContentChecksum returns the SHA256 checksum of the contents of the archive
+// MakeArchive would create from globs with opts: the names, modes, link targets and
+// contents of the archived files. Unlike the checksum of the archive itself, it does
+// not change with the modification times of the files, so that archives of the same
+// files can be compared, e.g. across checkouts of a repository.
+func ArchiveContentChecksum(opts ArchiveOptions, globs ...string) (*fv1.Checksum, error) {
+	files, err := FindAllGlobs(globs...)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &checksumWriter{h: sha256.New()}
+	for _, source := range files {
+		err = writeArchiveSource(w, opts, source)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error hashing %v", source)
+		}
+	}
+
+	return &fv1.Checksum{
+		Type: fv1.ChecksumTypeSHA256,
+		Sum:  hex.EncodeToString(w.h.Sum(nil)),
+	}, nil
+}
+
+func (w *checksumWriter) Create(out io.Writer) error {
+	return nil
+}
+
+func (w *checksumWriter) Write(f archiver.File) error {
+	var target string
+	if f.Mode()&os.ModeSymlink != 0 {
+		if fi, ok := f.FileInfo.(archiver.FileInfo); ok {
+			var err error
+			target, err = os.Readlink(fi.SourcePath)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	var size int64
+	if f.Mode().IsRegular() {
+		size = f.Size()
+	}
+	// the size delimits the content from the header of the next file
+	fmt.Fprintf(w.h, "%s\x00%o\x00%s\x00%d\x00", f.Name(), f.Mode(), target, size)
+	if f.ReadCloser == nil {
+		return nil
+	}
+	_, err := io.CopyN(w.h, f.ReadCloser, size)
+	return err
+}
+
+func (w *checksumWriter) Close() error {
+	return nil
+}
+
+func writeArchiveSource(w archiver.Writer, opts ArchiveOptions, source string) error {
+	ignoreParser, err := sourceIgnoreParser(opts, source)
+	if err != nil {
+		return err
+	}
+
+	info, err := os.Stat(source)
+	if err != nil {
+		return err
+	}
+	// symlinks must not point outside of the archived directory, or the
+	// directory of an archived file
+	root := source
+	if !info.IsDir() {
+		root = filepath.Dir(source)
+	}
+	root, err = filepath.EvalSymlinks(root)
+	if err != nil {
+		return err
+	}
+
+	name, err := filepath.Rel(filepath.Dir(source), source)
+	if err != nil {
+		return err
+	}
+	sw := &sourceWriter{
+		w:            w,
+		opts:         opts,
+		ignoreParser: ignoreParser,
+		root:         root,
+		sourceName:   name,
+	}
+	return sw.walk(source, name, []string{root})
+}
+
+// sourceWriter writes the files of an archive source.
+type sourceWriter struct {
+	w            archiver.Writer
+	opts         ArchiveOptions
+	ignoreParser *ignore.GitIgnore
+
+	// root is the resolved directory symlinks must point into
+	root string
+
+	// sourceName is the archived name of the source
+	sourceName string
+}
+
+// walk writes the files under dir with their archived names under name. Followed
+// holds the resolved directories being archived, to detect symlink cycles.
+func (sw *sourceWriter) walk(dir, name string, followed []string) error {
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		fileName := filepath.Join(name, rel)
+
+		if sw.ignoreParser != nil && fileName != sw.sourceName {
+			rel, err := filepath.Rel(sw.sourceName, fileName)
+			if err != nil {
+				return err
+			}
+			if sw.ignoreParser.MatchesPath(rel) {
+				if info.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+		}
+
+		if info.Mode()&os.ModeSymlink != 0 && path != dir {
+			return sw.writeSymlink(path, fileName, followed)
+		}
+		return sw.writeFile(path, fileName, info)
+	})
+}
+
+// writeSymlink archives the symlink at path as a link, or as its target with
+// opts.FollowSymlinks. Symlinks pointing outside of the root are rejected, so
+// that extracting the archive can't reach files outside of its directory.
+func (sw *sourceWriter) writeSymlink(path, name string, followed []string) error {
+	target, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return errors.Wrapf(err, "error resolving symlink %v", path)
+	}
+	rel, err := filepath.Rel(sw.root, target)
+	if err != nil {
+		return err
+	}
+	if rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return errors.Errorf("symlink %v points to %v, outside of %v", path, target, sw.root)
+	}
+
+	if !sw.opts.FollowSymlinks {
+		link, err := os.Readlink(path)
+		if err != nil {
+			return err
+		}
+		if filepath.IsAbs(link) {
+			return errors.Errorf("symlink %v has the absolute target %v, which can't be archived as a link", path, link)
+		}
+		info, err := os.Lstat(path)
+		if err != nil {
+			return err
+		}
+		return sw.writeFile(path, name, info)
+	}
+
+	info, err := os.Stat(target)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return sw.writeFile(target, name, info)
+	}
+
+	parent, err := filepath.EvalSymlinks(filepath.Dir(path))
+	if err != nil {
+		return err
+	}
+	for _, dir := range append(followed, parent) {
+		if dir == target || strings.HasPrefix(dir, target+string(filepath.Separator)) {
+			return errors.Errorf("symlink %v points to %v, which contains it", path, target)
+		}
+	}
+	return sw.walk(target, name, append(followed, target))
+}
+
+func (sw *sourceWriter) writeFile(path, name string, info os.FileInfo) error {
+	fi := archiver.FileInfo{
+		FileInfo:   info,
+		CustomName: filepath.ToSlash(name),
+		SourcePath: path,
+	}
+	file := archiver.File{FileInfo: fi}
+
+	if !info.Mode().IsRegular() {
+		// directories have no content, symlinks are stored as links
+		if info.IsDir() && !sw.opts.PreserveMode {
+			file.FileInfo = modeFileInfo{FileInfo: fi, mode: os.ModeDir | 0755}
+		}
+		return sw.w.Write(file)
+	}
+
+	if !sw.opts.PreserveMode {
+		file.FileInfo = modeFileInfo{FileInfo: fi, mode: 0644}
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	file.ReadCloser = f
+
+	return sw.w.Write(file)
+}
+
+// sourceIgnoreParser returns the patterns of the opts.IgnoreFile of the source
+// directory, or nil if source is no directory or has no such file.
+func sourceIgnoreParser(opts ArchiveOptions, source string) (*ignore.GitIgnore, error) {
+	if len(opts.IgnoreFile) == 0 {
+		return nil, nil
+	}
+	info, err := os.Stat(source)
+	if err != nil || !info.IsDir() {
+		return nil, nil
+	}
+	ignoreFile := filepath.Join(source, opts.IgnoreFile)
+	if _, err := os.Stat(ignoreFile); os.IsNotExist(err) {
+		return nil, nil
+	}
+	ignoreParser, err := ignore.CompileIgnoreFile(ignoreFile)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error reading %v", ignoreFile)
+	}
+	return ignoreParser, nil
+}
@@ -17,6 +17,8 @@ limitations under the License.
 package utils
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
 	"crypto/sha256"
 	"encoding/hex"
@@ -212,6 +214,83 @@ func IsZip(filename string) (bool, error) {
 	return archiver.DefaultZip.Match(f)
 }
 
+// IsTarGz checks if the file is a gzip compressed tarball.
+func IsTarGz(filename string) (bool, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return false, nil
+	}
+	defer f.Close()
+	// archiver.TarGz matches like an uncompressed tarball, so look for the tar
+	// header in the decompressed stream.
+	gzr, err := gzip.NewReader(f)
+	if err != nil {
+		return false, nil
+	}
+	defer gzr.Close()
+	buf := make([]byte, 512)
+	if _, err := io.ReadFull(gzr, buf); err != nil {
+		return false, nil
+	}
+	return archiver.DefaultTar.Match(bytes.NewReader(buf))
+}
+
+// IsTar checks if the file is an uncompressed tarball.
+func IsTar(filename string) (bool, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return false, nil
+	}
+	defer f.Close()
+	return archiver.DefaultTar.Match(f)
+}
+
+// archiveTypes are the media types and file extensions of the supported archive
+// formats.
+var archiveTypes = []struct {
+	contentType string
+	extension   string
+	match       func(string) (bool, error)
+}{
+	{"application/zip", ".zip", IsZip},
+	{"application/gzip", ".tar.gz", IsTarGz},
+	{"application/x-tar", ".tar", IsTar},
+}
+
+// ArchiveContentType returns the media type of the zip, tar.gz or tar archive at
+// filename, or application/octet-stream if it is none of them.
+func ArchiveContentType(filename string) string {
+	for _, t := range archiveTypes {
+		if match, _ := t.match(filename); match {
+			return t.contentType
+		}
+	}
+	return "application/octet-stream"
+}
+
+// ArchiveExtension returns the file extension of archives of the given media
+// type, or an empty string if it is not the type of a supported archive format.
+func ArchiveExtension(contentType string) string {
+	for _, t := range archiveTypes {
+		if t.contentType == contentType {
+			return t.extension
+		}
+	}
+	return ""
+}
+
+// ArchiveContentTypeByName returns the media type of the archive named name by
+// its file extension, or application/octet-stream if the extension is not one of
+// a supported archive format.
+func ArchiveContentTypeByName(name string) string {
+	for _, t := range archiveTypes {
+		if strings.HasSuffix(name, t.extension) {
+			return t.contentType
+		}
+	}
+	return "application/octet-stream"
+}
+
 func GetStringValueFromEnv(envVar string) (string, error) {
 	v := os.Getenv(envVar)
 	if v == "" {
@@ -20,9 +20,12 @@ import (
 	"bytes"
 	"io"
 	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
 
+	"github.com/mholt/archiver/v3"
+
 	fv1 "github.com/fission/fission/pkg/apis/core/v1"
 )
 
@@ -207,3 +210,46 @@ func TestGetIntValueFromEnv(t *testing.T) {
 		})
 	}
 }
+
+func TestArchiveContentType(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "hello.txt")
+	if err := os.WriteFile(src, []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tarPath := filepath.Join(dir, "hello.tar")
+	if err := archiver.NewTar().Archive([]string{src}, tarPath); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		path      string
+		format    ArchiveFormat
+		want      string
+		extension string
+	}{
+		{"zip", filepath.Join(dir, "hello.zip"), ArchiveFormatZip, "application/zip", ".zip"},
+		{"tar.gz", filepath.Join(dir, "hello.tar.gz"), ArchiveFormatTarGz, "application/gzip", ".tar.gz"},
+		{"tar", tarPath, "", "application/x-tar", ".tar"},
+		{"plain file", src, "", "application/octet-stream", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.format != "" {
+				if _, err := MakeArchive(tt.path, ArchiveOptions{Format: tt.format}, src); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got := ArchiveContentType(tt.path); got != tt.want {
+				t.Errorf("ArchiveContentType() = %v, want %v", got, tt.want)
+			}
+			if got := ArchiveExtension(tt.want); got != tt.extension {
+				t.Errorf("ArchiveExtension() = %v, want %v", got, tt.extension)
+			}
+			if got := ArchiveContentTypeByName("archive-id" + tt.extension); got != tt.want {
+				t.Errorf("ArchiveContentTypeByName() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}